@@ -0,0 +1,127 @@
+package kvm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/audio"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+type AudioConfig struct {
+	Enabled bool   `json:"enabled"`
+	Device  string `json:"device"`
+}
+
+type AudioState struct {
+	Enabled bool   `json:"enabled"`
+	Device  string `json:"device"`
+	Running bool   `json:"running"`
+	Error   string `json:"error,omitempty"`
+}
+
+var audioCapture *audio.Capture
+
+func initAudio() {
+	ensureConfigLoaded()
+
+	audioCapture = audio.NewCapture(&audio.CaptureOptions{
+		Device: config.AudioConfig.Device,
+		Logger: audioLogger,
+		OnFrame: func(frame []byte, duration time.Duration) {
+			session := currentSession
+			if session == nil || session.AudioTrack == nil {
+				return
+			}
+			if err := session.AudioTrack.WriteSample(media.Sample{Data: frame, Duration: duration}); err != nil {
+				audioLogger.Warn().Err(err).Msg("error writing audio sample")
+			}
+		},
+	})
+}
+
+// startAudioCapture starts the capture pipeline if audio is enabled and a session is active.
+func startAudioCapture() {
+	if audioCapture == nil || !config.AudioConfig.Enabled || actionSessions == 0 {
+		return
+	}
+
+	if err := audioCapture.Start(); err != nil {
+		audioLogger.Warn().Err(err).Msg("failed to start audio capture")
+	}
+	triggerAudioStateUpdate()
+}
+
+func stopAudioCapture() {
+	if audioCapture == nil {
+		return
+	}
+
+	audioCapture.Stop()
+	triggerAudioStateUpdate()
+}
+
+func getAudioState() AudioState {
+	state := AudioState{
+		Enabled: config.AudioConfig.Enabled,
+		Device:  config.AudioConfig.Device,
+	}
+
+	if audioCapture != nil {
+		state.Running = audioCapture.IsRunning()
+		if err := audioCapture.LastError(); err != nil {
+			state.Error = err.Error()
+		}
+	}
+
+	return state
+}
+
+func triggerAudioStateUpdate() {
+	go func() {
+		writeJSONRPCEvent("audioState", getAudioState(), currentSession)
+	}()
+}
+
+func rpcGetAudioState() (AudioState, error) {
+	return getAudioState(), nil
+}
+
+func rpcSetAudioEnabled(enabled bool) error {
+	config.AudioConfig.Enabled = enabled
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if enabled {
+		startAudioCapture()
+	} else {
+		stopAudioCapture()
+	}
+
+	return nil
+}
+
+func rpcGetAudioDevices() ([]audio.Device, error) {
+	return audio.ListCaptureDevices()
+}
+
+func rpcSetAudioDevice(device string) error {
+	if device == "" {
+		device = audio.DefaultDevice
+	}
+
+	config.AudioConfig.Device = device
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	if audioCapture != nil {
+		if err := audioCapture.SetDevice(device); err != nil {
+			return fmt.Errorf("failed to switch audio device: %w", err)
+		}
+	}
+
+	triggerAudioStateUpdate()
+	return nil
+}
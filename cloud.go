@@ -16,7 +16,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
-	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/jetkvm/kvm/internal/oidcauth"
+	"github.com/jetkvm/kvm/internal/users"
 
 	"github.com/coder/websocket"
 	"github.com/gin-gonic/gin"
@@ -252,24 +253,13 @@ func handleCloudRegister(c *gin.Context) {
 
 	config.CloudToken = tokenResp.SecretToken
 
-	provider, err := oidc.NewProvider(c, "https://accounts.google.com")
-	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to initialize OIDC provider: " + err.Error()})
-		return
-	}
-
-	oidcConfig := &oidc.Config{
-		ClientID: req.ClientId,
-	}
-
-	verifier := provider.Verifier(oidcConfig)
-	idToken, err := verifier.Verify(c, req.OidcGoogle)
+	identity, err := verifyCloudIdentity(c, req.OidcGoogle, req.ClientId)
 	if err != nil {
 		c.JSON(400, gin.H{"error": "Invalid OIDC token: " + err.Error()})
 		return
 	}
 
-	config.GoogleIdentity = idToken.Audience[0] + ":" + idToken.Subject
+	config.GoogleIdentity = identity
 
 	// Save the updated configuration
 	if err := SaveConfig(); err != nil {
@@ -386,33 +376,28 @@ func runWebsocketClient() error {
 	wsResetMetrics(true, "cloud", wsURL.Host)
 
 	// we don't have a source for the cloud connection
-	return handleWebRTCSignalWsMessages(c, true, wsURL.Host, connectionId, scopedLogger)
+	return handleWebRTCSignalWsMessages(c, true, wsURL.Host, connectionId, nil, scopedLogger)
 }
 
 func authenticateSession(ctx context.Context, c *websocket.Conn, req WebRTCSessionRequest) error {
 	oidcCtx, cancelOIDC := context.WithTimeout(ctx, CloudOidcRequestTimeout)
 	defer cancelOIDC()
-	provider, err := oidc.NewProvider(oidcCtx, "https://accounts.google.com")
-	if err != nil {
-		_ = wsjson.Write(context.Background(), c, gin.H{
-			"error": fmt.Sprintf("failed to initialize OIDC provider: %v", err),
-		})
-		cloudLogger.Warn().Err(err).Msg("failed to initialize OIDC provider")
+	identity, err := verifyCloudIdentity(oidcCtx, req.OidcGoogle, registeredAudience())
+	if errors.Is(err, oidcauth.ErrNotAllowed) {
+		_ = wsjson.Write(context.Background(), c, gin.H{"error": "identity not allowed"})
 		return err
 	}
-
-	oidcConfig := &oidc.Config{
-		SkipClientIDCheck: true,
-	}
-
-	verifier := provider.Verifier(oidcConfig)
-	idToken, err := verifier.Verify(oidcCtx, req.OidcGoogle)
 	if err != nil {
+		_ = wsjson.Write(context.Background(), c, gin.H{
+			"error": fmt.Sprintf("failed to verify OIDC token: %v", err),
+		})
+		cloudLogger.Warn().Err(err).Msg("failed to verify OIDC token")
 		return err
 	}
 
-	googleIdentity := idToken.Audience[0] + ":" + idToken.Subject
-	if config.GoogleIdentity != googleIdentity {
+	// with allow rules any allowed identity may connect, otherwise only the
+	// one that registered the device
+	if !config.CloudOIDC.hasRules() && config.GoogleIdentity != identity {
 		_ = wsjson.Write(context.Background(), c, gin.H{"error": "google identity mismatch"})
 		return fmt.Errorf("google identity mismatch")
 	}
@@ -426,6 +411,7 @@ func handleSessionRequest(
 	req WebRTCSessionRequest,
 	isCloudConnection bool,
 	source string,
+	user *users.Session,
 	scopedLogger *zerolog.Logger,
 ) error {
 	var sourceType string
@@ -448,12 +434,18 @@ func handleSessionRequest(
 		}
 	}
 
+	if user != nil {
+		l := scopedLogger.With().Str("username", user.Username).Logger()
+		scopedLogger = &l
+	}
+
 	session, err := newSession(SessionConfig{
 		ws:         c,
 		IsCloud:    isCloudConnection,
 		LocalIP:    req.IP,
 		ICEServers: req.ICEServers,
 		Logger:     scopedLogger,
+		User:       user,
 	})
 	if err != nil {
 		_ = wsjson.Write(context.Background(), c, gin.H{"error": err})
@@ -0,0 +1,111 @@
+// Command jetkvm-relay is a self-hosted relay for devices that can't be
+// reached directly. Devices with a relay configured keep a tunnel to it, and
+// the relay serves their web interface:
+//
+//	https://relay.example.com/d/<device id>/ selects a device by cookie
+//	https://<device id>.relay.example.com/   with -domain relay.example.com
+//
+// Selecting by cookie only works with a single device, devices sharing an
+// origin would share their sessions, more devices require -domain.
+//
+// The devices file is a JSON object mapping device IDs to their tokens, it's
+// reloaded on SIGHUP.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/relay"
+	"github.com/rs/zerolog"
+)
+
+func loadDevices(path, domain string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read devices file: %w", err)
+	}
+	devices := map[string]string{}
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("failed to parse devices file: %w", err)
+	}
+	for id, token := range devices {
+		if len(token) < 16 {
+			return nil, fmt.Errorf("token of device %s must be at least 16 characters", id)
+		}
+	}
+	if len(devices) > 1 && domain == "" {
+		return nil, errors.New("more than one device requires -domain")
+	}
+	return devices, nil
+}
+
+func main() {
+	listen := flag.String("listen", ":8443", "address to listen on")
+	certFile := flag.String("cert", "", "TLS certificate file, plain HTTP without it")
+	keyFile := flag.String("key", "", "TLS key file")
+	devicesFile := flag.String("devices", "devices.json", "JSON file mapping device IDs to tokens")
+	domain := flag.String("domain", "", "route <device id>.<domain> to the device")
+	flag.Parse()
+
+	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).With().Timestamp().Logger()
+
+	devices, err := loadDevices(*devicesFile, *domain)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to load devices")
+	}
+
+	relayServer := relay.NewServer(relay.ServerOptions{Devices: devices, Domain: *domain, Logger: &logger})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// only the count, device IDs shouldn't be public
+		_ = json.NewEncoder(w).Encode(map[string]int{"connected": len(relayServer.Devices())})
+	})
+	mux.Handle("/", relayServer)
+
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		for sig := range sigs {
+			if sig != syscall.SIGHUP {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				_ = server.Shutdown(ctx)
+				cancel()
+				return
+			}
+			devices, err := loadDevices(*devicesFile, *domain)
+			if err != nil {
+				logger.Error().Err(err).Msg("failed to reload devices, keeping the old ones")
+				continue
+			}
+			relayServer.SetDevices(devices)
+			logger.Info().Int("devices", len(devices)).Msg("reloaded devices")
+		}
+	}()
+
+	logger.Info().Str("listen", *listen).Int("devices", len(devices)).Msg("starting relay")
+	if *certFile != "" {
+		err = server.ListenAndServeTLS(*certFile, *keyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal().Err(err).Msg("relay stopped")
+	}
+}
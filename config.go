@@ -7,9 +7,12 @@ import (
 	"strconv"
 	"sync"
 
+	"github.com/jetkvm/kvm/internal/ldapauth"
 	"github.com/jetkvm/kvm/internal/logging"
 	"github.com/jetkvm/kvm/internal/network"
 	"github.com/jetkvm/kvm/internal/usbgadget"
+	"github.com/jetkvm/kvm/internal/users"
+	"github.com/jetkvm/kvm/internal/websecure"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -87,7 +90,6 @@ type Config struct {
 	AutoUpdateEnabled    bool                   `json:"auto_update_enabled"`
 	IncludePreRelease    bool                   `json:"include_pre_release"`
 	HashedPassword       string                 `json:"hashed_password"`
-	LocalAuthToken       string                 `json:"local_auth_token"`
 	LocalAuthMode        string                 `json:"localAuthMode"` //TODO: fix it with migration
 	LocalLoopbackOnly    bool                   `json:"local_loopback_only"`
 	WakeOnLanDevices     []WakeOnLanDevice      `json:"wake_on_lan_devices"`
@@ -99,11 +101,27 @@ type Config struct {
 	DisplayMaxBrightness int                    `json:"display_max_brightness"`
 	DisplayDimAfterSec   int                    `json:"display_dim_after_sec"`
 	DisplayOffAfterSec   int                    `json:"display_off_after_sec"`
-	TLSMode              string                 `json:"tls_mode"` // options: "self-signed", "user-defined", ""
+	TLSMode              string                 `json:"tls_mode"` // options: "self-signed", "user-defined", "acme", ""
+	ACME                 *ACMEConfig            `json:"acme"`
+	ClientCertAuth       *ClientCertAuthConfig  `json:"client_cert_auth"`
+	CloudOIDC            *OIDCConfig            `json:"cloud_oidc"`
+	LocalOIDC            *OIDCConfig            `json:"local_oidc"`
+	LDAP                 *LDAPConfig            `json:"ldap"`
+	Relay                *RelayConfig           `json:"relay"`
+	ICEServers           []ICEServerConfig      `json:"ice_servers"`
+	TURNServer           *TURNServerConfig      `json:"turn_server"`
 	UsbConfig            *usbgadget.Config      `json:"usb_config"`
 	UsbDevices           *usbgadget.Devices     `json:"usb_devices"`
 	NetworkConfig        *network.NetworkConfig `json:"network_config"`
 	DefaultLogLevel      string                 `json:"default_log_level"`
+	AudioConfig          *AudioConfig           `json:"audio_config"`
+	SerialSettings       *SerialSettings        `json:"serial_settings"`
+	SerialConsole        *SerialConsoleConfig   `json:"serial_console"`
+	SerialTriggers       []SerialTrigger        `json:"serial_triggers"`
+	UsbNetwork           *UsbNetworkConfig      `json:"usb_network"`
+	ScheduledTasks       []ScheduledTask        `json:"scheduled_tasks"`
+	Users                []users.User           `json:"users"`
+	UserSessions         []users.Session        `json:"user_sessions"`
 }
 
 func (c *Config) GetDisplayRotation() uint16 {
@@ -155,13 +173,63 @@ var defaultConfig = &Config{
 		Product:      "USB Emulation Device",
 	},
 	UsbDevices: &usbgadget.Devices{
-		AbsoluteMouse: true,
-		RelativeMouse: true,
-		Keyboard:      true,
-		MassStorage:   true,
+		AbsoluteMouse:   true,
+		RelativeMouse:   true,
+		Keyboard:        true,
+		MassStorage:     true,
+		Audio:           false,
+		ConsumerControl: false,
+		KeyboardNKRO:    false,
+		Serial:          false,
+		Network:         false,
+		NetworkECM:      false,
 	},
 	NetworkConfig:   &network.NetworkConfig{},
 	DefaultLogLevel: "INFO",
+	AudioConfig: &AudioConfig{
+		Enabled: false,
+		Device:  "default",
+	},
+	SerialSettings: &SerialSettings{
+		BaudRate: "115200",
+		DataBits: "8",
+		StopBits: "1",
+		Parity:   "none",
+	},
+	SerialConsole: &SerialConsoleConfig{
+		ScrollbackSize: 64 * 1024,
+		LogEnabled:     false,
+		LogMaxSize:     1024 * 1024,
+		TelnetEnabled:  false,
+		TelnetPort:     2323,
+		SSHEnabled:     false,
+		SSHPort:        2222,
+	},
+	SerialTriggers: []SerialTrigger{},
+	UsbNetwork: &UsbNetworkConfig{
+		Address:    "10.55.0.1/24",
+		DHCPServer: true,
+		LeaseTime:  60 * 60,
+		Forwarding: "disabled",
+		ProxyPort:  3128,
+	},
+	ScheduledTasks: []ScheduledTask{},
+	Users:          []users.User{},
+	UserSessions:   []users.Session{},
+	ClientCertAuth: &ClientCertAuthConfig{
+		Rules: []websecure.ClientCertRule{},
+	},
+	CloudOIDC: &OIDCConfig{
+		IssuerURL: defaultCloudOIDCIssuer,
+	},
+	LocalOIDC: &OIDCConfig{},
+	LDAP: &LDAPConfig{
+		UserFilter:     ldapauth.DefaultUserFilter,
+		GroupAttribute: ldapauth.DefaultGroupAttribute,
+	},
+	Relay:      &RelayConfig{},
+	ICEServers: []ICEServerConfig{},
+	TURNServer: &TURNServerConfig{},
 }
 
 var (
@@ -230,6 +298,66 @@ func LoadConfig() {
 		loadedConfig.JigglerConfig = defaultConfig.JigglerConfig
 	}
 
+	if loadedConfig.AudioConfig == nil {
+		loadedConfig.AudioConfig = defaultConfig.AudioConfig
+	}
+
+	if loadedConfig.SerialSettings == nil {
+		loadedConfig.SerialSettings = defaultConfig.SerialSettings
+	}
+
+	if loadedConfig.SerialConsole == nil {
+		loadedConfig.SerialConsole = defaultConfig.SerialConsole
+	}
+
+	if loadedConfig.SerialTriggers == nil {
+		loadedConfig.SerialTriggers = []SerialTrigger{}
+	}
+
+	if loadedConfig.UsbNetwork == nil {
+		loadedConfig.UsbNetwork = defaultConfig.UsbNetwork
+	}
+
+	if loadedConfig.ScheduledTasks == nil {
+		loadedConfig.ScheduledTasks = []ScheduledTask{}
+	}
+
+	if loadedConfig.Users == nil {
+		loadedConfig.Users = []users.User{}
+	}
+
+	if loadedConfig.UserSessions == nil {
+		loadedConfig.UserSessions = []users.Session{}
+	}
+
+	if loadedConfig.ClientCertAuth == nil {
+		loadedConfig.ClientCertAuth = defaultConfig.ClientCertAuth
+	}
+
+	if loadedConfig.CloudOIDC == nil {
+		loadedConfig.CloudOIDC = defaultConfig.CloudOIDC
+	}
+
+	if loadedConfig.LocalOIDC == nil {
+		loadedConfig.LocalOIDC = defaultConfig.LocalOIDC
+	}
+
+	if loadedConfig.LDAP == nil {
+		loadedConfig.LDAP = defaultConfig.LDAP
+	}
+
+	if loadedConfig.Relay == nil {
+		loadedConfig.Relay = defaultConfig.Relay
+	}
+
+	if loadedConfig.ICEServers == nil {
+		loadedConfig.ICEServers = defaultConfig.ICEServers
+	}
+
+	if loadedConfig.TURNServer == nil {
+		loadedConfig.TURNServer = defaultConfig.TURNServer
+	}
+
 	// fixup old keyboard layout value
 	if loadedConfig.KeyboardLayout == "en_US" {
 		loadedConfig.KeyboardLayout = "en-US"
@@ -35,7 +35,11 @@ func switchToMainScreen() {
 }
 
 func updateDisplay() {
-	nativeInstance.UpdateLabelIfChanged("home_info_ipv4_addr", networkState.IPv4String())
+	if status := ieee8021xDisplayStatus(); status != "" {
+		nativeInstance.UpdateLabelIfChanged("home_info_ipv4_addr", status)
+	} else {
+		nativeInstance.UpdateLabelIfChanged("home_info_ipv4_addr", networkState.IPv4String())
+	}
 	nativeInstance.UpdateLabelAndChangeVisibility("home_info_ipv6_addr", networkState.IPv6String())
 
 	_, _ = nativeInstance.UIObjHide("menu_btn_network")
@@ -0,0 +1,166 @@
+package kvm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/extension"
+)
+
+// extensionDetectTimeout is how long detection listens to the board.
+const extensionDetectTimeout = 5 * time.Second
+
+type (
+	ATXState     = extension.ATXState
+	DCPowerState = extension.DCPowerState
+)
+
+var (
+	atxExtension     = extension.NewATX()
+	dcExtension      = extension.NewDC()
+	genericExtension = extension.NewGeneric()
+
+	extensionRegistry = newExtensionRegistry()
+)
+
+func newExtensionRegistry() *extension.Registry {
+	r := extension.NewRegistry(serialLogger)
+	for _, ext := range []extension.Extension{atxExtension, dcExtension, genericExtension} {
+		if err := r.Register(ext); err != nil {
+			panic(err)
+		}
+	}
+	return r
+}
+
+// mountExtension lets the extension id take over the serial port.
+func mountExtension(id string) error {
+	if port == nil {
+		serialLogger.Warn().Str("extension", id).Msg("Serial port not available, skip extension")
+		return fmt.Errorf("serial port not available")
+	}
+	_ = port.SetMode(defaultMode)
+	if id == extension.DCID {
+		registerDCMetrics()
+	}
+	return extensionRegistry.Mount(id, port, func(state any) {
+		handleExtensionState(id, state)
+	})
+}
+
+// unmountExtension stops the active extension, reopening the port ends its reader.
+func unmountExtension() {
+	extensionRegistry.Unmount()
+	_ = reopenSerialPort()
+}
+
+// handleExtensionState forwards the state reported by an extension. ATX and
+// DC keep their own events, the UI has dedicated views for them.
+func handleExtensionState(id string, state any) {
+	switch s := state.(type) {
+	case ATXState:
+		if currentSession != nil {
+			writeJSONRPCEvent("atxState", s, currentSession)
+		}
+	case DCPowerState:
+		updateDCMetrics(s)
+		if currentSession != nil {
+			writeJSONRPCEvent("dcState", s, currentSession)
+		}
+	default:
+		if currentSession != nil {
+			writeJSONRPCEvent("extensionState", map[string]any{"id": id, "state": s}, currentSession)
+		}
+	}
+}
+
+// detectExtension identifies the board on the extension connector, the port
+// must not be used by an extension.
+func detectExtension(ctx context.Context) (string, error) {
+	if config.ActiveExtension != "" {
+		return "", fmt.Errorf("serial port is used by the %s extension", config.ActiveExtension)
+	}
+	if port == nil {
+		return "", fmt.Errorf("serial port not available")
+	}
+
+	// the boards all talk at the default settings
+	_ = port.SetMode(defaultMode)
+	defer func() {
+		_ = port.SetMode(serialPortMode)
+	}()
+
+	lines := make(chan string, 64)
+	var pending []byte
+	unsubscribe, err := console.subscribe(func(data []byte) {
+		pending = append(pending, data...)
+		for {
+			i := bytes.IndexByte(pending, '\n')
+			if i < 0 {
+				break
+			}
+			select {
+			case lines <- string(pending[:i]):
+			default:
+			}
+			pending = pending[i+1:]
+		}
+		if len(pending) > 4096 {
+			pending = pending[:0]
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	defer unsubscribe()
+
+	ctx, cancel := context.WithTimeout(ctx, extensionDetectTimeout)
+	defer cancel()
+	return extensionRegistry.Detect(ctx, func(line string) error {
+		return writeSerial([]byte(line + "\n"))
+	}, lines)
+}
+
+func pressATXPowerButton(duration time.Duration) error {
+	return atxExtension.Press(context.Background(), "PWR", duration)
+}
+
+func pressATXResetButton(duration time.Duration) error {
+	return atxExtension.Press(context.Background(), "RST", duration)
+}
+
+func setDCPowerState(on bool) error {
+	return dcExtension.SetPower(on)
+}
+
+func setDCRestoreState(state int) error {
+	return dcExtension.SetRestoreState(state)
+}
+
+func rpcGetExtensions() ([]extension.Info, error) {
+	return extensionRegistry.List(), nil
+}
+
+func rpcDetectExtension() (string, error) {
+	return detectExtension(context.Background())
+}
+
+// rpcGetExtensionState returns the state of the active extension.
+func rpcGetExtensionState() (any, error) {
+	ext := extensionRegistry.Active()
+	if ext == nil {
+		return nil, fmt.Errorf("no extension active")
+	}
+	return ext.State(), nil
+}
+
+func rpcExecuteExtensionCommand(command string, params map[string]any) (any, error) {
+	ext := extensionRegistry.Active()
+	if ext == nil {
+		return nil, fmt.Errorf("no extension active")
+	}
+	logger.Info().Str("extension", ext.Info().ID).Str("command", command).Msg("Executing extension command")
+	return ext.Execute(context.Background(), command, params)
+}
@@ -54,6 +54,34 @@ func handleHidRPCMessage(message hidrpc.Message, session *Session) {
 			return
 		}
 		rpcErr = rpcRelMouseReport(mouseReport.DX, mouseReport.DY, mouseReport.Button)
+	case hidrpc.TypeWheelReport:
+		wheelReport, err := message.WheelReport()
+		if err != nil {
+			logger.Warn().Err(err).Msg("failed to get wheel report")
+			return
+		}
+		rpcErr = rpcScrollReport(wheelReport.WheelY, wheelReport.WheelX)
+	case hidrpc.TypeRelWheelReport:
+		wheelReport, err := message.WheelReport()
+		if err != nil {
+			logger.Warn().Err(err).Msg("failed to get relative wheel report")
+			return
+		}
+		rpcErr = rpcRelScrollReport(wheelReport.WheelY, wheelReport.WheelX)
+	case hidrpc.TypeConsumerControlReport:
+		consumerControlReport, err := message.ConsumerControlReport()
+		if err != nil {
+			logger.Warn().Err(err).Msg("failed to get consumer control report")
+			return
+		}
+		rpcErr = rpcConsumerControlReport(consumerControlReport.Usage)
+	case hidrpc.TypeSystemControlReport:
+		systemControlReport, err := message.SystemControlReport()
+		if err != nil {
+			logger.Warn().Err(err).Msg("failed to get system control report")
+			return
+		}
+		rpcErr = rpcSystemControlReport(systemControlReport.Usage)
 	default:
 		logger.Warn().Uint8("type", uint8(message.Type())).Msg("unknown HID RPC message type")
 	}
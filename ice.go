@@ -0,0 +1,319 @@
+package kvm
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/turnserver"
+	"github.com/pion/webrtc/v4"
+)
+
+// ICEServerConfig is a STUN or TURN server for WebRTC sessions. With Secret
+// set, ephemeral TURN REST credentials are derived for every session
+// instead of using Username and Credential.
+type ICEServerConfig struct {
+	URLs                 []string `json:"urls"`
+	Username             string   `json:"username,omitempty"`
+	Credential           string   `json:"credential,omitempty"`
+	Secret               string   `json:"secret,omitempty"`
+	CredentialTTLSeconds int      `json:"credential_ttl_seconds,omitempty"`
+}
+
+func (c *ICEServerConfig) Validate() error {
+	if len(c.URLs) == 0 {
+		return fmt.Errorf("ICE server needs at least one URL")
+	}
+	turnURL := false
+	for _, u := range c.URLs {
+		scheme, _, _ := strings.Cut(u, ":")
+		switch scheme {
+		case "stun", "stuns":
+		case "turn", "turns":
+			turnURL = true
+		default:
+			return fmt.Errorf("invalid ICE server URL %s, expected stun:, stuns:, turn: or turns:", u)
+		}
+	}
+	if turnURL && c.Secret == "" && (c.Username == "" || c.Credential == "") {
+		return fmt.Errorf("TURN server %s needs a username and credential or a secret", c.URLs[0])
+	}
+	if c.CredentialTTLSeconds < 0 {
+		return fmt.Errorf("invalid credential TTL: %d", c.CredentialTTLSeconds)
+	}
+	return nil
+}
+
+// iceServer returns the server for a session of user.
+func (c *ICEServerConfig) iceServer(user string) (webrtc.ICEServer, error) {
+	server := webrtc.ICEServer{
+		URLs:       c.URLs,
+		Username:   c.Username,
+		Credential: c.Credential,
+	}
+	if c.Secret != "" {
+		username, credential, err := turnserver.Credentials(c.Secret, user, time.Duration(c.CredentialTTLSeconds)*time.Second)
+		if err != nil {
+			return server, err
+		}
+		server.Username = username
+		server.Credential = credential
+	}
+	return server, nil
+}
+
+// TURNServerConfig is the embedded TURN server, relaying sessions to the
+// device for clients that can't reach it directly on the site network.
+type TURNServerConfig struct {
+	Enabled    bool   `json:"enabled"`
+	ListenPort int    `json:"listen_port,omitempty"`
+	Realm      string `json:"realm,omitempty"`
+	// RelayAddress is the address of the relayed candidates, the IPv4
+	// address of the device without it.
+	RelayAddress string `json:"relay_address,omitempty"`
+	RelayPortMin int    `json:"relay_port_min,omitempty"`
+	RelayPortMax int    `json:"relay_port_max,omitempty"`
+	// Secret is the TURN REST secret, it's generated when the server is
+	// enabled without one.
+	Secret string `json:"secret,omitempty"`
+}
+
+func (c *TURNServerConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.ListenPort < 0 || c.ListenPort > 65535 {
+		return fmt.Errorf("invalid listen port: %d", c.ListenPort)
+	}
+	if c.RelayAddress != "" {
+		if _, err := netip.ParseAddr(c.RelayAddress); err != nil {
+			return fmt.Errorf("invalid relay address: %s", c.RelayAddress)
+		}
+	}
+	if c.RelayPortMin != 0 || c.RelayPortMax != 0 {
+		if c.RelayPortMin < 1024 || c.RelayPortMax > 65535 || c.RelayPortMin > c.RelayPortMax {
+			return fmt.Errorf("invalid relay port range %d-%d", c.RelayPortMin, c.RelayPortMax)
+		}
+	}
+	return nil
+}
+
+func (c *TURNServerConfig) port() int {
+	if c.ListenPort == 0 {
+		return turnserver.DefaultPort
+	}
+	return c.ListenPort
+}
+
+type TURNServerState struct {
+	Running      bool   `json:"running"`
+	ListenAddr   string `json:"listen_addr,omitempty"`
+	RelayAddress string `json:"relay_address,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+var (
+	turnServer       *turnserver.Server
+	turnServerConfig TURNServerConfig
+	turnServerState  TURNServerState
+	turnServerLock   sync.Mutex
+)
+
+// turnRelayAddress returns the address the embedded TURN server relays on.
+func turnRelayAddress(c *TURNServerConfig) net.IP {
+	if c.RelayAddress != "" {
+		return net.ParseIP(c.RelayAddress)
+	}
+	if networkState == nil || networkState.IPv4() == nil {
+		return nil
+	}
+	return *networkState.IPv4()
+}
+
+// isDeviceIP tells if ip is one of the device addresses, the embedded TURN
+// server only relays to the device.
+func isDeviceIP(ip net.IP) bool {
+	if networkState == nil {
+		return false
+	}
+	if v4 := networkState.IPv4(); v4 != nil && v4.Equal(ip) {
+		return true
+	}
+	if v6 := networkState.IPv6(); v6 != nil && v6.Equal(ip) {
+		return true
+	}
+	if addr, ok := netip.AddrFromSlice(ip); ok {
+		return slices.Contains(networkState.WireGuardAddresses(), addr.Unmap())
+	}
+	return false
+}
+
+// updateTURNServer starts, restarts or stops the embedded TURN server to
+// match the config and the device address.
+func updateTURNServer() {
+	turnServerLock.Lock()
+	defer turnServerLock.Unlock()
+
+	wanted := TURNServerConfig{}
+	var relayAddress net.IP
+	if config.TURNServer.Enabled {
+		wanted = *config.TURNServer
+		relayAddress = turnRelayAddress(&wanted)
+	}
+	if turnServer != nil && wanted == turnServerConfig && relayAddress.Equal(net.ParseIP(turnServerState.RelayAddress)) {
+		return
+	}
+
+	if turnServer != nil {
+		_ = turnServer.Close()
+		turnServer = nil
+	}
+	turnServerConfig = wanted
+	turnServerState = TURNServerState{}
+	if !wanted.Enabled {
+		return
+	}
+	if relayAddress == nil {
+		turnServerState.Error = "waiting for the device to get an IPv4 address"
+		return
+	}
+
+	s, err := turnserver.NewServer(turnserver.Options{
+		ListenAddr:   fmt.Sprintf(":%d", wanted.port()),
+		Realm:        wanted.Realm,
+		Secret:       wanted.Secret,
+		RelayAddress: relayAddress,
+		RelayPortMin: uint16(wanted.RelayPortMin),
+		RelayPortMax: uint16(wanted.RelayPortMax),
+		AllowPeer:    isDeviceIP,
+		Logger:       webrtcLogger,
+	})
+	if err != nil {
+		webrtcLogger.Error().Err(err).Msg("failed to start TURN server")
+		turnServerState.Error = err.Error()
+		return
+	}
+	turnServer = s
+	turnServerState = TURNServerState{
+		Running:      true,
+		ListenAddr:   s.Addr().String(),
+		RelayAddress: relayAddress.String(),
+	}
+}
+
+// embeddedTURNServer returns the embedded TURN server for a client session
+// of user, false if it isn't running.
+func embeddedTURNServer(user string) (webrtc.ICEServer, bool) {
+	turnServerLock.Lock()
+	defer turnServerLock.Unlock()
+
+	if turnServer == nil {
+		return webrtc.ICEServer{}, false
+	}
+	username, credential, err := turnServer.Credentials(user, 0)
+	if err != nil {
+		webrtcLogger.Warn().Err(err).Msg("failed to generate TURN credentials")
+		return webrtc.ICEServer{}, false
+	}
+	host := net.JoinHostPort(turnServerState.RelayAddress, fmt.Sprint(turnServerConfig.port()))
+	return webrtc.ICEServer{
+		URLs:       []string{"turn:" + host + "?transport=udp"},
+		Username:   username,
+		Credential: credential,
+	}, true
+}
+
+// sessionICEServers returns the ICE servers for a session of user, after the
+// ones provided by the cloud.
+func sessionICEServers(cloudURLs []string, user string) []webrtc.ICEServer {
+	servers := make([]webrtc.ICEServer, 0, len(config.ICEServers)+1)
+	if len(cloudURLs) > 0 {
+		servers = append(servers, webrtc.ICEServer{URLs: cloudURLs})
+	}
+	for i := range config.ICEServers {
+		server, err := config.ICEServers[i].iceServer(user)
+		if err != nil {
+			webrtcLogger.Warn().Err(err).Strs("urls", config.ICEServers[i].URLs).Msg("failed to generate TURN credentials")
+			continue
+		}
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+// clientICEServers returns the ICE servers for the browser of a local
+// session, sent with the device metadata.
+func clientICEServers(user string) []webrtc.ICEServer {
+	servers := sessionICEServers(nil, user)
+	if server, ok := embeddedTURNServer(user); ok {
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+func generateTURNSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func rpcGetICEServers() ([]ICEServerConfig, error) {
+	return config.ICEServers, nil
+}
+
+func rpcSetICEServers(iceServers []ICEServerConfig) error {
+	for i := range iceServers {
+		if err := iceServers[i].Validate(); err != nil {
+			return err
+		}
+	}
+	if iceServers == nil {
+		iceServers = []ICEServerConfig{}
+	}
+
+	config.ICEServers = iceServers
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	webrtcLogger.Info().Int("count", len(iceServers)).Msg("ICE servers updated")
+	return nil
+}
+
+func rpcGetTURNServerConfig() (*TURNServerConfig, error) {
+	return config.TURNServer, nil
+}
+
+func rpcSetTURNServerConfig(turnServerConfig TURNServerConfig) error {
+	if err := turnServerConfig.Validate(); err != nil {
+		return err
+	}
+	if turnServerConfig.Enabled && turnServerConfig.Secret == "" {
+		secret, err := generateTURNSecret()
+		if err != nil {
+			return fmt.Errorf("failed to generate TURN secret: %w", err)
+		}
+		turnServerConfig.Secret = secret
+	}
+
+	config.TURNServer = &turnServerConfig
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	webrtcLogger.Info().Bool("enabled", turnServerConfig.Enabled).Int("port", turnServerConfig.port()).Msg("TURN server settings updated")
+	updateTURNServer()
+	return nil
+}
+
+func rpcGetTURNServerState() TURNServerState {
+	turnServerLock.Lock()
+	defer turnServerLock.Unlock()
+	return turnServerState
+}
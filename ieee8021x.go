@@ -0,0 +1,110 @@
+package kvm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/network"
+	"github.com/jetkvm/kvm/internal/wpasupplicant"
+)
+
+// The 802.1X certificates are kept in the TLS certificate store under these
+// names.
+const (
+	ieee8021xClientCertificateName = "ieee8021x-client"
+	ieee8021xCACertificateName     = "ieee8021x-ca"
+)
+
+type IEEE8021XCertificateInfo struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+type IEEE8021XCertificates struct {
+	ClientCertificate *IEEE8021XCertificateInfo `json:"client_certificate,omitempty"`
+	CACertificate     bool                      `json:"ca_certificate"`
+}
+
+// ieee8021xCertificates returns the certificate files for the supplicant.
+func ieee8021xCertificates() (caFile, certFile, keyFile string) {
+	if certStore == nil {
+		initCertStore()
+	}
+	certFile, keyFile, _ = certStore.CertificateFiles(ieee8021xClientCertificateName)
+	return certStore.CABundleFile(ieee8021xCACertificateName), certFile, keyFile
+}
+
+func ieee8021xStateChanged(state *network.NetworkInterfaceState) {
+	go waitCtrlAndRequestDisplayUpdate(true, "ieee8021x_state_changed")
+
+	if currentSession != nil {
+		writeJSONRPCEvent("networkState", state.RpcGetNetworkState(), currentSession)
+	}
+}
+
+// ieee8021xDisplayStatus returns the status shown instead of the IPv4
+// address while the port isn't authorized, empty otherwise.
+func ieee8021xDisplayStatus() string {
+	if networkState == nil {
+		return ""
+	}
+	state := networkState.IEEE8021XState()
+	if state == nil {
+		return ""
+	}
+	switch state.Status {
+	case wpasupplicant.StatusAuthenticated:
+		return ""
+	case wpasupplicant.StatusFailed:
+		return "802.1X failed"
+	default:
+		return "802.1X authenticating"
+	}
+}
+
+func rpcGetIEEE8021XCertificates() (*IEEE8021XCertificates, error) {
+	if certStore == nil {
+		initCertStore()
+	}
+
+	certs := &IEEE8021XCertificates{
+		CACertificate: certStore.CABundleFile(ieee8021xCACertificateName) != "",
+	}
+	if cert := certStore.GetCertificate(ieee8021xClientCertificateName); cert != nil && cert.Leaf != nil {
+		certs.ClientCertificate = &IEEE8021XCertificateInfo{
+			Subject:  cert.Leaf.Subject.String(),
+			Issuer:   cert.Leaf.Issuer.String(),
+			NotAfter: cert.Leaf.NotAfter,
+		}
+	}
+	return certs, nil
+}
+
+func rpcSetIEEE8021XCertificate(certificate string, privateKey string) error {
+	if certStore == nil {
+		initCertStore()
+	}
+	// the client certificate isn't issued for a host name, skip that check
+	err, _ := certStore.ValidateAndSaveCertificate(ieee8021xClientCertificateName, certificate, privateKey, true)
+	if err != nil {
+		return fmt.Errorf("invalid client certificate: %w", err)
+	}
+
+	networkLogger.Info().Msg("802.1X client certificate updated")
+	networkState.ReloadIEEE8021X()
+	return nil
+}
+
+func rpcSetIEEE8021XCACertificate(certificate string) error {
+	if certStore == nil {
+		initCertStore()
+	}
+	if err := certStore.ValidateAndSaveCABundle(ieee8021xCACertificateName, certificate); err != nil {
+		return err
+	}
+
+	networkLogger.Info().Msg("802.1X CA certificate updated")
+	networkState.ReloadIEEE8021X()
+	return nil
+}
@@ -0,0 +1,282 @@
+// Package audio captures audio from ALSA devices and encodes it to Opus
+// so it can be forwarded as a WebRTC track.
+package audio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/pion/webrtc/v4/pkg/media/oggreader"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultDevice is the ALSA device used when none is configured.
+	DefaultDevice = "default"
+	// DefaultBitrate is the Opus bitrate passed to the encoder.
+	DefaultBitrate = "64k"
+	// FrameDuration is the duration of a single Opus frame produced by the encoder.
+	FrameDuration = 20 * time.Millisecond
+
+	restartDelay = 3 * time.Second
+)
+
+var defaultLogger = logging.GetSubsystemLogger("audio")
+
+// CaptureOptions are the options for a new Capture.
+type CaptureOptions struct {
+	Device  string
+	Bitrate string
+	Logger  *zerolog.Logger
+	OnFrame func(frame []byte, duration time.Duration)
+}
+
+// Capture runs an ffmpeg process reading from an ALSA capture device and
+// emits Opus frames through OnFrame.
+type Capture struct {
+	device  string
+	bitrate string
+	onFrame func(frame []byte, duration time.Duration)
+	l       *zerolog.Logger
+
+	lock    sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+	running bool
+	lastErr error
+}
+
+// NewCapture creates a new Capture, it does not start capturing.
+func NewCapture(opts *CaptureOptions) *Capture {
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+
+	if opts.Device == "" {
+		opts.Device = DefaultDevice
+	}
+
+	if opts.Bitrate == "" {
+		opts.Bitrate = DefaultBitrate
+	}
+
+	onFrame := opts.OnFrame
+	if onFrame == nil {
+		onFrame = func(frame []byte, duration time.Duration) {}
+	}
+
+	return &Capture{
+		device:  opts.Device,
+		bitrate: opts.Bitrate,
+		onFrame: onFrame,
+		l:       opts.Logger,
+	}
+}
+
+// Device returns the ALSA device the capture reads from.
+func (c *Capture) Device() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.device
+}
+
+// IsRunning returns true if the capture pipeline is running.
+func (c *Capture) IsRunning() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.running
+}
+
+// LastError returns the last error reported by the capture pipeline.
+func (c *Capture) LastError() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.lastErr
+}
+
+// SetDevice changes the ALSA device, restarting the pipeline if it is running.
+func (c *Capture) SetDevice(device string) error {
+	if device == "" {
+		device = DefaultDevice
+	}
+
+	c.lock.Lock()
+	changed := c.device != device
+	c.device = device
+	running := c.running
+	c.lock.Unlock()
+
+	if !changed || !running {
+		return nil
+	}
+
+	c.Stop()
+	return c.Start()
+}
+
+// Start starts the capture pipeline. The pipeline is restarted if ffmpeg exits
+// unexpectedly, until Stop is called.
+func (c *Capture) Start() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.running {
+		return nil
+	}
+
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		c.lastErr = fmt.Errorf("ffmpeg not found: %w", err)
+		return c.lastErr
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	c.running = true
+	c.lastErr = nil
+
+	go c.supervise(ctx, c.device, c.done)
+
+	return nil
+}
+
+// Stop stops the capture pipeline and waits for it to exit.
+func (c *Capture) Stop() {
+	c.lock.Lock()
+	if !c.running {
+		c.lock.Unlock()
+		return
+	}
+	cancel := c.cancel
+	done := c.done
+	c.running = false
+	c.cancel = nil
+	c.lock.Unlock()
+
+	cancel()
+	<-done
+}
+
+func (c *Capture) supervise(ctx context.Context, device string, done chan struct{}) {
+	defer close(done)
+
+	l := c.l.With().Str("device", device).Logger()
+
+	for {
+		l.Info().Msg("starting audio capture")
+		err := c.run(ctx, device, &l)
+		if ctx.Err() != nil {
+			l.Info().Msg("audio capture stopped")
+			return
+		}
+
+		if err == nil {
+			err = errors.New("ffmpeg exited unexpectedly")
+		}
+
+		c.lock.Lock()
+		c.lastErr = err
+		c.lock.Unlock()
+
+		l.Warn().Err(err).Dur("retry_in", restartDelay).Msg("audio capture failed")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(restartDelay):
+		}
+	}
+}
+
+func (c *Capture) buildFFmpegArgs(device string) []string {
+	return []string{
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-fflags", "nobuffer",
+		"-f", "alsa",
+		"-i", device,
+		"-vn",
+		"-ac", "2",
+		"-ar", "48000",
+		"-c:a", "libopus",
+		"-b:a", c.bitrate,
+		"-application", "lowdelay",
+		"-frame_duration", "20",
+		// one Opus packet per Ogg page so every page maps to a single sample
+		"-page_duration", "20000",
+		"-f", "ogg",
+		"pipe:1",
+	}
+}
+
+func (c *Capture) run(ctx context.Context, device string, l *zerolog.Logger) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg", c.buildFFmpegArgs(device)...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get ffmpeg stdout: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get ffmpeg stderr: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	go func() {
+		sc := bufio.NewScanner(stderr)
+		for sc.Scan() {
+			l.Debug().Str("line", sc.Text()).Msg("ffmpeg")
+		}
+	}()
+
+	readErr := c.readOpusPages(stdout)
+
+	waitErr := cmd.Wait()
+	if readErr != nil && !errors.Is(readErr, io.EOF) {
+		return readErr
+	}
+	return waitErr
+}
+
+func (c *Capture) readOpusPages(r io.Reader) error {
+	ogg, _, err := oggreader.NewWith(r)
+	if err != nil {
+		return fmt.Errorf("failed to read ogg header: %w", err)
+	}
+
+	var lastGranule uint64
+	for {
+		payload, header, err := ogg.ParseNextPage()
+		if err != nil {
+			return err
+		}
+
+		// skip the OpusTags comment header
+		if len(payload) >= 8 && string(payload[:8]) == "OpusTags" {
+			continue
+		}
+
+		duration := FrameDuration
+		if lastGranule != 0 && header.GranulePosition > lastGranule {
+			// granule position is always counted at 48kHz for Opus
+			duration = time.Duration(header.GranulePosition-lastGranule) * time.Second / 48000
+		}
+		lastGranule = header.GranulePosition
+
+		c.onFrame(payload, duration)
+	}
+}
@@ -0,0 +1,103 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const procPCMPath = "/proc/asound/pcm"
+
+// Device is an ALSA PCM device.
+type Device struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Capture  bool   `json:"capture"`
+	Playback bool   `json:"playback"`
+}
+
+// ListDevices returns the ALSA PCM devices known to the kernel.
+func ListDevices() ([]Device, error) {
+	data, err := os.ReadFile(procPCMPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", procPCMPath, err)
+	}
+
+	return parsePCMList(string(data)), nil
+}
+
+// ListCaptureDevices returns the ALSA PCM devices that support capture.
+func ListCaptureDevices() ([]Device, error) {
+	devices, err := ListDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	captureDevices := make([]Device, 0, len(devices))
+	for _, d := range devices {
+		if d.Capture {
+			captureDevices = append(captureDevices, d)
+		}
+	}
+
+	return captureDevices, nil
+}
+
+// parsePCMList parses the content of /proc/asound/pcm, for example:
+//
+//	00-00: USB Audio : USB Audio : playback 1 : capture 1
+func parsePCMList(data string) []Device {
+	devices := make([]Device, 0)
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, " : ")
+		if len(fields) < 2 {
+			continue
+		}
+
+		head := strings.SplitN(fields[0], ":", 2)
+		if len(head) != 2 {
+			continue
+		}
+
+		ids := strings.SplitN(head[0], "-", 2)
+		if len(ids) != 2 {
+			continue
+		}
+
+		card, err := strconv.Atoi(ids[0])
+		if err != nil {
+			continue
+		}
+
+		dev, err := strconv.Atoi(ids[1])
+		if err != nil {
+			continue
+		}
+
+		device := Device{
+			ID:   fmt.Sprintf("hw:%d,%d", card, dev),
+			Name: strings.TrimSpace(fields[1]),
+		}
+
+		for _, f := range fields[2:] {
+			f = strings.TrimSpace(f)
+			switch {
+			case strings.HasPrefix(f, "capture"):
+				device.Capture = true
+			case strings.HasPrefix(f, "playback"):
+				device.Playback = true
+			}
+		}
+
+		devices = append(devices, device)
+	}
+
+	return devices
+}
@@ -0,0 +1,30 @@
+package audio
+
+import "testing"
+
+func TestParsePCMList(t *testing.T) {
+	devices := parsePCMList(`00-00: ALC892 Analog : ALC892 Analog : playback 1 : capture 1
+00-01: ALC892 Digital : ALC892 Digital : playback 1
+01-00: USB Audio : USB Audio : capture 1
+garbage line
+`)
+
+	if len(devices) != 3 {
+		t.Fatalf("expected 3 devices, got %d", len(devices))
+	}
+
+	if devices[0].ID != "hw:0,0" || devices[0].Name != "ALC892 Analog" {
+		t.Fatalf("unexpected first device: %+v", devices[0])
+	}
+	if !devices[0].Capture || !devices[0].Playback {
+		t.Fatalf("expected first device to support capture and playback: %+v", devices[0])
+	}
+
+	if devices[1].Capture || !devices[1].Playback {
+		t.Fatalf("expected second device to be playback only: %+v", devices[1])
+	}
+
+	if devices[2].ID != "hw:1,0" || !devices[2].Capture || devices[2].Playback {
+		t.Fatalf("unexpected third device: %+v", devices[2])
+	}
+}
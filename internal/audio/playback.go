@@ -0,0 +1,214 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+	"github.com/rs/zerolog"
+)
+
+// PlaybackOptions are the options for a new Playback.
+type PlaybackOptions struct {
+	Device string
+	Logger *zerolog.Logger
+}
+
+// Playback runs an ffmpeg process decoding Opus RTP packets and playing them
+// to an ALSA playback device.
+type Playback struct {
+	device string
+	l      *zerolog.Logger
+	// ffmpeg is the command run, tests replace it
+	ffmpeg string
+
+	lock    sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+	writer  *oggwriter.OggWriter
+	stdin   io.WriteCloser
+	running bool
+	lastErr error
+}
+
+// NewPlayback creates a new Playback, it does not start playing.
+func NewPlayback(opts *PlaybackOptions) *Playback {
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+
+	if opts.Device == "" {
+		opts.Device = DefaultDevice
+	}
+
+	return &Playback{
+		device: opts.Device,
+		l:      opts.Logger,
+		ffmpeg: "ffmpeg",
+	}
+}
+
+// IsRunning returns true if the playback pipeline is running.
+func (p *Playback) IsRunning() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.running
+}
+
+// LastError returns the last error reported by the playback pipeline.
+func (p *Playback) LastError() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.lastErr
+}
+
+func (p *Playback) buildFFmpegArgs() []string {
+	return []string{
+		"-hide_banner",
+		"-loglevel", "warning",
+		"-fflags", "nobuffer",
+		"-flags", "low_delay",
+		"-f", "ogg",
+		"-i", "pipe:0",
+		"-ac", "2",
+		"-ar", "48000",
+		"-f", "alsa",
+		p.device,
+	}
+}
+
+// Start starts the playback pipeline. Unlike Capture, the pipeline is not
+// restarted automatically, as the ogg stream can't be resumed mid-way.
+func (p *Playback) Start() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	if _, err := exec.LookPath(p.ffmpeg); err != nil {
+		p.lastErr = fmt.Errorf("ffmpeg not found: %w", err)
+		return p.lastErr
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, p.ffmpeg, p.buildFFmpegArgs()...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		p.lastErr = fmt.Errorf("failed to get ffmpeg stdin: %w", err)
+		return p.lastErr
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		cancel()
+		p.lastErr = fmt.Errorf("failed to get ffmpeg stderr: %w", err)
+		return p.lastErr
+	}
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		p.lastErr = fmt.Errorf("failed to start ffmpeg: %w", err)
+		return p.lastErr
+	}
+
+	writer, err := oggwriter.NewWith(stdin, 48000, 2)
+	if err != nil {
+		cancel()
+		_ = cmd.Wait()
+		p.lastErr = fmt.Errorf("failed to create ogg writer: %w", err)
+		return p.lastErr
+	}
+
+	l := p.l.With().Str("device", p.device).Logger()
+	l.Info().Msg("starting audio playback")
+
+	done := make(chan struct{})
+	go func() {
+		sc := bufio.NewScanner(stderr)
+		for sc.Scan() {
+			l.Debug().Str("line", sc.Text()).Msg("ffmpeg")
+		}
+	}()
+	go func() {
+		defer close(done)
+		err := cmd.Wait()
+		if ctx.Err() != nil {
+			l.Info().Msg("audio playback stopped")
+			return
+		}
+
+		if err == nil {
+			err = errors.New("ffmpeg exited unexpectedly")
+		}
+		l.Warn().Err(err).Msg("audio playback failed")
+
+		// the pipeline is gone, the next Start starts a new one and Stop has
+		// nothing to wait for
+		p.lock.Lock()
+		p.lastErr = err
+		if p.done == done {
+			p.cancel = nil
+			p.done = nil
+			p.writer = nil
+			p.stdin = nil
+			p.running = false
+		}
+		p.lock.Unlock()
+		cancel()
+	}()
+
+	p.cancel = cancel
+	p.done = done
+	p.writer = writer
+	p.stdin = stdin
+	p.running = true
+	p.lastErr = nil
+
+	return nil
+}
+
+// WriteRTP writes an Opus RTP packet to the playback pipeline. Packets are
+// dropped if the pipeline is not running.
+func (p *Playback) WriteRTP(packet *rtp.Packet) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.writer == nil {
+		return nil
+	}
+
+	return p.writer.WriteRTP(packet)
+}
+
+// Stop stops the playback pipeline and waits for it to exit.
+func (p *Playback) Stop() {
+	p.lock.Lock()
+	if p.cancel == nil {
+		p.lock.Unlock()
+		return
+	}
+	cancel := p.cancel
+	done := p.done
+	stdin := p.stdin
+	p.cancel = nil
+	p.writer = nil
+	p.stdin = nil
+	p.running = false
+	p.lock.Unlock()
+
+	_ = stdin.Close()
+	cancel()
+	<-done
+}
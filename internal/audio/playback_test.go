@@ -0,0 +1,68 @@
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeFFmpeg returns a command that exits on its own, like ffmpeg does when
+// the device is unplugged.
+func fakeFFmpeg(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nsleep 0.1\nexit 1\n"), 0o755); err != nil {
+		t.Fatalf("failed to write fake ffmpeg: %v", err)
+	}
+	return path
+}
+
+func waitStopped(t *testing.T, p *Playback) {
+	deadline := time.Now().Add(5 * time.Second)
+	for p.IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("playback didn't notice that ffmpeg exited")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPlaybackRestartAfterExit(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no shell for the fake ffmpeg")
+	}
+
+	p := NewPlayback(&PlaybackOptions{Device: "null"})
+	p.ffmpeg = fakeFFmpeg(t)
+
+	if err := p.Start(); err != nil {
+		t.Fatalf("failed to start: %v", err)
+	}
+	waitStopped(t, p)
+	if p.LastError() == nil {
+		t.Fatal("expected the exit to be reported")
+	}
+
+	// Stop must not wait for the exited process
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked after ffmpeg exited")
+	}
+
+	if err := p.Start(); err != nil {
+		t.Fatalf("failed to restart: %v", err)
+	}
+	if !p.IsRunning() {
+		t.Fatal("expected playback to run again")
+	}
+	p.Stop()
+	if p.IsRunning() {
+		t.Fatal("expected playback to be stopped")
+	}
+}
@@ -0,0 +1,199 @@
+package dhcpserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// BOOTP/DHCP message layout, RFC 2131
+const (
+	opRequest = 1
+	opReply   = 2
+
+	htypeEthernet = 1
+
+	headerSize = 236
+)
+
+var magicCookie = []byte{99, 130, 83, 99}
+
+// DHCP options, RFC 2132
+const (
+	optionPad         = 0
+	optionSubnetMask  = 1
+	optionRouter      = 3
+	optionDNS         = 6
+	optionHostname    = 12
+	optionBroadcast   = 28
+	optionRequestedIP = 50
+	optionLeaseTime   = 51
+	optionMessageType = 53
+	optionServerID    = 54
+	optionRenewalTime = 58
+	optionRebindTime  = 59
+	optionEnd         = 255
+)
+
+type messageType byte
+
+// DHCP message types, RFC 2132 section 9.6
+const (
+	msgDiscover messageType = 1
+	msgOffer    messageType = 2
+	msgRequest  messageType = 3
+	msgDecline  messageType = 4
+	msgAck      messageType = 5
+	msgNak      messageType = 6
+	msgRelease  messageType = 7
+	msgInform   messageType = 8
+)
+
+var errInvalidPacket = errors.New("invalid DHCP packet")
+
+type packet struct {
+	op      byte
+	xid     uint32
+	flags   uint16
+	ciaddr  net.IP
+	yiaddr  net.IP
+	siaddr  net.IP
+	giaddr  net.IP
+	chaddr  net.HardwareAddr
+	options map[byte][]byte
+}
+
+func parsePacket(data []byte) (*packet, error) {
+	if len(data) < headerSize+len(magicCookie) {
+		return nil, errInvalidPacket
+	}
+	if data[1] != htypeEthernet || data[2] != 6 {
+		return nil, errInvalidPacket
+	}
+	if string(data[headerSize:headerSize+4]) != string(magicCookie) {
+		return nil, errInvalidPacket
+	}
+
+	p := &packet{
+		op:      data[0],
+		xid:     binary.BigEndian.Uint32(data[4:8]),
+		flags:   binary.BigEndian.Uint16(data[10:12]),
+		ciaddr:  net.IP(append([]byte{}, data[12:16]...)),
+		yiaddr:  net.IP(append([]byte{}, data[16:20]...)),
+		siaddr:  net.IP(append([]byte{}, data[20:24]...)),
+		giaddr:  net.IP(append([]byte{}, data[24:28]...)),
+		chaddr:  net.HardwareAddr(append([]byte{}, data[28:34]...)),
+		options: make(map[byte][]byte),
+	}
+
+	opts := data[headerSize+4:]
+	for i := 0; i < len(opts); {
+		code := opts[i]
+		if code == optionEnd {
+			break
+		}
+		if code == optionPad {
+			i++
+			continue
+		}
+		if i+1 >= len(opts) {
+			return nil, errInvalidPacket
+		}
+		size := int(opts[i+1])
+		if i+2+size > len(opts) {
+			return nil, errInvalidPacket
+		}
+		// options split over several instances are concatenated, RFC 3396
+		p.options[code] = append(p.options[code], opts[i+2:i+2+size]...)
+		i += 2 + size
+	}
+
+	return p, nil
+}
+
+func (p *packet) messageType() messageType {
+	if v := p.options[optionMessageType]; len(v) == 1 {
+		return messageType(v[0])
+	}
+	return 0
+}
+
+// ipOption returns an option holding a single IPv4 address.
+func (p *packet) ipOption(code byte) net.IP {
+	if v := p.options[code]; len(v) == 4 {
+		return net.IP(v)
+	}
+	return nil
+}
+
+// reply creates the reply to a client's packet.
+func (p *packet) reply(t messageType, serverIP net.IP) *packet {
+	return &packet{
+		op:     opReply,
+		xid:    p.xid,
+		flags:  p.flags,
+		ciaddr: net.IPv4zero,
+		yiaddr: net.IPv4zero,
+		siaddr: net.IPv4zero,
+		giaddr: p.giaddr,
+		chaddr: p.chaddr,
+		options: map[byte][]byte{
+			optionMessageType: {byte(t)},
+			optionServerID:    serverIP.To4(),
+		},
+	}
+}
+
+func (p *packet) marshal() []byte {
+	data := make([]byte, headerSize, 300)
+	data[0] = p.op
+	data[1] = htypeEthernet
+	data[2] = 6
+	binary.BigEndian.PutUint32(data[4:8], p.xid)
+	binary.BigEndian.PutUint16(data[10:12], p.flags)
+	copy(data[12:16], p.ciaddr.To4())
+	copy(data[16:20], p.yiaddr.To4())
+	copy(data[20:24], p.siaddr.To4())
+	copy(data[24:28], p.giaddr.To4())
+	copy(data[28:44], p.chaddr)
+	data = append(data, magicCookie...)
+
+	// the message type goes first, some clients expect it there
+	if v, ok := p.options[optionMessageType]; ok {
+		data = append(data, optionMessageType, byte(len(v)))
+		data = append(data, v...)
+	}
+	for code := 1; code < optionEnd; code++ {
+		v, ok := p.options[byte(code)]
+		if !ok || code == optionMessageType {
+			continue
+		}
+		for len(v) > 0 {
+			n := min(len(v), 255)
+			data = append(data, byte(code), byte(n))
+			data = append(data, v[:n]...)
+			v = v[n:]
+		}
+	}
+	data = append(data, optionEnd)
+
+	// pad to the minimum BOOTP message size
+	for len(data) < 300 {
+		data = append(data, optionPad)
+	}
+	return data
+}
+
+func uint32Option(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func ipsOption(ips []net.IP) []byte {
+	var v []byte
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			v = append(v, ip4...)
+		}
+	}
+	return v
+}
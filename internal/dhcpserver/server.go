@@ -0,0 +1,329 @@
+// Package dhcpserver is a minimal DHCPv4 server for point-to-point links such
+// as the USB network gadget, where a handful of clients share a small subnet
+// with the device.
+package dhcpserver
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultLeaseTime = time.Hour
+
+	// offerTimeout is how long an offered address is held for the client
+	offerTimeout = time.Minute
+	serverPort   = 67
+	clientPort   = 68
+)
+
+var defaultLogger = logging.GetSubsystemLogger("dhcpserver")
+
+// Options configures a Server.
+type Options struct {
+	// Interface the server listens on, required to answer broadcasts
+	Interface string
+	// ServerIP is the device's address on the link, it must be inside Subnet
+	ServerIP net.IP
+	Subnet   *net.IPNet
+	// Router is handed out as the default gateway if set
+	Router    net.IP
+	DNS       []net.IP
+	LeaseTime time.Duration
+	Logger    *zerolog.Logger
+}
+
+// Lease is an address handed out to a client.
+type Lease struct {
+	MAC      string    `json:"mac"`
+	IP       string    `json:"ip"`
+	Hostname string    `json:"hostname,omitempty"`
+	Expiry   time.Time `json:"expiry"`
+
+	ip    net.IP
+	bound bool
+}
+
+type Server struct {
+	opts *Options
+	l    *zerolog.Logger
+
+	lock   sync.Mutex
+	leases map[string]*Lease
+	conn   net.PacketConn
+	closed bool
+	// now is replaced in tests
+	now func() time.Time
+}
+
+func NewServer(opts *Options) (*Server, error) {
+	if opts.Subnet == nil || opts.Subnet.IP.To4() == nil {
+		return nil, fmt.Errorf("an IPv4 subnet is required")
+	}
+	mask := opts.Subnet.Mask
+	if len(mask) == net.IPv6len {
+		mask = mask[12:]
+	}
+	opts.Subnet = &net.IPNet{IP: opts.Subnet.IP.To4().Mask(mask), Mask: mask}
+	if opts.ServerIP.To4() == nil || !opts.Subnet.Contains(opts.ServerIP) {
+		return nil, fmt.Errorf("server address %s is not in %s", opts.ServerIP, opts.Subnet)
+	}
+	if ones, bits := opts.Subnet.Mask.Size(); bits-ones < 2 {
+		return nil, fmt.Errorf("subnet %s is too small", opts.Subnet)
+	}
+	if opts.LeaseTime <= 0 {
+		opts.LeaseTime = DefaultLeaseTime
+	}
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+
+	l := opts.Logger.With().Str("interface", opts.Interface).Logger()
+	return &Server{
+		opts:   opts,
+		l:      &l,
+		leases: make(map[string]*Lease),
+		now:    time.Now,
+	}, nil
+}
+
+// ListenAndServe answers DHCP requests on the interface until Close is called.
+func (s *Server) ListenAndServe() error {
+	conn, err := listen(s.opts.Interface)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.opts.Interface, err)
+	}
+
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		conn.Close()
+		return nil
+	}
+	s.conn = conn
+	s.lock.Unlock()
+
+	s.l.Info().Str("subnet", s.opts.Subnet.String()).Msg("DHCP server started")
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			s.lock.Lock()
+			closed := s.closed
+			s.lock.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		req, err := parsePacket(buf[:n])
+		if err != nil || req.op != opRequest {
+			continue
+		}
+		resp := s.handle(req)
+		if resp == nil {
+			continue
+		}
+		if _, err := conn.WriteTo(resp.marshal(), s.replyAddr(req)); err != nil {
+			s.l.Warn().Err(err).Msg("failed to send DHCP reply")
+		}
+	}
+}
+
+func (s *Server) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// Leases returns the bound leases that haven't expired.
+func (s *Server) Leases() []Lease {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	leases := make([]Lease, 0, len(s.leases))
+	for _, lease := range s.leases {
+		if lease.bound && lease.Expiry.After(now) {
+			leases = append(leases, *lease)
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].IP < leases[j].IP })
+	return leases
+}
+
+// replyAddr is where the reply to req goes. Clients without an address can't
+// receive unicasts until they're configured, so they get a broadcast.
+func (s *Server) replyAddr(req *packet) net.Addr {
+	if !req.ciaddr.Equal(net.IPv4zero) {
+		return &net.UDPAddr{IP: req.ciaddr, Port: clientPort}
+	}
+	return &net.UDPAddr{IP: net.IPv4bcast, Port: clientPort}
+}
+
+// handle returns the reply to req, or nil if there's none.
+func (s *Server) handle(req *packet) *packet {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// relayed requests come from other links
+	if !req.giaddr.Equal(net.IPv4zero) {
+		return nil
+	}
+
+	mac := req.chaddr.String()
+	switch req.messageType() {
+	case msgDiscover:
+		ip := s.allocate(mac, req.ipOption(optionRequestedIP))
+		if ip == nil {
+			s.l.Warn().Str("mac", mac).Msg("no free address to offer")
+			return nil
+		}
+		s.leases[mac] = &Lease{MAC: mac, IP: ip.String(), Expiry: s.now().Add(offerTimeout), ip: ip}
+		return s.ack(req, msgOffer, ip)
+
+	case msgRequest:
+		if serverID := req.ipOption(optionServerID); serverID != nil && !serverID.Equal(s.opts.ServerIP) {
+			// the client picked another server's offer
+			if lease, ok := s.leases[mac]; ok && !lease.bound {
+				delete(s.leases, mac)
+			}
+			return nil
+		}
+
+		ip := req.ipOption(optionRequestedIP)
+		if ip == nil {
+			ip = req.ciaddr
+		}
+		if !s.available(mac, ip) {
+			s.l.Info().Str("mac", mac).Str("ip", ip.String()).Msg("rejecting DHCP request")
+			return req.reply(msgNak, s.opts.ServerIP)
+		}
+
+		hostname := string(req.options[optionHostname])
+		s.leases[mac] = &Lease{
+			MAC:      mac,
+			IP:       ip.String(),
+			Hostname: hostname,
+			Expiry:   s.now().Add(s.opts.LeaseTime),
+			ip:       ip,
+			bound:    true,
+		}
+		s.l.Info().Str("mac", mac).Str("ip", ip.String()).Str("hostname", hostname).Msg("DHCP lease bound")
+		return s.ack(req, msgAck, ip)
+
+	case msgInform:
+		// the client has an address already and only wants the configuration
+		resp := s.ack(req, msgAck, nil)
+		resp.ciaddr = req.ciaddr
+		delete(resp.options, optionLeaseTime)
+		delete(resp.options, optionRenewalTime)
+		delete(resp.options, optionRebindTime)
+		return resp
+
+	case msgRelease:
+		if lease, ok := s.leases[mac]; ok && lease.ip.Equal(req.ciaddr) {
+			delete(s.leases, mac)
+		}
+
+	case msgDecline:
+		// someone else uses the address, keep it out of the pool for a while
+		if ip := req.ipOption(optionRequestedIP); ip != nil {
+			delete(s.leases, mac)
+			s.leases["declined "+ip.String()] = &Lease{IP: ip.String(), Expiry: s.now().Add(s.opts.LeaseTime), ip: ip}
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) ack(req *packet, t messageType, ip net.IP) *packet {
+	resp := req.reply(t, s.opts.ServerIP)
+	if ip != nil {
+		resp.yiaddr = ip
+	}
+
+	leaseTime := uint32(s.opts.LeaseTime / time.Second)
+	resp.options[optionLeaseTime] = uint32Option(leaseTime)
+	resp.options[optionRenewalTime] = uint32Option(leaseTime / 2)
+	resp.options[optionRebindTime] = uint32Option(leaseTime * 7 / 8)
+	resp.options[optionSubnetMask] = []byte(s.opts.Subnet.Mask)
+	resp.options[optionBroadcast] = broadcastAddress(s.opts.Subnet).To4()
+	if s.opts.Router != nil {
+		resp.options[optionRouter] = s.opts.Router.To4()
+	}
+	if dns := ipsOption(s.opts.DNS); len(dns) > 0 {
+		resp.options[optionDNS] = dns
+	}
+	return resp
+}
+
+// available reports whether ip can be leased to mac, it's called with the lock held.
+func (s *Server) available(mac string, ip net.IP) bool {
+	ip = ip.To4()
+	if ip == nil || !s.inPool(ip) {
+		return false
+	}
+	now := s.now()
+	for owner, lease := range s.leases {
+		if owner != mac && lease.ip.Equal(ip) && lease.Expiry.After(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// allocate picks the address to offer to mac: its current one, the one it
+// asked for, or the first free one.
+func (s *Server) allocate(mac string, requested net.IP) net.IP {
+	if lease, ok := s.leases[mac]; ok && s.available(mac, lease.ip) {
+		return lease.ip
+	}
+	if requested != nil && s.available(mac, requested) {
+		return requested.To4()
+	}
+
+	network := binary.BigEndian.Uint32(s.opts.Subnet.IP.To4())
+	broadcast := binary.BigEndian.Uint32(broadcastAddress(s.opts.Subnet))
+	for i := network + 1; i < broadcast; i++ {
+		ip := binary.BigEndian.AppendUint32(nil, i)
+		if s.available(mac, ip) {
+			return net.IP(ip)
+		}
+	}
+	return nil
+}
+
+func (s *Server) inPool(ip net.IP) bool {
+	return s.opts.Subnet.Contains(ip) &&
+		!ip.Equal(s.opts.ServerIP) &&
+		!ip.Equal(s.opts.Subnet.IP) &&
+		!ip.Equal(broadcastAddress(s.opts.Subnet))
+}
+
+func broadcastAddress(subnet *net.IPNet) net.IP {
+	ip := subnet.IP.To4()
+	mask := net.IP(subnet.Mask).To4()
+	if ip == nil || mask == nil {
+		return nil
+	}
+	b := make(net.IP, 4)
+	for i := range b {
+		b[i] = ip[i] | ^mask[i]
+	}
+	return b
+}
@@ -0,0 +1,158 @@
+package dhcpserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *Server {
+	_, subnet, err := net.ParseCIDR("10.55.0.0/29")
+	require.NoError(t, err)
+	s, err := NewServer(&Options{
+		Interface: "usb0",
+		ServerIP:  net.ParseIP("10.55.0.1"),
+		Subnet:    subnet,
+		DNS:       []net.IP{net.ParseIP("10.55.0.1")},
+		LeaseTime: time.Hour,
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func clientPacket(t messageType, mac string, options map[byte][]byte) *packet {
+	hw, _ := net.ParseMAC(mac)
+	p := &packet{
+		op:      opRequest,
+		xid:     0x1234,
+		ciaddr:  net.IPv4zero,
+		yiaddr:  net.IPv4zero,
+		siaddr:  net.IPv4zero,
+		giaddr:  net.IPv4zero,
+		chaddr:  hw,
+		options: map[byte][]byte{optionMessageType: {byte(t)}},
+	}
+	for k, v := range options {
+		p.options[k] = v
+	}
+	return p
+}
+
+// roundTrip sends the packet through the wire format like the server does.
+func roundTrip(t *testing.T, p *packet) *packet {
+	parsed, err := parsePacket(p.marshal())
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestPacket(t *testing.T) {
+	p := clientPacket(msgRequest, "02:00:00:00:00:01", map[byte][]byte{
+		optionRequestedIP: net.ParseIP("10.55.0.2").To4(),
+		optionHostname:    []byte("host"),
+	})
+	parsed := roundTrip(t, p)
+	assert.Equal(t, msgRequest, parsed.messageType())
+	assert.Equal(t, uint32(0x1234), parsed.xid)
+	assert.Equal(t, "02:00:00:00:00:01", parsed.chaddr.String())
+	assert.Equal(t, "10.55.0.2", parsed.ipOption(optionRequestedIP).String())
+	assert.Equal(t, "host", string(parsed.options[optionHostname]))
+
+	_, err := parsePacket([]byte{1, 2, 3})
+	assert.ErrorIs(t, err, errInvalidPacket)
+}
+
+func TestServer(t *testing.T) {
+	s := newTestServer(t)
+
+	// discover, offer, request, ack
+	offer := s.handle(roundTrip(t, clientPacket(msgDiscover, "02:00:00:00:00:01", nil)))
+	require.NotNil(t, offer)
+	offer = roundTrip(t, offer)
+	assert.Equal(t, msgOffer, offer.messageType())
+	assert.Equal(t, "10.55.0.2", offer.yiaddr.String())
+	assert.Equal(t, "10.55.0.1", offer.ipOption(optionServerID).String())
+	assert.Equal(t, []byte{255, 255, 255, 248}, offer.options[optionSubnetMask])
+	assert.Equal(t, "10.55.0.7", offer.ipOption(optionBroadcast).String())
+	assert.Nil(t, offer.options[optionRouter])
+	assert.Empty(t, s.Leases())
+
+	ack := s.handle(roundTrip(t, clientPacket(msgRequest, "02:00:00:00:00:01", map[byte][]byte{
+		optionRequestedIP: offer.yiaddr.To4(),
+		optionServerID:    offer.ipOption(optionServerID),
+		optionHostname:    []byte("host1"),
+	})))
+	require.NotNil(t, ack)
+	assert.Equal(t, msgAck, ack.messageType())
+	assert.Equal(t, "10.55.0.2", ack.yiaddr.String())
+
+	leases := s.Leases()
+	require.Len(t, leases, 1)
+	assert.Equal(t, "host1", leases[0].Hostname)
+
+	// a second client gets the next address and can't take the first one
+	offer = s.handle(roundTrip(t, clientPacket(msgDiscover, "02:00:00:00:00:02", map[byte][]byte{
+		optionRequestedIP: net.ParseIP("10.55.0.2").To4(),
+	})))
+	require.NotNil(t, offer)
+	assert.Equal(t, "10.55.0.3", offer.yiaddr.String())
+
+	nak := s.handle(roundTrip(t, clientPacket(msgRequest, "02:00:00:00:00:02", map[byte][]byte{
+		optionRequestedIP: net.ParseIP("10.55.0.2").To4(),
+	})))
+	require.NotNil(t, nak)
+	assert.Equal(t, msgNak, nak.messageType())
+
+	// requests for other servers are ignored
+	assert.Nil(t, s.handle(roundTrip(t, clientPacket(msgRequest, "02:00:00:00:00:02", map[byte][]byte{
+		optionRequestedIP: net.ParseIP("10.55.0.3").To4(),
+		optionServerID:    net.ParseIP("10.55.0.5").To4(),
+	}))))
+
+	// the first client renews its address
+	renew := clientPacket(msgRequest, "02:00:00:00:00:01", nil)
+	renew.ciaddr = net.ParseIP("10.55.0.2").To4()
+	ack = s.handle(roundTrip(t, renew))
+	require.NotNil(t, ack)
+	assert.Equal(t, msgAck, ack.messageType())
+	assert.Equal(t, "10.55.0.2:68", s.replyAddr(renew).String())
+
+	// released addresses are handed out again
+	release := clientPacket(msgRelease, "02:00:00:00:00:01", nil)
+	release.ciaddr = net.ParseIP("10.55.0.2").To4()
+	assert.Nil(t, s.handle(roundTrip(t, release)))
+	assert.Empty(t, s.Leases())
+
+	// leases expire
+	ack = s.handle(roundTrip(t, clientPacket(msgRequest, "02:00:00:00:00:03", map[byte][]byte{
+		optionRequestedIP: net.ParseIP("10.55.0.4").To4(),
+	})))
+	require.NotNil(t, ack)
+	require.Len(t, s.Leases(), 1)
+	s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	assert.Empty(t, s.Leases())
+}
+
+func TestServerPoolExhausted(t *testing.T) {
+	s := newTestServer(t)
+
+	// a /29 has 5 addresses left after the network, broadcast and server
+	for i := 1; i <= 5; i++ {
+		mac := net.HardwareAddr{2, 0, 0, 0, 0, byte(i)}.String()
+		offer := s.handle(roundTrip(t, clientPacket(msgDiscover, mac, nil)))
+		require.NotNil(t, offer, "client %d", i)
+	}
+	assert.Nil(t, s.handle(roundTrip(t, clientPacket(msgDiscover, "02:00:00:00:00:06", nil))))
+}
+
+func TestNewServer(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.55.0.0/24")
+	_, err := NewServer(&Options{ServerIP: net.ParseIP("10.56.0.1"), Subnet: subnet})
+	assert.Error(t, err)
+
+	_, small, _ := net.ParseCIDR("10.55.0.0/31")
+	_, err = NewServer(&Options{ServerIP: net.ParseIP("10.55.0.1"), Subnet: small})
+	assert.Error(t, err)
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package dhcpserver
+
+import (
+	"errors"
+	"net"
+)
+
+func listen(_ string) (net.PacketConn, error) {
+	return nil, errors.New("DHCP server is only supported on Linux")
+}
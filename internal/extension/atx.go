@@ -0,0 +1,167 @@
+package extension
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ATXID is the ID of the ATX power extension.
+const ATXID = "atx-power"
+
+// ATX button press durations
+const (
+	ATXShortPress = 200 * time.Millisecond
+	ATXLongPress  = 5 * time.Second
+)
+
+// ATXState is the state of the host's front panel LEDs.
+type ATXState struct {
+	Power bool `json:"power"`
+	HDD   bool `json:"hdd"`
+}
+
+// ATX controls the host's power and reset buttons. The board sends its state
+// as four binary digits per line: HDD LED, power LED (both active low), reset
+// button and power button.
+type ATX struct {
+	lock   sync.Mutex
+	write  LineWriter
+	update func(state any)
+	state  ATXState
+	btnRST bool
+	btnPWR bool
+}
+
+func NewATX() *ATX {
+	return &ATX{}
+}
+
+func (a *ATX) Info() Info {
+	return Info{
+		ID:          ATXID,
+		Name:        "ATX power control",
+		Description: "Front panel power and reset buttons with power and HDD LEDs",
+		Commands: []Command{
+			{Name: "power-short", Description: "Short power button press"},
+			{Name: "power-long", Description: "Long power button press, forces the host off"},
+			{Name: "reset", Description: "Reset button press"},
+		},
+	}
+}
+
+func isATXLine(line string) bool {
+	if len(line) != 4 {
+		return false
+	}
+	for _, c := range line {
+		if c != '0' && c != '1' {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *ATX) Probe(line string) bool {
+	return isATXLine(line)
+}
+
+func (a *ATX) Mount(write LineWriter, update func(state any)) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.write = write
+	a.update = update
+	return nil
+}
+
+func (a *ATX) Unmount() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.write = nil
+	a.update = nil
+}
+
+func (a *ATX) State() any {
+	return a.ATXState()
+}
+
+// ATXState returns the LED states.
+func (a *ATX) ATXState() ATXState {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.state
+}
+
+func (a *ATX) HandleLine(line string) {
+	if !isATXLine(line) {
+		defaultLogger.Warn().Int("length", len(line)).Msg("Invalid ATX line")
+		return
+	}
+
+	state := ATXState{
+		HDD:   line[0] == '0',
+		Power: line[1] == '0',
+	}
+	btnRST := line[2] == '1'
+	btnPWR := line[3] == '1'
+
+	a.lock.Lock()
+	if state != a.state || btnRST != a.btnRST || btnPWR != a.btnPWR {
+		defaultLogger.Debug().
+			Bool("hdd", state.HDD).
+			Bool("pwr", state.Power).
+			Bool("btn_rst", btnRST).
+			Bool("btn_pwr", btnPWR).
+			Msg("ATX status changed")
+	}
+	a.state = state
+	a.btnRST = btnRST
+	a.btnPWR = btnPWR
+	update := a.update
+	a.lock.Unlock()
+
+	if update != nil {
+		update(state)
+	}
+}
+
+// Press holds the "PWR" or "RST" button for duration.
+func (a *ATX) Press(ctx context.Context, button string, duration time.Duration) error {
+	a.lock.Lock()
+	write := a.write
+	a.lock.Unlock()
+	if write == nil {
+		return fmt.Errorf("ATX extension not mounted")
+	}
+
+	if err := write(""); err != nil {
+		return err
+	}
+	if err := write("BTN_" + button + "_ON"); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		// never leave the button held
+	}
+	return write("BTN_" + button + "_OFF")
+}
+
+func (a *ATX) Execute(ctx context.Context, command string, _ map[string]any) (any, error) {
+	switch command {
+	case "power-short":
+		return nil, a.Press(ctx, "PWR", ATXShortPress)
+	case "power-long":
+		return nil, a.Press(ctx, "PWR", ATXLongPress)
+	case "reset":
+		return nil, a.Press(ctx, "RST", ATXShortPress)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCommand, command)
+}
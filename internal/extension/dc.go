@@ -0,0 +1,186 @@
+package extension
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DCID is the ID of the DC power extension.
+const DCID = "dc-power"
+
+// DC restore modes, what the board does when its own power comes back
+const (
+	DCRestoreUnsupported = -1
+	DCRestoreOff         = 0
+	DCRestoreOn          = 1
+	DCRestoreLastState   = 2
+)
+
+// DCPowerState is the state of the DC power board.
+type DCPowerState struct {
+	IsOn         bool    `json:"isOn"`
+	Voltage      float64 `json:"voltage"`
+	Current      float64 `json:"current"`
+	Power        float64 `json:"power"`
+	RestoreState int     `json:"restoreState"`
+}
+
+// DC switches the host's DC power. The board sends
+// "<on>;<mV>;<mA>;<mW>[;<restore mode>]" lines, the restore mode is only sent
+// by boards supporting it.
+type DC struct {
+	lock   sync.Mutex
+	write  LineWriter
+	update func(state any)
+	state  DCPowerState
+}
+
+func NewDC() *DC {
+	return &DC{state: DCPowerState{RestoreState: DCRestoreUnsupported}}
+}
+
+func (d *DC) Info() Info {
+	return Info{
+		ID:          DCID,
+		Name:        "DC power control",
+		Description: "DC power switch with voltage, current and power readings",
+		Commands: []Command{
+			{Name: "power-on", Description: "Turn the power on"},
+			{Name: "power-off", Description: "Turn the power off"},
+			{Name: "restore-mode", Description: "Set the power restore mode: 0 off, 1 on, 2 last state", Params: []string{"state"}},
+		},
+	}
+}
+
+// parseDCLine parses a state line of the DC board.
+func parseDCLine(line string) (DCPowerState, error) {
+	parts := strings.Split(strings.TrimSpace(line), ";")
+	if len(parts) != 4 && len(parts) != 5 {
+		return DCPowerState{}, fmt.Errorf("invalid line")
+	}
+
+	powerState, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return DCPowerState{}, fmt.Errorf("invalid power state: %w", err)
+	}
+	state := DCPowerState{IsOn: powerState == 1, RestoreState: DCRestoreUnsupported}
+	if len(parts) == 5 {
+		if state.RestoreState, err = strconv.Atoi(parts[4]); err != nil {
+			return DCPowerState{}, fmt.Errorf("invalid restore state: %w", err)
+		}
+	}
+
+	// the readings are in mV, mA and mW
+	values := make([]float64, 3)
+	for i, name := range []string{"voltage", "current", "power"} {
+		v, err := strconv.ParseFloat(parts[i+1], 64)
+		if err != nil {
+			return DCPowerState{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		values[i] = v / 1000
+	}
+	state.Voltage = values[0]
+	state.Current = values[1]
+	state.Power = values[2]
+	return state, nil
+}
+
+func (d *DC) Probe(line string) bool {
+	_, err := parseDCLine(line)
+	return err == nil
+}
+
+func (d *DC) Mount(write LineWriter, update func(state any)) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.write = write
+	d.update = update
+	return nil
+}
+
+func (d *DC) Unmount() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.write = nil
+	d.update = nil
+}
+
+func (d *DC) State() any {
+	return d.DCPowerState()
+}
+
+// DCPowerState returns the last state reported by the board.
+func (d *DC) DCPowerState() DCPowerState {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.state
+}
+
+func (d *DC) HandleLine(line string) {
+	state, err := parseDCLine(line)
+	if err != nil {
+		defaultLogger.Warn().Err(err).Str("line", line).Msg("Invalid DC line")
+		return
+	}
+
+	d.lock.Lock()
+	d.state = state
+	update := d.update
+	d.lock.Unlock()
+
+	if update != nil {
+		update(state)
+	}
+}
+
+func (d *DC) send(command string) error {
+	d.lock.Lock()
+	write := d.write
+	d.lock.Unlock()
+	if write == nil {
+		return fmt.Errorf("DC extension not mounted")
+	}
+
+	if err := write(""); err != nil {
+		return err
+	}
+	return write(command)
+}
+
+func (d *DC) SetPower(on bool) error {
+	if on {
+		return d.send("PWR_ON")
+	}
+	return d.send("PWR_OFF")
+}
+
+func (d *DC) SetRestoreState(state int) error {
+	switch state {
+	case DCRestoreOn:
+		return d.send("RESTORE_MODE_ON")
+	case DCRestoreLastState:
+		return d.send("RESTORE_MODE_LAST_STATE")
+	}
+	return d.send("RESTORE_MODE_OFF")
+}
+
+func (d *DC) Execute(_ context.Context, command string, params map[string]any) (any, error) {
+	switch command {
+	case "power-on":
+		return nil, d.SetPower(true)
+	case "power-off":
+		return nil, d.SetPower(false)
+	case "restore-mode":
+		state, ok := params["state"].(float64)
+		if !ok {
+			return nil, fmt.Errorf("state must be a number")
+		}
+		return nil, d.SetRestoreState(int(state))
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCommand, command)
+}
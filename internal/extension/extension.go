@@ -0,0 +1,247 @@
+// Package extension runs accessories connected to the serial port of the
+// extension connector, e.g. the ATX and DC power boards.
+//
+// Every accessory is an Extension registered in a Registry. The active
+// extension gets the lines read from the port and can write commands back;
+// it reports its state through the update function it's mounted with.
+//
+// Boards without a built-in extension can use the generic protocol, see
+// Generic. An accessory can be detected by its output: built-in extensions
+// recognize the lines their boards send on their own, generic boards answer
+// the hello request the host sends while detecting.
+package extension
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/rs/zerolog"
+)
+
+// HelloRequest is sent to the port while detecting, generic boards answer it
+// with their hello.
+const HelloRequest = "JETKVM_HELLO"
+
+var (
+	ErrUnknownExtension = errors.New("unknown extension")
+	ErrUnknownCommand   = errors.New("unknown command")
+	ErrNotDetected      = errors.New("no extension detected")
+)
+
+var defaultLogger = logging.GetSubsystemLogger("extension")
+
+// Command describes a command an extension accepts.
+type Command struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Params      []string `json:"params,omitempty"`
+}
+
+// Info describes an extension.
+type Info struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Commands    []Command `json:"commands"`
+}
+
+// LineWriter sends a line to the board, the newline is added.
+type LineWriter func(line string) error
+
+// Extension is an accessory on the serial port.
+type Extension interface {
+	Info() Info
+	// Probe reports whether line, read from a board that hasn't been
+	// identified yet, was sent by this extension's board.
+	Probe(line string) bool
+	// Mount is called when the extension becomes active. Commands are sent
+	// with write, state changes are reported with update.
+	Mount(write LineWriter, update func(state any)) error
+	Unmount()
+	// HandleLine is called with every line read from the board, without the
+	// line ending.
+	HandleLine(line string)
+	State() any
+	Execute(ctx context.Context, command string, params map[string]any) (any, error)
+}
+
+// Registry holds the available extensions and runs the active one.
+type Registry struct {
+	lock       sync.Mutex
+	extensions map[string]Extension
+	active     Extension
+	// stop ends the read loop of the active extension
+	stop chan struct{}
+	l    *zerolog.Logger
+}
+
+func NewRegistry(logger *zerolog.Logger) *Registry {
+	if logger == nil {
+		logger = defaultLogger
+	}
+	return &Registry{extensions: make(map[string]Extension), l: logger}
+}
+
+func (r *Registry) Register(ext Extension) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	id := ext.Info().ID
+	if id == "" {
+		return fmt.Errorf("extension id can't be empty")
+	}
+	if _, ok := r.extensions[id]; ok {
+		return fmt.Errorf("extension %s is already registered", id)
+	}
+	r.extensions[id] = ext
+	return nil
+}
+
+func (r *Registry) Get(id string) (Extension, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ext, ok := r.extensions[id]
+	return ext, ok
+}
+
+// List returns the registered extensions sorted by ID.
+func (r *Registry) List() []Info {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	infos := make([]Info, 0, len(r.extensions))
+	for _, ext := range r.extensions {
+		infos = append(infos, ext.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Active returns the mounted extension, nil if there's none.
+func (r *Registry) Active() Extension {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.active
+}
+
+// Mount activates the extension id on port, unmounting the active one. The
+// lines read from port are handed to the extension until it's unmounted or
+// reading fails.
+func (r *Registry) Mount(id string, port io.ReadWriter, update func(state any)) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ext, ok := r.extensions[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownExtension, id)
+	}
+	r.unmountLocked()
+
+	var writeLock sync.Mutex
+	write := func(line string) error {
+		writeLock.Lock()
+		defer writeLock.Unlock()
+		_, err := io.WriteString(port, line+"\n")
+		return err
+	}
+	if err := ext.Mount(write, update); err != nil {
+		return fmt.Errorf("failed to mount extension %s: %w", id, err)
+	}
+
+	stop := make(chan struct{})
+	r.active = ext
+	r.stop = stop
+	go r.run(ext, port, stop)
+
+	r.l.Info().Str("extension", id).Msg("extension mounted")
+	return nil
+}
+
+// Unmount deactivates the active extension. The read loop ends with the next
+// read, the caller usually closes the port to end it right away.
+func (r *Registry) Unmount() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.unmountLocked()
+}
+
+func (r *Registry) unmountLocked() {
+	if r.active == nil {
+		return
+	}
+	close(r.stop)
+	r.active.Unmount()
+	r.l.Info().Str("extension", r.active.Info().ID).Msg("extension unmounted")
+	r.active = nil
+	r.stop = nil
+}
+
+func (r *Registry) run(ext Extension, port io.Reader, stop chan struct{}) {
+	l := r.l.With().Str("extension", ext.Info().ID).Logger()
+	reader := bufio.NewReader(port)
+	for {
+		line, err := reader.ReadString('\n')
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if err != nil {
+			l.Warn().Err(err).Msg("Error reading from serial port")
+			return
+		}
+		ext.HandleLine(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// Detect identifies the board sending lines by asking every extension to
+// probe them, until ctx is done. It sends the hello request with write first.
+func (r *Registry) Detect(ctx context.Context, write LineWriter, lines <-chan string) (string, error) {
+	r.lock.Lock()
+	extensions := make([]Extension, 0, len(r.extensions))
+	for _, ext := range r.extensions {
+		extensions = append(extensions, ext)
+	}
+	r.lock.Unlock()
+	// built-in extensions get the first look, the generic one accepts any hello
+	sort.Slice(extensions, func(i, j int) bool {
+		_, gi := extensions[i].(*Generic)
+		_, gj := extensions[j].(*Generic)
+		if gi != gj {
+			return gj
+		}
+		return extensions[i].Info().ID < extensions[j].Info().ID
+	})
+
+	if err := write(""); err != nil {
+		return "", fmt.Errorf("failed to send hello request: %w", err)
+	}
+	if err := write(HelloRequest); err != nil {
+		return "", fmt.Errorf("failed to send hello request: %w", err)
+	}
+
+	for {
+		select {
+		case line := <-lines:
+			line = strings.TrimRight(line, "\r\n")
+			for _, ext := range extensions {
+				if ext.Probe(line) {
+					return ext.Info().ID, nil
+				}
+			}
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return "", ErrNotDetected
+			}
+			return "", ctx.Err()
+		}
+	}
+}
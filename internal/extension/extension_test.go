@@ -0,0 +1,262 @@
+package extension
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lineRecorder collects the lines written to a board.
+type lineRecorder struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (r *lineRecorder) write(line string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.lines = append(r.lines, line)
+	return nil
+}
+
+func (r *lineRecorder) Lines() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.lines...)
+}
+
+func newTestRegistry(t *testing.T) *Registry {
+	r := NewRegistry(nil)
+	require.NoError(t, r.Register(NewATX()))
+	require.NoError(t, r.Register(NewDC()))
+	require.NoError(t, r.Register(NewGeneric()))
+	return r
+}
+
+func TestRegistry(t *testing.T) {
+	r := newTestRegistry(t)
+	assert.Error(t, r.Register(NewATX()))
+
+	var ids []string
+	for _, info := range r.List() {
+		ids = append(ids, info.ID)
+	}
+	assert.Equal(t, []string{ATXID, DCID, GenericID}, ids)
+
+	assert.ErrorIs(t, r.Mount("nope", nil, nil), ErrUnknownExtension)
+	assert.Nil(t, r.Active())
+}
+
+func TestRegistryMount(t *testing.T) {
+	r := newTestRegistry(t)
+
+	boardReader, hostWriter := io.Pipe()
+	hostReader, boardWriter := io.Pipe()
+	port := struct {
+		io.Reader
+		io.Writer
+	}{hostReader, hostWriter}
+
+	written := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := boardReader.Read(buf)
+			if err != nil {
+				return
+			}
+			written <- string(buf[:n])
+		}
+	}()
+
+	states := make(chan any, 16)
+	require.NoError(t, r.Mount(DCID, port, func(state any) { states <- state }))
+	assert.Equal(t, DCID, r.Active().Info().ID)
+
+	_, err := io.WriteString(boardWriter, "1;12000;500;6000;2\r\n")
+	require.NoError(t, err)
+	select {
+	case state := <-states:
+		assert.Equal(t, DCPowerState{IsOn: true, Voltage: 12, Current: 0.5, Power: 6, RestoreState: DCRestoreLastState}, state)
+	case <-time.After(time.Second):
+		t.Fatal("no state reported")
+	}
+
+	ext, _ := r.Get(DCID)
+	go func() {
+		_ = ext.(*DC).SetPower(false)
+	}()
+	var sent strings.Builder
+	for !strings.Contains(sent.String(), "PWR_OFF\n") {
+		select {
+		case data := <-written:
+			sent.WriteString(data)
+		case <-time.After(time.Second):
+			t.Fatalf("command not written, got %q", sent.String())
+		}
+	}
+	assert.Equal(t, "\nPWR_OFF\n", sent.String())
+
+	r.Unmount()
+	assert.Nil(t, r.Active())
+	assert.Error(t, ext.(*DC).SetPower(true))
+	boardWriter.Close()
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{name: "atx", lines: []string{"garbage", "1000"}, want: ATXID},
+		{name: "dc", lines: []string{"0;5000;0;0"}, want: DCID},
+		{name: "generic text", lines: []string{"HELLO relay4 Relay board"}, want: GenericID},
+		{name: "generic json", lines: []string{`{"type":"hello","id":"relay4"}`}, want: GenericID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRegistry(t)
+			lines := make(chan string, len(tt.lines))
+			for _, l := range tt.lines {
+				lines <- l
+			}
+			rec := &lineRecorder{}
+			id, err := r.Detect(context.Background(), rec.write, lines)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, id)
+			assert.Equal(t, []string{"", HelloRequest}, rec.Lines())
+		})
+	}
+
+	r := newTestRegistry(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := r.Detect(ctx, (&lineRecorder{}).write, make(chan string))
+	assert.ErrorIs(t, err, ErrNotDetected)
+}
+
+func TestATX(t *testing.T) {
+	a := NewATX()
+	assert.True(t, a.Probe("0110"))
+	assert.False(t, a.Probe("011"))
+	assert.False(t, a.Probe("0120"))
+
+	_, err := a.Execute(context.Background(), "reset", nil)
+	assert.Error(t, err)
+
+	rec := &lineRecorder{}
+	var states []any
+	require.NoError(t, a.Mount(rec.write, func(state any) { states = append(states, state) }))
+	// the LEDs are active low
+	a.HandleLine("0000")
+	assert.Equal(t, ATXState{Power: true, HDD: true}, a.ATXState())
+	assert.Len(t, states, 1)
+
+	require.NoError(t, a.Press(context.Background(), "RST", time.Millisecond))
+	assert.Equal(t, []string{"", "BTN_RST_ON", "BTN_RST_OFF"}, rec.Lines())
+
+	_, err = a.Execute(context.Background(), "nope", nil)
+	assert.ErrorIs(t, err, ErrUnknownCommand)
+}
+
+func TestParseDCLine(t *testing.T) {
+	state, err := parseDCLine("1;12000;500;6000")
+	require.NoError(t, err)
+	assert.Equal(t, DCPowerState{IsOn: true, Voltage: 12, Current: 0.5, Power: 6, RestoreState: DCRestoreUnsupported}, state)
+
+	for _, line := range []string{"", "1;2;3", "x;1;2;3", "1;a;2;3", "1;2;3;4;x"} {
+		_, err := parseDCLine(line)
+		assert.Error(t, err, line)
+	}
+}
+
+func TestGenericText(t *testing.T) {
+	g := NewGeneric()
+	rec := &lineRecorder{}
+	states := make(chan any, 4)
+	require.NoError(t, g.Mount(rec.write, func(state any) { states <- state }))
+	assert.Equal(t, []string{HelloRequest}, rec.Lines())
+
+	g.HandleLine("HELLO relay4 Relay board")
+	g.HandleLine("CMD set channel on")
+	g.HandleLine("# debug output")
+	g.HandleLine("STATE ch1=true volts=3.3 mode=auto")
+
+	info := g.Info()
+	assert.Equal(t, "Relay board", info.Name)
+	assert.Equal(t, []Command{{Name: "set", Params: []string{"channel", "on"}}}, info.Commands)
+	assert.Equal(t, GenericState{
+		BoardID:   "relay4",
+		BoardName: "Relay board",
+		Values:    map[string]any{"ch1": true, "volts": 3.3, "mode": "auto"},
+	}, g.State())
+	assert.Len(t, states, 2)
+
+	_, err := g.Execute(context.Background(), "nope", nil)
+	assert.ErrorIs(t, err, ErrUnknownCommand)
+
+	done := make(chan error, 1)
+	go func() {
+		result, err := g.Execute(context.Background(), "set", map[string]any{"on": true, "channel": 1})
+		if err == nil {
+			assert.Equal(t, "switched", result)
+		}
+		done <- err
+	}()
+	require.Eventually(t, func() bool {
+		lines := rec.Lines()
+		return lines[len(lines)-1] == "set channel=1 on=true"
+	}, time.Second, time.Millisecond)
+	g.HandleLine("OK switched")
+	require.NoError(t, <-done)
+
+	go func() {
+		_, err := g.Execute(context.Background(), "set", map[string]any{"channel": 9})
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return len(rec.Lines()) == 3 }, time.Second, time.Millisecond)
+	g.HandleLine("ERR no such channel")
+	assert.EqualError(t, <-done, "no such channel")
+
+	_, err = g.Execute(context.Background(), "set", map[string]any{"channel": "a b"})
+	assert.Error(t, err)
+}
+
+func TestGenericJSON(t *testing.T) {
+	g := NewGeneric()
+	g.CommandTimeout = 50 * time.Millisecond
+	rec := &lineRecorder{}
+	require.NoError(t, g.Mount(rec.write, nil))
+
+	g.HandleLine(`{"type":"hello","id":"fan","commands":[{"name":"speed","params":["rpm"]}]}`)
+	g.HandleLine(`{"type":"state","state":{"rpm":1200}}`)
+	assert.Equal(t, "fan", g.Info().Name)
+	assert.Equal(t, map[string]any{"rpm": float64(1200)}, g.State().(GenericState).Values)
+
+	done := make(chan any, 1)
+	go func() {
+		result, err := g.Execute(context.Background(), "speed", map[string]any{"rpm": 800})
+		assert.NoError(t, err)
+		done <- result
+	}()
+	require.Eventually(t, func() bool { return len(rec.Lines()) == 2 }, time.Second, time.Millisecond)
+	assert.JSONEq(t, `{"type":"command","id":1,"name":"speed","params":{"rpm":800}}`, rec.Lines()[1])
+	// replies to other commands are ignored
+	g.HandleLine(`{"type":"result","id":7,"result":"stale"}`)
+	g.HandleLine(`{"type":"result","id":1,"result":{"rpm":800}}`)
+	assert.Equal(t, map[string]any{"rpm": float64(800)}, <-done)
+
+	_, err := g.Execute(context.Background(), "speed", nil)
+	assert.Error(t, err)
+
+	g.Unmount()
+	_, err = g.Execute(context.Background(), "speed", nil)
+	assert.Error(t, err)
+}
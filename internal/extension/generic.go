@@ -0,0 +1,392 @@
+package extension
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GenericID is the ID of the generic protocol extension.
+const GenericID = "generic"
+
+// DefaultCommandTimeout is how long Generic waits for a board to answer a command.
+const DefaultCommandTimeout = 5 * time.Second
+
+// Generic speaks the generic accessory protocol. It's line based at 115200
+// baud, 8N1, and every line is either plain text or a JSON object. A board
+// picks one framing in its hello and sticks to it.
+//
+// Text framing, board to host:
+//
+//	HELLO <id> <name>         identifies the board, the name may contain spaces
+//	CMD <name> [<param> ...]  declares a command, sent after the hello
+//	STATE <key>=<value> ...   reports state, numbers and true/false are typed
+//	OK [<message>]            the last command succeeded
+//	ERR <message>             the last command failed
+//	# <text>                  ignored, e.g. debug output
+//
+// Host to board, a command with its parameters:
+//
+//	<name> [<param>=<value> ...]
+//
+// JSON framing, board to host:
+//
+//	{"type":"hello","id":"relay4","name":"Relay board","commands":[{"name":"set","params":["channel","on"]}]}
+//	{"type":"state","state":{"channel1":true}}
+//	{"type":"result","id":1,"result":...}  or  {"type":"result","id":1,"error":"..."}
+//
+// Host to board:
+//
+//	{"type":"command","id":1,"name":"set","params":{"channel":1,"on":true}}
+//
+// The host sends JETKVM_HELLO to ask for the hello, when detecting and when
+// the extension is mounted. Every command has to be answered within 5 seconds.
+type Generic struct {
+	lock     sync.Mutex
+	write    LineWriter
+	update   func(state any)
+	hello    *genericHello
+	jsonMode bool
+	values   map[string]any
+	// pending is the reply channel of the command in flight
+	pending   chan genericResult
+	pendingID int
+	nextID    int
+	// CommandTimeout overrides DefaultCommandTimeout
+	CommandTimeout time.Duration
+}
+
+type genericHello struct {
+	Type     string    `json:"type"`
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Commands []Command `json:"commands"`
+}
+
+type genericMessage struct {
+	Type   string          `json:"type"`
+	ID     int             `json:"id"`
+	State  map[string]any  `json:"state,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+type genericResult struct {
+	result any
+	err    error
+}
+
+// GenericState is the state of a board speaking the generic protocol.
+type GenericState struct {
+	BoardID   string         `json:"boardId,omitempty"`
+	BoardName string         `json:"boardName,omitempty"`
+	Values    map[string]any `json:"values"`
+}
+
+func NewGeneric() *Generic {
+	return &Generic{values: make(map[string]any)}
+}
+
+func (g *Generic) Info() Info {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	info := Info{
+		ID:          GenericID,
+		Name:        "Generic accessory",
+		Description: "Boards speaking the generic line or JSON protocol",
+		Commands:    []Command{},
+	}
+	if g.hello != nil {
+		info.Name = g.hello.Name
+		info.Commands = append(info.Commands, g.hello.Commands...)
+	}
+	return info
+}
+
+func (g *Generic) Probe(line string) bool {
+	_, _, err := parseGenericHello(line)
+	return err == nil
+}
+
+func (g *Generic) Mount(write LineWriter, update func(state any)) error {
+	g.lock.Lock()
+	g.write = write
+	g.update = update
+	g.values = make(map[string]any)
+	g.lock.Unlock()
+
+	return write(HelloRequest)
+}
+
+func (g *Generic) Unmount() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.write = nil
+	g.update = nil
+	if g.pending != nil {
+		g.pending <- genericResult{err: fmt.Errorf("extension unmounted")}
+		g.pending = nil
+	}
+}
+
+func (g *Generic) State() any {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.stateLocked()
+}
+
+func (g *Generic) stateLocked() GenericState {
+	state := GenericState{Values: make(map[string]any, len(g.values))}
+	if g.hello != nil {
+		state.BoardID = g.hello.ID
+		state.BoardName = g.hello.Name
+	}
+	for k, v := range g.values {
+		state.Values[k] = v
+	}
+	return state
+}
+
+// parseGenericHello parses a hello in either framing, the commands are only
+// known for JSON hellos.
+func parseGenericHello(line string) (*genericHello, bool, error) {
+	if strings.HasPrefix(line, "{") {
+		var hello genericHello
+		if err := json.Unmarshal([]byte(line), &hello); err != nil || hello.Type != "hello" || hello.ID == "" {
+			return nil, false, fmt.Errorf("not a hello")
+		}
+		if hello.Name == "" {
+			hello.Name = hello.ID
+		}
+		return &hello, true, nil
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 || fields[0] != "HELLO" {
+		return nil, false, fmt.Errorf("not a hello")
+	}
+	hello := &genericHello{ID: fields[1], Name: fields[1]}
+	if len(fields) > 2 {
+		hello.Name = strings.Join(fields[2:], " ")
+	}
+	return hello, false, nil
+}
+
+func (g *Generic) HandleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+
+	if hello, jsonMode, err := parseGenericHello(line); err == nil {
+		g.lock.Lock()
+		g.hello = hello
+		g.jsonMode = jsonMode
+		g.lock.Unlock()
+		g.notify()
+		return
+	}
+
+	if strings.HasPrefix(line, "{") {
+		g.handleJSON(line)
+		return
+	}
+
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case "CMD":
+		if len(fields) < 2 {
+			return
+		}
+		g.lock.Lock()
+		if g.hello != nil {
+			g.hello.Commands = append(g.hello.Commands, Command{Name: fields[1], Params: fields[2:]})
+		}
+		g.lock.Unlock()
+	case "STATE":
+		g.lock.Lock()
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if ok && key != "" {
+				g.values[key] = parseGenericValue(value)
+			}
+		}
+		g.lock.Unlock()
+		g.notify()
+	case "OK":
+		g.resolve(0, genericResult{result: strings.TrimSpace(strings.TrimPrefix(line, "OK"))})
+	case "ERR":
+		g.resolve(0, genericResult{err: fmt.Errorf("%s", strings.TrimSpace(strings.TrimPrefix(line, "ERR")))})
+	}
+}
+
+func (g *Generic) handleJSON(line string) {
+	var msg genericMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return
+	}
+	switch msg.Type {
+	case "state":
+		g.lock.Lock()
+		for k, v := range msg.State {
+			g.values[k] = v
+		}
+		g.lock.Unlock()
+		g.notify()
+	case "result":
+		if msg.Error != "" {
+			g.resolve(msg.ID, genericResult{err: fmt.Errorf("%s", msg.Error)})
+			return
+		}
+		var result any
+		if len(msg.Result) > 0 {
+			_ = json.Unmarshal(msg.Result, &result)
+		}
+		g.resolve(msg.ID, genericResult{result: result})
+	}
+}
+
+func parseGenericValue(value string) any {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n
+	}
+	return value
+}
+
+// notify reports the state after it changed.
+func (g *Generic) notify() {
+	g.lock.Lock()
+	update := g.update
+	state := g.stateLocked()
+	g.lock.Unlock()
+
+	if update != nil {
+		update(state)
+	}
+}
+
+// resolve hands a reply to the command in flight, id is only checked in JSON framing.
+func (g *Generic) resolve(id int, result genericResult) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.pending == nil || (g.jsonMode && id != g.pendingID) {
+		return
+	}
+	g.pending <- result
+	g.pending = nil
+}
+
+func (g *Generic) Execute(ctx context.Context, command string, params map[string]any) (any, error) {
+	g.lock.Lock()
+	if g.write == nil {
+		g.lock.Unlock()
+		return nil, fmt.Errorf("extension not mounted")
+	}
+	if g.hello == nil {
+		g.lock.Unlock()
+		return nil, fmt.Errorf("board hasn't identified itself yet")
+	}
+	known := false
+	for _, c := range g.hello.Commands {
+		if c.Name == command {
+			known = true
+			break
+		}
+	}
+	if !known {
+		g.lock.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCommand, command)
+	}
+	if g.pending != nil {
+		g.lock.Unlock()
+		return nil, fmt.Errorf("another command is in progress")
+	}
+
+	g.nextID++
+	id := g.nextID
+	line, err := formatGenericCommand(g.jsonMode, id, command, params)
+	if err != nil {
+		g.lock.Unlock()
+		return nil, err
+	}
+	reply := make(chan genericResult, 1)
+	g.pending = reply
+	g.pendingID = id
+	write := g.write
+	timeout := g.CommandTimeout
+	g.lock.Unlock()
+
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+	cancel := func() {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+		if g.pending == reply {
+			g.pending = nil
+		}
+	}
+
+	if err := write(line); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-reply:
+		return r.result, r.err
+	case <-timer.C:
+		cancel()
+		return nil, fmt.Errorf("board didn't answer %s", command)
+	case <-ctx.Done():
+		cancel()
+		return nil, ctx.Err()
+	}
+}
+
+func formatGenericCommand(jsonMode bool, id int, command string, params map[string]any) (string, error) {
+	if jsonMode {
+		data, err := json.Marshal(map[string]any{
+			"type":   "command",
+			"id":     id,
+			"name":   command,
+			"params": params,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to encode command: %w", err)
+		}
+		return string(data), nil
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{command}
+	for _, k := range keys {
+		value := fmt.Sprint(params[k])
+		if strings.ContainsAny(k+value, " =\r\n") {
+			return "", fmt.Errorf("parameter %s can't contain spaces, = or line breaks", k)
+		}
+		parts = append(parts, k+"="+value)
+	}
+	return strings.Join(parts, " "), nil
+}
@@ -15,10 +15,13 @@ const (
 	TypePointerReport             MessageType = 0x03
 	TypeWheelReport               MessageType = 0x04
 	TypeKeypressReport            MessageType = 0x05
-	TypeKeypressKeepAliveReport   MessageType = 0x09
 	TypeMouseReport               MessageType = 0x06
 	TypeKeyboardMacroReport       MessageType = 0x07
 	TypeCancelKeyboardMacroReport MessageType = 0x08
+	TypeKeypressKeepAliveReport   MessageType = 0x09
+	TypeRelWheelReport            MessageType = 0x0A
+	TypeConsumerControlReport     MessageType = 0x0B
+	TypeSystemControlReport       MessageType = 0x0C
 	TypeKeyboardLedState          MessageType = 0x32
 	TypeKeydownState              MessageType = 0x33
 	TypeKeyboardMacroState        MessageType = 0x34
@@ -35,10 +38,10 @@ func GetQueueIndex(messageType MessageType) int {
 		return 0
 	case TypeKeyboardReport, TypeKeypressReport, TypeKeyboardMacroReport, TypeKeyboardLedState, TypeKeydownState, TypeKeyboardMacroState:
 		return 1
-	case TypePointerReport, TypeMouseReport, TypeWheelReport:
+	case TypePointerReport, TypeMouseReport, TypeWheelReport, TypeRelWheelReport:
 		return 2
-	// we don't want to block the queue for this message
-	case TypeCancelKeyboardMacroReport:
+	// we don't want to block the queue for these messages
+	case TypeCancelKeyboardMacroReport, TypeConsumerControlReport, TypeSystemControlReport:
 		return 3
 	default:
 		return 3
@@ -86,6 +89,58 @@ func NewKeyboardReportMessage(keys []byte, modifier uint8) *Message {
 	}
 }
 
+// NewPointerReportMessage creates a new absolute pointer report message.
+func NewPointerReportMessage(x int, y int, button uint8) *Message {
+	return &Message{
+		t: TypePointerReport,
+		d: []byte{
+			byte(x >> 24), byte(x >> 16), byte(x >> 8), byte(x),
+			byte(y >> 24), byte(y >> 16), byte(y >> 8), byte(y),
+			button,
+		},
+	}
+}
+
+// NewMouseReportMessage creates a new relative mouse report message.
+func NewMouseReportMessage(dx int8, dy int8, button uint8) *Message {
+	return &Message{
+		t: TypeMouseReport,
+		d: []byte{byte(dx), byte(dy), button},
+	}
+}
+
+// NewWheelReportMessage creates a new wheel report message for the absolute mouse.
+func NewWheelReportMessage(wheelY int8, wheelX int8) *Message {
+	return &Message{
+		t: TypeWheelReport,
+		d: []byte{byte(wheelY), byte(wheelX)},
+	}
+}
+
+// NewRelWheelReportMessage creates a new wheel report message for the relative mouse.
+func NewRelWheelReportMessage(wheelY int8, wheelX int8) *Message {
+	return &Message{
+		t: TypeRelWheelReport,
+		d: []byte{byte(wheelY), byte(wheelX)},
+	}
+}
+
+// NewConsumerControlReportMessage creates a new consumer control report message.
+func NewConsumerControlReportMessage(usage uint16) *Message {
+	return &Message{
+		t: TypeConsumerControlReport,
+		d: []byte{byte(usage >> 8), byte(usage)},
+	}
+}
+
+// NewSystemControlReportMessage creates a new system control report message.
+func NewSystemControlReportMessage(usage uint8) *Message {
+	return &Message{
+		t: TypeSystemControlReport,
+		d: []byte{usage},
+	}
+}
+
 // NewKeyboardLedMessage creates a new keyboard LED message.
 func NewKeyboardLedMessage(state usbgadget.KeyboardState) *Message {
 	return &Message{
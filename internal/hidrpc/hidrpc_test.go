@@ -0,0 +1,67 @@
+package hidrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func roundTrip(t *testing.T, message *Message) *Message {
+	t.Helper()
+
+	data, err := Marshal(message)
+	require.NoError(t, err)
+
+	var decoded Message
+	require.NoError(t, Unmarshal(data, &decoded))
+	return &decoded
+}
+
+func TestConsumerControlReportRoundTrip(t *testing.T) {
+	data, err := Marshal(NewConsumerControlReportMessage(0x0192))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{byte(TypeConsumerControlReport), 0x01, 0x92}, data, "usage is big-endian on the wire")
+
+	for _, usage := range []uint16{0, 0x00E9, 0x0192, 0x03FF} {
+		message := roundTrip(t, NewConsumerControlReportMessage(usage))
+		assert.Equal(t, TypeConsumerControlReport, message.Type())
+
+		report, err := message.ConsumerControlReport()
+		require.NoError(t, err)
+		assert.Equal(t, usage, report.Usage)
+	}
+}
+
+func TestSystemControlReportRoundTrip(t *testing.T) {
+	data, err := Marshal(NewSystemControlReportMessage(0x81))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{byte(TypeSystemControlReport), 0x81}, data)
+
+	for _, usage := range []uint8{0, 0x81, 0x82, 0x83} {
+		message := roundTrip(t, NewSystemControlReportMessage(usage))
+		assert.Equal(t, TypeSystemControlReport, message.Type())
+
+		report, err := message.SystemControlReport()
+		require.NoError(t, err)
+		assert.Equal(t, usage, report.Usage)
+	}
+}
+
+func TestControlReportInvalid(t *testing.T) {
+	// wrong type
+	_, err := NewSystemControlReportMessage(0x81).ConsumerControlReport()
+	assert.Error(t, err)
+	_, err = NewConsumerControlReportMessage(0x00E9).SystemControlReport()
+	assert.Error(t, err)
+
+	// wrong length
+	var message Message
+	require.NoError(t, Unmarshal([]byte{byte(TypeConsumerControlReport), 0xE9}, &message))
+	_, err = message.ConsumerControlReport()
+	assert.Error(t, err)
+
+	require.NoError(t, Unmarshal([]byte{byte(TypeSystemControlReport)}, &message))
+	_, err = message.SystemControlReport()
+	assert.Error(t, err)
+}
@@ -44,6 +44,28 @@ func (m *Message) String() string {
 			return fmt.Sprintf("MouseReport{Malformed: %v}", m.d)
 		}
 		return fmt.Sprintf("MouseReport{DX: %d, DY: %d, Button: %d}", m.d[0], m.d[1], m.d[2])
+	case TypeWheelReport, TypeRelWheelReport:
+		name := "WheelReport"
+		if m.t == TypeRelWheelReport {
+			name = "RelWheelReport"
+		}
+		if len(m.d) < 1 {
+			return fmt.Sprintf("%s{Malformed: %v}", name, m.d)
+		}
+		if len(m.d) < 2 {
+			return fmt.Sprintf("%s{WheelY: %d}", name, int8(m.d[0]))
+		}
+		return fmt.Sprintf("%s{WheelY: %d, WheelX: %d}", name, int8(m.d[0]), int8(m.d[1]))
+	case TypeConsumerControlReport:
+		if len(m.d) < 2 {
+			return fmt.Sprintf("ConsumerControlReport{Malformed: %v}", m.d)
+		}
+		return fmt.Sprintf("ConsumerControlReport{Usage: 0x%04x}", binary.BigEndian.Uint16(m.d[0:2]))
+	case TypeSystemControlReport:
+		if len(m.d) < 1 {
+			return fmt.Sprintf("SystemControlReport{Malformed: %v}", m.d)
+		}
+		return fmt.Sprintf("SystemControlReport{Usage: 0x%02x}", m.d[0])
 	case TypeKeypressKeepAliveReport:
 		return "KeypressKeepAliveReport"
 	case TypeKeyboardMacroReport:
@@ -189,6 +211,70 @@ func (m *Message) MouseReport() (MouseReport, error) {
 	}, nil
 }
 
+// WheelReport ..
+type WheelReport struct {
+	WheelY int8
+	WheelX int8
+}
+
+// WheelReport returns the wheel report from the message, it is used by both
+// TypeWheelReport and TypeRelWheelReport. The horizontal value is optional
+// so older clients sending only the vertical value keep working.
+func (m *Message) WheelReport() (WheelReport, error) {
+	if m.t != TypeWheelReport && m.t != TypeRelWheelReport {
+		return WheelReport{}, fmt.Errorf("invalid message type: %d", m.t)
+	}
+
+	switch len(m.d) {
+	case 1:
+		return WheelReport{WheelY: int8(m.d[0])}, nil
+	case 2:
+		return WheelReport{WheelY: int8(m.d[0]), WheelX: int8(m.d[1])}, nil
+	default:
+		return WheelReport{}, fmt.Errorf("invalid message length: %d", len(m.d))
+	}
+}
+
+// ConsumerControlReport ..
+type ConsumerControlReport struct {
+	Usage uint16
+}
+
+// ConsumerControlReport returns the consumer control report from the message.
+func (m *Message) ConsumerControlReport() (ConsumerControlReport, error) {
+	if m.t != TypeConsumerControlReport {
+		return ConsumerControlReport{}, fmt.Errorf("invalid message type: %d", m.t)
+	}
+
+	if len(m.d) != 2 {
+		return ConsumerControlReport{}, fmt.Errorf("invalid message length: %d", len(m.d))
+	}
+
+	return ConsumerControlReport{
+		Usage: binary.BigEndian.Uint16(m.d[0:2]),
+	}, nil
+}
+
+// SystemControlReport ..
+type SystemControlReport struct {
+	Usage uint8
+}
+
+// SystemControlReport returns the system control report from the message.
+func (m *Message) SystemControlReport() (SystemControlReport, error) {
+	if m.t != TypeSystemControlReport {
+		return SystemControlReport{}, fmt.Errorf("invalid message type: %d", m.t)
+	}
+
+	if len(m.d) != 1 {
+		return SystemControlReport{}, fmt.Errorf("invalid message length: %d", len(m.d))
+	}
+
+	return SystemControlReport{
+		Usage: m.d[0],
+	}, nil
+}
+
 type KeyboardMacroState struct {
 	State   bool
 	IsPaste bool
@@ -0,0 +1,248 @@
+package keyboardlayout
+
+// cs-CZ keyboard layout, kept in sync with ui/src/keyboardLayouts/cs_CZ.ts
+
+var (
+	csCZKeyTrema   = KeyInfo{Key: "Backslash"}                           // tréma (umlaut), two dots placed above a vowel
+	csCZKeyAcute   = KeyInfo{Key: "Equal"}                               // accent aigu (acute accent), mark ´ placed above the letter
+	csCZKeyHat     = KeyInfo{Key: "Digit3", Shift: true, AltRight: true} // accent circonflexe (accent hat), mark ^ placed above the letter
+	csCZKeyCaron   = KeyInfo{Key: "Equal", Shift: true}                  // caron or haček (inverted hat), mark ˇ placed above the letter
+	csCZKeyGrave   = KeyInfo{Key: "Digit7", Shift: true, AltRight: true} // accent grave, mark ` placed above the letter
+	csCZKeyTilde   = KeyInfo{Key: "Digit1", Shift: true, AltRight: true} // tilde, mark ~ placed above the letter
+	csCZKeyRing    = KeyInfo{Key: "Backquote", Shift: true}              // kroužek (little ring), mark ° placed above the letter
+	csCZKeyOverdot = KeyInfo{Key: "Digit8", Shift: true, AltRight: true} // overdot (dot above), mark ˙ placed above the letter
+	csCZKeyHook    = KeyInfo{Key: "Digit6", Shift: true, AltRight: true} // ogonoek (little hook), mark ˛ placed beneath a letter
+	csCZKeyCedille = KeyInfo{Key: "Equal", Shift: true, AltRight: true}  // accent cedille (cedilla), mark ¸ placed beneath a letter
+)
+
+var csCZ = &Layout{
+	ISOCode: "cs-CZ",
+	Name:    "Čeština",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyA", Shift: true},
+		'Ä':  {Key: "KeyA", Shift: true, AccentKey: &csCZKeyTrema},
+		'Á':  {Key: "KeyA", Shift: true, AccentKey: &csCZKeyAcute},
+		'Â':  {Key: "KeyA", Shift: true, AccentKey: &csCZKeyHat},
+		'À':  {Key: "KeyA", Shift: true, AccentKey: &csCZKeyGrave},
+		'Ã':  {Key: "KeyA", Shift: true, AccentKey: &csCZKeyTilde},
+		'Ȧ':  {Key: "KeyA", Shift: true, AccentKey: &csCZKeyOverdot},
+		'Ą':  {Key: "KeyA", Shift: true, AccentKey: &csCZKeyHook},
+		'B':  {Key: "KeyB", Shift: true},
+		'Ḃ':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'Č':  {Key: "KeyC", Shift: true, AccentKey: &csCZKeyCaron},
+		'Ċ':  {Key: "KeyC", Shift: true, AccentKey: &csCZKeyOverdot},
+		'Ç':  {Key: "KeyC", Shift: true, AccentKey: &csCZKeyCedille},
+		'D':  {Key: "KeyD", Shift: true},
+		'Ď':  {Key: "KeyD", Shift: true, AccentKey: &csCZKeyCaron},
+		'Ḋ':  {Key: "KeyD", Shift: true, AccentKey: &csCZKeyOverdot},
+		'E':  {Key: "KeyE", Shift: true},
+		'Ë':  {Key: "KeyE", Shift: true, AccentKey: &csCZKeyTrema},
+		'É':  {Key: "KeyE", Shift: true, AccentKey: &csCZKeyAcute},
+		'Ê':  {Key: "KeyE", Shift: true, AccentKey: &csCZKeyHat},
+		'Ě':  {Key: "KeyE", Shift: true, AccentKey: &csCZKeyCaron},
+		'È':  {Key: "KeyE", Shift: true, AccentKey: &csCZKeyGrave},
+		'Ẽ':  {Key: "KeyE", Shift: true, AccentKey: &csCZKeyTilde},
+		'Ė':  {Key: "KeyE", Shift: true},
+		'Ę':  {Key: "KeyE", Shift: true, AccentKey: &csCZKeyHook},
+		'F':  {Key: "KeyF", Shift: true},
+		'Ḟ':  {Key: "KeyF", Shift: true, AccentKey: &csCZKeyOverdot},
+		'G':  {Key: "KeyG", Shift: true},
+		'Ġ':  {Key: "KeyG", Shift: true, AccentKey: &csCZKeyOverdot},
+		'H':  {Key: "KeyH", Shift: true},
+		'Ḣ':  {Key: "KeyH", Shift: true, AccentKey: &csCZKeyOverdot},
+		'I':  {Key: "KeyI", Shift: true},
+		'Ï':  {Key: "KeyI", Shift: true, AccentKey: &csCZKeyTrema},
+		'Í':  {Key: "KeyI", Shift: true, AccentKey: &csCZKeyAcute},
+		'Î':  {Key: "KeyI", Shift: true, AccentKey: &csCZKeyHat},
+		'Ì':  {Key: "KeyI", Shift: true, AccentKey: &csCZKeyGrave},
+		'Ĩ':  {Key: "KeyI", Shift: true, AccentKey: &csCZKeyTilde},
+		'İ':  {Key: "KeyI", Shift: true, AccentKey: &csCZKeyOverdot},
+		'Į':  {Key: "KeyI", Shift: true, AccentKey: &csCZKeyHook},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'Ŀ':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "KeyM", Shift: true},
+		'Ṁ':  {Key: "KeyM", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'Ň':  {Key: "KeyN", Shift: true, AccentKey: &csCZKeyCaron},
+		'Ñ':  {Key: "KeyN", Shift: true, AccentKey: &csCZKeyTilde},
+		'Ṅ':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'Ö':  {Key: "KeyO", Shift: true, AccentKey: &csCZKeyTrema},
+		'Ó':  {Key: "KeyO", Shift: true, AccentKey: &csCZKeyAcute},
+		'Ô':  {Key: "KeyO", Shift: true, AccentKey: &csCZKeyHat},
+		'Ò':  {Key: "KeyO", Shift: true, AccentKey: &csCZKeyGrave},
+		'Õ':  {Key: "KeyO", Shift: true, AccentKey: &csCZKeyTilde},
+		'Ȯ':  {Key: "KeyO", Shift: true, AccentKey: &csCZKeyOverdot},
+		'Ǫ':  {Key: "KeyO", Shift: true, AccentKey: &csCZKeyHook},
+		'P':  {Key: "KeyP", Shift: true},
+		'Ṗ':  {Key: "KeyP", Shift: true, AccentKey: &csCZKeyOverdot},
+		'Q':  {Key: "KeyQ", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'Ř':  {Key: "KeyR", Shift: true, AccentKey: &csCZKeyCaron},
+		'Ṙ':  {Key: "KeyR", Shift: true, AccentKey: &csCZKeyOverdot},
+		'S':  {Key: "KeyS", Shift: true},
+		'Š':  {Key: "KeyS", Shift: true, AccentKey: &csCZKeyCaron},
+		'Ṡ':  {Key: "KeyS", Shift: true, AccentKey: &csCZKeyOverdot},
+		'T':  {Key: "KeyT", Shift: true},
+		'Ť':  {Key: "KeyT", Shift: true, AccentKey: &csCZKeyCaron},
+		'Ṫ':  {Key: "KeyT", Shift: true, AccentKey: &csCZKeyOverdot},
+		'U':  {Key: "KeyU", Shift: true},
+		'Ü':  {Key: "KeyU", Shift: true, AccentKey: &csCZKeyTrema},
+		'Ú':  {Key: "KeyU", Shift: true, AccentKey: &csCZKeyAcute},
+		'Û':  {Key: "KeyU", Shift: true, AccentKey: &csCZKeyHat},
+		'Ù':  {Key: "KeyU", Shift: true, AccentKey: &csCZKeyGrave},
+		'Ũ':  {Key: "KeyU", Shift: true, AccentKey: &csCZKeyTilde},
+		'Ů':  {Key: "KeyU", Shift: true, AccentKey: &csCZKeyRing},
+		'Ų':  {Key: "KeyU", Shift: true, AccentKey: &csCZKeyHook},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyW", Shift: true},
+		'Ẇ':  {Key: "KeyW", Shift: true, AccentKey: &csCZKeyOverdot},
+		'X':  {Key: "KeyX", Shift: true},
+		'Ẋ':  {Key: "KeyX", Shift: true, AccentKey: &csCZKeyOverdot},
+		'Y':  {Key: "KeyY", Shift: true},
+		'Ý':  {Key: "KeyY", Shift: true, AccentKey: &csCZKeyAcute},
+		'Ẏ':  {Key: "KeyY", Shift: true, AccentKey: &csCZKeyOverdot},
+		'Z':  {Key: "KeyZ", Shift: true},
+		'Ż':  {Key: "KeyZ", Shift: true, AccentKey: &csCZKeyOverdot},
+		'a':  {Key: "KeyA"},
+		'ä':  {Key: "KeyA", AccentKey: &csCZKeyTrema},
+		'â':  {Key: "KeyA", AccentKey: &csCZKeyHat},
+		'à':  {Key: "KeyA", AccentKey: &csCZKeyGrave},
+		'ã':  {Key: "KeyA", AccentKey: &csCZKeyTilde},
+		'ȧ':  {Key: "KeyA", AccentKey: &csCZKeyOverdot},
+		'ą':  {Key: "KeyA", AccentKey: &csCZKeyHook},
+		'b':  {Key: "KeyB"},
+		'{':  {Key: "KeyB", AltRight: true},
+		'ḃ':  {Key: "KeyB", AccentKey: &csCZKeyOverdot},
+		'c':  {Key: "KeyC"},
+		'&':  {Key: "KeyC", AltRight: true},
+		'ç':  {Key: "KeyC", AccentKey: &csCZKeyCedille},
+		'ċ':  {Key: "KeyC", AccentKey: &csCZKeyOverdot},
+		'd':  {Key: "KeyD"},
+		'ď':  {Key: "KeyD", AccentKey: &csCZKeyCaron},
+		'ḋ':  {Key: "KeyD", AccentKey: &csCZKeyOverdot},
+		'Đ':  {Key: "KeyD", AltRight: true},
+		'e':  {Key: "KeyE"},
+		'ë':  {Key: "KeyE", AccentKey: &csCZKeyTrema},
+		'ê':  {Key: "KeyE", AccentKey: &csCZKeyHat},
+		'ẽ':  {Key: "KeyE", AccentKey: &csCZKeyTilde},
+		'è':  {Key: "KeyE", AccentKey: &csCZKeyGrave},
+		'ė':  {Key: "KeyE", AccentKey: &csCZKeyOverdot},
+		'ę':  {Key: "KeyE", AccentKey: &csCZKeyHook},
+		'€':  {Key: "KeyE", AltRight: true},
+		'f':  {Key: "KeyF"},
+		'ḟ':  {Key: "KeyF", AccentKey: &csCZKeyOverdot},
+		'[':  {Key: "KeyF", AltRight: true},
+		'g':  {Key: "KeyG"},
+		'ġ':  {Key: "KeyG", AccentKey: &csCZKeyOverdot},
+		']':  {Key: "KeyF", AltRight: true},
+		'h':  {Key: "KeyH"},
+		'ḣ':  {Key: "KeyH", AccentKey: &csCZKeyOverdot},
+		'i':  {Key: "KeyI"},
+		'ï':  {Key: "KeyI", AccentKey: &csCZKeyTrema},
+		'î':  {Key: "KeyI", AccentKey: &csCZKeyHat},
+		'ì':  {Key: "KeyI", AccentKey: &csCZKeyGrave},
+		'ĩ':  {Key: "KeyI", AccentKey: &csCZKeyTilde},
+		'ı':  {Key: "KeyI", AccentKey: &csCZKeyOverdot},
+		'į':  {Key: "KeyI", AccentKey: &csCZKeyHook},
+		'j':  {Key: "KeyJ"},
+		'ȷ':  {Key: "KeyJ", AccentKey: &csCZKeyOverdot},
+		'k':  {Key: "KeyK"},
+		'ł':  {Key: "KeyK", AltRight: true},
+		'l':  {Key: "KeyL"},
+		'ŀ':  {Key: "KeyL", AccentKey: &csCZKeyOverdot},
+		'Ł':  {Key: "KeyL", AltRight: true},
+		'm':  {Key: "KeyM"},
+		'ṁ':  {Key: "KeyM", AccentKey: &csCZKeyOverdot},
+		'n':  {Key: "KeyN"},
+		'}':  {Key: "KeyN", AltRight: true},
+		'ň':  {Key: "KeyN", AccentKey: &csCZKeyCaron},
+		'ñ':  {Key: "KeyN", AccentKey: &csCZKeyTilde},
+		'ṅ':  {Key: "KeyN", AccentKey: &csCZKeyOverdot},
+		'o':  {Key: "KeyO"},
+		'ö':  {Key: "KeyO", AccentKey: &csCZKeyTrema},
+		'ó':  {Key: "KeyO", AccentKey: &csCZKeyAcute},
+		'ô':  {Key: "KeyO", AccentKey: &csCZKeyHat},
+		'ò':  {Key: "KeyO", AccentKey: &csCZKeyGrave},
+		'õ':  {Key: "KeyO", AccentKey: &csCZKeyTilde},
+		'ȯ':  {Key: "KeyO", AccentKey: &csCZKeyOverdot},
+		'ǫ':  {Key: "KeyO", AccentKey: &csCZKeyHook},
+		'p':  {Key: "KeyP"},
+		'ṗ':  {Key: "KeyP", AccentKey: &csCZKeyOverdot},
+		'q':  {Key: "KeyQ"},
+		'r':  {Key: "KeyR"},
+		'ṙ':  {Key: "KeyR", AccentKey: &csCZKeyOverdot},
+		's':  {Key: "KeyS"},
+		'ṡ':  {Key: "KeyS", AccentKey: &csCZKeyOverdot},
+		'đ':  {Key: "KeyS", AltRight: true},
+		't':  {Key: "KeyT"},
+		'ť':  {Key: "KeyT", AccentKey: &csCZKeyCaron},
+		'ṫ':  {Key: "KeyT", AccentKey: &csCZKeyOverdot},
+		'u':  {Key: "KeyU"},
+		'ü':  {Key: "KeyU", AccentKey: &csCZKeyTrema},
+		'û':  {Key: "KeyU", AccentKey: &csCZKeyHat},
+		'ù':  {Key: "KeyU", AccentKey: &csCZKeyGrave},
+		'ũ':  {Key: "KeyU", AccentKey: &csCZKeyTilde},
+		'ų':  {Key: "KeyU", AccentKey: &csCZKeyHook},
+		'v':  {Key: "KeyV"},
+		'@':  {Key: "KeyV", AltRight: true},
+		'w':  {Key: "KeyW"},
+		'ẇ':  {Key: "KeyW", AccentKey: &csCZKeyOverdot},
+		'x':  {Key: "KeyX"},
+		'#':  {Key: "KeyX", AltRight: true},
+		'ẋ':  {Key: "KeyX", AccentKey: &csCZKeyOverdot},
+		'y':  {Key: "KeyY"},
+		'ẏ':  {Key: "KeyY", AccentKey: &csCZKeyOverdot},
+		'z':  {Key: "KeyZ"},
+		'ż':  {Key: "KeyZ", AccentKey: &csCZKeyOverdot},
+		';':  {Key: "Backquote"},
+		'°':  {Key: "Backquote", Shift: true, DeadKey: true},
+		'+':  {Key: "Digit1"},
+		'1':  {Key: "Digit1", Shift: true},
+		'ě':  {Key: "Digit2"},
+		'2':  {Key: "Digit2", Shift: true},
+		'š':  {Key: "Digit3"},
+		'3':  {Key: "Digit3", Shift: true},
+		'č':  {Key: "Digit4"},
+		'4':  {Key: "Digit4", Shift: true},
+		'ř':  {Key: "Digit5"},
+		'5':  {Key: "Digit5", Shift: true},
+		'ž':  {Key: "Digit6"},
+		'6':  {Key: "Digit6", Shift: true},
+		'ý':  {Key: "Digit7"},
+		'7':  {Key: "Digit7", Shift: true},
+		'á':  {Key: "Digit8"},
+		'8':  {Key: "Digit8", Shift: true},
+		'í':  {Key: "Digit9"},
+		'9':  {Key: "Digit9", Shift: true},
+		'é':  {Key: "Digit0"},
+		'0':  {Key: "Digit0", Shift: true},
+		'=':  {Key: "Minus"},
+		'%':  {Key: "Minus", Shift: true},
+		'ú':  {Key: "BracketLeft"},
+		'/':  {Key: "BracketLeft", Shift: true},
+		')':  {Key: "BracketRight"},
+		'(':  {Key: "BracketRight", Shift: true},
+		'ů':  {Key: "Semicolon"},
+		'"':  {Key: "Semicolon", Shift: true},
+		'§':  {Key: "Quote"},
+		'!':  {Key: "Quote", Shift: true},
+		'\'': {Key: "Backslash", Shift: true},
+		',':  {Key: "Comma"},
+		'?':  {Key: "Comma", Shift: true},
+		'<':  {Key: "Comma", AltRight: true},
+		'.':  {Key: "Period"},
+		':':  {Key: "Period", Shift: true},
+		'>':  {Key: "Period", AltRight: true},
+		'-':  {Key: "Slash"},
+		'_':  {Key: "Slash", Shift: true},
+		'*':  {Key: "Slash", AltRight: true},
+		'\\': {Key: "IntlBackslash"},
+		'|':  {Key: "IntlBackslash", Shift: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
@@ -0,0 +1,177 @@
+package keyboardlayout
+
+// da-DK keyboard layout, kept in sync with ui/src/keyboardLayouts/da_DK.ts
+
+var (
+	daDKKeyTrema = KeyInfo{Key: "BracketRight"}
+	daDKKeyAcute = KeyInfo{Key: "Equal", AltRight: true}
+	daDKKeyHat   = KeyInfo{Key: "BracketRight", Shift: true}
+	daDKKeyGrave = KeyInfo{Key: "Equal", Shift: true}
+	daDKKeyTilde = KeyInfo{Key: "BracketRight", AltRight: true}
+)
+
+var daDK = &Layout{
+	ISOCode: "da-DK",
+	Name:    "Dansk",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyA", Shift: true},
+		'Ä':  {Key: "KeyA", Shift: true, AccentKey: &daDKKeyTrema},
+		'Á':  {Key: "KeyA", Shift: true, AccentKey: &daDKKeyAcute},
+		'Â':  {Key: "KeyA", Shift: true, AccentKey: &daDKKeyHat},
+		'À':  {Key: "KeyA", Shift: true, AccentKey: &daDKKeyGrave},
+		'Ã':  {Key: "KeyA", Shift: true, AccentKey: &daDKKeyTilde},
+		'B':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'D':  {Key: "KeyD", Shift: true},
+		'E':  {Key: "KeyE", Shift: true},
+		'Ë':  {Key: "KeyE", Shift: true, AccentKey: &daDKKeyTrema},
+		'É':  {Key: "KeyE", Shift: true, AccentKey: &daDKKeyAcute},
+		'Ê':  {Key: "KeyE", Shift: true, AccentKey: &daDKKeyHat},
+		'È':  {Key: "KeyE", Shift: true, AccentKey: &daDKKeyGrave},
+		'Ẽ':  {Key: "KeyE", Shift: true, AccentKey: &daDKKeyTilde},
+		'F':  {Key: "KeyF", Shift: true},
+		'G':  {Key: "KeyG", Shift: true},
+		'H':  {Key: "KeyH", Shift: true},
+		'I':  {Key: "KeyI", Shift: true},
+		'Ï':  {Key: "KeyI", Shift: true, AccentKey: &daDKKeyTrema},
+		'Í':  {Key: "KeyI", Shift: true, AccentKey: &daDKKeyAcute},
+		'Î':  {Key: "KeyI", Shift: true, AccentKey: &daDKKeyHat},
+		'Ì':  {Key: "KeyI", Shift: true, AccentKey: &daDKKeyGrave},
+		'Ĩ':  {Key: "KeyI", Shift: true, AccentKey: &daDKKeyTilde},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "KeyM", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'Ö':  {Key: "KeyO", Shift: true, AccentKey: &daDKKeyTrema},
+		'Ó':  {Key: "KeyO", Shift: true, AccentKey: &daDKKeyAcute},
+		'Ô':  {Key: "KeyO", Shift: true, AccentKey: &daDKKeyHat},
+		'Ò':  {Key: "KeyO", Shift: true, AccentKey: &daDKKeyGrave},
+		'Õ':  {Key: "KeyO", Shift: true, AccentKey: &daDKKeyTilde},
+		'P':  {Key: "KeyP", Shift: true},
+		'Q':  {Key: "KeyQ", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'S':  {Key: "KeyS", Shift: true},
+		'T':  {Key: "KeyT", Shift: true},
+		'U':  {Key: "KeyU", Shift: true},
+		'Ü':  {Key: "KeyU", Shift: true, AccentKey: &daDKKeyTrema},
+		'Ú':  {Key: "KeyU", Shift: true, AccentKey: &daDKKeyAcute},
+		'Û':  {Key: "KeyU", Shift: true, AccentKey: &daDKKeyHat},
+		'Ù':  {Key: "KeyU", Shift: true, AccentKey: &daDKKeyGrave},
+		'Ũ':  {Key: "KeyU", Shift: true, AccentKey: &daDKKeyTilde},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyW", Shift: true},
+		'X':  {Key: "KeyX", Shift: true},
+		'Y':  {Key: "KeyY", Shift: true},
+		'Z':  {Key: "KeyZ", Shift: true},
+		'a':  {Key: "KeyA"},
+		'ä':  {Key: "KeyA", AccentKey: &daDKKeyTrema},
+		'á':  {Key: "KeyA", AccentKey: &daDKKeyAcute},
+		'â':  {Key: "KeyA", AccentKey: &daDKKeyHat},
+		'à':  {Key: "KeyA", AccentKey: &daDKKeyGrave},
+		'ã':  {Key: "KeyA", AccentKey: &daDKKeyTilde},
+		'b':  {Key: "KeyB"},
+		'c':  {Key: "KeyC"},
+		'd':  {Key: "KeyD"},
+		'e':  {Key: "KeyE"},
+		'ë':  {Key: "KeyE", AccentKey: &daDKKeyTrema},
+		'é':  {Key: "KeyE", AccentKey: &daDKKeyAcute},
+		'ê':  {Key: "KeyE", AccentKey: &daDKKeyHat},
+		'è':  {Key: "KeyE", AccentKey: &daDKKeyGrave},
+		'ẽ':  {Key: "KeyE", AccentKey: &daDKKeyTilde},
+		'€':  {Key: "KeyE", AltRight: true},
+		'f':  {Key: "KeyF"},
+		'g':  {Key: "KeyG"},
+		'h':  {Key: "KeyH"},
+		'i':  {Key: "KeyI"},
+		'ï':  {Key: "KeyI", AccentKey: &daDKKeyTrema},
+		'í':  {Key: "KeyI", AccentKey: &daDKKeyAcute},
+		'î':  {Key: "KeyI", AccentKey: &daDKKeyHat},
+		'ì':  {Key: "KeyI", AccentKey: &daDKKeyGrave},
+		'ĩ':  {Key: "KeyI", AccentKey: &daDKKeyTilde},
+		'j':  {Key: "KeyJ"},
+		'k':  {Key: "KeyK"},
+		'l':  {Key: "KeyL"},
+		'm':  {Key: "KeyM"},
+		'n':  {Key: "KeyN"},
+		'o':  {Key: "KeyO"},
+		'ö':  {Key: "KeyO", AccentKey: &daDKKeyTrema},
+		'ó':  {Key: "KeyO", AccentKey: &daDKKeyAcute},
+		'ô':  {Key: "KeyO", AccentKey: &daDKKeyHat},
+		'ò':  {Key: "KeyO", AccentKey: &daDKKeyGrave},
+		'õ':  {Key: "KeyO", AccentKey: &daDKKeyTilde},
+		'p':  {Key: "KeyP"},
+		'q':  {Key: "KeyQ"},
+		'r':  {Key: "KeyR"},
+		's':  {Key: "KeyS"},
+		't':  {Key: "KeyT"},
+		'u':  {Key: "KeyU"},
+		'ü':  {Key: "KeyU", AccentKey: &daDKKeyTrema},
+		'ú':  {Key: "KeyU", AccentKey: &daDKKeyAcute},
+		'û':  {Key: "KeyU", AccentKey: &daDKKeyHat},
+		'ù':  {Key: "KeyU", AccentKey: &daDKKeyGrave},
+		'ũ':  {Key: "KeyU", AccentKey: &daDKKeyTilde},
+		'v':  {Key: "KeyV"},
+		'w':  {Key: "KeyW"},
+		'x':  {Key: "KeyX"},
+		'y':  {Key: "KeyY"}, // <-- corrected
+		'z':  {Key: "KeyZ"}, // <-- corrected
+		'½':  {Key: "Backquote"},
+		'§':  {Key: "Backquote", Shift: true},
+		'1':  {Key: "Digit1"},
+		'!':  {Key: "Digit1", Shift: true},
+		'2':  {Key: "Digit2"},
+		'"':  {Key: "Digit2", Shift: true},
+		'@':  {Key: "Digit2", AltRight: true},
+		'3':  {Key: "Digit3"},
+		'#':  {Key: "Digit3", Shift: true},
+		'£':  {Key: "Digit3", AltRight: true},
+		'4':  {Key: "Digit4"},
+		'¤':  {Key: "Digit4", Shift: true},
+		'$':  {Key: "Digit4", AltRight: true},
+		'5':  {Key: "Digit5"},
+		'%':  {Key: "Digit5", Shift: true},
+		'6':  {Key: "Digit6"},
+		'&':  {Key: "Digit6", Shift: true},
+		'7':  {Key: "Digit7"},
+		'/':  {Key: "Digit7", Shift: true},
+		'{':  {Key: "Digit7", AltRight: true},
+		'8':  {Key: "Digit8"},
+		'(':  {Key: "Digit8", Shift: true},
+		'[':  {Key: "Digit8", AltRight: true},
+		'9':  {Key: "Digit9"},
+		')':  {Key: "Digit9", Shift: true},
+		']':  {Key: "Digit9", AltRight: true},
+		'0':  {Key: "Digit0"},
+		'=':  {Key: "Digit0", Shift: true},
+		'}':  {Key: "Digit0", AltRight: true},
+		'+':  {Key: "Minus"},
+		'?':  {Key: "Minus", Shift: true},
+		'\\': {Key: "Equal"},
+		'å':  {Key: "BracketLeft"},
+		'Å':  {Key: "BracketLeft", Shift: true},
+		'ø':  {Key: "Semicolon"},
+		'Ø':  {Key: "Semicolon", Shift: true},
+		'æ':  {Key: "Quote"},
+		'Æ':  {Key: "Quote", Shift: true},
+		'\'': {Key: "Backslash"},
+		'*':  {Key: "Backslash", Shift: true},
+		',':  {Key: "Comma"},
+		';':  {Key: "Comma", Shift: true},
+		'.':  {Key: "Period"},
+		':':  {Key: "Period", Shift: true},
+		'-':  {Key: "Slash"},
+		'_':  {Key: "Slash", Shift: true},
+		'<':  {Key: "IntlBackslash"},
+		'>':  {Key: "IntlBackslash", Shift: true},
+		'~':  {Key: "BracketRight", AltRight: true, DeadKey: true},
+		'^':  {Key: "BracketRight", Shift: true, DeadKey: true},
+		'¨':  {Key: "BracketRight", DeadKey: true},
+		'|':  {Key: "Equal", AltRight: true, DeadKey: true},
+		'`':  {Key: "Equal", Shift: true, DeadKey: true},
+		'´':  {Key: "Equal", DeadKey: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
@@ -0,0 +1,169 @@
+package keyboardlayout
+
+// de-CH keyboard layout, kept in sync with ui/src/keyboardLayouts/de_CH.ts
+
+var (
+	deCHKeyTrema = KeyInfo{Key: "BracketRight"}          // tréma (umlaut), two dots placed above a vowel
+	deCHKeyAcute = KeyInfo{Key: "Minus", AltRight: true} // accent aigu (acute accent), mark ´ placed above the letter
+	deCHKeyHat   = KeyInfo{Key: "Equal"}                 // accent circonflexe (accent hat), mark ^ placed above the letter
+	deCHKeyGrave = KeyInfo{Key: "Equal", Shift: true}    // accent grave, mark ` placed above the letter
+	deCHKeyTilde = KeyInfo{Key: "Equal", AltRight: true} // tilde, mark ~ placed above the letter
+)
+
+var deCH = &Layout{
+	ISOCode: "de-CH",
+	Name:    "Schwiizerdütsch",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyA", Shift: true},
+		'Ä':  {Key: "KeyA", Shift: true, AccentKey: &deCHKeyTrema},
+		'Á':  {Key: "KeyA", Shift: true, AccentKey: &deCHKeyAcute},
+		'Â':  {Key: "KeyA", Shift: true, AccentKey: &deCHKeyHat},
+		'À':  {Key: "KeyA", Shift: true, AccentKey: &deCHKeyGrave},
+		'Ã':  {Key: "KeyA", Shift: true, AccentKey: &deCHKeyTilde},
+		'B':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'D':  {Key: "KeyD", Shift: true},
+		'E':  {Key: "KeyE", Shift: true},
+		'Ë':  {Key: "KeyE", Shift: true, AccentKey: &deCHKeyTrema},
+		'É':  {Key: "KeyE", Shift: true, AccentKey: &deCHKeyAcute},
+		'Ê':  {Key: "KeyE", Shift: true, AccentKey: &deCHKeyHat},
+		'È':  {Key: "KeyE", Shift: true, AccentKey: &deCHKeyGrave},
+		'Ẽ':  {Key: "KeyE", Shift: true, AccentKey: &deCHKeyTilde},
+		'F':  {Key: "KeyF", Shift: true},
+		'G':  {Key: "KeyG", Shift: true},
+		'H':  {Key: "KeyH", Shift: true},
+		'I':  {Key: "KeyI", Shift: true},
+		'Ï':  {Key: "KeyI", Shift: true, AccentKey: &deCHKeyTrema},
+		'Í':  {Key: "KeyI", Shift: true, AccentKey: &deCHKeyAcute},
+		'Î':  {Key: "KeyI", Shift: true, AccentKey: &deCHKeyHat},
+		'Ì':  {Key: "KeyI", Shift: true, AccentKey: &deCHKeyGrave},
+		'Ĩ':  {Key: "KeyI", Shift: true, AccentKey: &deCHKeyTilde},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "KeyM", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'Ö':  {Key: "KeyO", Shift: true, AccentKey: &deCHKeyTrema},
+		'Ó':  {Key: "KeyO", Shift: true, AccentKey: &deCHKeyAcute},
+		'Ô':  {Key: "KeyO", Shift: true, AccentKey: &deCHKeyHat},
+		'Ò':  {Key: "KeyO", Shift: true, AccentKey: &deCHKeyGrave},
+		'Õ':  {Key: "KeyO", Shift: true, AccentKey: &deCHKeyTilde},
+		'P':  {Key: "KeyP", Shift: true},
+		'Q':  {Key: "KeyQ", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'S':  {Key: "KeyS", Shift: true},
+		'T':  {Key: "KeyT", Shift: true},
+		'U':  {Key: "KeyU", Shift: true},
+		'Ü':  {Key: "KeyU", Shift: true, AccentKey: &deCHKeyTrema},
+		'Ú':  {Key: "KeyU", Shift: true, AccentKey: &deCHKeyAcute},
+		'Û':  {Key: "KeyU", Shift: true, AccentKey: &deCHKeyHat},
+		'Ù':  {Key: "KeyU", Shift: true, AccentKey: &deCHKeyGrave},
+		'Ũ':  {Key: "KeyU", Shift: true, AccentKey: &deCHKeyTilde},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyW", Shift: true},
+		'X':  {Key: "KeyX", Shift: true},
+		'Y':  {Key: "KeyZ", Shift: true},
+		'Z':  {Key: "KeyY", Shift: true},
+		'a':  {Key: "KeyA"},
+		'á':  {Key: "KeyA", AccentKey: &deCHKeyAcute},
+		'â':  {Key: "KeyA", AccentKey: &deCHKeyHat},
+		'ã':  {Key: "KeyA", AccentKey: &deCHKeyTilde},
+		'b':  {Key: "KeyB"},
+		'c':  {Key: "KeyC"},
+		'd':  {Key: "KeyD"},
+		'e':  {Key: "KeyE"},
+		'ë':  {Key: "KeyE", AccentKey: &deCHKeyTrema},
+		'ê':  {Key: "KeyE", AccentKey: &deCHKeyHat},
+		'ẽ':  {Key: "KeyE", AccentKey: &deCHKeyTilde},
+		'€':  {Key: "KeyE", AltRight: true},
+		'f':  {Key: "KeyF"},
+		'g':  {Key: "KeyG"},
+		'h':  {Key: "KeyH"},
+		'i':  {Key: "KeyI"},
+		'ï':  {Key: "KeyI", AccentKey: &deCHKeyTrema},
+		'í':  {Key: "KeyI", AccentKey: &deCHKeyAcute},
+		'î':  {Key: "KeyI", AccentKey: &deCHKeyHat},
+		'ì':  {Key: "KeyI", AccentKey: &deCHKeyGrave},
+		'ĩ':  {Key: "KeyI", AccentKey: &deCHKeyTilde},
+		'j':  {Key: "KeyJ"},
+		'k':  {Key: "KeyK"},
+		'l':  {Key: "KeyL"},
+		'm':  {Key: "KeyM"},
+		'n':  {Key: "KeyN"},
+		'o':  {Key: "KeyO"},
+		'ó':  {Key: "KeyO", AccentKey: &deCHKeyAcute},
+		'ô':  {Key: "KeyO", AccentKey: &deCHKeyHat},
+		'ò':  {Key: "KeyO", AccentKey: &deCHKeyGrave},
+		'õ':  {Key: "KeyO", AccentKey: &deCHKeyTilde},
+		'p':  {Key: "KeyP"},
+		'q':  {Key: "KeyQ"},
+		'r':  {Key: "KeyR"},
+		's':  {Key: "KeyS"},
+		't':  {Key: "KeyT"},
+		'u':  {Key: "KeyU"},
+		'ú':  {Key: "KeyU", AccentKey: &deCHKeyAcute},
+		'û':  {Key: "KeyU", AccentKey: &deCHKeyHat},
+		'ù':  {Key: "KeyU", AccentKey: &deCHKeyGrave},
+		'ũ':  {Key: "KeyU", AccentKey: &deCHKeyTilde},
+		'v':  {Key: "KeyV"},
+		'w':  {Key: "KeyW"},
+		'x':  {Key: "KeyX"},
+		'y':  {Key: "KeyZ"},
+		'z':  {Key: "KeyY"},
+		'§':  {Key: "Backquote"},
+		'°':  {Key: "Backquote", Shift: true},
+		'1':  {Key: "Digit1"},
+		'+':  {Key: "Digit1", Shift: true},
+		'|':  {Key: "Digit1", AltRight: true},
+		'2':  {Key: "Digit2"},
+		'"':  {Key: "Digit2", Shift: true},
+		'@':  {Key: "Digit2", AltRight: true},
+		'3':  {Key: "Digit3"},
+		'*':  {Key: "Digit3", Shift: true},
+		'#':  {Key: "Digit3", AltRight: true},
+		'4':  {Key: "Digit4"},
+		'ç':  {Key: "Digit4", Shift: true},
+		'5':  {Key: "Digit5"},
+		'%':  {Key: "Digit5", Shift: true},
+		'6':  {Key: "Digit6"},
+		'&':  {Key: "Digit6", Shift: true},
+		'7':  {Key: "Digit7"},
+		'/':  {Key: "Digit7", Shift: true},
+		'8':  {Key: "Digit8"},
+		'(':  {Key: "Digit8", Shift: true},
+		'9':  {Key: "Digit9"},
+		')':  {Key: "Digit9", Shift: true},
+		'0':  {Key: "Digit0"},
+		'=':  {Key: "Digit0", Shift: true},
+		'\'': {Key: "Minus"},
+		'?':  {Key: "Minus", Shift: true},
+		'^':  {Key: "Equal", DeadKey: true},
+		'`':  {Key: "Equal", Shift: true},
+		'~':  {Key: "Equal", AltRight: true, DeadKey: true},
+		'ü':  {Key: "BracketLeft"},
+		'è':  {Key: "BracketLeft", Shift: true},
+		'[':  {Key: "BracketLeft", AltRight: true},
+		'!':  {Key: "BracketRight", Shift: true},
+		']':  {Key: "BracketRight", AltRight: true},
+		'ö':  {Key: "Semicolon"},
+		'é':  {Key: "Semicolon", Shift: true},
+		'ä':  {Key: "Quote"},
+		'à':  {Key: "Quote", Shift: true},
+		'{':  {Key: "Quote", AltRight: true},
+		'$':  {Key: "Backslash"},
+		'£':  {Key: "Backslash", Shift: true},
+		'}':  {Key: "Backslash", AltRight: true},
+		',':  {Key: "Comma"},
+		';':  {Key: "Comma", Shift: true},
+		'.':  {Key: "Period"},
+		':':  {Key: "Period", Shift: true},
+		'-':  {Key: "Slash"},
+		'_':  {Key: "Slash", Shift: true},
+		'<':  {Key: "IntlBackslash"},
+		'>':  {Key: "IntlBackslash", Shift: true},
+		'\\': {Key: "IntlBackslash", AltRight: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
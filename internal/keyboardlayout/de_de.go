@@ -0,0 +1,190 @@
+package keyboardlayout
+
+// de-DE keyboard layout, kept in sync with ui/src/keyboardLayouts/de_DE.ts
+
+var (
+	deDEKeyAcute = KeyInfo{Key: "Equal"}              // accent aigu (acute accent), mark ´ placed above the letter
+	deDEKeyHat   = KeyInfo{Key: "Backquote"}          // accent circonflexe (accent hat), mark ^ placed above the letter
+	deDEKeyGrave = KeyInfo{Key: "Equal", Shift: true} // accent grave, mark ` placed above the letter
+)
+
+var deDE = &Layout{
+	ISOCode: "de-DE",
+	Name:    "Deutsch",
+	Chars: map[rune]KeyCombo{
+		'a':      {Key: "KeyA"},
+		'á':      {Key: "KeyA", AccentKey: &deDEKeyAcute},
+		'â':      {Key: "KeyA", AccentKey: &deDEKeyHat},
+		'à':      {Key: "KeyA", AccentKey: &deDEKeyGrave},
+		'A':      {Key: "KeyA", Shift: true},
+		'Á':      {Key: "KeyA", Shift: true, AccentKey: &deDEKeyAcute},
+		'Â':      {Key: "KeyA", Shift: true, AccentKey: &deDEKeyHat},
+		'À':      {Key: "KeyA", Shift: true, AccentKey: &deDEKeyGrave},
+		'☺':      {Key: "KeyA", AltRight: true}, // white smiling face ☺
+		'b':      {Key: "KeyB"},
+		'B':      {Key: "KeyB", Shift: true},
+		'‹':      {Key: "KeyB", AltRight: true}, // single left-pointing angle quotation mark, ‹
+		'c':      {Key: "KeyC"},
+		'C':      {Key: "KeyC", Shift: true},
+		'\u202f': {Key: "KeyC", AltRight: true}, // narrow no-break space
+		'd':      {Key: "KeyD"},
+		'D':      {Key: "KeyD", Shift: true},
+		'′':      {Key: "KeyD", AltRight: true}, // prime, mark ′ placed above the letter
+		'e':      {Key: "KeyE"},
+		'é':      {Key: "KeyE", AccentKey: &deDEKeyAcute},
+		'ê':      {Key: "KeyE", AccentKey: &deDEKeyHat},
+		'è':      {Key: "KeyE", AccentKey: &deDEKeyGrave},
+		'€':      {Key: "KeyE", AltRight: true},
+		'E':      {Key: "KeyE", Shift: true},
+		'É':      {Key: "KeyE", Shift: true, AccentKey: &deDEKeyAcute},
+		'Ê':      {Key: "KeyE", Shift: true, AccentKey: &deDEKeyHat},
+		'È':      {Key: "KeyE", Shift: true, AccentKey: &deDEKeyGrave},
+		'f':      {Key: "KeyF"},
+		'F':      {Key: "KeyF", Shift: true},
+		'˟':      {Key: "KeyF", AltRight: true, DeadKey: true}, // modifier letter cross accent, ˟
+		'G':      {Key: "KeyG", Shift: true},
+		'g':      {Key: "KeyG"},
+		'ẞ':      {Key: "KeyG", AltRight: true}, // capital sharp S, ẞ
+		'h':      {Key: "KeyH"},
+		'H':      {Key: "KeyH", Shift: true},
+		'ˍ':      {Key: "KeyH", AltRight: true, DeadKey: true}, // modifier letter low macron, ˍ
+		'i':      {Key: "KeyI"},
+		'í':      {Key: "KeyI", AccentKey: &deDEKeyAcute},
+		'î':      {Key: "KeyI", AccentKey: &deDEKeyHat},
+		'ì':      {Key: "KeyI", AccentKey: &deDEKeyGrave},
+		'I':      {Key: "KeyI", Shift: true},
+		'Í':      {Key: "KeyI", Shift: true, AccentKey: &deDEKeyAcute},
+		'Î':      {Key: "KeyI", Shift: true, AccentKey: &deDEKeyHat},
+		'Ì':      {Key: "KeyI", Shift: true, AccentKey: &deDEKeyGrave},
+		'˜':      {Key: "KeyI", AltRight: true, DeadKey: true}, // tilde accent, mark ˜ placed above the letter
+		'j':      {Key: "KeyJ"},
+		'J':      {Key: "KeyJ", Shift: true},
+		'¸':      {Key: "KeyJ", AltRight: true, DeadKey: true}, // cedilla accent, mark ¸ placed below the letter
+		'k':      {Key: "KeyK"},
+		'K':      {Key: "KeyK", Shift: true},
+		'l':      {Key: "KeyL"},
+		'L':      {Key: "KeyL", Shift: true},
+		'ˏ':      {Key: "KeyL", AltRight: true, DeadKey: true}, // modifier letter reversed comma, ˏ
+		'm':      {Key: "KeyM"},
+		'M':      {Key: "KeyM", Shift: true},
+		'µ':      {Key: "KeyM", AltRight: true},
+		'n':      {Key: "KeyN"},
+		'N':      {Key: "KeyN", Shift: true},
+		'–':      {Key: "KeyN", AltRight: true}, // en dash, –
+		'o':      {Key: "KeyO"},
+		'ó':      {Key: "KeyO", AccentKey: &deDEKeyAcute},
+		'ô':      {Key: "KeyO", AccentKey: &deDEKeyHat},
+		'ò':      {Key: "KeyO", AccentKey: &deDEKeyGrave},
+		'O':      {Key: "KeyO", Shift: true},
+		'Ó':      {Key: "KeyO", Shift: true, AccentKey: &deDEKeyAcute},
+		'Ô':      {Key: "KeyO", Shift: true, AccentKey: &deDEKeyHat},
+		'Ò':      {Key: "KeyO", Shift: true, AccentKey: &deDEKeyGrave},
+		'˚':      {Key: "KeyO", AltRight: true, DeadKey: true}, // ring above, ˚
+		'p':      {Key: "KeyP"},
+		'P':      {Key: "KeyP", Shift: true},
+		'ˀ':      {Key: "KeyP", AltRight: true, DeadKey: true}, // modifier letter apostrophe, ʾ
+		'q':      {Key: "KeyQ"},
+		'Q':      {Key: "KeyQ", Shift: true},
+		'@':      {Key: "KeyQ", AltRight: true},
+		'R':      {Key: "KeyR", Shift: true},
+		'r':      {Key: "KeyR"},
+		'˝':      {Key: "KeyR", AltRight: true, DeadKey: true}, // double acute accent, mark ˝ placed above the letter
+		'S':      {Key: "KeyS", Shift: true},
+		's':      {Key: "KeyS"},
+		'″':      {Key: "KeyS", AltRight: true}, // double prime, mark ″ placed above the letter
+		'T':      {Key: "KeyT", Shift: true},
+		't':      {Key: "KeyT"},
+		'ˇ':      {Key: "KeyT", AltRight: true, DeadKey: true}, // caron/hacek accent, mark ˇ placed above the letter
+		'u':      {Key: "KeyU"},
+		'ú':      {Key: "KeyU", AccentKey: &deDEKeyAcute},
+		'û':      {Key: "KeyU", AccentKey: &deDEKeyHat},
+		'ù':      {Key: "KeyU", AccentKey: &deDEKeyGrave},
+		'U':      {Key: "KeyU", Shift: true},
+		'Ú':      {Key: "KeyU", Shift: true, AccentKey: &deDEKeyAcute},
+		'Û':      {Key: "KeyU", Shift: true, AccentKey: &deDEKeyHat},
+		'Ù':      {Key: "KeyU", Shift: true, AccentKey: &deDEKeyGrave},
+		'˘':      {Key: "KeyU", AltRight: true, DeadKey: true}, // breve accent, ˘ placed above the letter
+		'v':      {Key: "KeyV"},
+		'V':      {Key: "KeyV", Shift: true},
+		'«':      {Key: "KeyV", AltRight: true}, // left-pointing double angle quotation mark, «
+		'w':      {Key: "KeyW"},
+		'W':      {Key: "KeyW", Shift: true},
+		'¯':      {Key: "KeyW", AltRight: true, DeadKey: true}, // macron accent, mark ¯ placed above the letter
+		'x':      {Key: "KeyX"},
+		'X':      {Key: "KeyX", Shift: true},
+		'»':      {Key: "KeyX", AltRight: true},
+		'y':      {Key: "KeyZ"},
+		'Y':      {Key: "KeyZ", Shift: true},
+		'›':      {Key: "KeyZ", AltRight: true}, // single right-pointing angle quotation mark, ›
+		'z':      {Key: "KeyY"},
+		'Z':      {Key: "KeyY", Shift: true},
+		'¨':      {Key: "KeyY", AltRight: true, DeadKey: true}, // diaeresis accent, mark ¨ placed above the letter
+		'°':      {Key: "Backquote", Shift: true},
+		'^':      {Key: "Backquote", DeadKey: true},
+		'|':      {Key: "Backquote", AltRight: true},
+		'1':      {Key: "Digit1"},
+		'!':      {Key: "Digit1", Shift: true},
+		'’':      {Key: "Digit1", AltRight: true}, // single quote, mark ’ placed above the letter
+		'2':      {Key: "Digit2"},
+		'"':      {Key: "Digit2", Shift: true},
+		'²':      {Key: "Digit2", AltRight: true},
+		'<':      {Key: "Digit2", AltRight: true}, // non-US < and >
+		'3':      {Key: "Digit3"},
+		'§':      {Key: "Digit3", Shift: true},
+		'³':      {Key: "Digit3", AltRight: true},
+		'>':      {Key: "Digit3", AltRight: true}, // non-US < and >
+		'4':      {Key: "Digit4"},
+		'$':      {Key: "Digit4", Shift: true},
+		'—':      {Key: "Digit4", AltRight: true}, // em dash, —
+		'5':      {Key: "Digit5"},
+		'%':      {Key: "Digit5", Shift: true},
+		'¡':      {Key: "Digit5", AltRight: true}, // inverted exclamation mark, ¡
+		'6':      {Key: "Digit6"},
+		'&':      {Key: "Digit6", Shift: true},
+		'¿':      {Key: "Digit6", AltRight: true}, // inverted question mark, ¿
+		'7':      {Key: "Digit7"},
+		'/':      {Key: "Digit7", Shift: true},
+		'{':      {Key: "Digit7", AltRight: true},
+		'8':      {Key: "Digit8"},
+		'(':      {Key: "Digit8", Shift: true},
+		'[':      {Key: "Digit8", AltRight: true},
+		'9':      {Key: "Digit9"},
+		')':      {Key: "Digit9", Shift: true},
+		']':      {Key: "Digit9", AltRight: true},
+		'0':      {Key: "Digit0"},
+		'=':      {Key: "Digit0", Shift: true},
+		'}':      {Key: "Digit0", AltRight: true},
+		'ß':      {Key: "Minus"},
+		'?':      {Key: "Minus", Shift: true},
+		'\\':     {Key: "Minus", AltRight: true},
+		'´':      {Key: "Equal", DeadKey: true},                 // accent acute, mark ´ placed above the letter
+		'`':      {Key: "Equal", Shift: true, DeadKey: true},    // accent grave, mark ` placed above the letter
+		'˙':      {Key: "Equal", AltRight: true, DeadKey: true}, // acute accent, mark ˙ placed above the letter
+		'ü':      {Key: "BracketLeft"},
+		'Ü':      {Key: "BracketLeft", Shift: true},
+		'ʼ':      {Key: "BracketLeft", AltRight: true}, // modifier letter apostrophe, ʼ
+		'+':      {Key: "BracketRight"},
+		'*':      {Key: "BracketRight", Shift: true},
+		'~':      {Key: "BracketRight", AltRight: true},
+		'ö':      {Key: "Semicolon"},
+		'Ö':      {Key: "Semicolon", Shift: true},
+		'ˌ':      {Key: "Semicolon", AltRight: true}, // modifier letter low vertical line, ˌ
+		'ä':      {Key: "Quote"},
+		'Ä':      {Key: "Quote", Shift: true},
+		'˗':      {Key: "Quote", AltRight: true, DeadKey: true}, // modifier letter minus sign, ˗
+		'#':      {Key: "Backslash"},
+		'\'':     {Key: "Backslash", Shift: true},
+		'−':      {Key: "Backslash", AltRight: true}, // minus sign, −
+		',':      {Key: "Comma"},
+		';':      {Key: "Comma", Shift: true},
+		'‑':      {Key: "Comma", AltRight: true}, // non-breaking hyphen, ‑
+		'.':      {Key: "Period"},
+		':':      {Key: "Period", Shift: true},
+		'·':      {Key: "Period", AltRight: true}, // middle dot, ·
+		'-':      {Key: "Slash"},
+		'_':      {Key: "Slash", Shift: true},
+		'\u00ad': {Key: "Slash", AltRight: true}, // soft hyphen, ­
+		' ':      {Key: "Space"},
+		'\n':     {Key: "Enter"},
+	},
+}
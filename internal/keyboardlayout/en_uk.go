@@ -0,0 +1,109 @@
+package keyboardlayout
+
+// en-UK keyboard layout, kept in sync with ui/src/keyboardLayouts/en_UK.ts
+
+var enUK = &Layout{
+	ISOCode: "en-UK",
+	Name:    "English (UK)",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyA", Shift: true},
+		'B':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'D':  {Key: "KeyD", Shift: true},
+		'E':  {Key: "KeyE", Shift: true},
+		'F':  {Key: "KeyF", Shift: true},
+		'G':  {Key: "KeyG", Shift: true},
+		'H':  {Key: "KeyH", Shift: true},
+		'I':  {Key: "KeyI", Shift: true},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "KeyM", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'P':  {Key: "KeyP", Shift: true},
+		'Q':  {Key: "KeyQ", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'S':  {Key: "KeyS", Shift: true},
+		'T':  {Key: "KeyT", Shift: true},
+		'U':  {Key: "KeyU", Shift: true},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyW", Shift: true},
+		'X':  {Key: "KeyX", Shift: true},
+		'Y':  {Key: "KeyY", Shift: true},
+		'Z':  {Key: "KeyZ", Shift: true},
+		'a':  {Key: "KeyA"},
+		'b':  {Key: "KeyB"},
+		'c':  {Key: "KeyC"},
+		'd':  {Key: "KeyD"},
+		'e':  {Key: "KeyE"},
+		'f':  {Key: "KeyF"},
+		'g':  {Key: "KeyG"},
+		'h':  {Key: "KeyH"},
+		'i':  {Key: "KeyI"},
+		'j':  {Key: "KeyJ"},
+		'k':  {Key: "KeyK"},
+		'l':  {Key: "KeyL"},
+		'm':  {Key: "KeyM"},
+		'n':  {Key: "KeyN"},
+		'o':  {Key: "KeyO"},
+		'p':  {Key: "KeyP"},
+		'q':  {Key: "KeyQ"},
+		'r':  {Key: "KeyR"},
+		's':  {Key: "KeyS"},
+		't':  {Key: "KeyT"},
+		'u':  {Key: "KeyU"},
+		'v':  {Key: "KeyV"},
+		'w':  {Key: "KeyW"},
+		'x':  {Key: "KeyX"},
+		'y':  {Key: "KeyY"},
+		'z':  {Key: "KeyZ"},
+		'1':  {Key: "Digit1"},
+		'!':  {Key: "Digit1", Shift: true},
+		'2':  {Key: "Digit2"},
+		'"':  {Key: "Digit2", Shift: true},
+		'3':  {Key: "Digit3"},
+		'£':  {Key: "Digit3", Shift: true},
+		'4':  {Key: "Digit4"},
+		'$':  {Key: "Digit4", Shift: true},
+		'€':  {Key: "Digit4", AltRight: true},
+		'5':  {Key: "Digit5"},
+		'%':  {Key: "Digit5", Shift: true},
+		'6':  {Key: "Digit6"},
+		'^':  {Key: "Digit6", Shift: true},
+		'7':  {Key: "Digit7"},
+		'&':  {Key: "Digit7", Shift: true},
+		'8':  {Key: "Digit8"},
+		'*':  {Key: "Digit8", Shift: true},
+		'9':  {Key: "Digit9"},
+		'(':  {Key: "Digit9", Shift: true},
+		'0':  {Key: "Digit0"},
+		')':  {Key: "Digit0", Shift: true},
+		'-':  {Key: "Minus"},
+		'_':  {Key: "Minus", Shift: true},
+		'=':  {Key: "Equal"},
+		'+':  {Key: "Equal", Shift: true},
+		'\'': {Key: "Quote"},
+		'@':  {Key: "Quote", Shift: true},
+		',':  {Key: "Comma"},
+		'<':  {Key: "Comma", Shift: true},
+		'/':  {Key: "Slash"},
+		'?':  {Key: "Slash", Shift: true},
+		'.':  {Key: "Period"},
+		'>':  {Key: "Period", Shift: true},
+		';':  {Key: "Semicolon"},
+		':':  {Key: "Semicolon", Shift: true},
+		'[':  {Key: "BracketLeft"},
+		'{':  {Key: "BracketLeft", Shift: true},
+		']':  {Key: "BracketRight"},
+		'}':  {Key: "BracketRight", Shift: true},
+		'#':  {Key: "Backslash"},
+		'~':  {Key: "Backslash", Shift: true},
+		'`':  {Key: "Backquote"},
+		'¬':  {Key: "Backquote", Shift: true},
+		'\\': {Key: "IntlBackslash"},
+		'|':  {Key: "IntlBackslash", Shift: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
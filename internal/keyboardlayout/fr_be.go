@@ -0,0 +1,171 @@
+package keyboardlayout
+
+// nl-BE keyboard layout, kept in sync with ui/src/keyboardLayouts/fr_BE.ts
+
+var (
+	frBEKeyTrema = KeyInfo{Key: "BracketLeft", Shift: true}  // tréma (umlaut), two dots placed above a vowel
+	frBEKeyHat   = KeyInfo{Key: "BracketLeft"}               // accent circonflexe (accent hat), mark ^ placed above the letter
+	frBEKeyAcute = KeyInfo{Key: "Semicolon", AltRight: true} // accent aigu (acute accent), mark ´ placed above the letter
+	frBEKeyGrave = KeyInfo{Key: "Quote", Shift: true}        // accent grave, mark ` placed above the letter
+	frBEKeyTilde = KeyInfo{Key: "Slash", AltRight: true}     // tilde, mark ~ placed above the letter
+)
+
+var frBE = &Layout{
+	ISOCode: "nl-BE",
+	Name:    "Belgisch Nederlands",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyQ", Shift: true},
+		'Ä':  {Key: "KeyQ", Shift: true, AccentKey: &frBEKeyTrema},
+		'Â':  {Key: "KeyQ", Shift: true, AccentKey: &frBEKeyHat},
+		'Á':  {Key: "KeyQ", Shift: true, AccentKey: &frBEKeyAcute},
+		'À':  {Key: "KeyQ", Shift: true, AccentKey: &frBEKeyGrave},
+		'Ã':  {Key: "KeyQ", Shift: true, AccentKey: &frBEKeyTilde},
+		'B':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'D':  {Key: "KeyD", Shift: true},
+		'E':  {Key: "KeyE", Shift: true},
+		'Ë':  {Key: "KeyE", Shift: true, AccentKey: &frBEKeyTrema},
+		'Ê':  {Key: "KeyE", Shift: true, AccentKey: &frBEKeyHat},
+		'É':  {Key: "KeyE", Shift: true, AccentKey: &frBEKeyAcute},
+		'È':  {Key: "KeyE", Shift: true, AccentKey: &frBEKeyGrave},
+		'Ẽ':  {Key: "KeyE", Shift: true, AccentKey: &frBEKeyTilde},
+		'F':  {Key: "KeyF", Shift: true},
+		'G':  {Key: "KeyG", Shift: true},
+		'H':  {Key: "KeyH", Shift: true},
+		'I':  {Key: "KeyI", Shift: true},
+		'Ï':  {Key: "KeyI", Shift: true, AccentKey: &frBEKeyTrema},
+		'Î':  {Key: "KeyI", Shift: true, AccentKey: &frBEKeyHat},
+		'Í':  {Key: "KeyI", Shift: true, AccentKey: &frBEKeyAcute},
+		'Ì':  {Key: "KeyI", Shift: true, AccentKey: &frBEKeyGrave},
+		'Ĩ':  {Key: "KeyI", Shift: true, AccentKey: &frBEKeyTilde},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "Semicolon", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'Ö':  {Key: "KeyO", Shift: true, AccentKey: &frBEKeyTrema},
+		'Ô':  {Key: "KeyO", Shift: true, AccentKey: &frBEKeyHat},
+		'Ó':  {Key: "KeyO", Shift: true, AccentKey: &frBEKeyAcute},
+		'Ò':  {Key: "KeyO", Shift: true, AccentKey: &frBEKeyGrave},
+		'Õ':  {Key: "KeyO", Shift: true, AccentKey: &frBEKeyTilde},
+		'P':  {Key: "KeyP", Shift: true},
+		'Q':  {Key: "KeyA", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'S':  {Key: "KeyS", Shift: true},
+		'T':  {Key: "KeyT", Shift: true},
+		'U':  {Key: "KeyU", Shift: true},
+		'Ü':  {Key: "KeyU", Shift: true, AccentKey: &frBEKeyTrema},
+		'Û':  {Key: "KeyU", Shift: true, AccentKey: &frBEKeyHat},
+		'Ú':  {Key: "KeyU", Shift: true, AccentKey: &frBEKeyAcute},
+		'Ù':  {Key: "KeyU", Shift: true, AccentKey: &frBEKeyGrave},
+		'Ũ':  {Key: "KeyU", Shift: true, AccentKey: &frBEKeyTilde},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyW", Shift: true},
+		'X':  {Key: "KeyX", Shift: true},
+		'Y':  {Key: "KeyZ", Shift: true},
+		'Z':  {Key: "KeyY", Shift: true},
+		'a':  {Key: "KeyQ"},
+		'ä':  {Key: "KeyQ", AccentKey: &frBEKeyTrema},
+		'â':  {Key: "KeyQ", AccentKey: &frBEKeyHat},
+		'á':  {Key: "KeyQ", AccentKey: &frBEKeyAcute},
+		'ã':  {Key: "KeyQ", AccentKey: &frBEKeyTilde},
+		'b':  {Key: "KeyB"},
+		'c':  {Key: "KeyC"},
+		'd':  {Key: "KeyD"},
+		'e':  {Key: "KeyE"},
+		'ë':  {Key: "KeyE", AccentKey: &frBEKeyTrema},
+		'ê':  {Key: "KeyE", AccentKey: &frBEKeyHat},
+		'ẽ':  {Key: "KeyE", AccentKey: &frBEKeyTilde},
+		'€':  {Key: "KeyE", AltRight: true},
+		'f':  {Key: "KeyF"},
+		'g':  {Key: "KeyG"},
+		'h':  {Key: "KeyH"},
+		'i':  {Key: "KeyI"},
+		'ï':  {Key: "KeyI", AccentKey: &frBEKeyTrema},
+		'î':  {Key: "KeyI", AccentKey: &frBEKeyHat},
+		'í':  {Key: "KeyI", AccentKey: &frBEKeyAcute},
+		'ì':  {Key: "KeyI", AccentKey: &frBEKeyGrave},
+		'ĩ':  {Key: "KeyI", AccentKey: &frBEKeyTilde},
+		'j':  {Key: "KeyJ"},
+		'k':  {Key: "KeyK"},
+		'l':  {Key: "KeyL"},
+		'm':  {Key: "Semicolon"},
+		'n':  {Key: "KeyN"},
+		'o':  {Key: "KeyO"},
+		'ö':  {Key: "KeyO", AccentKey: &frBEKeyTrema},
+		'ó':  {Key: "KeyO", AccentKey: &frBEKeyAcute},
+		'ô':  {Key: "KeyO", AccentKey: &frBEKeyHat},
+		'ò':  {Key: "KeyO", AccentKey: &frBEKeyGrave},
+		'õ':  {Key: "KeyO", AccentKey: &frBEKeyTilde},
+		'p':  {Key: "KeyP"},
+		'q':  {Key: "KeyA"},
+		'r':  {Key: "KeyR"},
+		's':  {Key: "KeyS"},
+		't':  {Key: "KeyT"},
+		'u':  {Key: "KeyU"},
+		'ü':  {Key: "KeyU", AccentKey: &frBEKeyTrema},
+		'û':  {Key: "KeyU", AccentKey: &frBEKeyHat},
+		'ú':  {Key: "KeyU", AccentKey: &frBEKeyAcute},
+		'ũ':  {Key: "KeyU", AccentKey: &frBEKeyTilde},
+		'v':  {Key: "KeyV"},
+		'w':  {Key: "KeyW"},
+		'x':  {Key: "KeyX"},
+		'y':  {Key: "KeyZ"},
+		'z':  {Key: "KeyY"},
+		'²':  {Key: "Backquote"},
+		'³':  {Key: "Backquote", Shift: true},
+		'&':  {Key: "Digit1"},
+		'1':  {Key: "Digit1", Shift: true},
+		'|':  {Key: "Digit1", AltRight: true},
+		'é':  {Key: "Digit2"},
+		'2':  {Key: "Digit2", Shift: true},
+		'@':  {Key: "Digit2", AltRight: true},
+		'"':  {Key: "Digit3"},
+		'3':  {Key: "Digit3", Shift: true},
+		'#':  {Key: "Digit3", AltRight: true},
+		'\'': {Key: "Digit4"},
+		'4':  {Key: "Digit4", Shift: true},
+		'(':  {Key: "Digit5"},
+		'5':  {Key: "Digit5", Shift: true},
+		'§':  {Key: "Digit6"},
+		'6':  {Key: "Digit6", Shift: true},
+		'^':  {Key: "Digit6", AltRight: true},
+		'è':  {Key: "Digit7"},
+		'7':  {Key: "Digit7", Shift: true},
+		'!':  {Key: "Digit8"},
+		'8':  {Key: "Digit8", Shift: true},
+		'ç':  {Key: "Digit9"},
+		'9':  {Key: "Digit9", Shift: true},
+		'{':  {Key: "Digit9", AltRight: true},
+		'à':  {Key: "Digit0"},
+		'0':  {Key: "Digit0", Shift: true},
+		'}':  {Key: "Digit0", AltRight: true},
+		')':  {Key: "Minus"},
+		'°':  {Key: "Minus", Shift: true},
+		'-':  {Key: "Equal", DeadKey: true},
+		'_':  {Key: "Equal", Shift: true},
+		'[':  {Key: "BracketLeft", AltRight: true},
+		'$':  {Key: "BracketRight"},
+		'*':  {Key: "BracketRight", AltRight: true},
+		']':  {Key: "BracketRight", AltRight: true},
+		'ù':  {Key: "Quote"},
+		'%':  {Key: "Quote", Shift: true},
+		'µ':  {Key: "Backslash"},
+		'£':  {Key: "Backslash", Shift: true},
+		',':  {Key: "KeyM"},
+		'?':  {Key: "KeyM", Shift: true},
+		';':  {Key: "Comma"},
+		'.':  {Key: "Comma", Shift: true},
+		':':  {Key: "Period"},
+		'/':  {Key: "Period", Shift: true},
+		'=':  {Key: "Slash"},
+		'+':  {Key: "Slash", Shift: true},
+		'~':  {Key: "Slash", DeadKey: true},
+		'<':  {Key: "IntlBackslash"},
+		'>':  {Key: "IntlBackslash", Shift: true},
+		'\\': {Key: "IntlBackslash", AltRight: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
@@ -0,0 +1,16 @@
+package keyboardlayout
+
+// fr-CH keyboard layout, kept in sync with ui/src/keyboardLayouts/fr_CH.ts
+
+var frCH = &Layout{
+	ISOCode: "fr-CH",
+	Name:    "Français de Suisse",
+	Chars: extendChars(deCH.Chars, map[rune]KeyCombo{
+		'è': {Key: "BracketLeft"},
+		'ü': {Key: "BracketLeft", Shift: true},
+		'é': {Key: "Semicolon"},
+		'ö': {Key: "Semicolon", Shift: true},
+		'à': {Key: "Quote"},
+		'ä': {Key: "Quote", Shift: true},
+	}),
+}
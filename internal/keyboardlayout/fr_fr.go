@@ -0,0 +1,143 @@
+package keyboardlayout
+
+// fr-FR keyboard layout, kept in sync with ui/src/keyboardLayouts/fr_FR.ts
+
+var (
+	frFRKeyTrema = KeyInfo{Key: "BracketLeft", Shift: true} // tréma (umlaut), two dots placed above a vowel
+	frFRKeyHat   = KeyInfo{Key: "BracketLeft"}              // accent circonflexe (accent hat), mark ^ placed above the letter
+)
+
+var frFR = &Layout{
+	ISOCode: "fr-FR",
+	Name:    "Français",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyQ", Shift: true},
+		'Ä':  {Key: "KeyQ", Shift: true, AccentKey: &frFRKeyTrema},
+		'Â':  {Key: "KeyQ", Shift: true, AccentKey: &frFRKeyHat},
+		'B':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'D':  {Key: "KeyD", Shift: true},
+		'E':  {Key: "KeyE", Shift: true},
+		'Ë':  {Key: "KeyE", Shift: true, AccentKey: &frFRKeyTrema},
+		'Ê':  {Key: "KeyE", Shift: true, AccentKey: &frFRKeyHat},
+		'F':  {Key: "KeyF", Shift: true},
+		'G':  {Key: "KeyG", Shift: true},
+		'H':  {Key: "KeyH", Shift: true},
+		'I':  {Key: "KeyI", Shift: true},
+		'Ï':  {Key: "KeyI", Shift: true, AccentKey: &frFRKeyTrema},
+		'Î':  {Key: "KeyI", Shift: true, AccentKey: &frFRKeyHat},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "Semicolon", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'Ö':  {Key: "KeyO", Shift: true, AccentKey: &frFRKeyTrema},
+		'Ô':  {Key: "KeyO", Shift: true, AccentKey: &frFRKeyHat},
+		'P':  {Key: "KeyP", Shift: true},
+		'Q':  {Key: "KeyA", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'S':  {Key: "KeyS", Shift: true},
+		'T':  {Key: "KeyT", Shift: true},
+		'U':  {Key: "KeyU", Shift: true},
+		'Ü':  {Key: "KeyU", Shift: true, AccentKey: &frFRKeyTrema},
+		'Û':  {Key: "KeyU", Shift: true, AccentKey: &frFRKeyHat},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyZ", Shift: true},
+		'X':  {Key: "KeyX", Shift: true},
+		'Y':  {Key: "KeyY", Shift: true},
+		'Z':  {Key: "KeyW", Shift: true},
+		'a':  {Key: "KeyQ"},
+		'ä':  {Key: "KeyQ", AccentKey: &frFRKeyTrema},
+		'â':  {Key: "KeyQ", AccentKey: &frFRKeyHat},
+		'b':  {Key: "KeyB"},
+		'c':  {Key: "KeyC"},
+		'd':  {Key: "KeyD"},
+		'e':  {Key: "KeyE"},
+		'ë':  {Key: "KeyE", AccentKey: &frFRKeyTrema},
+		'ê':  {Key: "KeyE", AccentKey: &frFRKeyHat},
+		'€':  {Key: "KeyE", AltRight: true},
+		'f':  {Key: "KeyF"},
+		'g':  {Key: "KeyG"},
+		'h':  {Key: "KeyH"},
+		'i':  {Key: "KeyI"},
+		'ï':  {Key: "KeyI", AccentKey: &frFRKeyTrema},
+		'î':  {Key: "KeyI", AccentKey: &frFRKeyHat},
+		'j':  {Key: "KeyJ"},
+		'k':  {Key: "KeyK"},
+		'l':  {Key: "KeyL"},
+		'm':  {Key: "Semicolon"},
+		'n':  {Key: "KeyN"},
+		'o':  {Key: "KeyO"},
+		'ö':  {Key: "KeyO", AccentKey: &frFRKeyTrema},
+		'ô':  {Key: "KeyO", AccentKey: &frFRKeyHat},
+		'p':  {Key: "KeyP"},
+		'q':  {Key: "KeyA"},
+		'r':  {Key: "KeyR"},
+		's':  {Key: "KeyS"},
+		't':  {Key: "KeyT"},
+		'u':  {Key: "KeyU"},
+		'ü':  {Key: "KeyU", AccentKey: &frFRKeyTrema},
+		'û':  {Key: "KeyU", AccentKey: &frFRKeyHat},
+		'v':  {Key: "KeyV"},
+		'w':  {Key: "KeyZ"},
+		'x':  {Key: "KeyX"},
+		'y':  {Key: "KeyY"},
+		'z':  {Key: "KeyW"},
+		'²':  {Key: "Backquote"},
+		'&':  {Key: "Digit1"},
+		'1':  {Key: "Digit1", Shift: true},
+		'é':  {Key: "Digit2"},
+		'2':  {Key: "Digit2", Shift: true},
+		'~':  {Key: "Digit2", AltRight: true},
+		'"':  {Key: "Digit3"},
+		'3':  {Key: "Digit3", Shift: true},
+		'#':  {Key: "Digit3", AltRight: true},
+		'\'': {Key: "Digit4"},
+		'4':  {Key: "Digit4", Shift: true},
+		'{':  {Key: "Digit4", AltRight: true},
+		'(':  {Key: "Digit5"},
+		'5':  {Key: "Digit5", Shift: true},
+		'[':  {Key: "Digit5", AltRight: true},
+		'-':  {Key: "Digit6"},
+		'6':  {Key: "Digit6", Shift: true},
+		'|':  {Key: "Digit6", AltRight: true},
+		'è':  {Key: "Digit7"},
+		'7':  {Key: "Digit7", Shift: true},
+		'`':  {Key: "Digit7", AltRight: true},
+		'_':  {Key: "Digit8"},
+		'8':  {Key: "Digit8", Shift: true},
+		'\\': {Key: "Digit8", AltRight: true},
+		'ç':  {Key: "Digit9"},
+		'9':  {Key: "Digit9", Shift: true},
+		'^':  {Key: "Digit9", AltRight: true},
+		'à':  {Key: "Digit0"},
+		'0':  {Key: "Digit0", Shift: true},
+		'@':  {Key: "Digit0", AltRight: true},
+		')':  {Key: "Minus"},
+		'°':  {Key: "Minus", Shift: true},
+		']':  {Key: "Minus", AltRight: true},
+		'=':  {Key: "Equal"},
+		'+':  {Key: "Equal", Shift: true},
+		'}':  {Key: "Equal", AltRight: true},
+		'$':  {Key: "BracketRight"},
+		'£':  {Key: "BracketRight", Shift: true},
+		'¤':  {Key: "BracketRight", AltRight: true},
+		'ù':  {Key: "Quote"},
+		'%':  {Key: "Quote", Shift: true},
+		'*':  {Key: "Backslash"},
+		'µ':  {Key: "Backslash", Shift: true},
+		',':  {Key: "KeyM"},
+		'?':  {Key: "KeyM", Shift: true},
+		';':  {Key: "Comma"},
+		'.':  {Key: "Comma", Shift: true},
+		':':  {Key: "Period"},
+		'/':  {Key: "Period", Shift: true},
+		'!':  {Key: "Slash"},
+		'§':  {Key: "Slash", Shift: true},
+		'<':  {Key: "IntlBackslash"},
+		'>':  {Key: "IntlBackslash", Shift: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
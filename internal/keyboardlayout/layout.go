@@ -0,0 +1,167 @@
+// Package keyboardlayout translates text into HID key strokes for the keyboard
+// layout configured on the host.
+//
+// The tables mirror the layouts offered by the web UI (ui/src/keyboardLayouts),
+// so typing through the API produces the same key strokes as pasting in the browser.
+package keyboardlayout
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// DefaultLayout is used when no layout is configured.
+	DefaultLayout = "en-US"
+
+	modifierMaskLeftShift = 0x02
+	modifierMaskRightAlt  = 0x40 // AltGr
+)
+
+// KeyInfo is a key and the modifiers needed to produce a character.
+type KeyInfo struct {
+	Key      string
+	Shift    bool
+	AltRight bool
+}
+
+// KeyCombo describes how to type a character.
+type KeyCombo struct {
+	Key      string
+	Shift    bool
+	AltRight bool
+	// DeadKey is set when the key is a dead key, it is followed by a space to
+	// emit the accent on its own.
+	DeadKey bool
+	// AccentKey is the dead key typed before Key, e.g. ´ before e for é.
+	AccentKey *KeyInfo
+}
+
+// Layout is a keyboard layout.
+type Layout struct {
+	ISOCode string
+	Name    string
+	Chars   map[rune]KeyCombo
+}
+
+// KeyStroke is a single key pressed together with its modifiers.
+type KeyStroke struct {
+	Modifier byte
+	Key      byte
+}
+
+var layouts = newRegistry(
+	csCZ, daDK, deCH, deDE, enUK, enUS, esES, frBE, frCH, frFR, itIT, jaJP, nbNO, svSE,
+)
+
+func newRegistry(l ...*Layout) map[string]*Layout {
+	m := make(map[string]*Layout, len(l))
+	for _, layout := range l {
+		m[layout.ISOCode] = layout
+	}
+	return m
+}
+
+func extendChars(base map[rune]KeyCombo, chars map[rune]KeyCombo) map[rune]KeyCombo {
+	m := make(map[rune]KeyCombo, len(base)+len(chars))
+	for c, k := range base {
+		m[c] = k
+	}
+	for c, k := range chars {
+		m[c] = k
+	}
+	return m
+}
+
+// Get returns the layout for the given ISO code, e.g. "de-DE".
+func Get(isoCode string) (*Layout, error) {
+	layout, ok := layouts[isoCode]
+	if !ok {
+		return nil, fmt.Errorf("unknown keyboard layout: %s", isoCode)
+	}
+	return layout, nil
+}
+
+// List returns the ISO codes of all known layouts, sorted.
+func List() []string {
+	codes := make([]string, 0, len(layouts))
+	for code := range layouts {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// KeyCode returns the HID usage of a key by its name, e.g. "KeyA" or "ControlLeft".
+// The names are the ones used by the web UI (ui/src/keyboardMappings.ts).
+func KeyCode(name string) (byte, bool) {
+	code, ok := keyCodes[name]
+	return code, ok
+}
+
+func keyStroke(key string, shift bool, altRight bool) (KeyStroke, bool) {
+	code, ok := keyCodes[key]
+	if !ok {
+		return KeyStroke{}, false
+	}
+
+	s := KeyStroke{Key: code}
+	if shift {
+		s.Modifier |= modifierMaskLeftShift
+	}
+	if altRight {
+		s.Modifier |= modifierMaskRightAlt
+	}
+	return s, true
+}
+
+// CharStrokes returns the key strokes needed to type c.
+func (l *Layout) CharStrokes(c rune) ([]KeyStroke, bool) {
+	combo, ok := l.Chars[c]
+	if !ok {
+		// tabs aren't part of the UI tables but are the same on every layout
+		if c != '\t' {
+			return nil, false
+		}
+		combo = KeyCombo{Key: "Tab"}
+	}
+
+	strokes := make([]KeyStroke, 0, 3)
+
+	// an accented character is typed as the accent (dead key) followed by the letter
+	if combo.AccentKey != nil {
+		accent, ok := keyStroke(combo.AccentKey.Key, combo.AccentKey.Shift, combo.AccentKey.AltRight)
+		if !ok {
+			return nil, false
+		}
+		strokes = append(strokes, accent)
+	}
+
+	stroke, ok := keyStroke(combo.Key, combo.Shift, combo.AltRight)
+	if !ok {
+		return nil, false
+	}
+	strokes = append(strokes, stroke)
+
+	// a dead key on its own needs a space to emit the accent
+	if combo.DeadKey {
+		space, _ := keyStroke("Space", false, false)
+		strokes = append(strokes, space)
+	}
+
+	return strokes, true
+}
+
+// Strokes returns the key strokes needed to type text, characters that can't be
+// typed on this layout are skipped and returned in invalid.
+func (l *Layout) Strokes(text string) (strokes []KeyStroke, invalid []rune) {
+	for _, c := range text {
+		s, ok := l.CharStrokes(c)
+		if !ok {
+			invalid = append(invalid, c)
+			continue
+		}
+		strokes = append(strokes, s...)
+	}
+	return strokes, invalid
+}
@@ -0,0 +1,55 @@
+package keyboardlayout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrokes(t *testing.T) {
+	assert := assert.New(t)
+
+	de, err := Get("de-DE")
+	assert.NoError(err)
+
+	// y and z are swapped, @ is AltGr+Q
+	strokes, invalid := de.Strokes("zY@")
+	assert.Empty(invalid)
+	assert.Equal([]KeyStroke{
+		{Key: keyCodes["KeyY"]},
+		{Modifier: modifierMaskLeftShift, Key: keyCodes["KeyZ"]},
+		{Modifier: modifierMaskRightAlt, Key: keyCodes["KeyQ"]},
+	}, strokes)
+
+	// é is the acute dead key followed by e, ^ is a dead key followed by space
+	strokes, invalid = de.Strokes("é^")
+	assert.Empty(invalid)
+	assert.Equal([]KeyStroke{
+		{Key: keyCodes["Equal"]},
+		{Key: keyCodes["KeyE"]},
+		{Key: keyCodes["Backquote"]},
+		{Key: keyCodes["Space"]},
+	}, strokes)
+
+	strokes, invalid = de.Strokes("a\tb€ж")
+	assert.Equal([]rune{'ж'}, invalid)
+	assert.Len(strokes, 4)
+
+	_, err = Get("xx-XX")
+	assert.Error(err)
+}
+
+func TestLayoutKeys(t *testing.T) {
+	for _, code := range List() {
+		layout, err := Get(code)
+		assert.NoError(t, err)
+		assert.Equal(t, code, layout.ISOCode)
+
+		for c, combo := range layout.Chars {
+			assert.Contains(t, keyCodes, combo.Key, "%s: %q", code, c)
+			if combo.AccentKey != nil {
+				assert.Contains(t, keyCodes, combo.AccentKey.Key, "%s: %q accent", code, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package keyboardlayout
+
+// nb-NO keyboard layout, kept in sync with ui/src/keyboardLayouts/nb_NO.ts
+
+var (
+	nbNOKeyTrema = KeyInfo{Key: "BracketRight"}                 // tréma (umlaut), two dots placed above a vowel
+	nbNOKeyAcute = KeyInfo{Key: "Equal", AltRight: true}        // accent aigu (acute accent), mark ´ placed above the letter
+	nbNOKeyHat   = KeyInfo{Key: "BracketRight", Shift: true}    // accent circonflexe (accent hat), mark ^ placed above the letter
+	nbNOKeyGrave = KeyInfo{Key: "Equal", Shift: true}           // accent grave, mark ` placed above the letter
+	nbNOKeyTilde = KeyInfo{Key: "BracketRight", AltRight: true} // tilde, mark ~ placed above the letter
+)
+
+var nbNO = &Layout{
+	ISOCode: "nb-NO",
+	Name:    "Norsk bokmål",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyA", Shift: true},
+		'Ä':  {Key: "KeyA", Shift: true, AccentKey: &nbNOKeyTrema},
+		'Á':  {Key: "KeyA", Shift: true, AccentKey: &nbNOKeyAcute},
+		'Â':  {Key: "KeyA", Shift: true, AccentKey: &nbNOKeyHat},
+		'À':  {Key: "KeyA", Shift: true, AccentKey: &nbNOKeyGrave},
+		'Ã':  {Key: "KeyA", Shift: true, AccentKey: &nbNOKeyTilde},
+		'B':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'D':  {Key: "KeyD", Shift: true},
+		'E':  {Key: "KeyE", Shift: true},
+		'Ë':  {Key: "KeyE", Shift: true, AccentKey: &nbNOKeyTrema},
+		'É':  {Key: "KeyE", Shift: true, AccentKey: &nbNOKeyAcute},
+		'Ê':  {Key: "KeyE", Shift: true, AccentKey: &nbNOKeyHat},
+		'È':  {Key: "KeyE", Shift: true, AccentKey: &nbNOKeyGrave},
+		'Ẽ':  {Key: "KeyE", Shift: true, AccentKey: &nbNOKeyTilde},
+		'F':  {Key: "KeyF", Shift: true},
+		'G':  {Key: "KeyG", Shift: true},
+		'H':  {Key: "KeyH", Shift: true},
+		'I':  {Key: "KeyI", Shift: true},
+		'Ï':  {Key: "KeyI", Shift: true, AccentKey: &nbNOKeyTrema},
+		'Í':  {Key: "KeyI", Shift: true, AccentKey: &nbNOKeyAcute},
+		'Î':  {Key: "KeyI", Shift: true, AccentKey: &nbNOKeyHat},
+		'Ì':  {Key: "KeyI", Shift: true, AccentKey: &nbNOKeyGrave},
+		'Ĩ':  {Key: "KeyI", Shift: true, AccentKey: &nbNOKeyTilde},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "KeyM", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'Ö':  {Key: "KeyO", Shift: true, AccentKey: &nbNOKeyTrema},
+		'Ó':  {Key: "KeyO", Shift: true, AccentKey: &nbNOKeyAcute},
+		'Ô':  {Key: "KeyO", Shift: true, AccentKey: &nbNOKeyHat},
+		'Ò':  {Key: "KeyO", Shift: true, AccentKey: &nbNOKeyGrave},
+		'Õ':  {Key: "KeyO", Shift: true, AccentKey: &nbNOKeyTilde},
+		'P':  {Key: "KeyP", Shift: true},
+		'Q':  {Key: "KeyQ", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'S':  {Key: "KeyS", Shift: true},
+		'T':  {Key: "KeyT", Shift: true},
+		'U':  {Key: "KeyU", Shift: true},
+		'Ü':  {Key: "KeyU", Shift: true, AccentKey: &nbNOKeyTrema},
+		'Ú':  {Key: "KeyU", Shift: true, AccentKey: &nbNOKeyAcute},
+		'Û':  {Key: "KeyU", Shift: true, AccentKey: &nbNOKeyHat},
+		'Ù':  {Key: "KeyU", Shift: true, AccentKey: &nbNOKeyGrave},
+		'Ũ':  {Key: "KeyU", Shift: true, AccentKey: &nbNOKeyTilde},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyW", Shift: true},
+		'X':  {Key: "KeyX", Shift: true},
+		'Y':  {Key: "KeyZ", Shift: true},
+		'Z':  {Key: "KeyY", Shift: true},
+		'a':  {Key: "KeyA"},
+		'ä':  {Key: "KeyA", AccentKey: &nbNOKeyTrema},
+		'á':  {Key: "KeyA", AccentKey: &nbNOKeyAcute},
+		'â':  {Key: "KeyA", AccentKey: &nbNOKeyHat},
+		'à':  {Key: "KeyA", AccentKey: &nbNOKeyGrave},
+		'ã':  {Key: "KeyA", AccentKey: &nbNOKeyTilde},
+		'b':  {Key: "KeyB"},
+		'c':  {Key: "KeyC"},
+		'd':  {Key: "KeyD"},
+		'e':  {Key: "KeyE"},
+		'ë':  {Key: "KeyE", AccentKey: &nbNOKeyTrema},
+		'é':  {Key: "KeyE", AccentKey: &nbNOKeyAcute},
+		'ê':  {Key: "KeyE", AccentKey: &nbNOKeyHat},
+		'è':  {Key: "KeyE", AccentKey: &nbNOKeyGrave},
+		'ẽ':  {Key: "KeyE", AccentKey: &nbNOKeyTilde},
+		'€':  {Key: "KeyE", AltRight: true},
+		'f':  {Key: "KeyF"},
+		'g':  {Key: "KeyG"},
+		'h':  {Key: "KeyH"},
+		'i':  {Key: "KeyI"},
+		'ï':  {Key: "KeyI", AccentKey: &nbNOKeyTrema},
+		'í':  {Key: "KeyI", AccentKey: &nbNOKeyAcute},
+		'î':  {Key: "KeyI", AccentKey: &nbNOKeyHat},
+		'ì':  {Key: "KeyI", AccentKey: &nbNOKeyGrave},
+		'ĩ':  {Key: "KeyI", AccentKey: &nbNOKeyTilde},
+		'j':  {Key: "KeyJ"},
+		'k':  {Key: "KeyK"},
+		'l':  {Key: "KeyL"},
+		'm':  {Key: "KeyM"},
+		'n':  {Key: "KeyN"},
+		'o':  {Key: "KeyO"},
+		'ö':  {Key: "KeyO", AccentKey: &nbNOKeyTrema},
+		'ó':  {Key: "KeyO", AccentKey: &nbNOKeyAcute},
+		'ô':  {Key: "KeyO", AccentKey: &nbNOKeyHat},
+		'ò':  {Key: "KeyO", AccentKey: &nbNOKeyGrave},
+		'õ':  {Key: "KeyO", AccentKey: &nbNOKeyTilde},
+		'p':  {Key: "KeyP"},
+		'q':  {Key: "KeyQ"},
+		'r':  {Key: "KeyR"},
+		's':  {Key: "KeyS"},
+		't':  {Key: "KeyT"},
+		'u':  {Key: "KeyU"},
+		'ü':  {Key: "KeyU", AccentKey: &nbNOKeyTrema},
+		'ú':  {Key: "KeyU", AccentKey: &nbNOKeyAcute},
+		'û':  {Key: "KeyU", AccentKey: &nbNOKeyHat},
+		'ù':  {Key: "KeyU", AccentKey: &nbNOKeyGrave},
+		'ũ':  {Key: "KeyU", AccentKey: &nbNOKeyTilde},
+		'v':  {Key: "KeyV"},
+		'w':  {Key: "KeyW"},
+		'x':  {Key: "KeyX"},
+		'y':  {Key: "KeyZ"},
+		'z':  {Key: "KeyY"},
+		'|':  {Key: "Backquote"},
+		'§':  {Key: "Backquote", Shift: true},
+		'1':  {Key: "Digit1"},
+		'!':  {Key: "Digit1", Shift: true},
+		'2':  {Key: "Digit2"},
+		'"':  {Key: "Digit2", Shift: true},
+		'@':  {Key: "Digit2", AltRight: true},
+		'3':  {Key: "Digit3"},
+		'#':  {Key: "Digit3", Shift: true},
+		'£':  {Key: "Digit3", AltRight: true},
+		'4':  {Key: "Digit4"},
+		'¤':  {Key: "Digit4", Shift: true},
+		'$':  {Key: "Digit4", AltRight: true},
+		'5':  {Key: "Digit5"},
+		'%':  {Key: "Digit5", Shift: true},
+		'6':  {Key: "Digit6"},
+		'&':  {Key: "Digit6", Shift: true},
+		'7':  {Key: "Digit7"},
+		'/':  {Key: "Digit7", Shift: true},
+		'{':  {Key: "Digit7", AltRight: true},
+		'8':  {Key: "Digit8"},
+		'(':  {Key: "Digit8", Shift: true},
+		'[':  {Key: "Digit8", AltRight: true},
+		'9':  {Key: "Digit9"},
+		')':  {Key: "Digit9", Shift: true},
+		']':  {Key: "Digit9", AltRight: true},
+		'0':  {Key: "Digit0"},
+		'=':  {Key: "Digit0", Shift: true},
+		'}':  {Key: "Digit0", AltRight: true},
+		'+':  {Key: "Minus"},
+		'?':  {Key: "Minus", Shift: true},
+		'\\': {Key: "Equal"},
+		'å':  {Key: "BracketLeft"},
+		'Å':  {Key: "BracketLeft", Shift: true},
+		'ø':  {Key: "Semicolon"},
+		'Ø':  {Key: "Semicolon", Shift: true},
+		'æ':  {Key: "Quote"},
+		'Æ':  {Key: "Quote", Shift: true},
+		'\'': {Key: "Backslash"},
+		'*':  {Key: "Backslash", Shift: true},
+		',':  {Key: "Comma"},
+		';':  {Key: "Comma", Shift: true},
+		'.':  {Key: "Period"},
+		':':  {Key: "Period", Shift: true},
+		'-':  {Key: "Slash"},
+		'_':  {Key: "Slash", Shift: true},
+		'<':  {Key: "IntlBackslash"},
+		'>':  {Key: "IntlBackslash", Shift: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
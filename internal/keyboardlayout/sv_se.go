@@ -0,0 +1,168 @@
+package keyboardlayout
+
+// sv-SE keyboard layout, kept in sync with ui/src/keyboardLayouts/sv_SE.ts
+
+var (
+	svSEKeyTrema = KeyInfo{Key: "BracketRight"}                 // tréma (umlaut), two dots placed above a vowel
+	svSEKeyAcute = KeyInfo{Key: "Equal"}                        // accent aigu (acute accent), mark ´ placed above the letter
+	svSEKeyHat   = KeyInfo{Key: "BracketRight", Shift: true}    // accent circonflexe (accent hat), mark ^ placed above the letter
+	svSEKeyGrave = KeyInfo{Key: "Equal", Shift: true}           // accent grave, mark ` placed above the letter
+	svSEKeyTilde = KeyInfo{Key: "BracketRight", AltRight: true} // tilde, mark ~ placed above the letter
+)
+
+var svSE = &Layout{
+	ISOCode: "sv-SE",
+	Name:    "Svenska",
+	Chars: map[rune]KeyCombo{
+		'A':  {Key: "KeyA", Shift: true},
+		'Á':  {Key: "KeyA", Shift: true, AccentKey: &svSEKeyAcute},
+		'Â':  {Key: "KeyA", Shift: true, AccentKey: &svSEKeyHat},
+		'À':  {Key: "KeyA", Shift: true, AccentKey: &svSEKeyGrave},
+		'Ã':  {Key: "KeyA", Shift: true, AccentKey: &svSEKeyTilde},
+		'B':  {Key: "KeyB", Shift: true},
+		'C':  {Key: "KeyC", Shift: true},
+		'D':  {Key: "KeyD", Shift: true},
+		'E':  {Key: "KeyE", Shift: true},
+		'Ë':  {Key: "KeyE", Shift: true, AccentKey: &svSEKeyTrema},
+		'É':  {Key: "KeyE", Shift: true, AccentKey: &svSEKeyAcute},
+		'Ê':  {Key: "KeyE", Shift: true, AccentKey: &svSEKeyHat},
+		'È':  {Key: "KeyE", Shift: true, AccentKey: &svSEKeyGrave},
+		'Ẽ':  {Key: "KeyE", Shift: true, AccentKey: &svSEKeyTilde},
+		'F':  {Key: "KeyF", Shift: true},
+		'G':  {Key: "KeyG", Shift: true},
+		'H':  {Key: "KeyH", Shift: true},
+		'I':  {Key: "KeyI", Shift: true},
+		'Ï':  {Key: "KeyI", Shift: true, AccentKey: &svSEKeyTrema},
+		'Í':  {Key: "KeyI", Shift: true, AccentKey: &svSEKeyAcute},
+		'Î':  {Key: "KeyI", Shift: true, AccentKey: &svSEKeyHat},
+		'Ì':  {Key: "KeyI", Shift: true, AccentKey: &svSEKeyGrave},
+		'Ĩ':  {Key: "KeyI", Shift: true, AccentKey: &svSEKeyTilde},
+		'J':  {Key: "KeyJ", Shift: true},
+		'K':  {Key: "KeyK", Shift: true},
+		'L':  {Key: "KeyL", Shift: true},
+		'M':  {Key: "KeyM", Shift: true},
+		'N':  {Key: "KeyN", Shift: true},
+		'O':  {Key: "KeyO", Shift: true},
+		'Ó':  {Key: "KeyO", Shift: true, AccentKey: &svSEKeyAcute},
+		'Ô':  {Key: "KeyO", Shift: true, AccentKey: &svSEKeyHat},
+		'Ò':  {Key: "KeyO", Shift: true, AccentKey: &svSEKeyGrave},
+		'Õ':  {Key: "KeyO", Shift: true, AccentKey: &svSEKeyTilde},
+		'P':  {Key: "KeyP", Shift: true},
+		'Q':  {Key: "KeyQ", Shift: true},
+		'R':  {Key: "KeyR", Shift: true},
+		'S':  {Key: "KeyS", Shift: true},
+		'T':  {Key: "KeyT", Shift: true},
+		'U':  {Key: "KeyU", Shift: true},
+		'Ü':  {Key: "KeyU", Shift: true, AccentKey: &svSEKeyTrema},
+		'Ú':  {Key: "KeyU", Shift: true, AccentKey: &svSEKeyAcute},
+		'Û':  {Key: "KeyU", Shift: true, AccentKey: &svSEKeyHat},
+		'Ù':  {Key: "KeyU", Shift: true, AccentKey: &svSEKeyGrave},
+		'Ũ':  {Key: "KeyU", Shift: true, AccentKey: &svSEKeyTilde},
+		'V':  {Key: "KeyV", Shift: true},
+		'W':  {Key: "KeyW", Shift: true},
+		'X':  {Key: "KeyX", Shift: true},
+		'Y':  {Key: "KeyY", Shift: true},
+		'Z':  {Key: "KeyZ", Shift: true},
+		'a':  {Key: "KeyA"},
+		'á':  {Key: "KeyA", AccentKey: &svSEKeyAcute},
+		'â':  {Key: "KeyA", AccentKey: &svSEKeyHat},
+		'à':  {Key: "KeyA", AccentKey: &svSEKeyGrave},
+		'ã':  {Key: "KeyA", AccentKey: &svSEKeyTilde},
+		'b':  {Key: "KeyB"},
+		'c':  {Key: "KeyC"},
+		'd':  {Key: "KeyD"},
+		'e':  {Key: "KeyE"},
+		'ë':  {Key: "KeyE", AccentKey: &svSEKeyTrema},
+		'é':  {Key: "KeyE", AccentKey: &svSEKeyAcute},
+		'ê':  {Key: "KeyE", AccentKey: &svSEKeyHat},
+		'è':  {Key: "KeyE", AccentKey: &svSEKeyGrave},
+		'ẽ':  {Key: "KeyE", AccentKey: &svSEKeyTilde},
+		'€':  {Key: "KeyE", AltRight: true},
+		'f':  {Key: "KeyF"},
+		'g':  {Key: "KeyG"},
+		'h':  {Key: "KeyH"},
+		'i':  {Key: "KeyI"},
+		'ï':  {Key: "KeyI", AccentKey: &svSEKeyTrema},
+		'í':  {Key: "KeyI", AccentKey: &svSEKeyAcute},
+		'î':  {Key: "KeyI", AccentKey: &svSEKeyHat},
+		'ì':  {Key: "KeyI", AccentKey: &svSEKeyGrave},
+		'ĩ':  {Key: "KeyI", AccentKey: &svSEKeyTilde},
+		'j':  {Key: "KeyJ"},
+		'k':  {Key: "KeyK"},
+		'l':  {Key: "KeyL"},
+		'm':  {Key: "KeyM"},
+		'n':  {Key: "KeyN"},
+		'o':  {Key: "KeyO"},
+		'ó':  {Key: "KeyO", AccentKey: &svSEKeyAcute},
+		'ô':  {Key: "KeyO", AccentKey: &svSEKeyHat},
+		'ò':  {Key: "KeyO", AccentKey: &svSEKeyGrave},
+		'õ':  {Key: "KeyO", AccentKey: &svSEKeyTilde},
+		'p':  {Key: "KeyP"},
+		'q':  {Key: "KeyQ"},
+		'r':  {Key: "KeyR"},
+		's':  {Key: "KeyS"},
+		't':  {Key: "KeyT"},
+		'u':  {Key: "KeyU"},
+		'ü':  {Key: "KeyU", AccentKey: &svSEKeyTrema},
+		'ú':  {Key: "KeyU", AccentKey: &svSEKeyAcute},
+		'û':  {Key: "KeyU", AccentKey: &svSEKeyHat},
+		'ù':  {Key: "KeyU", AccentKey: &svSEKeyGrave},
+		'ũ':  {Key: "KeyU", AccentKey: &svSEKeyTilde},
+		'v':  {Key: "KeyV"},
+		'w':  {Key: "KeyW"},
+		'x':  {Key: "KeyX"},
+		'y':  {Key: "KeyY"},
+		'z':  {Key: "KeyZ"},
+		'§':  {Key: "Backquote"},
+		'½':  {Key: "Backquote", Shift: true},
+		'1':  {Key: "Digit1"},
+		'!':  {Key: "Digit1", Shift: true},
+		'2':  {Key: "Digit2"},
+		'"':  {Key: "Digit2", Shift: true},
+		'@':  {Key: "Digit2", AltRight: true},
+		'3':  {Key: "Digit3"},
+		'#':  {Key: "Digit3", Shift: true},
+		'£':  {Key: "Digit3", AltRight: true},
+		'4':  {Key: "Digit4"},
+		'¤':  {Key: "Digit4", Shift: true},
+		'$':  {Key: "Digit4", AltRight: true},
+		'5':  {Key: "Digit5"},
+		'%':  {Key: "Digit5", Shift: true},
+		'6':  {Key: "Digit6"},
+		'&':  {Key: "Digit6", Shift: true},
+		'7':  {Key: "Digit7"},
+		'/':  {Key: "Digit7", Shift: true},
+		'{':  {Key: "Digit7", AltRight: true},
+		'8':  {Key: "Digit8"},
+		'(':  {Key: "Digit8", Shift: true},
+		'[':  {Key: "Digit8", AltRight: true},
+		'9':  {Key: "Digit9"},
+		')':  {Key: "Digit9", Shift: true},
+		']':  {Key: "Digit9", AltRight: true},
+		'0':  {Key: "Digit0"},
+		'=':  {Key: "Digit0", Shift: true},
+		'}':  {Key: "Digit0", AltRight: true},
+		'+':  {Key: "Minus"},
+		'?':  {Key: "Minus", Shift: true},
+		'\\': {Key: "Minus", AltRight: true},
+		'å':  {Key: "BracketLeft"},
+		'Å':  {Key: "BracketLeft", Shift: true},
+		'ö':  {Key: "Semicolon"},
+		'Ö':  {Key: "Semicolon", Shift: true},
+		'ä':  {Key: "Quote"},
+		'Ä':  {Key: "Quote", Shift: true},
+		'\'': {Key: "Backslash"},
+		'*':  {Key: "Backslash", Shift: true},
+		',':  {Key: "Comma"},
+		';':  {Key: "Comma", Shift: true},
+		'.':  {Key: "Period"},
+		':':  {Key: "Period", Shift: true},
+		'-':  {Key: "Slash"},
+		'_':  {Key: "Slash", Shift: true},
+		'<':  {Key: "IntlBackslash"},
+		'>':  {Key: "IntlBackslash", Shift: true},
+		'|':  {Key: "IntlBackslash", AltRight: true},
+		' ':  {Key: "Space"},
+		'\n': {Key: "Enter"},
+	},
+}
@@ -0,0 +1,231 @@
+// Package ldapauth authenticates users against an LDAP directory or Active
+// Directory: it searches the user with a service account, binds as the user
+// to check the password and checks the group memberships.
+package ldapauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultUserFilter matches OpenLDAP users, use
+	// (&(objectClass=user)(sAMAccountName=%s)) for Active Directory.
+	DefaultUserFilter     = "(&(objectClass=person)(uid=%s))"
+	DefaultGroupAttribute = "memberOf"
+	DefaultTimeout        = 5 * time.Second
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrUserNotFound is returned if the directory has no such user.
+	ErrUserNotFound = errors.New("user not found in directory")
+	ErrNotAllowed   = errors.New("user is not a member of the required group")
+	// ErrUnavailable is returned if the directory can't be reached or the
+	// service account can't search it.
+	ErrUnavailable = errors.New("directory is unavailable")
+)
+
+var defaultLogger = logging.GetSubsystemLogger("ldap")
+
+type Options struct {
+	// URL is ldap://host[:port] or ldaps://host[:port].
+	URL      string
+	StartTLS bool
+	// CACert is a PEM bundle to verify the server with, the system roots
+	// are used without it.
+	CACert             string
+	InsecureSkipVerify bool
+
+	// BindDN and BindPassword is the service account searching users, the
+	// search is anonymous without it.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter is the search filter, %s is replaced by the escaped user name.
+	UserFilter     string
+	GroupAttribute string
+	// RequiredGroup is the DN of the group users must be a member of, any
+	// user is allowed without it.
+	RequiredGroup string
+	// AdminGroup is the DN of the group whose members are admins.
+	AdminGroup string
+
+	Timeout time.Duration
+	Logger  *zerolog.Logger
+}
+
+// Identity is an authenticated directory user.
+type Identity struct {
+	Username string   `json:"username"`
+	DN       string   `json:"dn"`
+	Groups   []string `json:"groups"`
+	Admin    bool     `json:"admin"`
+}
+
+type Authenticator struct {
+	opts      Options
+	tlsConfig *tls.Config
+	l         *zerolog.Logger
+}
+
+func NewAuthenticator(opts Options) (*Authenticator, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return nil, fmt.Errorf("invalid LDAP url: %s", opts.URL)
+	}
+	if opts.StartTLS && u.Scheme == "ldaps" {
+		return nil, fmt.Errorf("StartTLS can't be used with ldaps")
+	}
+	if opts.BaseDN == "" {
+		return nil, fmt.Errorf("base DN is required")
+	}
+	if opts.UserFilter == "" {
+		opts.UserFilter = DefaultUserFilter
+	}
+	if strings.Count(opts.UserFilter, "%s") != 1 {
+		return nil, fmt.Errorf("user filter must contain %%s once: %s", opts.UserFilter)
+	}
+	if _, err := ldap.CompileFilter(fmt.Sprintf(opts.UserFilter, "user")); err != nil {
+		return nil, fmt.Errorf("invalid user filter: %w", err)
+	}
+	if opts.GroupAttribute == "" {
+		opts.GroupAttribute = DefaultGroupAttribute
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: opts.InsecureSkipVerify, //nolint:gosec
+		MinVersion:         tls.VersionTLS12,
+	}
+	if opts.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(opts.CACert)) {
+			return nil, fmt.Errorf("CA certificate contains no certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &Authenticator{opts: opts, tlsConfig: tlsConfig, l: opts.Logger}, nil
+}
+
+func (a *Authenticator) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(a.opts.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: a.opts.Timeout}),
+		ldap.DialWithTLSConfig(a.tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	conn.SetTimeout(a.opts.Timeout)
+
+	if a.opts.StartTLS {
+		if err := conn.StartTLS(a.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: StartTLS failed: %v", ErrUnavailable, err)
+		}
+	}
+	return conn, nil
+}
+
+// sameDN compares DNs ignoring case and spacing, like directories do for
+// group DNs.
+func sameDN(a, b string) bool {
+	dnA, errA := ldap.ParseDN(strings.ToLower(a))
+	dnB, errB := ldap.ParseDN(strings.ToLower(b))
+	if errA != nil || errB != nil {
+		return strings.EqualFold(a, b)
+	}
+	return dnA.Equal(dnB)
+}
+
+func memberOf(groups []string, group string) bool {
+	for _, g := range groups {
+		if sameDN(g, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate checks the credentials of username. Errors wrap
+// ErrUnavailable if the directory couldn't answer, callers may fall back to
+// other backends then.
+func (a *Authenticator) Authenticate(username, password string) (*Identity, error) {
+	// an empty password would be an unauthenticated bind, which succeeds
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := a.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if a.opts.BindDN != "" {
+		if err := conn.Bind(a.opts.BindDN, a.opts.BindPassword); err != nil {
+			return nil, fmt.Errorf("%w: service account bind failed: %v", ErrUnavailable, err)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.opts.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(a.opts.Timeout.Seconds()), false,
+		fmt.Sprintf(a.opts.UserFilter, ldap.EscapeFilter(username)),
+		[]string{"dn", a.opts.GroupAttribute},
+		nil,
+	))
+	switch {
+	case err == nil:
+	case ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject):
+		return nil, ErrUserNotFound
+	case ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded):
+		a.l.Warn().Str("username", username).Msg("user filter matches more than one entry")
+		return nil, ErrInvalidCredentials
+	default:
+		return nil, fmt.Errorf("%w: search failed: %v", ErrUnavailable, err)
+	}
+	if len(result.Entries) == 0 {
+		return nil, ErrUserNotFound
+	}
+	if len(result.Entries) > 1 {
+		a.l.Warn().Str("username", username).Msg("user filter matches more than one entry")
+		return nil, ErrInvalidCredentials
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("%w: user bind failed: %v", ErrUnavailable, err)
+	}
+
+	id := &Identity{
+		Username: username,
+		DN:       entry.DN,
+		Groups:   entry.GetAttributeValues(a.opts.GroupAttribute),
+	}
+	if a.opts.RequiredGroup != "" && !memberOf(id.Groups, a.opts.RequiredGroup) {
+		return nil, ErrNotAllowed
+	}
+	id.Admin = a.opts.AdminGroup != "" && memberOf(id.Groups, a.opts.AdminGroup)
+	return id, nil
+}
@@ -0,0 +1,295 @@
+package ldapauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+type fakeEntry struct {
+	dn       string
+	password string
+	groups   []string
+}
+
+// fakeDirectory is a minimal LDAP server answering simple binds and searches
+// by uid, enough to stand in for OpenLDAP.
+type fakeDirectory struct {
+	entries map[string]fakeEntry // by uid
+}
+
+const (
+	testBaseDN   = "dc=example,dc=com"
+	testBindDN   = "cn=svc,dc=example,dc=com"
+	testBindPass = "svc-secret"
+	testKVMGroup = "cn=kvm,ou=groups,dc=example,dc=com"
+	testAdmGroup = "cn=kvm-admins,ou=groups,dc=example,dc=com"
+)
+
+func newFakeDirectory() *fakeDirectory {
+	return &fakeDirectory{entries: map[string]fakeEntry{
+		"alice": {dn: "uid=alice,ou=people,dc=example,dc=com", password: "alice-pw", groups: []string{"CN=KVM,OU=Groups,DC=example,DC=com", testAdmGroup}},
+		"bob":   {dn: "uid=bob,ou=people,dc=example,dc=com", password: "bob-pw", groups: []string{testKVMGroup}},
+		"eve":   {dn: "uid=eve,ou=people,dc=example,dc=com", password: "eve-pw"},
+	}}
+}
+
+func ldapResult(tag ber.Tag, id int64, code uint16) *ber.Packet {
+	msg := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	msg.AppendChild(res)
+	return msg
+}
+
+func (d *fakeDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Data.String()
+			password := op.Children[2].Data.String()
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if dn == testBindDN && password == testBindPass {
+				code = ldap.LDAPResultSuccess
+			}
+			for _, e := range d.entries {
+				if e.dn == dn && e.password == password {
+					code = ldap.LDAPResultSuccess
+				}
+			}
+			_, _ = conn.Write(ldapResult(ldap.ApplicationBindResponse, id, code).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			for uid, e := range d.entries {
+				if !strings.Contains(filter, "(uid="+uid+")") {
+					continue
+				}
+				msg := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+				msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+				entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Entry")
+				entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "objectName"))
+				attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+				attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+				attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "memberOf", "type"))
+				vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+				for _, g := range e.groups {
+					vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, g, "value"))
+				}
+				attr.AppendChild(vals)
+				attrs.AppendChild(attr)
+				entry.AppendChild(attrs)
+				msg.AppendChild(entry)
+				_, _ = conn.Write(msg.Bytes())
+			}
+			_, _ = conn.Write(ldapResult(ldap.ApplicationSearchResultDone, id, ldap.LDAPResultSuccess).Bytes())
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (d *fakeDirectory) listen(t *testing.T, tlsConfig *tls.Config) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func testOptions(url string) Options {
+	return Options{
+		URL:           url,
+		BindDN:        testBindDN,
+		BindPassword:  testBindPass,
+		BaseDN:        testBaseDN,
+		RequiredGroup: testKVMGroup,
+		AdminGroup:    testAdmGroup,
+		Timeout:       2 * time.Second,
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	addr := newFakeDirectory().listen(t, nil)
+	a, err := NewAuthenticator(testOptions("ldap://" + addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := a.Authenticate("alice", "alice-pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.DN != "uid=alice,ou=people,dc=example,dc=com" || !id.Admin {
+		t.Errorf("unexpected identity: %+v", id)
+	}
+
+	id, err = a.Authenticate("bob", "bob-pw")
+	if err != nil || id.Admin {
+		t.Errorf("bob: %+v, %v", id, err)
+	}
+
+	tests := []struct {
+		username, password string
+		err                error
+	}{
+		{"alice", "wrong", ErrInvalidCredentials},
+		{"alice", "", ErrInvalidCredentials},
+		{"eve", "eve-pw", ErrNotAllowed},
+		{"mallory", "pw", ErrUserNotFound},
+		{"*", "pw", ErrUserNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := a.Authenticate(tt.username, tt.password); !errors.Is(err, tt.err) {
+			t.Errorf("%s/%s: got %v, want %v", tt.username, tt.password, err, tt.err)
+		}
+	}
+
+	opts := testOptions("ldap://" + addr)
+	opts.BindPassword = "wrong"
+	a, _ = NewAuthenticator(opts)
+	if _, err := a.Authenticate("alice", "alice-pw"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected a failing service bind to be unavailable, got %v", err)
+	}
+}
+
+func TestAuthenticateUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	_ = l.Close()
+
+	a, err := NewAuthenticator(testOptions("ldap://" + addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate("alice", "alice-pw"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected unavailable, got %v", err)
+	}
+}
+
+func TestAuthenticateLDAPS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ldap"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := newFakeDirectory().listen(t, &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+
+	// without the CA the server isn't trusted
+	a, err := NewAuthenticator(testOptions("ldaps://" + addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate("bob", "bob-pw"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected an untrusted server to be unavailable, got %v", err)
+	}
+
+	opts := testOptions("ldaps://" + addr)
+	opts.CACert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	a, err = NewAuthenticator(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Authenticate("bob", "bob-pw"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	for _, opts := range []Options{
+		{URL: "http://ldap.example.com", BaseDN: testBaseDN},
+		{URL: "ldaps://ldap.example.com", BaseDN: testBaseDN, StartTLS: true},
+		{URL: "ldap://ldap.example.com"},
+		{URL: "ldap://ldap.example.com", BaseDN: testBaseDN, UserFilter: "(uid=alice)"},
+		{URL: "ldap://ldap.example.com", BaseDN: testBaseDN, UserFilter: "uid=%s"},
+		{URL: "ldap://ldap.example.com", BaseDN: testBaseDN, CACert: "not a pem"},
+	} {
+		if _, err := NewAuthenticator(opts); err == nil {
+			t.Errorf("expected an error for %+v", opts)
+		}
+	}
+}
+
+// TestAuthenticateDirectory runs against a real directory, e.g. glauth or
+// OpenLDAP, given by JETKVM_LDAP_URL, JETKVM_LDAP_BASE_DN, JETKVM_LDAP_BIND_DN,
+// JETKVM_LDAP_BIND_PASSWORD, JETKVM_LDAP_USER and JETKVM_LDAP_PASSWORD.
+func TestAuthenticateDirectory(t *testing.T) {
+	url := os.Getenv("JETKVM_LDAP_URL")
+	if url == "" {
+		t.Skip("JETKVM_LDAP_URL is not set")
+	}
+	a, err := NewAuthenticator(Options{
+		URL:                url,
+		StartTLS:           os.Getenv("JETKVM_LDAP_STARTTLS") != "",
+		InsecureSkipVerify: true,
+		BindDN:             os.Getenv("JETKVM_LDAP_BIND_DN"),
+		BindPassword:       os.Getenv("JETKVM_LDAP_BIND_PASSWORD"),
+		BaseDN:             os.Getenv("JETKVM_LDAP_BASE_DN"),
+		UserFilter:         os.Getenv("JETKVM_LDAP_USER_FILTER"),
+		RequiredGroup:      os.Getenv("JETKVM_LDAP_GROUP"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := a.Authenticate(os.Getenv("JETKVM_LDAP_USER"), os.Getenv("JETKVM_LDAP_PASSWORD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("authenticated %s, groups %v", id.DN, id.Groups)
+
+	if _, err := a.Authenticate(os.Getenv("JETKVM_LDAP_USER"), "wrong password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+}
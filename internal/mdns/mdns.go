@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 
@@ -26,6 +27,8 @@ type MDNS struct {
 type MDNSListenOptions struct {
 	IPv4 bool
 	IPv6 bool
+	// ExcludeInterfaces are interfaces mDNS doesn't answer on.
+	ExcludeInterfaces []string
 }
 
 type MDNSOptions struct {
@@ -130,9 +133,16 @@ func (m *MDNS) start(allowRestart bool) error {
 		}
 	}
 
+	interfaces, err := m.interfaces()
+	if err != nil {
+		scopeLogger.Warn().Err(err).Msg("failed to list interfaces")
+		return err
+	}
+
 	mDNSConn, err := pion_mdns.Server(p4, p6, &pion_mdns.Config{
 		LocalNames:    newLocalNames,
 		LoggerFactory: logging.GetPionDefaultLoggerFactory(),
+		Interfaces:    interfaces,
 	})
 
 	if err != nil {
@@ -146,6 +156,25 @@ func (m *MDNS) start(allowRestart bool) error {
 	return nil
 }
 
+// interfaces returns the interfaces to answer on, nil for all of them.
+func (m *MDNS) interfaces() ([]net.Interface, error) {
+	if len(m.listenOptions.ExcludeInterfaces) == 0 {
+		return nil, nil
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([]net.Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		if !slices.Contains(m.listenOptions.ExcludeInterfaces, iface.Name) {
+			filtered = append(filtered, iface)
+		}
+	}
+	return filtered, nil
+}
+
 func (m *MDNS) Start() error {
 	return m.start(false)
 }
@@ -179,7 +208,8 @@ func (m *MDNS) SetLocalNames(localNames []string, always bool) error {
 func (m *MDNS) SetListenOptions(listenOptions *MDNSListenOptions) error {
 	if m.listenOptions != nil &&
 		m.listenOptions.IPv4 == listenOptions.IPv4 &&
-		m.listenOptions.IPv6 == listenOptions.IPv6 {
+		m.listenOptions.IPv6 == listenOptions.IPv6 &&
+		slices.Equal(m.listenOptions.ExcludeInterfaces, listenOptions.ExcludeInterfaces) {
 		return nil
 	}
 
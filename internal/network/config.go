@@ -44,6 +44,11 @@ type NetworkConfig struct {
 	IPv6Mode   null.String       `json:"ipv6_mode,omitempty" one_of:"slaac,dhcpv6,slaac_and_dhcpv6,static,link_local,disabled" default:"slaac"`
 	IPv6Static *IPv6StaticConfig `json:"ipv6_static,omitempty" required_if:"IPv6Mode=static"`
 
+	// VLANID tags the management interface, 0 leaves it untagged. VLANs are
+	// additional tagged interfaces, see VLANConfig.
+	VLANID null.Int     `json:"vlan_id,omitempty"`
+	VLANs  []VLANConfig `json:"vlans,omitempty"`
+
 	LLDPMode                null.String `json:"lldp_mode,omitempty" one_of:"disabled,rx_only,tx_only,basic,all,enabled" default:"enabled"`
 	LLDPTxTLVs              []string    `json:"lldp_tx_tlvs,omitempty" one_of:"chassis,port,system,vlan" default:"chassis,port,system,vlan"`
 	MDNSMode                null.String `json:"mdns_mode,omitempty" one_of:"disabled,auto,ipv4_only,ipv6_only" default:"auto"`
@@ -53,6 +58,12 @@ type NetworkConfig struct {
 	TimeSyncParallel        null.Int    `json:"time_sync_parallel,omitempty" default:"4"`
 	TimeSyncNTPServers      []string    `json:"time_sync_ntp_servers,omitempty" validate_type:"ipv4_or_ipv6" required_if:"TimeSyncOrdering=ntp_user_provided"`
 	TimeSyncHTTPUrls        []string    `json:"time_sync_http_urls,omitempty" validate_type:"url" required_if:"TimeSyncOrdering=http_user_provided"`
+
+	// WireGuard isn't handled by confparser, see WireGuardConfig.Validate.
+	WireGuard *WireGuardConfig `json:"wireguard,omitempty"`
+	// IEEE8021X isn't handled by confparser either, see
+	// IEEE8021XConfig.Validate.
+	IEEE8021X *IEEE8021XConfig `json:"ieee8021x,omitempty"`
 }
 
 func (c *NetworkConfig) GetMDNSMode() *mdns.MDNSListenOptions {
@@ -71,6 +82,10 @@ func (c *NetworkConfig) GetMDNSMode() *mdns.MDNSListenOptions {
 		listenOptions.IPv6 = false
 	}
 
+	if c.WireGuard.IsEnabled() && !c.WireGuard.MDNS {
+		listenOptions.ExcludeInterfaces = []string{c.WireGuard.Interface()}
+	}
+
 	return listenOptions
 }
 
@@ -0,0 +1,157 @@
+package network
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jetkvm/kvm/internal/wpasupplicant"
+)
+
+// IEEE8021XConfig are the 802.1X credentials of the management interface.
+// The certificates are kept in the certificate store, see
+// NetworkInterfaceOptions.IEEE8021XCertificates.
+type IEEE8021XConfig struct {
+	Enabled bool `json:"enabled"`
+	// EAPMethod is tls for EAP-TLS or peap for PEAP-MSCHAPv2.
+	EAPMethod         string `json:"eap_method"`
+	Identity          string `json:"identity"`
+	AnonymousIdentity string `json:"anonymous_identity,omitempty"`
+	Password          string `json:"password,omitempty"`
+	// DomainSuffixMatch is checked against the server certificate, on top
+	// of the CA certificate.
+	DomainSuffixMatch string `json:"domain_suffix_match,omitempty"`
+}
+
+func (c *IEEE8021XConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// Validate checks the config, the certificates are checked when the
+// supplicant starts.
+func (c *IEEE8021XConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	switch c.EAPMethod {
+	case wpasupplicant.MethodTLS:
+	case wpasupplicant.MethodPEAP:
+		if c.Password == "" {
+			return fmt.Errorf("PEAP needs a password")
+		}
+	default:
+		return fmt.Errorf("invalid EAP method %s, expected tls or peap", c.EAPMethod)
+	}
+	if strings.TrimSpace(c.Identity) == "" {
+		return fmt.Errorf("identity is required")
+	}
+	return nil
+}
+
+// IEEE8021XState is the 802.1X authentication state of the interface.
+type IEEE8021XState struct {
+	Enabled   bool   `json:"enabled"`
+	EAPMethod string `json:"eap_method"`
+	wpasupplicant.State
+}
+
+// ieee8021xAuthorized tells if the port may be used, either without 802.1X
+// or once authenticated.
+func (s *NetworkInterfaceState) ieee8021xAuthorized() bool {
+	if !s.config.IEEE8021X.IsEnabled() {
+		return true
+	}
+	return s.supplicantAuthenticated.Load()
+}
+
+func (s *NetworkInterfaceState) supplicantConfig(config *IEEE8021XConfig) wpasupplicant.Config {
+	c := wpasupplicant.Config{
+		Method:            config.EAPMethod,
+		Identity:          config.Identity,
+		AnonymousIdentity: config.AnonymousIdentity,
+		Password:          config.Password,
+		DomainSuffixMatch: config.DomainSuffixMatch,
+	}
+	if s.ieee8021xCertificates != nil {
+		c.CACertFile, c.ClientCertFile, c.PrivateKeyFile = s.ieee8021xCertificates()
+	}
+	if c.Method != wpasupplicant.MethodTLS {
+		c.ClientCertFile, c.PrivateKeyFile = "", ""
+	}
+	return c
+}
+
+// applyIEEE8021X starts, restarts or stops the supplicant to match config,
+// force restarts it also when the config didn't change, e.g. for new
+// certificates.
+func (s *NetworkInterfaceState) applyIEEE8021X(config *IEEE8021XConfig, force bool) {
+	s.supplicantLock.Lock()
+	defer s.supplicantLock.Unlock()
+
+	if !config.IsEnabled() {
+		if s.supplicantConfigRunning != nil {
+			s.supplicant.Stop()
+			s.supplicantConfigRunning = nil
+		}
+		s.supplicantErr = nil
+		return
+	}
+
+	wanted := s.supplicantConfig(config)
+	if !force && s.supplicantConfigRunning != nil && *s.supplicantConfigRunning == wanted {
+		return
+	}
+
+	s.supplicantErr = nil
+	if err := s.supplicant.Start(wanted); err != nil {
+		s.l.Error().Err(err).Msg("failed to start 802.1X supplicant")
+		s.supplicant.Stop()
+		s.supplicantConfigRunning = nil
+		s.supplicantErr = err
+		return
+	}
+	s.supplicantConfigRunning = &wanted
+}
+
+// ReloadIEEE8021X restarts the supplicant, e.g. after the certificates
+// changed.
+func (s *NetworkInterfaceState) ReloadIEEE8021X() {
+	s.applyIEEE8021X(s.config.IEEE8021X, true)
+}
+
+func (s *NetworkInterfaceState) onSupplicantStateChange(state wpasupplicant.State) {
+	// called from Start and Stop too, so don't take supplicantLock here
+	authenticated := state.Status == wpasupplicant.StatusAuthenticated
+	wasAuthenticated := s.supplicantAuthenticated.Swap(authenticated)
+
+	// the lease was held back until the port is authorized
+	if authenticated && !wasAuthenticated && s.config.IPv4Mode.String == "dhcp" {
+		s.l.Info().Msg("802.1X authenticated, renewing DHCP lease")
+		_ = s.dhcpClient.Renew()
+	}
+
+	if s.onIEEE8021XStateChange != nil {
+		go s.onIEEE8021XStateChange(s)
+	}
+}
+
+// IEEE8021XState returns the authentication state, nil if 802.1X is
+// disabled.
+func (s *NetworkInterfaceState) IEEE8021XState() *IEEE8021XState {
+	if !s.config.IEEE8021X.IsEnabled() {
+		return nil
+	}
+
+	state := &IEEE8021XState{
+		Enabled:   true,
+		EAPMethod: s.config.IEEE8021X.EAPMethod,
+		State:     s.supplicant.State(),
+	}
+
+	s.supplicantLock.Lock()
+	defer s.supplicantLock.Unlock()
+	if s.supplicantErr != nil {
+		state.Status = wpasupplicant.StatusFailed
+		state.Error = s.supplicantErr.Error()
+	}
+	return state
+}
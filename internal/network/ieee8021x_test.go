@@ -0,0 +1,38 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIEEE8021XValidate(t *testing.T) {
+	var disabled *IEEE8021XConfig
+	assert.NoError(t, disabled.Validate())
+	assert.NoError(t, (&IEEE8021XConfig{EAPMethod: "md5"}).Validate(), "disabled config isn't checked")
+
+	assert.NoError(t, (&IEEE8021XConfig{Enabled: true, EAPMethod: "tls", Identity: "kvm01"}).Validate())
+	assert.NoError(t, (&IEEE8021XConfig{Enabled: true, EAPMethod: "peap", Identity: "kvm01", Password: "secret"}).Validate())
+
+	assert.Error(t, (&IEEE8021XConfig{Enabled: true, EAPMethod: "md5", Identity: "kvm01"}).Validate())
+	assert.Error(t, (&IEEE8021XConfig{Enabled: true, EAPMethod: "peap", Identity: "kvm01"}).Validate())
+	assert.Error(t, (&IEEE8021XConfig{Enabled: true, EAPMethod: "tls", Identity: " "}).Validate())
+}
+
+func TestSupplicantConfig(t *testing.T) {
+	s := &NetworkInterfaceState{
+		ieee8021xCertificates: func() (string, string, string) {
+			return "/tls/ca.ca.pem", "/tls/client.crt", "/tls/client.key"
+		},
+	}
+
+	c := s.supplicantConfig(&IEEE8021XConfig{Enabled: true, EAPMethod: "tls", Identity: "kvm01"})
+	assert.Equal(t, "/tls/ca.ca.pem", c.CACertFile)
+	assert.Equal(t, "/tls/client.crt", c.ClientCertFile)
+	assert.Equal(t, "/tls/client.key", c.PrivateKeyFile)
+
+	c = s.supplicantConfig(&IEEE8021XConfig{Enabled: true, EAPMethod: "peap", Identity: "kvm01", Password: "secret"})
+	assert.Equal(t, "/tls/ca.ca.pem", c.CACertFile)
+	assert.Empty(t, c.ClientCertFile, "PEAP doesn't use the client certificate")
+	assert.Empty(t, c.PrivateKeyFile)
+}
@@ -4,17 +4,21 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 
 	"github.com/jetkvm/kvm/internal/confparser"
 	"github.com/jetkvm/kvm/internal/logging"
 	"github.com/jetkvm/kvm/internal/udhcpc"
+	"github.com/jetkvm/kvm/internal/wpasupplicant"
 	"github.com/rs/zerolog"
 
 	"github.com/vishvananda/netlink"
 )
 
 type NetworkInterfaceState struct {
+	// interfaceName is the management interface, parentName or a VLAN on it
 	interfaceName string
+	parentName    string
 	interfaceUp   bool
 	ipv4Addr      *net.IP
 	ipv4Addresses []string
@@ -29,6 +33,26 @@ type NetworkInterfaceState struct {
 
 	config     *NetworkConfig
 	dhcpClient *udhcpc.DHCPClient
+	// untaggedDhcpClient follows the udhcpc the system runs on parentName
+	untaggedDhcpClient *udhcpc.DHCPClient
+
+	managementVLAN    *vlanInterface
+	managementVLANErr error
+	vlans             map[int]*vlanInterface
+	vlanErrs          map[int]error
+	lastVLANStates    []VLANState
+	vlanLock          sync.Mutex
+
+	wireguard     *wireGuardInterface
+	wireguardErr  error
+	wireguardLock sync.Mutex
+
+	supplicant              *wpasupplicant.Supplicant
+	supplicantConfigRunning *wpasupplicant.Config
+	supplicantErr           error
+	supplicantAuthenticated atomic.Bool
+	supplicantLock          sync.Mutex
+	ieee8021xCertificates   func() (caFile, certFile, keyFile string)
 
 	defaultHostname string
 	currentHostname string
@@ -36,8 +60,11 @@ type NetworkInterfaceState struct {
 
 	onStateChange  func(state *NetworkInterfaceState)
 	onInitialCheck func(state *NetworkInterfaceState)
+	onDhcpLease    func(lease *udhcpc.Lease, state *NetworkInterfaceState)
 	cbConfigChange func(config *NetworkConfig)
 
+	onIEEE8021XStateChange func(state *NetworkInterfaceState)
+
 	checked bool
 }
 
@@ -51,6 +78,11 @@ type NetworkInterfaceOptions struct {
 	OnDhcpLeaseChange func(lease *udhcpc.Lease, state *NetworkInterfaceState)
 	OnConfigChange    func(config *NetworkConfig)
 	NetworkConfig     *NetworkConfig
+
+	// IEEE8021XCertificates returns the PEM files of the CA and client
+	// certificates for 802.1X, empty when they aren't set.
+	IEEE8021XCertificates  func() (caFile, certFile, keyFile string)
+	OnIEEE8021XStateChange func(state *NetworkInterfaceState)
 }
 
 func NewNetworkInterfaceState(opts *NetworkInterfaceOptions) (*NetworkInterfaceState, error) {
@@ -66,18 +98,34 @@ func NewNetworkInterfaceState(opts *NetworkInterfaceOptions) (*NetworkInterfaceS
 	if err != nil {
 		return nil, err
 	}
+	if err := opts.NetworkConfig.WireGuard.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid WireGuard config: %w", err)
+	}
+	if err := opts.NetworkConfig.IEEE8021X.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid 802.1X config: %w", err)
+	}
+	if err := opts.NetworkConfig.validateVLANs(); err != nil {
+		return nil, fmt.Errorf("invalid VLAN config: %w", err)
+	}
 
 	l := opts.Logger
 	s := &NetworkInterfaceState{
 		interfaceName:   opts.InterfaceName,
+		parentName:      opts.InterfaceName,
 		defaultHostname: opts.DefaultHostname,
 		stateLock:       sync.Mutex{},
 		l:               l,
 		onStateChange:   opts.OnStateChange,
 		onInitialCheck:  opts.OnInitialCheck,
+		onDhcpLease:     opts.OnDhcpLeaseChange,
 		cbConfigChange:  opts.OnConfigChange,
 		config:          opts.NetworkConfig,
 		ntpAddresses:    make([]*net.IP, 0),
+		vlans:           make(map[int]*vlanInterface),
+		vlanErrs:        make(map[int]error),
+
+		ieee8021xCertificates:  opts.IEEE8021XCertificates,
+		onIEEE8021XStateChange: opts.OnIEEE8021XStateChange,
 	}
 
 	// create the dhcp client
@@ -85,23 +133,43 @@ func NewNetworkInterfaceState(opts *NetworkInterfaceOptions) (*NetworkInterfaceS
 		InterfaceName: opts.InterfaceName,
 		PidFile:       opts.DhcpPidFile,
 		Logger:        l,
-		OnLeaseChange: func(lease *udhcpc.Lease) {
-			_, err := s.update()
-			if err != nil {
-				opts.Logger.Error().Err(err).Msg("failed to update network state")
-				return
-			}
-			_ = s.updateNtpServersFromLease(lease)
-			_ = s.setHostnameIfNotSame()
-
-			opts.OnDhcpLeaseChange(lease, s)
-		},
+		OnLeaseChange: s.leaseHandler(opts.InterfaceName),
 	})
 
 	s.dhcpClient = dhcpClient
+	s.untaggedDhcpClient = dhcpClient
+	s.supplicant = wpasupplicant.NewSupplicant(&wpasupplicant.SupplicantOptions{
+		InterfaceName: opts.InterfaceName,
+		Logger:        l,
+		OnStateChange: s.onSupplicantStateChange,
+	})
 	return s, nil
 }
 
+// leaseHandler returns the lease callback of the DHCP client on
+// interfaceName, leases are only handled while it's the management
+// interface.
+func (s *NetworkInterfaceState) leaseHandler(interfaceName string) func(lease *udhcpc.Lease) {
+	return func(lease *udhcpc.Lease) {
+		s.stateLock.Lock()
+		current := s.interfaceName == interfaceName
+		s.stateLock.Unlock()
+		if !current {
+			return
+		}
+
+		_, err := s.update()
+		if err != nil {
+			s.l.Error().Err(err).Msg("failed to update network state")
+			return
+		}
+		_ = s.updateNtpServersFromLease(lease)
+		_ = s.setHostnameIfNotSame()
+
+		s.onDhcpLease(lease, s)
+	}
+}
+
 func (s *NetworkInterfaceState) IsUp() bool {
 	return s.interfaceUp
 }
@@ -383,6 +451,10 @@ func (s *NetworkInterfaceState) CheckAndUpdateDhcp() error {
 
 	switch dhcpTargetState {
 	case DhcpTargetStateRenew:
+		if !s.ieee8021xAuthorized() {
+			s.l.Info().Msg("waiting for 802.1X authentication before renewing DHCP lease")
+			break
+		}
 		s.l.Info().Msg("renewing DHCP lease")
 		_ = s.dhcpClient.Renew()
 	case DhcpTargetStateRelease:
@@ -27,6 +27,10 @@ func (s *NetworkInterfaceState) Run() error {
 
 	_ = s.setHostnameIfNotSame()
 
+	// authenticate the port before asking for a lease
+	s.applyIEEE8021X(s.config.IEEE8021X, false)
+	s.applyVLANs()
+
 	// run the dhcp client
 	go s.dhcpClient.Run() // nolint:errcheck
 
@@ -34,6 +38,8 @@ func (s *NetworkInterfaceState) Run() error {
 		return err
 	}
 
+	s.applyWireGuard(s.config.WireGuard)
+
 	go func() {
 		ticker := time.NewTicker(1 * time.Second)
 		defer ticker.Stop()
@@ -44,6 +50,7 @@ func (s *NetworkInterfaceState) Run() error {
 				s.HandleLinkUpdate(update)
 			case <-ticker.C:
 				_ = s.CheckAndUpdateDhcp()
+				s.checkVLANs()
 			case <-done:
 				return
 			}
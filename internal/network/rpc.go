@@ -24,6 +24,11 @@ type RpcNetworkState struct {
 	IPv4Addresses []string         `json:"ipv4_addresses,omitempty"`
 	IPv6Addresses []RpcIPv6Address `json:"ipv6_addresses,omitempty"`
 	DHCPLease     *udhcpc.Lease    `json:"dhcp_lease,omitempty"`
+	WireGuard     *WireGuardState  `json:"wireguard,omitempty"`
+	IEEE8021X     *IEEE8021XState  `json:"ieee8021x,omitempty"`
+	VLANID        int              `json:"vlan_id,omitempty"`
+	VLANError     string           `json:"vlan_error,omitempty"`
+	VLANs         []VLANState      `json:"vlans,omitempty"`
 }
 
 type RpcNetworkSettings struct {
@@ -85,6 +90,11 @@ func (s *NetworkInterfaceState) RpcGetNetworkState() RpcNetworkState {
 		IPv4Addresses: s.ipv4Addresses,
 		IPv6Addresses: ipv6Addresses,
 		DHCPLease:     s.dhcpClient.GetLease(),
+		WireGuard:     s.WireGuardState(),
+		IEEE8021X:     s.IEEE8021XState(),
+		VLANID:        int(s.config.VLANID.Int64),
+		VLANError:     s.managementVLANError(),
+		VLANs:         s.VLANStates(),
 	}
 }
 
@@ -105,6 +115,15 @@ func (s *NetworkInterfaceState) RpcSetNetworkSettings(settings RpcNetworkSetting
 	if err != nil {
 		return err
 	}
+	if err := settings.WireGuard.Validate(); err != nil {
+		return fmt.Errorf("invalid WireGuard config: %w", err)
+	}
+	if err := settings.IEEE8021X.Validate(); err != nil {
+		return fmt.Errorf("invalid 802.1X config: %w", err)
+	}
+	if err := settings.validateVLANs(); err != nil {
+		return fmt.Errorf("invalid VLAN config: %w", err)
+	}
 
 	if IsSame(currentSettings, settings.NetworkConfig) {
 		// no changes, do nothing
@@ -112,6 +131,9 @@ func (s *NetworkInterfaceState) RpcSetNetworkSettings(settings RpcNetworkSetting
 	}
 
 	s.config = &settings.NetworkConfig
+	s.applyWireGuard(s.config.WireGuard)
+	s.applyIEEE8021X(s.config.IEEE8021X, false)
+	s.applyVLANs()
 	s.onConfigChange(s.config)
 
 	return nil
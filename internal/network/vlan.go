@@ -0,0 +1,336 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"reflect"
+
+	"github.com/guregu/null/v6"
+	"github.com/jetkvm/kvm/internal/confparser"
+	"github.com/jetkvm/kvm/internal/mdns"
+	"github.com/jetkvm/kvm/internal/udhcpc"
+)
+
+const MaxVLANID = 4094
+
+// VLANConfig is a tagged sub-interface of the management port, with its own
+// addresses and DHCP client.
+type VLANConfig struct {
+	ID int `json:"id"`
+
+	IPv4Mode   null.String       `json:"ipv4_mode,omitempty" one_of:"dhcp,static,disabled" default:"dhcp"`
+	IPv4Static *IPv4StaticConfig `json:"ipv4_static,omitempty" required_if:"IPv4Mode=static"`
+
+	IPv6Mode   null.String       `json:"ipv6_mode,omitempty" one_of:"slaac,static,link_local,disabled" default:"slaac"`
+	IPv6Static *IPv6StaticConfig `json:"ipv6_static,omitempty" required_if:"IPv6Mode=static"`
+
+	// WebServer serves the web interface on the VLAN.
+	WebServer bool `json:"web_server"`
+	// MDNS answers mDNS queries on the VLAN.
+	MDNS bool `json:"mdns"`
+}
+
+// VLANState is the state of a tagged sub-interface.
+type VLANState struct {
+	ID            int           `json:"id"`
+	InterfaceName string        `json:"interface_name"`
+	Up            bool          `json:"up"`
+	IPv4Addresses []string      `json:"ipv4_addresses,omitempty"`
+	IPv6Addresses []string      `json:"ipv6_addresses,omitempty"`
+	DHCPLease     *udhcpc.Lease `json:"dhcp_lease,omitempty"`
+	Error         string        `json:"error,omitempty"`
+}
+
+// VLANInterfaceName returns the name of the interface tagged with id on
+// parent, e.g. eth0.10.
+func VLANInterfaceName(parent string, id int) string {
+	return fmt.Sprintf("%s.%d", parent, id)
+}
+
+func (c *NetworkConfig) hasVLANs() bool {
+	return c.VLANID.Int64 != 0 || len(c.VLANs) > 0
+}
+
+// managementVLAN returns the management interface as a VLAN, nil if it's
+// untagged.
+func (c *NetworkConfig) managementVLAN() *VLANConfig {
+	if c.VLANID.Int64 == 0 {
+		return nil
+	}
+	// DHCPv6 is left to the system, which only runs it untagged
+	ipv6Mode := c.IPv6Mode
+	switch ipv6Mode.String {
+	case "dhcpv6", "slaac_and_dhcpv6":
+		ipv6Mode = null.StringFrom("slaac")
+	}
+	return &VLANConfig{
+		ID:         int(c.VLANID.Int64),
+		IPv4Mode:   c.IPv4Mode,
+		IPv4Static: c.IPv4Static,
+		IPv6Mode:   ipv6Mode,
+		IPv6Static: c.IPv6Static,
+		WebServer:  true,
+		MDNS:       true,
+	}
+}
+
+// validateVLANs checks the management VLAN and the sub-interfaces and fills
+// in their defaults, confparser doesn't handle lists of structs.
+func (c *NetworkConfig) validateVLANs() error {
+	if c.VLANID.Int64 < 0 || c.VLANID.Int64 > MaxVLANID {
+		return fmt.Errorf("invalid management VLAN ID: %d", c.VLANID.Int64)
+	}
+
+	seen := make(map[int]bool, len(c.VLANs))
+	for i := range c.VLANs {
+		vlan := &c.VLANs[i]
+		if vlan.ID < 1 || vlan.ID > MaxVLANID {
+			return fmt.Errorf("invalid VLAN ID: %d", vlan.ID)
+		}
+		if int64(vlan.ID) == c.VLANID.Int64 {
+			return fmt.Errorf("VLAN %d is the management VLAN", vlan.ID)
+		}
+		if seen[vlan.ID] {
+			return fmt.Errorf("duplicate VLAN %d", vlan.ID)
+		}
+		seen[vlan.ID] = true
+
+		if err := confparser.SetDefaultsAndValidate(vlan); err != nil {
+			return fmt.Errorf("VLAN %d: %w", vlan.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetMDNSMode returns the mDNS listen options, leaving out the untagged
+// parent of a tagged management interface and the VLANs without mDNS.
+func (s *NetworkInterfaceState) GetMDNSMode() *mdns.MDNSListenOptions {
+	listenOptions := s.config.GetMDNSMode()
+
+	if s.config.VLANID.Int64 != 0 {
+		listenOptions.ExcludeInterfaces = append(listenOptions.ExcludeInterfaces, s.parentName)
+	}
+	for _, vlan := range s.config.VLANs {
+		if !vlan.MDNS {
+			listenOptions.ExcludeInterfaces = append(listenOptions.ExcludeInterfaces, VLANInterfaceName(s.parentName, vlan.ID))
+		}
+	}
+
+	return listenOptions
+}
+
+// interfaceOfAddr returns the name of the interface that has ip, empty if
+// there's none.
+func interfaceOfAddr(ip netip.Addr) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			if a, ok := netip.AddrFromSlice(ipNet.IP); ok && a.Unmap() == ip {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
+
+// WebServerAllowed tells if the web server may answer on the interface that
+// has the local address ip. The untagged parent of a tagged management
+// interface and the VLANs without WebServer are refused, as are addresses
+// that don't belong to an interface while VLANs are configured.
+//
+// Linux accepts packets for any local address on every interface, so this
+// can't stop a host on the untagged port from reaching the address of a VLAN,
+// WebServerDeniedInterfaces is for filtering by the ingress interface.
+func (s *NetworkInterfaceState) WebServerAllowed(ip netip.Addr) bool {
+	if !s.config.hasVLANs() || ip.Unmap().IsLoopback() {
+		return true
+	}
+
+	// link local addresses carry the interface as zone
+	name := ip.Zone()
+	if name == "" {
+		name = interfaceOfAddr(ip.Unmap())
+	}
+	if name == "" {
+		return false
+	}
+	if name == s.parentName {
+		return s.config.VLANID.Int64 == 0
+	}
+	for _, vlan := range s.config.VLANs {
+		if name == VLANInterfaceName(s.parentName, vlan.ID) {
+			return vlan.WebServer
+		}
+	}
+	return true
+}
+
+// WebServerDeniedInterfaces returns the interfaces the web server must not
+// be reachable on: the untagged parent of a tagged management interface and
+// the VLANs without WebServer.
+func (s *NetworkInterfaceState) WebServerDeniedInterfaces() []string {
+	var denied []string
+	if s.config.VLANID.Int64 != 0 {
+		denied = append(denied, s.parentName)
+	}
+	for _, vlan := range s.config.VLANs {
+		if !vlan.WebServer {
+			denied = append(denied, VLANInterfaceName(s.parentName, vlan.ID))
+		}
+	}
+	return denied
+}
+
+// applyVLANs creates, reconfigures or removes the tagged interfaces to
+// match the config, and moves the management state to the tagged interface.
+func (s *NetworkInterfaceState) applyVLANs() {
+	dhcpClient := s.applyVLANsLocked()
+
+	// the lease may have come in before the switch
+	if dhcpClient != nil {
+		if lease := dhcpClient.GetLease(); lease != nil {
+			s.leaseHandler(dhcpClient.InterfaceName)(lease)
+		}
+	}
+	s.checkVLANs()
+}
+
+// applyVLANsLocked returns the DHCP client of the management interface when
+// it changed.
+func (s *NetworkInterfaceState) applyVLANsLocked() *udhcpc.DHCPClient {
+	s.vlanLock.Lock()
+	defer s.vlanLock.Unlock()
+
+	hostname := s.GetHostname()
+
+	// the management interface
+	wanted := s.config.managementVLAN()
+	if s.managementVLAN != nil && (wanted == nil || !reflect.DeepEqual(s.managementVLAN.config, *wanted)) {
+		s.managementVLAN.close()
+		s.managementVLAN = nil
+	}
+	s.managementVLANErr = nil
+	if wanted != nil && s.managementVLAN == nil {
+		name := VLANInterfaceName(s.parentName, wanted.ID)
+		v, err := startVLANInterface(s.parentName, *wanted, true, hostname, s.leaseHandler(name), s.l)
+		if err != nil {
+			s.l.Error().Err(err).Int("vlan", wanted.ID).Msg("failed to start management VLAN interface")
+			s.managementVLANErr = err
+		} else {
+			s.managementVLAN = v
+		}
+	}
+
+	interfaceName, dhcpClient := s.parentName, s.untaggedDhcpClient
+	if s.managementVLAN != nil {
+		interfaceName, dhcpClient = s.managementVLAN.name, s.managementVLAN.dhcpClient
+	}
+	var switched *udhcpc.DHCPClient
+	s.stateLock.Lock()
+	if s.interfaceName != interfaceName {
+		switched = dhcpClient
+		s.l.Info().Str("from", s.interfaceName).Str("to", interfaceName).Msg("management interface changed")
+		s.interfaceName = interfaceName
+		s.dhcpClient = dhcpClient
+		// the next update picks up the addresses of the new interface
+		s.interfaceUp = false
+		s.ipv4Addr = nil
+		s.ipv4Addresses = nil
+		s.ipv6Addr = nil
+		s.ipv6Addresses = nil
+		s.ipv6LinkLocal = nil
+	}
+	s.stateLock.Unlock()
+
+	// the sub-interfaces
+	configs := make(map[int]VLANConfig, len(s.config.VLANs))
+	for _, vlan := range s.config.VLANs {
+		configs[vlan.ID] = vlan
+	}
+	for id, v := range s.vlans {
+		if config, ok := configs[id]; !ok || !reflect.DeepEqual(v.config, config) {
+			v.close()
+			delete(s.vlans, id)
+		}
+	}
+	s.vlanErrs = make(map[int]error)
+	for id, config := range configs {
+		if _, ok := s.vlans[id]; ok {
+			continue
+		}
+		v, err := startVLANInterface(s.parentName, config, false, hostname, func(*udhcpc.Lease) {
+			s.checkVLANs()
+		}, s.l)
+		if err != nil {
+			s.l.Error().Err(err).Int("vlan", id).Msg("failed to start VLAN interface")
+			s.vlanErrs[id] = err
+			continue
+		}
+		s.vlans[id] = v
+	}
+	return switched
+}
+
+func (s *NetworkInterfaceState) managementVLANError() string {
+	s.vlanLock.Lock()
+	defer s.vlanLock.Unlock()
+
+	if s.managementVLANErr == nil {
+		return ""
+	}
+	return s.managementVLANErr.Error()
+}
+
+// VLANStates returns the state of the sub-interfaces, in config order.
+func (s *NetworkInterfaceState) VLANStates() []VLANState {
+	s.vlanLock.Lock()
+	defer s.vlanLock.Unlock()
+
+	if len(s.config.VLANs) == 0 {
+		return nil
+	}
+	states := make([]VLANState, 0, len(s.config.VLANs))
+	for _, vlan := range s.config.VLANs {
+		if v, ok := s.vlans[vlan.ID]; ok {
+			states = append(states, v.state())
+			continue
+		}
+		state := VLANState{ID: vlan.ID, InterfaceName: VLANInterfaceName(s.parentName, vlan.ID)}
+		if err := s.vlanErrs[vlan.ID]; err != nil {
+			state.Error = err.Error()
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// checkVLANs reports a state change when the addresses of a sub-interface
+// changed, e.g. for the web server and mDNS.
+func (s *NetworkInterfaceState) checkVLANs() {
+	states := s.VLANStates()
+	for i := range states {
+		// the lease is reported separately, only compare addresses
+		states[i].DHCPLease = nil
+	}
+
+	s.vlanLock.Lock()
+	changed := !reflect.DeepEqual(states, s.lastVLANStates)
+	s.lastVLANStates = states
+	s.vlanLock.Unlock()
+
+	if changed && s.checked {
+		s.handleStateChange()
+	}
+}
@@ -0,0 +1,226 @@
+//go:build linux
+
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/jetkvm/kvm/internal/udhcpc"
+	"github.com/rs/zerolog"
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+)
+
+// vlanRouteMetric keeps the static default routes of sub-interfaces behind
+// the one of the management interface.
+const vlanRouteMetric = 1000
+
+type vlanInterface struct {
+	config     VLANConfig
+	name       string
+	link       netlink.Link
+	dhcpClient *udhcpc.DHCPClient
+	l          *zerolog.Logger
+}
+
+// ensureVLANLink returns the VLAN interface, created if it doesn't exist.
+func ensureVLANLink(parent string, id int) (netlink.Link, error) {
+	name := VLANInterfaceName(parent, id)
+	if link, err := netlink.LinkByName(name); err == nil {
+		vlan, ok := link.(*netlink.Vlan)
+		if !ok || vlan.VlanId != id {
+			return nil, fmt.Errorf("interface %s exists but isn't VLAN %d", name, id)
+		}
+		return link, nil
+	}
+
+	parentLink, err := netlink.LinkByName(parent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interface %s: %w", parent, err)
+	}
+	link := &netlink.Vlan{
+		LinkAttrs: netlink.LinkAttrs{
+			Name:        name,
+			ParentIndex: parentLink.Attrs().Index,
+		},
+		VlanId: id,
+	}
+	if err := netlink.LinkAdd(link); err != nil {
+		return nil, fmt.Errorf("failed to create interface %s: %w", name, err)
+	}
+	return netlink.LinkByName(name)
+}
+
+func setIPv6Sysctl(name, key, value string) error {
+	path := filepath.Join("/proc/sys/net/ipv6/conf", name, key)
+	return os.WriteFile(path, []byte(value), 0644)
+}
+
+// applyIPv6Mode sets up SLAAC or turns IPv6 off, static addresses are added
+// with the IPv4 ones.
+func applyIPv6Mode(name, mode string) error {
+	disable, autoconf := "0", "0"
+	switch mode {
+	case "disabled":
+		disable = "1"
+	case "slaac":
+		autoconf = "1"
+	}
+	if err := setIPv6Sysctl(name, "disable_ipv6", disable); err != nil {
+		return err
+	}
+	if disable == "1" {
+		return nil
+	}
+	if err := setIPv6Sysctl(name, "accept_ra", autoconf); err != nil {
+		return err
+	}
+	return setIPv6Sysctl(name, "autoconf", autoconf)
+}
+
+func addStaticAddress(link netlink.Link, address, gateway string, mask net.IPMask, family, metric int) error {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return fmt.Errorf("invalid address: %s", address)
+	}
+	addr := &netlink.Addr{IPNet: &net.IPNet{IP: ip, Mask: mask}}
+	if err := netlink.AddrReplace(link, addr); err != nil {
+		return fmt.Errorf("failed to add address %s: %w", address, err)
+	}
+
+	gw := net.ParseIP(gateway)
+	if gw == nil {
+		return nil
+	}
+	route := &netlink.Route{
+		LinkIndex: link.Attrs().Index,
+		Gw:        gw,
+		Family:    family,
+		Priority:  metric,
+	}
+	if err := netlink.RouteReplace(route); err != nil {
+		return fmt.Errorf("failed to add default route via %s: %w", gateway, err)
+	}
+	return nil
+}
+
+func applyStaticAddresses(link netlink.Link, config VLANConfig, metric int) error {
+	if config.IPv4Mode.String == "static" && config.IPv4Static != nil {
+		mask := net.IPMask(net.ParseIP(config.IPv4Static.Netmask.String).To4())
+		if err := addStaticAddress(link, config.IPv4Static.Address.String, config.IPv4Static.Gateway.String, mask, nl.FAMILY_V4, metric); err != nil {
+			return err
+		}
+	}
+	if config.IPv6Mode.String == "static" && config.IPv6Static != nil {
+		mask := net.IPMask(net.ParseIP(config.IPv6Static.Prefix.String).To16())
+		if ones, bits := mask.Size(); bits != 128 || ones == 0 {
+			return fmt.Errorf("invalid IPv6 prefix: %s", config.IPv6Static.Prefix.String)
+		}
+		if err := addStaticAddress(link, config.IPv6Static.Address.String, config.IPv6Static.Gateway.String, mask, nl.FAMILY_V6, metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startVLANInterface brings up the VLAN, management tells if it's the
+// management interface, whose default route is preferred.
+func startVLANInterface(parent string, config VLANConfig, management bool, hostname string, onLeaseChange func(lease *udhcpc.Lease), l *zerolog.Logger) (*vlanInterface, error) {
+	name := VLANInterfaceName(parent, config.ID)
+	scopedLogger := l.With().Str("interface", name).Int("vlan", config.ID).Logger()
+
+	link, err := ensureVLANLink(parent, config.ID)
+	if err != nil {
+		return nil, err
+	}
+	v := &vlanInterface{
+		config: config,
+		name:   name,
+		link:   link,
+		l:      &scopedLogger,
+	}
+
+	if err := applyIPv6Mode(name, config.IPv6Mode.String); err != nil {
+		v.close()
+		return nil, fmt.Errorf("failed to set IPv6 mode: %w", err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		v.close()
+		return nil, fmt.Errorf("failed to bring up %s: %w", name, err)
+	}
+	metric := 0
+	if !management {
+		metric = vlanRouteMetric + config.ID
+	}
+	if err := applyStaticAddresses(link, config, metric); err != nil {
+		v.close()
+		return nil, err
+	}
+
+	v.dhcpClient = udhcpc.NewDHCPClient(&udhcpc.DHCPClientOptions{
+		InterfaceName: name,
+		PidFile:       fmt.Sprintf(udhcpc.DHCPPidFile, name),
+		Logger:        &scopedLogger,
+		OnLeaseChange: onLeaseChange,
+	})
+	go v.dhcpClient.Run() // nolint:errcheck
+	if config.IPv4Mode.String == "dhcp" {
+		if err := v.dhcpClient.StartProcess(hostname); err != nil {
+			v.close()
+			return nil, err
+		}
+	}
+
+	scopedLogger.Info().
+		Str("ipv4_mode", config.IPv4Mode.String).
+		Str("ipv6_mode", config.IPv6Mode.String).
+		Msg("VLAN interface started")
+	return v, nil
+}
+
+func (v *vlanInterface) state() VLANState {
+	state := VLANState{
+		ID:            v.config.ID,
+		InterfaceName: v.name,
+	}
+	if v.dhcpClient != nil {
+		state.DHCPLease = v.dhcpClient.GetLease()
+	}
+
+	link, err := netlink.LinkByName(v.name)
+	if err != nil {
+		state.Error = err.Error()
+		return state
+	}
+	state.Up = link.Attrs().OperState == netlink.OperUp
+
+	addrs, err := netlinkAddrs(link)
+	if err != nil {
+		state.Error = err.Error()
+		return state
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			state.IPv4Addresses = append(state.IPv4Addresses, addr.IPNet.String())
+		} else if addr.IP.IsGlobalUnicast() {
+			state.IPv6Addresses = append(state.IPv6Addresses, addr.IPNet.String())
+		}
+	}
+	return state
+}
+
+// close stops the DHCP client and removes the interface with its addresses
+// and routes.
+func (v *vlanInterface) close() {
+	if v.dhcpClient != nil {
+		v.dhcpClient.Stop()
+	}
+	if err := netlink.LinkDel(v.link); err != nil && !errors.Is(err, syscall.ENODEV) {
+		v.l.Warn().Err(err).Msg("failed to remove VLAN interface")
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package network
+
+import (
+	"fmt"
+
+	"github.com/jetkvm/kvm/internal/udhcpc"
+	"github.com/rs/zerolog"
+)
+
+type vlanInterface struct {
+	config     VLANConfig
+	name       string
+	dhcpClient *udhcpc.DHCPClient
+}
+
+func startVLANInterface(parent string, config VLANConfig, management bool, hostname string, onLeaseChange func(lease *udhcpc.Lease), l *zerolog.Logger) (*vlanInterface, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (v *vlanInterface) state() VLANState {
+	return VLANState{ID: v.config.ID, InterfaceName: v.name}
+}
+
+func (v *vlanInterface) close() {}
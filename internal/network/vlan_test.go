@@ -0,0 +1,101 @@
+package network
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/guregu/null/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateVLANs(t *testing.T) {
+	c := &NetworkConfig{
+		VLANID: null.IntFrom(10),
+		VLANs:  []VLANConfig{{ID: 20}, {ID: 30, IPv4Mode: null.StringFrom("disabled")}},
+	}
+	require.NoError(t, c.validateVLANs())
+	assert.Equal(t, "dhcp", c.VLANs[0].IPv4Mode.String, "defaults are filled in")
+	assert.Equal(t, "slaac", c.VLANs[0].IPv6Mode.String)
+	assert.Equal(t, "disabled", c.VLANs[1].IPv4Mode.String)
+
+	tests := map[string]*NetworkConfig{
+		"management id": {VLANID: null.IntFrom(4095)},
+		"id":            {VLANs: []VLANConfig{{ID: 0}}},
+		"duplicate":     {VLANs: []VLANConfig{{ID: 20}, {ID: 20}}},
+		"management":    {VLANID: null.IntFrom(20), VLANs: []VLANConfig{{ID: 20}}},
+		"mode":          {VLANs: []VLANConfig{{ID: 20, IPv6Mode: null.StringFrom("dhcpv6")}}},
+		"static":        {VLANs: []VLANConfig{{ID: 20, IPv4Mode: null.StringFrom("static")}}},
+	}
+	for name, c := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, c.validateVLANs())
+		})
+	}
+}
+
+func TestManagementVLAN(t *testing.T) {
+	c := &NetworkConfig{IPv4Mode: null.StringFrom("dhcp"), IPv6Mode: null.StringFrom("slaac_and_dhcpv6")}
+	assert.Nil(t, c.managementVLAN(), "untagged")
+
+	c.VLANID = null.IntFrom(10)
+	vlan := c.managementVLAN()
+	require.NotNil(t, vlan)
+	assert.Equal(t, 10, vlan.ID)
+	assert.Equal(t, "dhcp", vlan.IPv4Mode.String)
+	assert.Equal(t, "slaac", vlan.IPv6Mode.String)
+	assert.True(t, vlan.WebServer)
+	assert.True(t, vlan.MDNS)
+}
+
+func TestVLANMDNSMode(t *testing.T) {
+	s := &NetworkInterfaceState{
+		parentName: "eth0",
+		config: &NetworkConfig{
+			IPv4Mode: null.StringFrom("dhcp"),
+			IPv6Mode: null.StringFrom("slaac"),
+			MDNSMode: null.StringFrom("auto"),
+			VLANID:   null.IntFrom(10),
+			VLANs:    []VLANConfig{{ID: 20, MDNS: true}, {ID: 30}},
+		},
+	}
+	assert.Equal(t, []string{"eth0", "eth0.30"}, s.GetMDNSMode().ExcludeInterfaces)
+
+	s.config.VLANID = null.Int{}
+	s.config.VLANs = nil
+	assert.Empty(t, s.GetMDNSMode().ExcludeInterfaces)
+}
+
+func TestWebServerAllowed(t *testing.T) {
+	s := &NetworkInterfaceState{
+		parentName: "eth0",
+		config:     &NetworkConfig{},
+	}
+	zoned := func(name string) netip.Addr {
+		return netip.MustParseAddr("fe80::1").WithZone(name)
+	}
+	assert.True(t, s.WebServerAllowed(zoned("eth0")), "no VLANs")
+
+	s.config.VLANID = null.IntFrom(10)
+	s.config.VLANs = []VLANConfig{{ID: 20, WebServer: true}, {ID: 30}}
+	assert.False(t, s.WebServerAllowed(zoned("eth0")), "untagged port")
+	assert.True(t, s.WebServerAllowed(zoned("eth0.10")), "management VLAN")
+	assert.True(t, s.WebServerAllowed(zoned("eth0.20")))
+	assert.False(t, s.WebServerAllowed(zoned("eth0.30")))
+	assert.True(t, s.WebServerAllowed(netip.MustParseAddr("127.0.0.1")), "loopback")
+	assert.False(t, s.WebServerAllowed(netip.MustParseAddr("192.0.2.1")), "unknown interface")
+}
+
+func TestWebServerDeniedInterfaces(t *testing.T) {
+	s := &NetworkInterfaceState{
+		parentName: "eth0",
+		config:     &NetworkConfig{},
+	}
+	assert.Empty(t, s.WebServerDeniedInterfaces(), "no VLANs")
+
+	s.config.VLANs = []VLANConfig{{ID: 20, WebServer: true}, {ID: 30}}
+	assert.Equal(t, []string{"eth0.30"}, s.WebServerDeniedInterfaces(), "untagged management")
+
+	s.config.VLANID = null.IntFrom(10)
+	assert.Equal(t, []string{"eth0", "eth0.30"}, s.WebServerDeniedInterfaces())
+}
@@ -0,0 +1,297 @@
+package network
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	DefaultWireGuardInterface = "wg0"
+	DefaultWireGuardMTU       = 1420
+)
+
+// WireGuardPeer is the remote end of the tunnel, usually the site gateway.
+type WireGuardPeer struct {
+	PublicKey    string `json:"public_key"`
+	PresharedKey string `json:"preshared_key,omitempty"`
+	// Endpoint is host:port, a host name is resolved again while the peer
+	// doesn't answer.
+	Endpoint            string   `json:"endpoint,omitempty"`
+	AllowedIPs          []string `json:"allowed_ips"`
+	PersistentKeepalive int      `json:"persistent_keepalive,omitempty"`
+}
+
+// WireGuardConfig is an embedded userspace WireGuard interface. WebServer,
+// MDNS and ICE choose which services use the tunnel.
+type WireGuardConfig struct {
+	Enabled       bool          `json:"enabled"`
+	InterfaceName string        `json:"interface_name,omitempty"`
+	PrivateKey    string        `json:"private_key"`
+	Addresses     []string      `json:"addresses"`
+	ListenPort    int           `json:"listen_port,omitempty"`
+	MTU           int           `json:"mtu,omitempty"`
+	Peer          WireGuardPeer `json:"peer"`
+
+	// WebServer serves the web interface on the tunnel, also when it's
+	// otherwise bound to loopback only.
+	WebServer bool `json:"web_server"`
+	// MDNS answers mDNS queries on the tunnel.
+	MDNS bool `json:"mdns"`
+	// ICE offers the tunnel addresses as WebRTC host candidates.
+	ICE bool `json:"ice"`
+}
+
+func (c *WireGuardConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// Interface returns the name of the WireGuard interface.
+func (c *WireGuardConfig) Interface() string {
+	if c == nil || c.InterfaceName == "" {
+		return DefaultWireGuardInterface
+	}
+	return c.InterfaceName
+}
+
+func parseWireGuardKey(name, key string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(b) != 32 {
+		return nil, fmt.Errorf("%s must be a base64 encoded 32 byte key", name)
+	}
+	return b, nil
+}
+
+// Validate checks the config and fills in the defaults.
+func (c *WireGuardConfig) Validate() error {
+	if !c.IsEnabled() {
+		return nil
+	}
+	if c.InterfaceName == "" {
+		c.InterfaceName = DefaultWireGuardInterface
+	}
+	if len(c.InterfaceName) > 15 || strings.ContainsAny(c.InterfaceName, "/ ") {
+		return fmt.Errorf("invalid interface name: %s", c.InterfaceName)
+	}
+	if c.MTU == 0 {
+		c.MTU = DefaultWireGuardMTU
+	}
+	if c.MTU < 576 || c.MTU > 9000 {
+		return fmt.Errorf("MTU must be between 576 and 9000")
+	}
+	if c.ListenPort < 0 || c.ListenPort > 65535 {
+		return fmt.Errorf("invalid listen port: %d", c.ListenPort)
+	}
+	if _, err := parseWireGuardKey("private key", c.PrivateKey); err != nil {
+		return err
+	}
+	if len(c.Addresses) == 0 {
+		return fmt.Errorf("at least one interface address is required")
+	}
+	for _, addr := range c.Addresses {
+		if _, err := netip.ParsePrefix(addr); err != nil {
+			return fmt.Errorf("invalid interface address %s, expected CIDR notation", addr)
+		}
+	}
+
+	if _, err := parseWireGuardKey("peer public key", c.Peer.PublicKey); err != nil {
+		return err
+	}
+	if c.Peer.PresharedKey != "" {
+		if _, err := parseWireGuardKey("peer preshared key", c.Peer.PresharedKey); err != nil {
+			return err
+		}
+	}
+	if c.Peer.Endpoint != "" {
+		host, port, err := net.SplitHostPort(c.Peer.Endpoint)
+		if err != nil || host == "" {
+			return fmt.Errorf("invalid peer endpoint %s, expected host:port", c.Peer.Endpoint)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			return fmt.Errorf("invalid peer endpoint port: %s", port)
+		}
+	}
+	if len(c.Peer.AllowedIPs) == 0 {
+		return fmt.Errorf("at least one allowed IP is required")
+	}
+	for _, allowed := range c.Peer.AllowedIPs {
+		prefix, err := netip.ParsePrefix(allowed)
+		if err != nil {
+			return fmt.Errorf("invalid allowed IP %s, expected CIDR notation", allowed)
+		}
+		if prefix.Bits() == 0 {
+			// the management interface keeps the default route
+			return fmt.Errorf("allowed IP %s would route all traffic through the tunnel", allowed)
+		}
+	}
+	if c.Peer.PersistentKeepalive < 0 || c.Peer.PersistentKeepalive > 65535 {
+		return fmt.Errorf("invalid persistent keepalive: %d", c.Peer.PersistentKeepalive)
+	}
+	return nil
+}
+
+// PublicKey returns the public key of the interface, for the peer config.
+func (c *WireGuardConfig) PublicKey() (string, error) {
+	priv, err := parseWireGuardKey("private key", c.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(pub), nil
+}
+
+// uapiConfig returns the config in the wireguard-go UAPI format, with the
+// endpoint already resolved.
+func (c *WireGuardConfig) uapiConfig(endpoint string) (string, error) {
+	priv, err := parseWireGuardKey("private key", c.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	pub, err := parseWireGuardKey("peer public key", c.Peer.PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "private_key=%s\n", hex.EncodeToString(priv))
+	if c.ListenPort != 0 {
+		fmt.Fprintf(&b, "listen_port=%d\n", c.ListenPort)
+	}
+	b.WriteString("replace_peers=true\n")
+	fmt.Fprintf(&b, "public_key=%s\n", hex.EncodeToString(pub))
+	if c.Peer.PresharedKey != "" {
+		psk, err := parseWireGuardKey("peer preshared key", c.Peer.PresharedKey)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "preshared_key=%s\n", hex.EncodeToString(psk))
+	}
+	if endpoint != "" {
+		fmt.Fprintf(&b, "endpoint=%s\n", endpoint)
+	}
+	fmt.Fprintf(&b, "persistent_keepalive_interval=%d\n", c.Peer.PersistentKeepalive)
+	b.WriteString("replace_allowed_ips=true\n")
+	for _, allowed := range c.Peer.AllowedIPs {
+		fmt.Fprintf(&b, "allowed_ip=%s\n", netip.MustParsePrefix(allowed).Masked())
+	}
+	return b.String(), nil
+}
+
+// WireGuardState is the state of the tunnel, LastHandshake is nil until the
+// peer answered.
+type WireGuardState struct {
+	Enabled       bool       `json:"enabled"`
+	Up            bool       `json:"up"`
+	InterfaceName string     `json:"interface_name,omitempty"`
+	PublicKey     string     `json:"public_key,omitempty"`
+	Addresses     []string   `json:"addresses,omitempty"`
+	Endpoint      string     `json:"endpoint,omitempty"`
+	LastHandshake *time.Time `json:"last_handshake,omitempty"`
+	RxBytes       uint64     `json:"rx_bytes"`
+	TxBytes       uint64     `json:"tx_bytes"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// parseUAPIState reads the peer statistics of a UAPI get response.
+func parseUAPIState(uapi string, state *WireGuardState) {
+	var sec, nsec int64
+	for _, line := range strings.Split(uapi, "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "endpoint":
+			state.Endpoint = value
+		case "last_handshake_time_sec":
+			sec, _ = strconv.ParseInt(value, 10, 64)
+		case "last_handshake_time_nsec":
+			nsec, _ = strconv.ParseInt(value, 10, 64)
+		case "rx_bytes":
+			state.RxBytes, _ = strconv.ParseUint(value, 10, 64)
+		case "tx_bytes":
+			state.TxBytes, _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	if sec != 0 || nsec != 0 {
+		t := time.Unix(sec, nsec)
+		state.LastHandshake = &t
+	}
+}
+
+// applyWireGuard starts, restarts or stops the tunnel to match config.
+func (s *NetworkInterfaceState) applyWireGuard(config *WireGuardConfig) {
+	s.wireguardLock.Lock()
+	defer s.wireguardLock.Unlock()
+
+	if s.wireguard != nil {
+		if s.wireguard.sameConfig(config) {
+			return
+		}
+		s.wireguard.close()
+		s.wireguard = nil
+	}
+	s.wireguardErr = nil
+
+	if !config.IsEnabled() {
+		return
+	}
+	// keep our own copy, the running tunnel is compared to later configs
+	running := *config
+	running.Addresses = append([]string(nil), config.Addresses...)
+	running.Peer.AllowedIPs = append([]string(nil), config.Peer.AllowedIPs...)
+
+	w, err := startWireGuard(&running, s.l)
+	if err != nil {
+		s.l.Error().Err(err).Str("interface", config.Interface()).Msg("failed to start WireGuard interface")
+		s.wireguardErr = err
+		return
+	}
+	s.wireguard = w
+}
+
+// WireGuardState returns the state of the tunnel, nil if it's disabled.
+func (s *NetworkInterfaceState) WireGuardState() *WireGuardState {
+	s.wireguardLock.Lock()
+	defer s.wireguardLock.Unlock()
+
+	if !s.config.WireGuard.IsEnabled() {
+		return nil
+	}
+	if s.wireguard == nil {
+		state := &WireGuardState{Enabled: true, InterfaceName: s.config.WireGuard.Interface()}
+		if s.wireguardErr != nil {
+			state.Error = s.wireguardErr.Error()
+		}
+		return state
+	}
+	state := s.wireguard.state()
+	return &state
+}
+
+// WireGuardAddresses returns the addresses of the tunnel, nil if it isn't up.
+func (s *NetworkInterfaceState) WireGuardAddresses() []netip.Addr {
+	s.wireguardLock.Lock()
+	defer s.wireguardLock.Unlock()
+
+	if s.wireguard == nil {
+		return nil
+	}
+	addrs := make([]netip.Addr, 0, len(s.wireguard.config.Addresses))
+	for _, address := range s.wireguard.config.Addresses {
+		if prefix, err := netip.ParsePrefix(address); err == nil {
+			addrs = append(addrs, prefix.Addr())
+		}
+	}
+	return addrs
+}
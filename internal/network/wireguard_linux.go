@@ -0,0 +1,201 @@
+//go:build linux
+
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/vishvananda/netlink"
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun"
+)
+
+// wireGuardStaleHandshake is how long the peer may be silent before a host
+// name endpoint is resolved again.
+const wireGuardStaleHandshake = 3 * time.Minute
+
+type wireGuardInterface struct {
+	config *WireGuardConfig
+	dev    *device.Device
+	l      *zerolog.Logger
+
+	lock     sync.Mutex
+	endpoint string
+	done     chan struct{}
+}
+
+func resolveWireGuardEndpoint(endpoint string) (string, error) {
+	if endpoint == "" {
+		return "", nil
+	}
+	if ap, err := netip.ParseAddrPort(endpoint); err == nil {
+		return ap.String(), nil
+	}
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve peer endpoint: %w", err)
+	}
+	// prefer IPv4, the management network often has no IPv6 route
+	addr := addrs[0]
+	for _, a := range addrs {
+		if a.Unmap().Is4() {
+			addr = a.Unmap()
+			break
+		}
+	}
+	return net.JoinHostPort(addr.String(), port), nil
+}
+
+func startWireGuard(config *WireGuardConfig, l *zerolog.Logger) (*wireGuardInterface, error) {
+	scopedLogger := l.With().Str("interface", config.Interface()).Logger()
+
+	endpoint, err := resolveWireGuardEndpoint(config.Peer.Endpoint)
+	if err != nil {
+		// the peer may still connect to us, the monitor keeps resolving
+		scopedLogger.Warn().Err(err).Str("endpoint", config.Peer.Endpoint).Msg("failed to resolve WireGuard endpoint")
+	}
+	uapi, err := config.uapiConfig(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	tunDev, err := tun.CreateTUN(config.Interface(), config.MTU)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create TUN device: %w", err)
+	}
+	dev := device.NewDevice(tunDev, conn.NewDefaultBind(), &device.Logger{
+		Verbosef: func(format string, args ...any) { scopedLogger.Trace().Msgf(format, args...) },
+		Errorf:   func(format string, args ...any) { scopedLogger.Warn().Msgf(format, args...) },
+	})
+
+	w := &wireGuardInterface{config: config, dev: dev, l: &scopedLogger, endpoint: endpoint, done: make(chan struct{})}
+	if err := w.setup(uapi); err != nil {
+		dev.Close()
+		return nil, err
+	}
+	go w.monitor()
+
+	scopedLogger.Info().Strs("addresses", config.Addresses).Str("endpoint", endpoint).Msg("WireGuard interface started")
+	return w, nil
+}
+
+func (w *wireGuardInterface) setup(uapi string) error {
+	if err := w.dev.IpcSet(uapi); err != nil {
+		return fmt.Errorf("failed to configure WireGuard device: %w", err)
+	}
+	if err := w.dev.Up(); err != nil {
+		return fmt.Errorf("failed to bring up WireGuard device: %w", err)
+	}
+
+	link, err := netlink.LinkByName(w.config.Interface())
+	if err != nil {
+		return fmt.Errorf("failed to get WireGuard interface: %w", err)
+	}
+	for _, address := range w.config.Addresses {
+		addr, err := netlink.ParseAddr(address)
+		if err != nil {
+			return fmt.Errorf("invalid interface address %s: %w", address, err)
+		}
+		if err := netlink.AddrReplace(link, addr); err != nil {
+			return fmt.Errorf("failed to add address %s: %w", address, err)
+		}
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("failed to set WireGuard interface up: %w", err)
+	}
+	for _, allowed := range w.config.Peer.AllowedIPs {
+		_, dst, err := net.ParseCIDR(allowed)
+		if err != nil {
+			return fmt.Errorf("invalid allowed IP %s: %w", allowed, err)
+		}
+		if err := netlink.RouteReplace(&netlink.Route{LinkIndex: link.Attrs().Index, Dst: dst}); err != nil {
+			return fmt.Errorf("failed to add route %s: %w", allowed, err)
+		}
+	}
+	return nil
+}
+
+// monitor resolves a host name endpoint again while the peer is silent,
+// e.g. after the site's dynamic address changed.
+func (w *wireGuardInterface) monitor() {
+	if _, err := netip.ParseAddrPort(w.config.Peer.Endpoint); err == nil || w.config.Peer.Endpoint == "" {
+		return
+	}
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		state := w.state()
+		if state.LastHandshake != nil && time.Since(*state.LastHandshake) < wireGuardStaleHandshake {
+			continue
+		}
+		endpoint, err := resolveWireGuardEndpoint(w.config.Peer.Endpoint)
+		if err != nil {
+			w.l.Debug().Err(err).Msg("failed to resolve WireGuard endpoint")
+			continue
+		}
+
+		w.lock.Lock()
+		changed := endpoint != w.endpoint
+		w.endpoint = endpoint
+		w.lock.Unlock()
+		if !changed {
+			continue
+		}
+
+		pub, _ := parseWireGuardKey("peer public key", w.config.Peer.PublicKey)
+		if err := w.dev.IpcSet(fmt.Sprintf("public_key=%x\nupdate_only=true\nendpoint=%s\n", pub, endpoint)); err != nil {
+			w.l.Warn().Err(err).Msg("failed to update WireGuard endpoint")
+			continue
+		}
+		w.l.Info().Str("endpoint", endpoint).Msg("WireGuard endpoint changed")
+	}
+}
+
+func (w *wireGuardInterface) state() WireGuardState {
+	state := WireGuardState{
+		Enabled:       true,
+		Up:            true,
+		InterfaceName: w.config.Interface(),
+		Addresses:     w.config.Addresses,
+	}
+	state.PublicKey, _ = w.config.PublicKey()
+	uapi, err := w.dev.IpcGet()
+	if err != nil {
+		state.Error = err.Error()
+		return state
+	}
+	parseUAPIState(uapi, &state)
+	return state
+}
+
+func (w *wireGuardInterface) close() {
+	close(w.done)
+	// closing the TUN device removes the interface with its routes
+	w.dev.Close()
+	w.l.Info().Msg("WireGuard interface stopped")
+}
+
+func (w *wireGuardInterface) sameConfig(config *WireGuardConfig) bool {
+	return reflect.DeepEqual(w.config, config)
+}
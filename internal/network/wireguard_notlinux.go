@@ -0,0 +1,27 @@
+//go:build !linux
+
+package network
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+type wireGuardInterface struct {
+	config *WireGuardConfig
+}
+
+func startWireGuard(config *WireGuardConfig, l *zerolog.Logger) (*wireGuardInterface, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (w *wireGuardInterface) state() WireGuardState {
+	return WireGuardState{}
+}
+
+func (w *wireGuardInterface) close() {}
+
+func (w *wireGuardInterface) sameConfig(config *WireGuardConfig) bool {
+	return false
+}
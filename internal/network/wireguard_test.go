@@ -0,0 +1,102 @@
+package network
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hexKey(t *testing.T, h string) string {
+	t.Helper()
+	b, err := hex.DecodeString(h)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func testWireGuardConfig(t *testing.T) *WireGuardConfig {
+	return &WireGuardConfig{
+		Enabled: true,
+		// RFC 7748 test vectors
+		PrivateKey: hexKey(t, "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"),
+		Addresses:  []string{"10.8.0.2/24"},
+		Peer: WireGuardPeer{
+			PublicKey:           hexKey(t, "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f"),
+			Endpoint:            "vpn.example.com:51820",
+			AllowedIPs:          []string{"10.8.0.1/24", "192.168.50.0/24"},
+			PersistentKeepalive: 25,
+		},
+	}
+}
+
+func TestWireGuardValidate(t *testing.T) {
+	var disabled *WireGuardConfig
+	assert.NoError(t, disabled.Validate())
+
+	c := testWireGuardConfig(t)
+	require.NoError(t, c.Validate())
+	assert.Equal(t, DefaultWireGuardInterface, c.InterfaceName)
+	assert.Equal(t, DefaultWireGuardMTU, c.MTU)
+
+	tests := map[string]func(c *WireGuardConfig){
+		"private key":   func(c *WireGuardConfig) { c.PrivateKey = "short" },
+		"address":       func(c *WireGuardConfig) { c.Addresses = []string{"10.8.0.2"} },
+		"no address":    func(c *WireGuardConfig) { c.Addresses = nil },
+		"peer key":      func(c *WireGuardConfig) { c.Peer.PublicKey = "" },
+		"endpoint":      func(c *WireGuardConfig) { c.Peer.Endpoint = "vpn.example.com" },
+		"port":          func(c *WireGuardConfig) { c.Peer.Endpoint = "vpn.example.com:0" },
+		"allowed ip":    func(c *WireGuardConfig) { c.Peer.AllowedIPs = []string{"10.8.0.0"} },
+		"default":       func(c *WireGuardConfig) { c.Peer.AllowedIPs = []string{"0.0.0.0/0"} },
+		"interface":     func(c *WireGuardConfig) { c.InterfaceName = "a-very-long-interface" },
+		"mtu":           func(c *WireGuardConfig) { c.MTU = 100 },
+		"preshared":     func(c *WireGuardConfig) { c.Peer.PresharedKey = "x" },
+		"keepalive":     func(c *WireGuardConfig) { c.Peer.PersistentKeepalive = -1 },
+		"listen port":   func(c *WireGuardConfig) { c.ListenPort = 70000 },
+		"no allowed":    func(c *WireGuardConfig) { c.Peer.AllowedIPs = nil },
+		"endpoint ipv6": func(c *WireGuardConfig) { c.Peer.Endpoint = "[fd00::1]" },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := testWireGuardConfig(t)
+			mutate(c)
+			assert.Error(t, c.Validate())
+		})
+	}
+}
+
+func TestWireGuardPublicKey(t *testing.T) {
+	pub, err := testWireGuardConfig(t).PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, hexKey(t, "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a"), pub)
+}
+
+func TestWireGuardUAPIConfig(t *testing.T) {
+	uapi, err := testWireGuardConfig(t).uapiConfig("203.0.113.7:51820")
+	require.NoError(t, err)
+	assert.Equal(t, "private_key=77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a\n"+
+		"replace_peers=true\n"+
+		"public_key=de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f\n"+
+		"endpoint=203.0.113.7:51820\n"+
+		"persistent_keepalive_interval=25\n"+
+		"replace_allowed_ips=true\n"+
+		"allowed_ip=10.8.0.0/24\n"+
+		"allowed_ip=192.168.50.0/24\n", uapi)
+}
+
+func TestParseUAPIState(t *testing.T) {
+	var state WireGuardState
+	parseUAPIState("private_key=00\npublic_key=01\nendpoint=203.0.113.7:51820\n"+
+		"last_handshake_time_sec=1700000000\nlast_handshake_time_nsec=5\n"+
+		"rx_bytes=1024\ntx_bytes=2048\nerrno=0\n", &state)
+	assert.Equal(t, "203.0.113.7:51820", state.Endpoint)
+	require.NotNil(t, state.LastHandshake)
+	assert.Equal(t, int64(1700000000), state.LastHandshake.Unix())
+	assert.Equal(t, uint64(1024), state.RxBytes)
+	assert.Equal(t, uint64(2048), state.TxBytes)
+
+	state = WireGuardState{}
+	parseUAPIState("last_handshake_time_sec=0\nlast_handshake_time_nsec=0\n", &state)
+	assert.Nil(t, state.LastHandshake, "no handshake yet")
+}
@@ -0,0 +1,278 @@
+// Package oidcauth verifies OpenID Connect identities, for the sessions of the
+// cloud and for logging in to the local web UI with the authorization code
+// flow and PKCE.
+package oidcauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/rs/zerolog"
+	"golang.org/x/oauth2"
+)
+
+// DefaultGroupsClaim is the claim holding the groups of a user, Keycloak's
+// default.
+const DefaultGroupsClaim = "groups"
+
+// ErrNotAllowed is returned for identities not matching any allow rule.
+var ErrNotAllowed = errors.New("identity is not allowed")
+
+var defaultLogger = logging.GetSubsystemLogger("oidc")
+
+type Options struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// Scopes are requested in addition to openid, profile and email.
+	Scopes      []string
+	GroupsClaim string
+
+	// An identity is allowed if it matches any of the rules. Emails can be
+	// given as @domain to allow a whole domain, they must be verified.
+	AllowedSubjects []string
+	AllowedEmails   []string
+	AllowedGroups   []string
+
+	HTTPClient *http.Client
+	Logger     *zerolog.Logger
+}
+
+// Identity is a verified ID token.
+type Identity struct {
+	Issuer        string   `json:"issuer"`
+	Subject       string   `json:"subject"`
+	Audience      []string `json:"audience"`
+	Email         string   `json:"email,omitempty"`
+	EmailVerified bool     `json:"emailVerified"`
+	Name          string   `json:"name,omitempty"`
+	Groups        []string `json:"groups,omitempty"`
+}
+
+// Provider verifies tokens of an OIDC issuer.
+type Provider struct {
+	opts     Options
+	provider *oidc.Provider
+	l        *zerolog.Logger
+}
+
+// NewProvider discovers the issuer's configuration.
+func NewProvider(ctx context.Context, opts Options) (*Provider, error) {
+	if opts.IssuerURL == "" {
+		return nil, fmt.Errorf("issuer URL is required")
+	}
+	if opts.GroupsClaim == "" {
+		opts.GroupsClaim = DefaultGroupsClaim
+	}
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+	if opts.HTTPClient != nil {
+		ctx = oidc.ClientContext(ctx, opts.HTTPClient)
+	}
+
+	provider, err := oidc.NewProvider(ctx, opts.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize OIDC provider: %w", err)
+	}
+	return &Provider{opts: opts, provider: provider, l: opts.Logger}, nil
+}
+
+// HasRules reports whether any allow rule is configured.
+func (p *Provider) HasRules() bool {
+	return len(p.opts.AllowedSubjects)+len(p.opts.AllowedEmails)+len(p.opts.AllowedGroups) > 0
+}
+
+// Allowed checks id against the allow rules.
+func (p *Provider) Allowed(id *Identity) error {
+	if slices.Contains(p.opts.AllowedSubjects, id.Subject) {
+		return nil
+	}
+	if id.Email != "" && id.EmailVerified {
+		email := strings.ToLower(id.Email)
+		for _, allowed := range p.opts.AllowedEmails {
+			allowed = strings.ToLower(allowed)
+			if email == allowed || (strings.HasPrefix(allowed, "@") && strings.HasSuffix(email, allowed)) {
+				return nil
+			}
+		}
+	}
+	for _, group := range id.Groups {
+		if slices.Contains(p.opts.AllowedGroups, group) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNotAllowed, id.Subject)
+}
+
+// Verify verifies a raw ID token, which must be issued for the client ID.
+func (p *Provider) Verify(ctx context.Context, rawIDToken string) (*Identity, error) {
+	if p.opts.ClientID == "" {
+		return nil, errors.New("a client ID is required to check the audience")
+	}
+	if p.opts.HTTPClient != nil {
+		ctx = oidc.ClientContext(ctx, p.opts.HTTPClient)
+	}
+	verifier := p.provider.Verifier(&oidc.Config{ClientID: p.opts.ClientID})
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	return p.identity(idToken)
+}
+
+func (p *Provider) identity(idToken *oidc.IDToken) (*Identity, error) {
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse claims: %w", err)
+	}
+
+	id := &Identity{
+		Issuer:   idToken.Issuer,
+		Subject:  idToken.Subject,
+		Audience: idToken.Audience,
+	}
+	id.Email, _ = claims["email"].(string)
+	id.EmailVerified, _ = claims["email_verified"].(bool)
+	id.Name, _ = claims["name"].(string)
+	if groups, ok := claims[p.opts.GroupsClaim].([]any); ok {
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				id.Groups = append(id.Groups, s)
+			}
+		}
+	}
+	return id, nil
+}
+
+// AuthRequest is a pending authorization code login.
+type AuthRequest struct {
+	State       string
+	Nonce       string
+	Verifier    string
+	RedirectURL string
+	Created     time.Time
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (p *Provider) oauth2Config(redirectURL string) *oauth2.Config {
+	scopes := []string{oidc.ScopeOpenID, "profile", "email"}
+	for _, s := range p.opts.Scopes {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return &oauth2.Config{
+		ClientID:     p.opts.ClientID,
+		ClientSecret: p.opts.ClientSecret,
+		Endpoint:     p.provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       scopes,
+	}
+}
+
+// StartLogin returns a new login and the URL to send the browser to.
+func (p *Provider) StartLogin(redirectURL string) (*AuthRequest, string) {
+	req := &AuthRequest{
+		State:       randomString(),
+		Nonce:       randomString(),
+		Verifier:    oauth2.GenerateVerifier(),
+		RedirectURL: redirectURL,
+		Created:     time.Now(),
+	}
+	authURL := p.oauth2Config(redirectURL).AuthCodeURL(
+		req.State,
+		oidc.Nonce(req.Nonce),
+		oauth2.S256ChallengeOption(req.Verifier),
+	)
+	return req, authURL
+}
+
+// FinishLogin exchanges the code of a login and verifies the ID token.
+func (p *Provider) FinishLogin(ctx context.Context, req *AuthRequest, code string) (*Identity, error) {
+	if p.opts.HTTPClient != nil {
+		ctx = oidc.ClientContext(ctx, p.opts.HTTPClient)
+	}
+	token, err := p.oauth2Config(req.RedirectURL).Exchange(ctx, code, oauth2.VerifierOption(req.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("token response has no ID token")
+	}
+
+	idToken, err := p.provider.Verifier(&oidc.Config{ClientID: p.opts.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != req.Nonce {
+		return nil, fmt.Errorf("ID token nonce mismatch")
+	}
+	return p.identity(idToken)
+}
+
+// LoginStore keeps the pending logins until they're finished or expire.
+type LoginStore struct {
+	lock    sync.Mutex
+	pending map[string]*AuthRequest
+	ttl     time.Duration
+	max     int
+}
+
+func NewLoginStore(ttl time.Duration, max int) *LoginStore {
+	return &LoginStore{pending: make(map[string]*AuthRequest), ttl: ttl, max: max}
+}
+
+func (s *LoginStore) expireLocked(now time.Time) {
+	for state, req := range s.pending {
+		if now.Sub(req.Created) > s.ttl {
+			delete(s.pending, state)
+		}
+	}
+}
+
+// Add stores req. When there are too many pending logins the oldest one is
+// dropped, so unfinished logins can't block new ones.
+func (s *LoginStore) Add(req *AuthRequest) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expireLocked(time.Now())
+	for len(s.pending) >= s.max {
+		var oldest *AuthRequest
+		for _, r := range s.pending {
+			if oldest == nil || r.Created.Before(oldest.Created) {
+				oldest = r
+			}
+		}
+		delete(s.pending, oldest.State)
+	}
+	s.pending[req.State] = req
+}
+
+// Take removes and returns the login of state, logins are used once.
+func (s *LoginStore) Take(state string) (*AuthRequest, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expireLocked(time.Now())
+	req, ok := s.pending[state]
+	delete(s.pending, state)
+	return req, ok
+}
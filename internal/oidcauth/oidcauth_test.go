@@ -0,0 +1,230 @@
+package oidcauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIssuer is a minimal OIDC provider, it issues tokens with claims for
+// the code "good".
+type fakeIssuer struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	claims    map[string]any
+	challenge string
+	nonce     string
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	f := &fakeIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                f.server.URL,
+			"authorization_endpoint":                f.server.URL + "/auth",
+			"token_endpoint":                        f.server.URL + "/token",
+			"jwks_uri":                              f.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   b64(key.N.Bytes()),
+			"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good" || b64(sum[:]) != f.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		claims := map[string]any{"nonce": f.nonce}
+		for k, v := range f.claims {
+			claims[k] = v
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     f.sign(t, claims),
+		})
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeIssuer) sign(t *testing.T, extra map[string]any) string {
+	claims := map[string]any{
+		"iss": f.server.URL,
+		"sub": "user-1",
+		"aud": "jetkvm",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + b64(sig)
+}
+
+func TestAllowed(t *testing.T) {
+	p := &Provider{opts: Options{
+		AllowedSubjects: []string{"admin"},
+		AllowedEmails:   []string{"ops@example.com", "@corp.example.com"},
+		AllowedGroups:   []string{"kvm-users"},
+	}}
+	assert.True(t, p.HasRules())
+
+	tests := []struct {
+		name string
+		id   Identity
+		ok   bool
+	}{
+		{name: "subject", id: Identity{Subject: "admin"}, ok: true},
+		{name: "email", id: Identity{Subject: "x", Email: "OPS@example.com", EmailVerified: true}, ok: true},
+		{name: "domain", id: Identity{Subject: "x", Email: "bob@corp.example.com", EmailVerified: true}, ok: true},
+		{name: "unverified email", id: Identity{Subject: "x", Email: "ops@example.com"}},
+		{name: "other domain", id: Identity{Subject: "x", Email: "bob@evilcorp.example.com.attacker", EmailVerified: true}},
+		{name: "group", id: Identity{Subject: "x", Groups: []string{"staff", "kvm-users"}}, ok: true},
+		{name: "nothing", id: Identity{Subject: "x", Groups: []string{"staff"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Allowed(&tt.id)
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrNotAllowed)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	f := newFakeIssuer(t)
+	ctx := context.Background()
+
+	p, err := NewProvider(ctx, Options{IssuerURL: f.server.URL, GroupsClaim: "roles"})
+	require.NoError(t, err)
+	_, err = p.Verify(ctx, f.sign(t, nil))
+	assert.Error(t, err, "the audience is always checked")
+
+	p, err = NewProvider(ctx, Options{IssuerURL: f.server.URL, ClientID: "jetkvm", GroupsClaim: "roles"})
+	require.NoError(t, err)
+
+	id, err := p.Verify(ctx, f.sign(t, map[string]any{
+		"email":          "bob@example.com",
+		"email_verified": true,
+		"roles":          []string{"kvm-users"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", id.Subject)
+	assert.Equal(t, []string{"jetkvm"}, id.Audience)
+	assert.Equal(t, "bob@example.com", id.Email)
+	assert.Equal(t, []string{"kvm-users"}, id.Groups)
+
+	// tokens of other clients are refused
+	p, err = NewProvider(ctx, Options{IssuerURL: f.server.URL, ClientID: "other"})
+	require.NoError(t, err)
+	_, err = p.Verify(ctx, f.sign(t, nil))
+	assert.Error(t, err)
+
+	_, err = p.Verify(ctx, f.sign(t, map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}))
+	assert.Error(t, err)
+}
+
+func TestLogin(t *testing.T) {
+	f := newFakeIssuer(t)
+	ctx := context.Background()
+	p, err := NewProvider(ctx, Options{IssuerURL: f.server.URL, ClientID: "jetkvm", Scopes: []string{"groups"}})
+	require.NoError(t, err)
+
+	req, authURL := p.StartLogin("https://kvm.example.com/auth/oidc/callback")
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, "/auth", u.Path)
+	assert.Equal(t, req.State, q.Get("state"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+	assert.Equal(t, "openid profile email groups", q.Get("scope"))
+	assert.Equal(t, "https://kvm.example.com/auth/oidc/callback", q.Get("redirect_uri"))
+	f.challenge = q.Get("code_challenge")
+	f.nonce = q.Get("nonce")
+	f.claims = map[string]any{"email": "bob@example.com", "email_verified": true}
+
+	_, err = p.FinishLogin(ctx, req, "bad")
+	assert.Error(t, err)
+
+	id, err := p.FinishLogin(ctx, req, "good")
+	require.NoError(t, err)
+	assert.Equal(t, "bob@example.com", id.Email)
+
+	// a token issued for another login is rejected
+	f.nonce = "replayed"
+	_, err = p.FinishLogin(ctx, req, "good")
+	assert.Error(t, err)
+}
+
+func TestLoginStore(t *testing.T) {
+	s := NewLoginStore(time.Minute, 2)
+	now := time.Now()
+	s.Add(&AuthRequest{State: "a", Created: now.Add(-30 * time.Second)})
+	s.Add(&AuthRequest{State: "b", Created: now.Add(-2 * time.Minute)})
+	// b expired
+	s.Add(&AuthRequest{State: "c", Created: now})
+
+	req, ok := s.Take("a")
+	assert.True(t, ok)
+	assert.Equal(t, "a", req.State)
+	_, ok = s.Take("a")
+	assert.False(t, ok)
+	_, ok = s.Take("b")
+	assert.False(t, ok)
+
+	// a full store drops the oldest login
+	s.Add(&AuthRequest{State: "d", Created: now.Add(-10 * time.Second)})
+	s.Add(&AuthRequest{State: "e", Created: now})
+	_, ok = s.Take("d")
+	assert.False(t, ok)
+	_, ok = s.Take("c")
+	assert.True(t, ok)
+	_, ok = s.Take("e")
+	assert.True(t, ok)
+}
@@ -0,0 +1,276 @@
+// Package power runs power actions on the host, e.g. a graceful shutdown that
+// presses the power button and escalates to a forced off if the host doesn't
+// go down. Every action verifies the power state it leads to.
+package power
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/rs/zerolog"
+)
+
+// Action is a power action.
+type Action string
+
+const (
+	// ActionOn turns the host on unless it's already on.
+	ActionOn Action = "on"
+	// ActionGracefulOff asks the host to shut down and forces it off if it
+	// doesn't within the shutdown timeout.
+	ActionGracefulOff Action = "graceful-off"
+	// ActionForceOff cuts the power.
+	ActionForceOff Action = "force-off"
+	// ActionCycle forces the host off, waits and turns it on again.
+	ActionCycle Action = "power-cycle"
+)
+
+// Actions lists the supported actions.
+var Actions = []Action{ActionOn, ActionGracefulOff, ActionForceOff, ActionCycle}
+
+// Step names.
+const (
+	StepState      = "state"
+	StepPowerOn    = "power-on"
+	StepShutdown   = "shutdown"
+	StepForceOff   = "force-off"
+	StepPoweredOn  = "powered-on"
+	StepPoweredOff = "powered-off"
+	StepTimeout    = "timeout"
+	StepWait       = "wait"
+)
+
+var (
+	ErrUnknownAction = errors.New("unknown power action")
+	// ErrUnsupportedAction is returned by backends that can't run a step,
+	// e.g. ask the host to shut down.
+	ErrUnsupportedAction = errors.New("power action not supported")
+	// ErrNotVerified is returned when the host didn't reach the expected state.
+	ErrNotVerified = errors.New("power state not reached")
+)
+
+var defaultLogger = logging.GetSubsystemLogger("power")
+
+// Backend switches the power of the host.
+type Backend interface {
+	// IsOn reports the power state last read from the board.
+	IsOn() bool
+	// PowerOn turns the host on, it must be off.
+	PowerOn(ctx context.Context) error
+	// Shutdown asks the host to shut down. A backend that can't ask returns
+	// ErrUnsupportedAction rather than cutting the power.
+	Shutdown(ctx context.Context) error
+	// ForceOff cuts the power.
+	ForceOff(ctx context.Context) error
+}
+
+// Step is a step of an action, On is the power state read after it.
+type Step struct {
+	Time    time.Time `json:"time"`
+	Name    string    `json:"name"`
+	On      bool      `json:"on"`
+	Message string    `json:"message,omitempty"`
+}
+
+type Options struct {
+	// ShutdownTimeout is how long the host gets to shut down gracefully.
+	ShutdownTimeout time.Duration
+	// VerifyTimeout is how long a forced transition may take to show up.
+	VerifyTimeout time.Duration
+	// CycleDelay is how long the host stays off during a power cycle.
+	CycleDelay time.Duration
+	// PollInterval is how often the power state is read while waiting.
+	PollInterval time.Duration
+	Logger       *zerolog.Logger
+}
+
+// Controller runs power actions.
+type Controller struct {
+	shutdownTimeout time.Duration
+	verifyTimeout   time.Duration
+	cycleDelay      time.Duration
+	pollInterval    time.Duration
+	l               *zerolog.Logger
+}
+
+func NewController(opts Options) *Controller {
+	c := &Controller{
+		shutdownTimeout: opts.ShutdownTimeout,
+		verifyTimeout:   opts.VerifyTimeout,
+		cycleDelay:      opts.CycleDelay,
+		pollInterval:    opts.PollInterval,
+		l:               opts.Logger,
+	}
+	if c.shutdownTimeout <= 0 {
+		c.shutdownTimeout = 2 * time.Minute
+	}
+	if c.verifyTimeout <= 0 {
+		c.verifyTimeout = 15 * time.Second
+	}
+	if c.cycleDelay <= 0 {
+		c.cycleDelay = 5 * time.Second
+	}
+	if c.pollInterval <= 0 {
+		c.pollInterval = 250 * time.Millisecond
+	}
+	if c.l == nil {
+		c.l = defaultLogger
+	}
+	return c
+}
+
+// ParseAction checks that name is a supported action.
+func ParseAction(name string) (Action, error) {
+	for _, a := range Actions {
+		if string(a) == name {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownAction, name)
+}
+
+// run is a single action run, it reports the steps.
+type run struct {
+	c       *Controller
+	backend Backend
+	onStep  func(Step)
+}
+
+func (r *run) step(name, format string, args ...any) {
+	s := Step{
+		Time:    time.Now().UTC(),
+		Name:    name,
+		On:      r.backend.IsOn(),
+		Message: fmt.Sprintf(format, args...),
+	}
+	r.c.l.Info().Str("step", s.Name).Bool("on", s.On).Msg(s.Message)
+	if r.onStep != nil {
+		r.onStep(s)
+	}
+}
+
+// waitFor waits until the host is on or off, it reports whether it got there
+// within timeout.
+func (r *run) waitFor(ctx context.Context, on bool, timeout time.Duration) (bool, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(r.c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if r.backend.IsOn() == on {
+			return true, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-deadline.C:
+			return r.backend.IsOn() == on, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *run) powerOn(ctx context.Context) error {
+	if r.backend.IsOn() {
+		r.step(StepState, "host is already on")
+		return nil
+	}
+	if err := r.backend.PowerOn(ctx); err != nil {
+		return fmt.Errorf("failed to power on: %w", err)
+	}
+	r.step(StepPowerOn, "power on requested")
+
+	ok, err := r.waitFor(ctx, true, r.c.verifyTimeout)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		r.step(StepTimeout, "host didn't power on within %s", r.c.verifyTimeout)
+		return fmt.Errorf("%w: host is still off", ErrNotVerified)
+	}
+	r.step(StepPoweredOn, "host powered on")
+	return nil
+}
+
+func (r *run) forceOff(ctx context.Context) error {
+	if err := r.backend.ForceOff(ctx); err != nil {
+		return fmt.Errorf("failed to force off: %w", err)
+	}
+	r.step(StepForceOff, "forced power off")
+
+	ok, err := r.waitFor(ctx, false, r.c.verifyTimeout)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		r.step(StepTimeout, "host didn't power off within %s", r.c.verifyTimeout)
+		return fmt.Errorf("%w: host is still on", ErrNotVerified)
+	}
+	r.step(StepPoweredOff, "host powered off")
+	return nil
+}
+
+func (r *run) gracefulOff(ctx context.Context) error {
+	if !r.backend.IsOn() {
+		r.step(StepState, "host is already off")
+		return nil
+	}
+	if err := r.backend.Shutdown(ctx); err != nil {
+		return fmt.Errorf("failed to request shutdown: %w", err)
+	}
+	r.step(StepShutdown, "shutdown requested")
+
+	ok, err := r.waitFor(ctx, false, r.c.shutdownTimeout)
+	if err != nil {
+		return err
+	}
+	if ok {
+		r.step(StepPoweredOff, "host shut down")
+		return nil
+	}
+	r.step(StepTimeout, "host didn't shut down within %s, forcing it off", r.c.shutdownTimeout)
+	return r.forceOff(ctx)
+}
+
+func (r *run) cycle(ctx context.Context) error {
+	if r.backend.IsOn() {
+		if err := r.forceOff(ctx); err != nil {
+			return err
+		}
+	} else {
+		r.step(StepState, "host is already off")
+	}
+
+	r.step(StepWait, "waiting %s before powering on", r.c.cycleDelay)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(r.c.cycleDelay):
+	}
+	return r.powerOn(ctx)
+}
+
+// Run runs action with backend, onStep is called with every step. It returns
+// nil once the host reached the state the action leads to.
+func (c *Controller) Run(ctx context.Context, backend Backend, action Action, onStep func(Step)) error {
+	r := &run{c: c, backend: backend, onStep: onStep}
+	switch action {
+	case ActionOn:
+		return r.powerOn(ctx)
+	case ActionGracefulOff:
+		return r.gracefulOff(ctx)
+	case ActionForceOff:
+		if !backend.IsOn() {
+			r.step(StepState, "host is already off")
+			return nil
+		}
+		return r.forceOff(ctx)
+	case ActionCycle:
+		return r.cycle(ctx)
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownAction, action)
+}
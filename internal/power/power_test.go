@@ -0,0 +1,160 @@
+package power
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend is a host that powers on and off after delay. A host that
+// ignores shutdowns stays on until it's forced off.
+type fakeBackend struct {
+	lock           sync.Mutex
+	on             bool
+	delay          time.Duration
+	ignoreShutdown bool
+	noShutdown     bool
+	ignoreForceOff bool
+	calls          []string
+}
+
+func (b *fakeBackend) IsOn() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.on
+}
+
+func (b *fakeBackend) set(call string, on bool, ignore bool) {
+	b.lock.Lock()
+	b.calls = append(b.calls, call)
+	b.lock.Unlock()
+	if ignore {
+		return
+	}
+	time.AfterFunc(b.delay, func() {
+		b.lock.Lock()
+		b.on = on
+		b.lock.Unlock()
+	})
+}
+
+func (b *fakeBackend) PowerOn(context.Context) error {
+	b.set("on", true, false)
+	return nil
+}
+
+func (b *fakeBackend) Shutdown(context.Context) error {
+	if b.noShutdown {
+		return ErrUnsupportedAction
+	}
+	b.set("shutdown", false, b.ignoreShutdown)
+	return nil
+}
+
+func (b *fakeBackend) ForceOff(context.Context) error {
+	b.set("force-off", false, b.ignoreForceOff)
+	return nil
+}
+
+func (b *fakeBackend) Calls() []string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return append([]string(nil), b.calls...)
+}
+
+func newTestController() *Controller {
+	return NewController(Options{
+		ShutdownTimeout: 100 * time.Millisecond,
+		VerifyTimeout:   100 * time.Millisecond,
+		CycleDelay:      10 * time.Millisecond,
+		PollInterval:    time.Millisecond,
+	})
+}
+
+func runAction(t *testing.T, b *fakeBackend, action Action) ([]string, error) {
+	t.Helper()
+	var steps []string
+	err := newTestController().Run(context.Background(), b, action, func(s Step) {
+		steps = append(steps, s.Name)
+	})
+	return steps, err
+}
+
+func TestParseAction(t *testing.T) {
+	a, err := ParseAction("graceful-off")
+	require.NoError(t, err)
+	assert.Equal(t, ActionGracefulOff, a)
+
+	_, err = ParseAction("explode")
+	assert.ErrorIs(t, err, ErrUnknownAction)
+}
+
+func TestOn(t *testing.T) {
+	b := &fakeBackend{delay: 5 * time.Millisecond}
+	steps, err := runAction(t, b, ActionOn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{StepPowerOn, StepPoweredOn}, steps)
+	assert.True(t, b.IsOn())
+
+	// pressing the button again would turn the host off
+	steps, err = runAction(t, b, ActionOn)
+	require.NoError(t, err)
+	assert.Equal(t, []string{StepState}, steps)
+	assert.Equal(t, []string{"on"}, b.Calls())
+}
+
+func TestGracefulOff(t *testing.T) {
+	b := &fakeBackend{on: true, delay: 5 * time.Millisecond}
+	steps, err := runAction(t, b, ActionGracefulOff)
+	require.NoError(t, err)
+	assert.Equal(t, []string{StepShutdown, StepPoweredOff}, steps)
+	assert.Equal(t, []string{"shutdown"}, b.Calls())
+}
+
+func TestGracefulOffEscalates(t *testing.T) {
+	b := &fakeBackend{on: true, delay: 5 * time.Millisecond, ignoreShutdown: true}
+	steps, err := runAction(t, b, ActionGracefulOff)
+	require.NoError(t, err)
+	assert.Equal(t, []string{StepShutdown, StepTimeout, StepForceOff, StepPoweredOff}, steps)
+	assert.Equal(t, []string{"shutdown", "force-off"}, b.Calls())
+	assert.False(t, b.IsOn())
+}
+
+func TestGracefulOffUnsupported(t *testing.T) {
+	b := &fakeBackend{on: true, delay: 5 * time.Millisecond, noShutdown: true}
+	steps, err := runAction(t, b, ActionGracefulOff)
+	assert.ErrorIs(t, err, ErrUnsupportedAction)
+	assert.Empty(t, steps, "no shutdown is reported")
+	assert.Empty(t, b.Calls())
+	assert.True(t, b.IsOn())
+}
+
+func TestForceOffNotVerified(t *testing.T) {
+	b := &fakeBackend{on: true, ignoreForceOff: true}
+	steps, err := runAction(t, b, ActionForceOff)
+	assert.ErrorIs(t, err, ErrNotVerified)
+	assert.Equal(t, []string{StepForceOff, StepTimeout}, steps)
+}
+
+func TestCycle(t *testing.T) {
+	b := &fakeBackend{on: true, delay: 5 * time.Millisecond}
+	steps, err := runAction(t, b, ActionCycle)
+	require.NoError(t, err)
+	assert.Equal(t, []string{StepForceOff, StepPoweredOff, StepWait, StepPowerOn, StepPoweredOn}, steps)
+	assert.Equal(t, []string{"force-off", "on"}, b.Calls())
+	assert.True(t, b.IsOn())
+}
+
+func TestCancel(t *testing.T) {
+	b := &fakeBackend{on: true, ignoreShutdown: true}
+	c := NewController(Options{ShutdownTimeout: time.Minute, PollInterval: time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := c.Run(ctx, b, ActionGracefulOff, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"shutdown"}, b.Calls())
+}
@@ -0,0 +1,92 @@
+package relay
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/coder/websocket"
+	"github.com/hashicorp/yamux"
+)
+
+type ClientOptions struct {
+	// URL is the tunnel endpoint of the relay, e.g. wss://relay.example.com/tunnel.
+	URL        string
+	DeviceID   string
+	Token      string
+	AppVersion string
+	// TLSConfig verifies the relay, the system roots are used without it.
+	TLSConfig *tls.Config
+}
+
+// Tunnel is a connection to the relay. It's a net.Listener returning the
+// connections the relay forwards, so an http.Server can serve it.
+type Tunnel struct {
+	conn    *websocket.Conn
+	session *yamux.Session
+	cancel  context.CancelFunc
+}
+
+// Dial connects to the relay. ctx only bounds the handshake.
+func Dial(ctx context.Context, opts ClientOptions) (*Tunnel, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return nil, fmt.Errorf("invalid relay url: %s", opts.URL)
+	}
+	if opts.DeviceID == "" || opts.Token == "" {
+		return nil, fmt.Errorf("device id and token are required")
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+opts.Token)
+	header.Set(DeviceIDHeader, opts.DeviceID)
+	if opts.AppVersion != "" {
+		header.Set(AppVersionHeader, opts.AppVersion)
+	}
+	dialOpts := &websocket.DialOptions{HTTPHeader: header}
+	if opts.TLSConfig != nil {
+		dialOpts.HTTPClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: opts.TLSConfig},
+		}
+	}
+
+	conn, resp, err := websocket.Dial(ctx, opts.URL, dialOpts)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("failed to connect to relay: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("failed to connect to relay: %w", err)
+	}
+
+	// the NetConn lives as long as the tunnel, not the dial context
+	connCtx, cancel := context.WithCancel(context.Background())
+	session, err := yamux.Server(websocket.NetConn(connCtx, conn, websocket.MessageBinary), yamuxConfig())
+	if err != nil {
+		cancel()
+		conn.Close(websocket.StatusInternalError, "")
+		return nil, fmt.Errorf("failed to start tunnel session: %w", err)
+	}
+	return &Tunnel{conn: conn, session: session, cancel: cancel}, nil
+}
+
+func (t *Tunnel) Accept() (net.Conn, error) {
+	return t.session.Accept()
+}
+
+func (t *Tunnel) Addr() net.Addr {
+	return t.session.Addr()
+}
+
+func (t *Tunnel) Close() error {
+	err := t.session.Close()
+	t.cancel()
+	return err
+}
+
+// Done is closed when the tunnel is closed or the relay goes away.
+func (t *Tunnel) Done() <-chan struct{} {
+	return t.session.CloseChan()
+}
@@ -0,0 +1,37 @@
+// Package relay tunnels the web interface of devices behind NAT through a
+// self-hosted relay. The device keeps an outbound WebSocket to the relay and
+// multiplexes it with yamux, the relay opens a stream per proxied connection
+// and the device serves HTTP on them.
+package relay
+
+import (
+	"io"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	"github.com/jetkvm/kvm/internal/logging"
+)
+
+const (
+	// TunnelPath is where devices connect to the relay.
+	TunnelPath = "/tunnel"
+
+	DeviceIDHeader   = "X-Device-ID"
+	AppVersionHeader = "X-App-Version"
+
+	// DeviceCookie selects the device for requests that don't name it in
+	// the host.
+	DeviceCookie = "jetkvm_device"
+	// DevicePathPrefix selects a device by visiting /d/<device id>/.
+	DevicePathPrefix = "/d/"
+)
+
+var defaultLogger = logging.GetSubsystemLogger("relay")
+
+func yamuxConfig() *yamux.Config {
+	cfg := yamux.DefaultConfig()
+	cfg.KeepAliveInterval = 20 * time.Second
+	cfg.ConnectionWriteTimeout = 15 * time.Second
+	cfg.LogOutput = io.Discard
+	return cfg
+}
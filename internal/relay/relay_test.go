@@ -0,0 +1,202 @@
+package relay
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func deviceHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello from "+r.Host+" via "+r.Header.Get("X-Forwarded-For"))
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow()
+		for {
+			typ, msg, err := conn.Read(r.Context())
+			if err != nil {
+				return
+			}
+			if err := conn.Write(r.Context(), typ, msg); err != nil {
+				return
+			}
+		}
+	})
+	return mux
+}
+
+func startRelay(t *testing.T, domain string) (*Server, *httptest.Server) {
+	t.Helper()
+	s := NewServer(ServerOptions{Devices: map[string]string{"dev1": "secret"}, Domain: domain})
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func connectDevice(t *testing.T, s *Server, ts *httptest.Server) *Tunnel {
+	t.Helper()
+	tunnel, err := Dial(context.Background(), ClientOptions{
+		URL:        "ws" + strings.TrimPrefix(ts.URL, "http") + TunnelPath,
+		DeviceID:   "dev1",
+		Token:      "secret",
+		AppVersion: "1.2.3",
+	})
+	require.NoError(t, err)
+	srv := &http.Server{Handler: deviceHandler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() { _ = srv.Serve(tunnel) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	require.Eventually(t, func() bool { return len(s.Devices()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	return tunnel
+}
+
+func TestProxyHTTP(t *testing.T) {
+	s, ts := startRelay(t, "")
+
+	resp, err := http.Get(ts.URL + "/hello")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "no device selected")
+
+	connectDevice(t, s, ts)
+	devices := s.Devices()
+	assert.Equal(t, "dev1", devices[0].ID)
+	assert.Equal(t, "1.2.3", devices[0].AppVersion)
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+
+	resp, err = client.Get(ts.URL + "/d/unknown/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = client.Get(ts.URL + "/d/dev1/hello")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello from "+strings.TrimPrefix(ts.URL, "http://")+" via 127.0.0.1", string(body))
+
+	// the cookie keeps routing to the device
+	resp, err = client.Get(ts.URL + "/hello")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestProxyWebSocket(t *testing.T) {
+	s, ts := startRelay(t, "")
+	connectDevice(t, s, ts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	header := http.Header{}
+	header.Set("Cookie", DeviceCookie+"=dev1")
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(ts.URL, "http")+"/echo", &websocket.DialOptions{HTTPHeader: header})
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte("ping")))
+	_, msg, err := conn.Read(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(msg))
+}
+
+func TestDomainRouting(t *testing.T) {
+	s, ts := startRelay(t, "relay.example")
+	connectDevice(t, s, ts)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/hello", nil)
+	require.NoError(t, err)
+	req.Host = "dev1.relay.example"
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(string(body), "hello from dev1.relay.example"))
+
+	req.Host = "dev2.relay.example"
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+}
+
+func TestReconnect(t *testing.T) {
+	s, ts := startRelay(t, "")
+	first := connectDevice(t, s, ts)
+	connectDevice(t, s, ts)
+
+	// the relay drops the older tunnel of the device
+	select {
+	case <-first.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("old tunnel wasn't closed")
+	}
+	assert.Len(t, s.Devices(), 1)
+}
+
+func TestTunnelAuth(t *testing.T) {
+	_, ts := startRelay(t, "")
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + TunnelPath
+
+	_, err := Dial(context.Background(), ClientOptions{URL: url, DeviceID: "dev1", Token: "wrong"})
+	assert.ErrorContains(t, err, "401")
+	_, err = Dial(context.Background(), ClientOptions{URL: url, DeviceID: "dev2", Token: "secret"})
+	assert.ErrorContains(t, err, "401")
+	_, err = Dial(context.Background(), ClientOptions{URL: "http://relay", DeviceID: "dev1", Token: "secret"})
+	assert.ErrorContains(t, err, "invalid relay url")
+}
+
+func TestSetDevices(t *testing.T) {
+	s, ts := startRelay(t, "")
+	tunnel := connectDevice(t, s, ts)
+
+	s.SetDevices(map[string]string{"dev1": "secret"})
+	assert.Len(t, s.Devices(), 1, "unchanged devices stay connected")
+
+	s.SetDevices(map[string]string{"dev1": "rotated"})
+	select {
+	case <-tunnel.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("tunnel with the old token wasn't closed")
+	}
+	require.Eventually(t, func() bool { return len(s.Devices()) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPathRoutingSingleDevice(t *testing.T) {
+	s, ts := startRelay(t, "")
+	connectDevice(t, s, ts)
+	s.SetDevices(map[string]string{"dev1": "secret", "dev2": "other"})
+
+	// devices would share the relay origin
+	resp, err := http.Get(ts.URL + "/d/dev1/hello")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/hello", nil)
+	require.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: DeviceCookie, Value: "dev1"})
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "the cookie is ignored")
+}
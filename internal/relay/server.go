@@ -0,0 +1,277 @@
+package relay
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/hashicorp/yamux"
+	"github.com/rs/zerolog"
+)
+
+type ServerOptions struct {
+	// Devices maps the device IDs allowed to connect to their tokens, see
+	// SetDevices.
+	Devices map[string]string
+	// Domain routes requests for <device id>.<Domain> to the device. Other
+	// hosts are routed by the DeviceCookie, but only while a single device
+	// is allowed: devices sharing the relay origin would share their cookies
+	// and could script each other.
+	Domain string
+	Logger *zerolog.Logger
+}
+
+type device struct {
+	session    *yamux.Session
+	token      string
+	proxy      *httputil.ReverseProxy
+	appVersion string
+	connected  time.Time
+	remoteAddr string
+}
+
+// DeviceInfo describes a connected device.
+type DeviceInfo struct {
+	ID         string    `json:"id"`
+	AppVersion string    `json:"app_version"`
+	Connected  time.Time `json:"connected"`
+	RemoteAddr string    `json:"remote_addr"`
+}
+
+// Server is the relay. It accepts device tunnels on TunnelPath and proxies
+// all other requests, including WebSocket upgrades, to the selected device.
+type Server struct {
+	opts ServerOptions
+	l    *zerolog.Logger
+
+	mu      sync.Mutex
+	tokens  map[string]string
+	devices map[string]*device
+}
+
+func NewServer(opts ServerOptions) *Server {
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+	opts.Domain = strings.TrimPrefix(strings.ToLower(opts.Domain), ".")
+	s := &Server{opts: opts, l: opts.Logger, devices: make(map[string]*device)}
+	s.SetDevices(opts.Devices)
+	return s
+}
+
+// SetDevices replaces the allowed devices. Connected devices that were
+// removed or got a new token are disconnected.
+func (s *Server) SetDevices(tokens map[string]string) {
+	copied := make(map[string]string, len(tokens))
+	for id, token := range tokens {
+		copied[id] = token
+	}
+
+	s.mu.Lock()
+	s.tokens = copied
+	var stale []*device
+	for id, d := range s.devices {
+		if copied[id] != d.token {
+			stale = append(stale, d)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, d := range stale {
+		d.session.Close()
+	}
+}
+
+// pathRouting reports whether devices can be selected by the DeviceCookie.
+func (s *Server) pathRouting() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tokens) <= 1
+}
+
+func (s *Server) token(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[id]
+	return token, ok
+}
+
+// Devices returns the connected devices.
+func (s *Server) Devices() []DeviceInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]DeviceInfo, 0, len(s.devices))
+	for id, d := range s.devices {
+		list = append(list, DeviceInfo{ID: id, AppVersion: d.appVersion, Connected: d.connected, RemoteAddr: d.remoteAddr})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == TunnelPath && r.Header.Get(DeviceIDHeader) != "":
+		s.serveTunnel(w, r)
+	case strings.HasPrefix(r.URL.Path, DevicePathPrefix):
+		s.selectDevice(w, r)
+	default:
+		s.proxy(w, r)
+	}
+}
+
+func (s *Server) authenticate(r *http.Request) (id, token string, ok bool) {
+	id = r.Header.Get(DeviceIDHeader)
+	token, ok = s.token(id)
+	if !ok || token == "" {
+		return id, "", false
+	}
+	got, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return id, token, found && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func (s *Server) serveTunnel(w http.ResponseWriter, r *http.Request) {
+	id, token, ok := s.authenticate(r)
+	if !ok {
+		s.l.Warn().Str("device", id).Str("remote", r.RemoteAddr).Msg("rejected tunnel")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		s.l.Warn().Err(err).Str("device", id).Msg("failed to accept tunnel")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session, err := yamux.Client(websocket.NetConn(ctx, conn, websocket.MessageBinary), yamuxConfig())
+	if err != nil {
+		s.l.Warn().Err(err).Str("device", id).Msg("failed to start tunnel session")
+		conn.Close(websocket.StatusInternalError, "")
+		return
+	}
+
+	d := &device{
+		session:    session,
+		token:      token,
+		appVersion: r.Header.Get(AppVersionHeader),
+		connected:  time.Now(),
+		remoteAddr: r.RemoteAddr,
+	}
+	d.proxy = newDeviceProxy(session, s.l)
+
+	s.mu.Lock()
+	old := s.devices[id]
+	s.devices[id] = d
+	s.mu.Unlock()
+	if old != nil {
+		// the device reconnected before the old tunnel timed out
+		old.session.Close()
+	}
+	s.l.Info().Str("device", id).Str("remote", r.RemoteAddr).Str("version", d.appVersion).Msg("device connected")
+
+	<-session.CloseChan()
+
+	s.mu.Lock()
+	if s.devices[id] == d {
+		delete(s.devices, id)
+	}
+	s.mu.Unlock()
+	s.l.Info().Str("device", id).Msg("device disconnected")
+}
+
+func newDeviceProxy(session *yamux.Session, l *zerolog.Logger) *httputil.ReverseProxy {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return session.Open()
+		},
+		MaxIdleConns:    10,
+		IdleConnTimeout: 90 * time.Second,
+	}
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(&url.URL{Scheme: "http", Host: "device"})
+			// the device builds redirect URLs from the public host
+			pr.Out.Host = pr.In.Host
+			pr.SetXForwarded()
+		},
+		Transport:     transport,
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			l.Debug().Err(err).Str("path", r.URL.Path).Msg("proxy request failed")
+			http.Error(w, "device unavailable", http.StatusBadGateway)
+		},
+	}
+}
+
+// deviceID returns the device a proxied request is for.
+func (s *Server) deviceID(r *http.Request) string {
+	if s.opts.Domain != "" {
+		host := strings.ToLower(r.Host)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if id, ok := strings.CutSuffix(host, "."+s.opts.Domain); ok && !strings.Contains(id, ".") {
+			return id
+		}
+	}
+	if !s.pathRouting() {
+		return ""
+	}
+	if c, err := r.Cookie(DeviceCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// selectDevice handles /d/<device id>/..., it remembers the device in a
+// cookie and redirects to the path on the device.
+func (s *Server) selectDevice(w http.ResponseWriter, r *http.Request) {
+	if !s.pathRouting() {
+		http.Error(w, "more than one device is registered, open <device id>.<relay domain>", http.StatusNotFound)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, DevicePathPrefix)
+	id, path, _ := strings.Cut(rest, "/")
+	if _, ok := s.token(id); !ok {
+		http.NotFound(w, r)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     DeviceCookie,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	target := "/" + path
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+func (s *Server) proxy(w http.ResponseWriter, r *http.Request) {
+	id := s.deviceID(r)
+	if id == "" {
+		http.Error(w, "no device selected, open "+DevicePathPrefix+"<device id>/", http.StatusNotFound)
+		return
+	}
+	s.mu.Lock()
+	d := s.devices[id]
+	s.mu.Unlock()
+	if d == nil {
+		http.Error(w, "device is not connected", http.StatusBadGateway)
+		return
+	}
+	d.proxy.ServeHTTP(w, r)
+}
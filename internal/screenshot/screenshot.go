@@ -0,0 +1,255 @@
+// Package screenshot grabs still images from the H.264 video stream.
+//
+// The frames since the last keyframe are kept in memory, a screenshot decodes
+// them with ffmpeg and returns the most recent frame.
+package screenshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultMaxFrames is the number of frames buffered when the keyframe interval is longer.
+	DefaultMaxFrames = 300
+	// DefaultMaxBytes is the size of the buffered frames when the keyframe interval is longer.
+	DefaultMaxBytes = 16 * 1024 * 1024
+
+	nalTypeIDR = 5
+	nalTypeSPS = 7
+	nalTypePPS = 8
+)
+
+var (
+	defaultLogger = logging.GetSubsystemLogger("screenshot")
+
+	// ErrNoFrame is returned when no keyframe has been received yet.
+	ErrNoFrame = errors.New("no video frame available")
+)
+
+// Options are the options for a new Grabber.
+type Options struct {
+	MaxFrames int
+	MaxBytes  int
+	Logger    *zerolog.Logger
+}
+
+// Grabber buffers the frames of an Annex B H.264 stream so the current
+// picture can be decoded at any time.
+type Grabber struct {
+	maxFrames int
+	maxBytes  int
+	l         *zerolog.Logger
+
+	lock   sync.Mutex
+	frames [][]byte
+	size   int
+	sps    []byte
+	pps    []byte
+	// updated is closed and replaced whenever a frame is buffered
+	updated chan struct{}
+}
+
+// NewGrabber creates a new Grabber.
+func NewGrabber(opts *Options) *Grabber {
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+	if opts.MaxFrames <= 0 {
+		opts.MaxFrames = DefaultMaxFrames
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+
+	return &Grabber{
+		maxFrames: opts.MaxFrames,
+		maxBytes:  opts.MaxBytes,
+		l:         opts.Logger,
+		updated:   make(chan struct{}),
+	}
+}
+
+// nalUnits returns the NAL units of an Annex B access unit.
+func nalUnits(frame []byte) [][]byte {
+	var units [][]byte
+
+	start := -1
+	for i := 0; i+2 < len(frame); i++ {
+		if frame[i] != 0 || frame[i+1] != 0 || frame[i+2] != 1 {
+			continue
+		}
+		if start >= 0 {
+			end := i
+			// a 4 byte start code belongs to the next unit
+			if end > start && frame[end-1] == 0 {
+				end--
+			}
+			units = append(units, frame[start:end])
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 && start < len(frame) {
+		units = append(units, frame[start:])
+	}
+	return units
+}
+
+// WriteFrame adds an access unit from the encoder to the buffer.
+func (g *Grabber) WriteFrame(frame []byte) {
+	keyframe := false
+	var sps, pps []byte
+	for _, nal := range nalUnits(frame) {
+		if len(nal) == 0 {
+			continue
+		}
+		switch nal[0] & 0x1F {
+		case nalTypeIDR:
+			keyframe = true
+		case nalTypeSPS:
+			sps = nal
+		case nalTypePPS:
+			pps = nal
+		}
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if sps != nil {
+		g.sps = append([]byte{}, sps...)
+	}
+	if pps != nil {
+		g.pps = append([]byte{}, pps...)
+	}
+
+	if keyframe {
+		g.frames = g.frames[:0]
+		g.size = 0
+	} else if len(g.frames) == 0 {
+		// can't decode without the keyframe, wait for the next one
+		return
+	}
+
+	if len(g.frames) >= g.maxFrames || g.size+len(frame) > g.maxBytes {
+		g.l.Debug().Int("frames", len(g.frames)).Int("size", g.size).Msg("screenshot buffer full, waiting for next keyframe")
+		g.frames = nil
+		g.size = 0
+		return
+	}
+
+	g.frames = append(g.frames, append([]byte{}, frame...))
+	g.size += len(frame)
+
+	close(g.updated)
+	g.updated = make(chan struct{})
+}
+
+// Reset drops all buffered frames, e.g. when the video stream is restarted.
+func (g *Grabber) Reset() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.frames = nil
+	g.size = 0
+}
+
+// snapshot returns the buffered stream, starting with the parameter sets.
+func (g *Grabber) snapshot() ([]byte, int, chan struct{}) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if len(g.frames) == 0 {
+		return nil, 0, g.updated
+	}
+
+	startCode := []byte{0, 0, 0, 1}
+	var buf bytes.Buffer
+	buf.Grow(g.size + len(g.sps) + len(g.pps) + 8)
+	for _, ps := range [][]byte{g.sps, g.pps} {
+		if ps != nil {
+			buf.Write(startCode)
+			buf.Write(ps)
+		}
+	}
+	for _, frame := range g.frames {
+		buf.Write(frame)
+	}
+	return buf.Bytes(), len(g.frames), g.updated
+}
+
+// Capture decodes the most recent frame. If no keyframe has been received yet,
+// it waits for one until ctx is done.
+func (g *Grabber) Capture(ctx context.Context) (image.Image, error) {
+	for {
+		stream, count, updated := g.snapshot()
+		if count > 0 {
+			return decodeLastFrame(ctx, stream, count)
+		}
+
+		select {
+		case <-updated:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, ErrNoFrame
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// CaptureTimeout is Capture with a timeout for the first keyframe.
+func (g *Grabber) CaptureTimeout(timeout time.Duration) (image.Image, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return g.Capture(ctx)
+}
+
+func decodeLastFrame(ctx context.Context, stream []byte, count int) (image.Image, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, fmt.Errorf("ffmpeg not found: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-f", "h264",
+		"-i", "pipe:0",
+		"-vf", "select=eq(n\\,"+strconv.Itoa(count-1)+")",
+		"-frames:v", "1",
+		"-f", "image2pipe",
+		"-c:v", "png",
+		"pipe:1",
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stream)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to decode frame: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("failed to decode frame: no output: %s", bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	img, err := png.Decode(&stdout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode png: %w", err)
+	}
+	return img, nil
+}
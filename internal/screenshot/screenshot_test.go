@@ -0,0 +1,67 @@
+package screenshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSPS = []byte{0, 0, 0, 1, 0x67, 0x42, 0x00, 0x1f}
+	testPPS = []byte{0, 0, 0, 1, 0x68, 0xce, 0x3c, 0x80}
+	testIDR = []byte{0, 0, 1, 0x65, 0x88, 0x84}
+	testP   = []byte{0, 0, 0, 1, 0x41, 0x9a, 0x02}
+)
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestNALUnits(t *testing.T) {
+	units := nalUnits(concat(testSPS, testPPS, testIDR))
+	assert.Equal(t, [][]byte{
+		{0x67, 0x42, 0x00, 0x1f},
+		{0x68, 0xce, 0x3c, 0x80},
+		{0x65, 0x88, 0x84},
+	}, units)
+
+	assert.Empty(t, nalUnits([]byte{0x01, 0x02}))
+}
+
+func TestGrabberBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrabber(&Options{MaxFrames: 3})
+
+	// frames before the first keyframe can't be decoded
+	g.WriteFrame(testP)
+	_, count, _ := g.snapshot()
+	assert.Equal(0, count)
+
+	g.WriteFrame(concat(testSPS, testPPS, testIDR))
+	g.WriteFrame(testP)
+	stream, count, _ := g.snapshot()
+	assert.Equal(2, count)
+	assert.Equal(concat(testSPS, testPPS, testSPS, testPPS, testIDR, testP), stream)
+
+	// a new keyframe starts a new group of pictures
+	g.WriteFrame(testIDR)
+	_, count, _ = g.snapshot()
+	assert.Equal(1, count)
+
+	// the buffer is dropped when the keyframe interval is too long
+	g.WriteFrame(testP)
+	g.WriteFrame(testP)
+	g.WriteFrame(testP)
+	_, count, _ = g.snapshot()
+	assert.Equal(0, count)
+
+	g.WriteFrame(testIDR)
+	g.Reset()
+	_, count, _ = g.snapshot()
+	assert.Equal(0, count)
+}
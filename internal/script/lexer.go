@@ -0,0 +1,252 @@
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenNumber
+	tokenString
+	tokenKeyword
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	line int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of script"
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "if": true,
+	"local": true, "nil": true, "not": true, "or": true, "repeat": true,
+	"return": true, "then": true, "true": true, "until": true, "while": true,
+}
+
+// operators, longest first
+var operators = []string{
+	"==", "~=", "<=", ">=", "..",
+	"+", "-", "*", "/", "%", "<", ">", "=", "(", ")", ",", "#", ";",
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func tokenize(src string) ([]token, error) {
+	l := &lexer{src: src, line: 1}
+
+	var tokens []token
+	for {
+		t, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+		if t.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) errorf(format string, args ...any) error {
+	return &Error{Line: l.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) skipSpaceAndComments() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "--[["):
+			end := strings.Index(l.src[l.pos+4:], "]]")
+			if end < 0 {
+				return l.errorf("unfinished long comment")
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+4+end], "\n")
+			l.pos += 4 + end + 2
+		case strings.HasPrefix(l.src[l.pos:], "--"):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *lexer) next() (token, error) {
+	if err := l.skipSpaceAndComments(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, line: l.line}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+
+	switch {
+	case isNameStart(c):
+		for l.pos < len(l.src) && (isNameStart(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		text := l.src[start:l.pos]
+		if keywords[text] {
+			return token{kind: tokenKeyword, text: text, line: l.line}, nil
+		}
+		return token{kind: tokenName, text: text, line: l.line}, nil
+
+	case isDigit(c) || (c == '.' && l.pos+1 < len(l.src) && isDigit(l.src[l.pos+1])):
+		return l.number()
+
+	case c == '"' || c == '\'':
+		return l.quotedString(c)
+
+	case strings.HasPrefix(l.src[l.pos:], "[["):
+		end := strings.Index(l.src[l.pos+2:], "]]")
+		if end < 0 {
+			return token{}, l.errorf("unfinished long string")
+		}
+		text := l.src[l.pos+2 : l.pos+2+end]
+		line := l.line
+		l.line += strings.Count(text, "\n")
+		l.pos += 2 + end + 2
+		// like Lua, a newline right after the opening bracket is skipped
+		text = strings.TrimPrefix(strings.TrimPrefix(text, "\r"), "\n")
+		return token{kind: tokenString, text: text, line: line}, nil
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokenOp, text: op, line: l.line}, nil
+		}
+	}
+
+	return token{}, l.errorf("unexpected character %q", c)
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	if strings.HasPrefix(l.src[l.pos:], "0x") || strings.HasPrefix(l.src[l.pos:], "0X") {
+		l.pos += 2
+		for l.pos < len(l.src) && strings.IndexByte("0123456789abcdefABCDEF", l.src[l.pos]) >= 0 {
+			l.pos++
+		}
+		v, err := strconv.ParseUint(l.src[start+2:l.pos], 16, 53)
+		if err != nil {
+			return token{}, l.errorf("malformed number %s", l.src[start:l.pos])
+		}
+		return token{kind: tokenNumber, text: l.src[start:l.pos], num: float64(v), line: l.line}, nil
+	}
+
+	for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+		// don't swallow the concat operator in 1..2
+		if strings.HasPrefix(l.src[l.pos:], "..") {
+			break
+		}
+		l.pos++
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+	}
+
+	text := l.src[start:l.pos]
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return token{}, l.errorf("malformed number %s", text)
+	}
+	return token{kind: tokenNumber, text: text, num: v, line: l.line}, nil
+}
+
+func (l *lexer) quotedString(quote byte) (token, error) {
+	line := l.line
+	l.pos++
+
+	var sb strings.Builder
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			return token{}, l.errorf("unfinished string")
+		}
+
+		c := l.src[l.pos]
+		l.pos++
+		if c == quote {
+			break
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+
+		if l.pos >= len(l.src) {
+			return token{}, l.errorf("unfinished string")
+		}
+		e := l.src[l.pos]
+		l.pos++
+		switch e {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'e':
+			sb.WriteByte(0x1b)
+		case '\\', '"', '\'':
+			sb.WriteByte(e)
+		case '\n':
+			sb.WriteByte('\n')
+			l.line++
+		default:
+			return token{}, l.errorf("invalid escape sequence \\%c", e)
+		}
+	}
+
+	return token{kind: tokenString, text: sb.String(), line: line}, nil
+}
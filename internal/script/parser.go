@@ -0,0 +1,595 @@
+package script
+
+import "fmt"
+
+type expr interface {
+	exprLine() int
+}
+
+type (
+	literalExpr struct {
+		line  int
+		value Value
+	}
+	nameExpr struct {
+		line int
+		name string
+	}
+	callExpr struct {
+		line int
+		name string
+		args []expr
+	}
+	unaryExpr struct {
+		line int
+		op   string
+		x    expr
+	}
+	binaryExpr struct {
+		line int
+		op   string
+		x, y expr
+	}
+)
+
+func (e *literalExpr) exprLine() int { return e.line }
+func (e *nameExpr) exprLine() int    { return e.line }
+func (e *callExpr) exprLine() int    { return e.line }
+func (e *unaryExpr) exprLine() int   { return e.line }
+func (e *binaryExpr) exprLine() int  { return e.line }
+
+type stmt interface {
+	stmtLine() int
+}
+
+type block []stmt
+
+type (
+	localStmt struct {
+		line  int
+		name  string
+		value expr
+	}
+	assignStmt struct {
+		line  int
+		name  string
+		value expr
+	}
+	callStmt struct {
+		call *callExpr
+	}
+	ifStmt struct {
+		line      int
+		conds     []expr
+		blocks    []block
+		elseBlock block
+	}
+	whileStmt struct {
+		line int
+		cond expr
+		body block
+	}
+	repeatStmt struct {
+		line int
+		body block
+		cond expr
+	}
+	forStmt struct {
+		line              int
+		name              string
+		start, stop, step expr
+		body              block
+	}
+	doStmt struct {
+		line int
+		body block
+	}
+	breakStmt struct {
+		line int
+	}
+	returnStmt struct {
+		line  int
+		value expr
+	}
+	functionStmt struct {
+		line   int
+		name   string
+		local  bool
+		params []string
+		body   block
+	}
+)
+
+func (s *localStmt) stmtLine() int    { return s.line }
+func (s *assignStmt) stmtLine() int   { return s.line }
+func (s *callStmt) stmtLine() int     { return s.call.line }
+func (s *ifStmt) stmtLine() int       { return s.line }
+func (s *whileStmt) stmtLine() int    { return s.line }
+func (s *repeatStmt) stmtLine() int   { return s.line }
+func (s *forStmt) stmtLine() int      { return s.line }
+func (s *doStmt) stmtLine() int       { return s.line }
+func (s *breakStmt) stmtLine() int    { return s.line }
+func (s *returnStmt) stmtLine() int   { return s.line }
+func (s *functionStmt) stmtLine() int { return s.line }
+
+type parser struct {
+	tokens []token
+	pos    int
+	loops  int
+}
+
+func parse(src string) (block, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return body, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) is(kind tokenKind, text string) bool {
+	t := p.peek()
+	return t.kind == kind && t.text == text
+}
+
+func (p *parser) accept(kind tokenKind, text string) bool {
+	if p.is(kind, text) {
+		p.advance()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, text string) (token, error) {
+	t := p.peek()
+	if t.kind != kind || t.text != text {
+		return t, p.errorf(t, "'%s' expected near %s", text, t)
+	}
+	return p.advance(), nil
+}
+
+func (p *parser) expectName() (token, error) {
+	t := p.peek()
+	if t.kind != tokenName {
+		return t, p.errorf(t, "name expected near %s", t)
+	}
+	return p.advance(), nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &Error{Line: t.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// blockEnd reports whether the next token ends the current block.
+func (p *parser) blockEnd() bool {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return true
+	}
+	if t.kind != tokenKeyword {
+		return false
+	}
+	switch t.text {
+	case "end", "else", "elseif", "until":
+		return true
+	}
+	return false
+}
+
+func (p *parser) block() (block, error) {
+	var b block
+	for !p.blockEnd() {
+		if p.accept(tokenOp, ";") {
+			continue
+		}
+
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, s)
+
+		// return must be the last statement of a block
+		if _, ok := s.(*returnStmt); ok {
+			p.accept(tokenOp, ";")
+			if !p.blockEnd() {
+				t := p.peek()
+				return nil, p.errorf(t, "'end' expected after return near %s", t)
+			}
+		}
+	}
+	return b, nil
+}
+
+func (p *parser) loopBody(endKeyword string) (block, error) {
+	p.loops++
+	defer func() { p.loops-- }()
+
+	body, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if endKeyword != "" {
+		if _, err := p.expect(tokenKeyword, endKeyword); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+func (p *parser) statement() (stmt, error) {
+	t := p.peek()
+
+	if t.kind == tokenKeyword {
+		switch t.text {
+		case "local":
+			p.advance()
+			if p.accept(tokenKeyword, "function") {
+				return p.function(t.line, true)
+			}
+			name, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			s := &localStmt{line: t.line, name: name.text}
+			if p.accept(tokenOp, "=") {
+				if s.value, err = p.expr(); err != nil {
+					return nil, err
+				}
+			}
+			return s, nil
+
+		case "function":
+			p.advance()
+			return p.function(t.line, false)
+
+		case "if":
+			return p.ifStatement()
+
+		case "while":
+			p.advance()
+			cond, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokenKeyword, "do"); err != nil {
+				return nil, err
+			}
+			body, err := p.loopBody("end")
+			if err != nil {
+				return nil, err
+			}
+			return &whileStmt{line: t.line, cond: cond, body: body}, nil
+
+		case "repeat":
+			p.advance()
+			body, err := p.loopBody("until")
+			if err != nil {
+				return nil, err
+			}
+			cond, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return &repeatStmt{line: t.line, body: body, cond: cond}, nil
+
+		case "for":
+			return p.forStatement()
+
+		case "do":
+			p.advance()
+			body, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokenKeyword, "end"); err != nil {
+				return nil, err
+			}
+			return &doStmt{line: t.line, body: body}, nil
+
+		case "break":
+			p.advance()
+			if p.loops == 0 {
+				return nil, p.errorf(t, "break outside a loop")
+			}
+			return &breakStmt{line: t.line}, nil
+
+		case "return":
+			p.advance()
+			s := &returnStmt{line: t.line}
+			if !p.blockEnd() && !p.is(tokenOp, ";") {
+				var err error
+				if s.value, err = p.expr(); err != nil {
+					return nil, err
+				}
+			}
+			return s, nil
+		}
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.is(tokenOp, "(") {
+		call, err := p.call(name)
+		if err != nil {
+			return nil, err
+		}
+		return &callStmt{call: call}, nil
+	}
+
+	if _, err := p.expect(tokenOp, "="); err != nil {
+		return nil, err
+	}
+	value, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	return &assignStmt{line: name.line, name: name.text, value: value}, nil
+}
+
+func (p *parser) function(line int, local bool) (stmt, error) {
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenOp, "("); err != nil {
+		return nil, err
+	}
+
+	var params []string
+	if !p.is(tokenOp, ")") {
+		for {
+			param, err := p.expectName()
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, param.text)
+			if !p.accept(tokenOp, ",") {
+				break
+			}
+		}
+	}
+	if _, err := p.expect(tokenOp, ")"); err != nil {
+		return nil, err
+	}
+
+	// break can't jump out of a function body
+	loops := p.loops
+	p.loops = 0
+	body, err := p.block()
+	p.loops = loops
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenKeyword, "end"); err != nil {
+		return nil, err
+	}
+
+	return &functionStmt{line: line, name: name.text, local: local, params: params, body: body}, nil
+}
+
+func (p *parser) ifStatement() (stmt, error) {
+	t := p.advance()
+	s := &ifStmt{line: t.line}
+
+	for {
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenKeyword, "then"); err != nil {
+			return nil, err
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		s.conds = append(s.conds, cond)
+		s.blocks = append(s.blocks, body)
+
+		if !p.accept(tokenKeyword, "elseif") {
+			break
+		}
+	}
+
+	if p.accept(tokenKeyword, "else") {
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		s.elseBlock = body
+	}
+
+	if _, err := p.expect(tokenKeyword, "end"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (p *parser) forStatement() (stmt, error) {
+	t := p.advance()
+
+	name, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenOp, "="); err != nil {
+		return nil, err
+	}
+
+	s := &forStmt{line: t.line, name: name.text}
+	if s.start, err = p.expr(); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokenOp, ","); err != nil {
+		return nil, err
+	}
+	if s.stop, err = p.expr(); err != nil {
+		return nil, err
+	}
+	if p.accept(tokenOp, ",") {
+		if s.step, err = p.expr(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.expect(tokenKeyword, "do"); err != nil {
+		return nil, err
+	}
+	if s.body, err = p.loopBody("end"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (p *parser) call(name token) (*callExpr, error) {
+	if _, err := p.expect(tokenOp, "("); err != nil {
+		return nil, err
+	}
+
+	c := &callExpr{line: name.line, name: name.text}
+	if !p.is(tokenOp, ")") {
+		for {
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			c.args = append(c.args, arg)
+			if !p.accept(tokenOp, ",") {
+				break
+			}
+		}
+	}
+	if _, err := p.expect(tokenOp, ")"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// binary operator precedence, higher binds tighter
+var binaryPrecedence = map[string]int{
+	"or":  1,
+	"and": 2,
+	"<":   3, ">": 3, "<=": 3, ">=": 3, "~=": 3, "==": 3,
+	"..": 4,
+	"+":  5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+const unaryPrecedence = 7
+
+func (p *parser) binaryOp() (string, int) {
+	t := p.peek()
+	if t.kind != tokenOp && t.kind != tokenKeyword {
+		return "", 0
+	}
+	prec, ok := binaryPrecedence[t.text]
+	if !ok {
+		return "", 0
+	}
+	return t.text, prec
+}
+
+func (p *parser) expr() (expr, error) {
+	return p.subexpr(0)
+}
+
+// subexpr parses an expression where all binary operators bind tighter than limit.
+func (p *parser) subexpr(limit int) (expr, error) {
+	var x expr
+
+	t := p.peek()
+	if (t.kind == tokenKeyword && t.text == "not") || (t.kind == tokenOp && (t.text == "-" || t.text == "#")) {
+		p.advance()
+		operand, err := p.subexpr(unaryPrecedence)
+		if err != nil {
+			return nil, err
+		}
+		x = &unaryExpr{line: t.line, op: t.text, x: operand}
+	} else {
+		var err error
+		if x, err = p.primary(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		op, prec := p.binaryOp()
+		if prec == 0 || prec <= limit {
+			return x, nil
+		}
+		t := p.advance()
+
+		// concatenation is right associative
+		next := prec
+		if op == ".." {
+			next = prec - 1
+		}
+		y, err := p.subexpr(next)
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryExpr{line: t.line, op: op, x: x, y: y}
+	}
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.advance()
+
+	switch t.kind {
+	case tokenNumber:
+		return &literalExpr{line: t.line, value: t.num}, nil
+	case tokenString:
+		return &literalExpr{line: t.line, value: t.text}, nil
+	case tokenName:
+		if p.is(tokenOp, "(") {
+			return p.call(t)
+		}
+		return &nameExpr{line: t.line, name: t.text}, nil
+	case tokenKeyword:
+		switch t.text {
+		case "nil":
+			return &literalExpr{line: t.line, value: nil}, nil
+		case "true":
+			return &literalExpr{line: t.line, value: true}, nil
+		case "false":
+			return &literalExpr{line: t.line, value: false}, nil
+		}
+	case tokenOp:
+		if t.text == "(" {
+			x, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(tokenOp, ")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	}
+
+	return nil, p.errorf(t, "unexpected %s", t)
+}
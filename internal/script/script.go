@@ -0,0 +1,637 @@
+// Package script implements a small, sandboxed scripting language for
+// automating the device.
+//
+// The language is a subset of Lua: local and global variables, if/elseif/else,
+// while, repeat/until, numeric for loops, functions, and nil, boolean, number and
+// string values. There are no tables, no modules and no access to the file system
+// or the network, everything a script can do on the device goes through the
+// functions passed in RunOptions.Builtins.
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const (
+	// DefaultMaxSteps is the number of statements a script may run.
+	DefaultMaxSteps = 10_000_000
+	// MaxSourceSize is the maximum size of a script in bytes.
+	MaxSourceSize = 64 * 1024
+
+	maxCallDepth    = 200
+	maxStringLength = 1024 * 1024
+)
+
+// Value is a script value: nil, bool, float64, string or a function.
+type Value any
+
+// Func is a function provided by the host. It's called with the script's
+// context and should return early when it's done.
+type Func func(ctx context.Context, args []Value) (Value, error)
+
+type function struct {
+	name   string
+	params []string
+	body   block
+	env    *env
+}
+
+// Error is a compile or runtime error in a script.
+type Error struct {
+	Line int
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrStepLimit is returned when a script runs more statements than allowed.
+var ErrStepLimit = errors.New("step limit exceeded")
+
+// Program is a compiled script.
+type Program struct {
+	body block
+}
+
+// Compile parses a script.
+func Compile(src string) (*Program, error) {
+	if len(src) > MaxSourceSize {
+		return nil, fmt.Errorf("script is too large: %d bytes, maximum is %d", len(src), MaxSourceSize)
+	}
+
+	body, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{body: body}, nil
+}
+
+// RunOptions are the options for running a Program.
+type RunOptions struct {
+	// Builtins are the global functions available to the script, in addition to
+	// the ones of this package.
+	Builtins map[string]Func
+	// MaxSteps is the number of statements the script may run, 0 for DefaultMaxSteps.
+	MaxSteps int
+	// OnLine is called before a statement is run.
+	OnLine func(line int)
+}
+
+type env struct {
+	vars   map[string]Value
+	parent *env
+}
+
+func newEnv(parent *env) *env {
+	return &env{vars: make(map[string]Value), parent: parent}
+}
+
+func (e *env) lookup(name string) (*env, bool) {
+	for s := e; s != nil; s = s.parent {
+		if _, ok := s.vars[name]; ok {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+type control int
+
+const (
+	controlNone control = iota
+	controlBreak
+	controlReturn
+)
+
+type interpreter struct {
+	ctx      context.Context
+	globals  *env
+	steps    int
+	maxSteps int
+	depth    int
+	onLine   func(line int)
+}
+
+// Run runs the program until it finishes, fails or ctx is done. It returns the
+// value of a top level return statement.
+func (p *Program) Run(ctx context.Context, opts *RunOptions) (Value, error) {
+	if opts == nil {
+		opts = &RunOptions{}
+	}
+	if opts.MaxSteps <= 0 {
+		opts.MaxSteps = DefaultMaxSteps
+	}
+
+	in := &interpreter{
+		ctx:      ctx,
+		globals:  newEnv(nil),
+		maxSteps: opts.MaxSteps,
+		onLine:   opts.OnLine,
+	}
+	for name, f := range stdlib {
+		in.globals.vars[name] = f
+	}
+	for name, f := range opts.Builtins {
+		in.globals.vars[name] = f
+	}
+
+	_, ret, err := in.execBlock(p.body, newEnv(in.globals))
+	return ret, err
+}
+
+func (in *interpreter) errorf(line int, format string, args ...any) error {
+	return &Error{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (in *interpreter) execBlock(b block, scope *env) (control, Value, error) {
+	for _, s := range b {
+		ctrl, ret, err := in.exec(s, scope)
+		if err != nil || ctrl != controlNone {
+			return ctrl, ret, err
+		}
+	}
+	return controlNone, nil, nil
+}
+
+func (in *interpreter) step(line int) error {
+	if err := in.ctx.Err(); err != nil {
+		return &Error{Line: line, Msg: err.Error(), Err: err}
+	}
+
+	in.steps++
+	if in.steps > in.maxSteps {
+		return &Error{Line: line, Msg: ErrStepLimit.Error(), Err: ErrStepLimit}
+	}
+
+	if in.onLine != nil {
+		in.onLine(line)
+	}
+	return nil
+}
+
+func (in *interpreter) exec(s stmt, scope *env) (control, Value, error) {
+	if err := in.step(s.stmtLine()); err != nil {
+		return controlNone, nil, err
+	}
+
+	switch s := s.(type) {
+	case *localStmt:
+		var v Value
+		if s.value != nil {
+			var err error
+			if v, err = in.eval(s.value, scope); err != nil {
+				return controlNone, nil, err
+			}
+		}
+		scope.vars[s.name] = v
+
+	case *assignStmt:
+		v, err := in.eval(s.value, scope)
+		if err != nil {
+			return controlNone, nil, err
+		}
+		if target, ok := scope.lookup(s.name); ok {
+			target.vars[s.name] = v
+		} else {
+			in.globals.vars[s.name] = v
+		}
+
+	case *callStmt:
+		if _, err := in.eval(s.call, scope); err != nil {
+			return controlNone, nil, err
+		}
+
+	case *functionStmt:
+		// the body is evaluated in the declaring scope, so a local function can call itself
+		f := &function{name: s.name, params: s.params, body: s.body, env: scope}
+		if s.local {
+			scope.vars[s.name] = f
+		} else {
+			in.globals.vars[s.name] = f
+		}
+
+	case *ifStmt:
+		for i, cond := range s.conds {
+			v, err := in.eval(cond, scope)
+			if err != nil {
+				return controlNone, nil, err
+			}
+			if truthy(v) {
+				return in.execBlock(s.blocks[i], newEnv(scope))
+			}
+		}
+		if s.elseBlock != nil {
+			return in.execBlock(s.elseBlock, newEnv(scope))
+		}
+
+	case *whileStmt:
+		for {
+			v, err := in.eval(s.cond, scope)
+			if err != nil {
+				return controlNone, nil, err
+			}
+			if !truthy(v) {
+				break
+			}
+
+			ctrl, ret, err := in.execBlock(s.body, newEnv(scope))
+			if err != nil || ctrl == controlReturn {
+				return ctrl, ret, err
+			}
+			if ctrl == controlBreak {
+				break
+			}
+			if err := in.step(s.line); err != nil {
+				return controlNone, nil, err
+			}
+		}
+
+	case *repeatStmt:
+		for {
+			// the condition can see the body's locals
+			bodyScope := newEnv(scope)
+			ctrl, ret, err := in.execBlock(s.body, bodyScope)
+			if err != nil || ctrl == controlReturn {
+				return ctrl, ret, err
+			}
+			if ctrl == controlBreak {
+				break
+			}
+
+			v, err := in.eval(s.cond, bodyScope)
+			if err != nil {
+				return controlNone, nil, err
+			}
+			if truthy(v) {
+				break
+			}
+			if err := in.step(s.line); err != nil {
+				return controlNone, nil, err
+			}
+		}
+
+	case *forStmt:
+		return in.execFor(s, scope)
+
+	case *doStmt:
+		return in.execBlock(s.body, newEnv(scope))
+
+	case *breakStmt:
+		return controlBreak, nil, nil
+
+	case *returnStmt:
+		var v Value
+		if s.value != nil {
+			var err error
+			if v, err = in.eval(s.value, scope); err != nil {
+				return controlNone, nil, err
+			}
+		}
+		return controlReturn, v, nil
+
+	default:
+		return controlNone, nil, in.errorf(s.stmtLine(), "unknown statement %T", s)
+	}
+
+	return controlNone, nil, nil
+}
+
+func (in *interpreter) execFor(s *forStmt, scope *env) (control, Value, error) {
+	number := func(e expr, what string) (float64, error) {
+		v, err := in.eval(e, scope)
+		if err != nil {
+			return 0, err
+		}
+		n, ok := v.(float64)
+		if !ok {
+			return 0, in.errorf(s.line, "'for' %s must be a number, got %s", what, TypeName(v))
+		}
+		return n, nil
+	}
+
+	start, err := number(s.start, "initial value")
+	if err != nil {
+		return controlNone, nil, err
+	}
+	stop, err := number(s.stop, "limit")
+	if err != nil {
+		return controlNone, nil, err
+	}
+	step := 1.0
+	if s.step != nil {
+		if step, err = number(s.step, "step"); err != nil {
+			return controlNone, nil, err
+		}
+		if step == 0 {
+			return controlNone, nil, in.errorf(s.line, "'for' step is zero")
+		}
+	}
+
+	for i := start; (step > 0 && i <= stop) || (step < 0 && i >= stop); i += step {
+		loopScope := newEnv(scope)
+		loopScope.vars[s.name] = i
+
+		ctrl, ret, err := in.execBlock(s.body, loopScope)
+		if err != nil || ctrl == controlReturn {
+			return ctrl, ret, err
+		}
+		if ctrl == controlBreak {
+			break
+		}
+		if err := in.step(s.line); err != nil {
+			return controlNone, nil, err
+		}
+	}
+	return controlNone, nil, nil
+}
+
+func (in *interpreter) eval(e expr, scope *env) (Value, error) {
+	switch e := e.(type) {
+	case *literalExpr:
+		return e.value, nil
+
+	case *nameExpr:
+		if target, ok := scope.lookup(e.name); ok {
+			return target.vars[e.name], nil
+		}
+		return nil, nil
+
+	case *callExpr:
+		return in.call(e, scope)
+
+	case *unaryExpr:
+		x, err := in.eval(e.x, scope)
+		if err != nil {
+			return nil, err
+		}
+		switch e.op {
+		case "not":
+			return !truthy(x), nil
+		case "-":
+			n, ok := x.(float64)
+			if !ok {
+				return nil, in.errorf(e.line, "attempt to negate a %s value", TypeName(x))
+			}
+			return -n, nil
+		case "#":
+			s, ok := x.(string)
+			if !ok {
+				return nil, in.errorf(e.line, "attempt to get length of a %s value", TypeName(x))
+			}
+			return float64(len(s)), nil
+		}
+
+	case *binaryExpr:
+		return in.evalBinary(e, scope)
+	}
+
+	return nil, in.errorf(e.exprLine(), "unknown expression %T", e)
+}
+
+func (in *interpreter) evalBinary(e *binaryExpr, scope *env) (Value, error) {
+	x, err := in.eval(e.x, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// short-circuit evaluation
+	switch e.op {
+	case "and":
+		if !truthy(x) {
+			return x, nil
+		}
+		return in.eval(e.y, scope)
+	case "or":
+		if truthy(x) {
+			return x, nil
+		}
+		return in.eval(e.y, scope)
+	}
+
+	y, err := in.eval(e.y, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return equal(x, y), nil
+	case "~=":
+		return !equal(x, y), nil
+
+	case "..":
+		xs, xok := concatString(x)
+		ys, yok := concatString(y)
+		if !xok || !yok {
+			bad := x
+			if xok {
+				bad = y
+			}
+			return nil, in.errorf(e.line, "attempt to concatenate a %s value", TypeName(bad))
+		}
+		if len(xs)+len(ys) > maxStringLength {
+			return nil, in.errorf(e.line, "string too long")
+		}
+		return xs + ys, nil
+
+	case "<", "<=", ">", ">=":
+		return in.compare(e, x, y)
+	}
+
+	xn, xok := x.(float64)
+	yn, yok := y.(float64)
+	if !xok || !yok {
+		bad := x
+		if xok {
+			bad = y
+		}
+		return nil, in.errorf(e.line, "attempt to perform arithmetic on a %s value", TypeName(bad))
+	}
+
+	switch e.op {
+	case "+":
+		return xn + yn, nil
+	case "-":
+		return xn - yn, nil
+	case "*":
+		return xn * yn, nil
+	case "/":
+		return xn / yn, nil
+	case "%":
+		// Lua's modulo has the sign of the divisor
+		return xn - math.Floor(xn/yn)*yn, nil
+	}
+
+	return nil, in.errorf(e.line, "unknown operator %s", e.op)
+}
+
+func (in *interpreter) compare(e *binaryExpr, x, y Value) (Value, error) {
+	var less, eq bool
+	switch xv := x.(type) {
+	case float64:
+		yv, ok := y.(float64)
+		if !ok {
+			return nil, in.errorf(e.line, "attempt to compare number with %s", TypeName(y))
+		}
+		less, eq = xv < yv, xv == yv
+	case string:
+		yv, ok := y.(string)
+		if !ok {
+			return nil, in.errorf(e.line, "attempt to compare string with %s", TypeName(y))
+		}
+		less, eq = xv < yv, xv == yv
+	default:
+		return nil, in.errorf(e.line, "attempt to compare two %s values", TypeName(x))
+	}
+
+	switch e.op {
+	case "<":
+		return less, nil
+	case "<=":
+		return less || eq, nil
+	case ">":
+		return !less && !eq, nil
+	default:
+		return !less, nil
+	}
+}
+
+func (in *interpreter) call(e *callExpr, scope *env) (Value, error) {
+	var callee Value
+	if target, ok := scope.lookup(e.name); ok {
+		callee = target.vars[e.name]
+	}
+
+	args := make([]Value, len(e.args))
+	for i, arg := range e.args {
+		v, err := in.eval(arg, scope)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch f := callee.(type) {
+	case Func:
+		v, err := f(in.ctx, args)
+		if err != nil {
+			var scriptErr *Error
+			if errors.As(err, &scriptErr) {
+				return nil, err
+			}
+			return nil, &Error{Line: e.line, Msg: fmt.Sprintf("%s: %v", e.name, err), Err: err}
+		}
+		return v, nil
+
+	case *function:
+		if in.depth >= maxCallDepth {
+			return nil, in.errorf(e.line, "stack overflow")
+		}
+		in.depth++
+		defer func() { in.depth-- }()
+
+		fnScope := newEnv(f.env)
+		for i, param := range f.params {
+			var v Value
+			if i < len(args) {
+				v = args[i]
+			}
+			fnScope.vars[param] = v
+		}
+		_, ret, err := in.execBlock(f.body, fnScope)
+		return ret, err
+	}
+
+	if callee == nil {
+		return nil, in.errorf(e.line, "attempt to call undefined function '%s'", e.name)
+	}
+	return nil, in.errorf(e.line, "attempt to call a %s value '%s'", TypeName(callee), e.name)
+}
+
+func truthy(v Value) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	}
+	return true
+}
+
+func equal(x, y Value) bool {
+	switch xv := x.(type) {
+	case *function:
+		yv, ok := y.(*function)
+		return ok && xv == yv
+	case Func:
+		// host functions can't be compared
+		return false
+	}
+	if _, ok := y.(Func); ok {
+		return false
+	}
+	return x == y
+}
+
+func concatString(v Value) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return formatNumber(v), true
+	}
+	return "", false
+}
+
+func formatNumber(n float64) string {
+	if n == math.Trunc(n) && math.Abs(n) < 1e15 {
+		return strconv.FormatInt(int64(n), 10)
+	}
+	return strconv.FormatFloat(n, 'g', 14, 64)
+}
+
+// TypeName returns the script type name of v.
+func TypeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case Func, *function:
+		return "function"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// ToString formats a value the way the script's tostring does.
+func ToString(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return formatNumber(v)
+	case string:
+		return v
+	case *function:
+		return "function: " + v.name
+	case Func:
+		return "function: builtin"
+	}
+	return fmt.Sprintf("%v", v)
+}
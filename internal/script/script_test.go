@@ -0,0 +1,184 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func run(t *testing.T, src string, builtins map[string]Func) (Value, error) {
+	t.Helper()
+
+	p, err := Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return p.Run(context.Background(), &RunOptions{Builtins: builtins, MaxSteps: 10000})
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want Value
+	}{
+		{"arithmetic", "return 1 + 2 * 3 - 4 / 2", 5.0},
+		{"modulo", "return -7 % 3", 2.0},
+		{"concat", `return "a" .. 1 .. "b" .. 2.5`, "a1b2.5"},
+		{"comparison", "return 1 < 2 and 'a' < 'b' and 2 >= 2 and 1 ~= 2", true},
+		{"short circuit", "return nil or false or 'x'", "x"},
+		{"not", "return not nil == true", true},
+		{"length", "return #'hello'", 5.0},
+		{"while", "local i = 0 while i < 10 do i = i + 1 end return i", 10.0},
+		{"repeat", "local i = 0 repeat local j = i i = i + 1 until j >= 3 return i", 4.0},
+		{"for", "local s = 0 for i = 1, 10, 2 do s = s + i end return s", 25.0},
+		{"for down", "local s = '' for i = 3, 1, -1 do s = s .. i end return s", "321"},
+		{"break", "local i = 0 while true do i = i + 1 if i == 5 then break end end return i", 5.0},
+		{"if elseif", "local x = 2 if x == 1 then return 'a' elseif x == 2 then return 'b' else return 'c' end", "b"},
+		{"scope", "local x = 1 do local x = 2 end return x", 1.0},
+		{"globals", "function set() y = 3 end set() return y", 3.0},
+		{"recursion", `
+local function fib(n)
+  if n < 2 then return n end
+  return fib(n - 1) + fib(n - 2)
+end
+return fib(10)`, 55.0},
+		{"long string", "return [[\nline1\nline2]]", "line1\nline2"},
+		{"escapes", `return "a\tb\n"`, "a\tb\n"},
+		{"stdlib", "return tostring(tonumber(' 42 ')) .. upper('x') .. tostring(contains('abc', 'b'))", "42Xtrue"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := run(t, tt.src, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		line int
+	}{
+		{"syntax", "local x = \nif", 2},
+		{"unfinished", "if true then\n", 2},
+		{"break outside loop", "break", 1},
+		{"undefined function", "\nfoo()", 2},
+		{"arithmetic", "return 1 + 'a'", 1},
+		{"compare", "return 1 < 'a'", 1},
+		{"step limit", "while true do end", 1},
+		{"stack overflow", "function f() f() end f()", 1},
+		{"error", "\n\nerror('boom')", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := run(t, tt.src, nil)
+			var scriptErr *Error
+			if assert.ErrorAs(t, err, &scriptErr) {
+				assert.Equal(t, tt.line, scriptErr.Line, err.Error())
+			}
+		})
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	assert := assert.New(t)
+
+	var typed []string
+	builtins := map[string]Func{
+		"type_text": func(_ context.Context, args []Value) (Value, error) {
+			s, err := StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			typed = append(typed, s)
+			return nil, nil
+		},
+		"fail": func(_ context.Context, _ []Value) (Value, error) {
+			return nil, errors.New("failed")
+		},
+	}
+
+	_, err := run(t, "for i = 1, 3 do type_text('x' .. i) end", builtins)
+	assert.NoError(err)
+	assert.Equal([]string{"x1", "x2", "x3"}, typed)
+
+	_, err = run(t, "type_text(true)", builtins)
+	assert.EqualError(err, "line 1: type_text: argument #1: string expected, got boolean")
+
+	_, err = run(t, "\nfail()", builtins)
+	assert.EqualError(err, "line 2: fail: failed")
+}
+
+func TestCancel(t *testing.T) {
+	p, err := Compile("while true do sleep() end")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = p.Run(ctx, &RunOptions{Builtins: map[string]Func{
+		"sleep": func(ctx context.Context, _ []Value) (Value, error) {
+			select {
+			case <-time.After(10 * time.Millisecond):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			return nil, nil
+		},
+	}})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestStore(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewStore(&StoreOptions{Dir: t.TempDir(), MaxVersions: 2})
+
+	list, err := s.List()
+	assert.NoError(err)
+	assert.Empty(list)
+
+	_, err = s.Save("", "broken", "", "if")
+	assert.Error(err)
+
+	script, err := s.Save("", "boot", "", "sleep(1)")
+	assert.NoError(err)
+	assert.Len(script.Versions, 1)
+
+	// unchanged source doesn't add a version
+	script, err = s.Save(script.ID, "boot", "enter setup", "sleep(1)")
+	assert.NoError(err)
+	assert.Len(script.Versions, 1)
+	assert.Equal("enter setup", script.Description)
+
+	_, err = s.Save(script.ID, "boot", "", "sleep(2)")
+	assert.NoError(err)
+	script, err = s.Save(script.ID, "boot", "", "sleep(3)")
+	assert.NoError(err)
+	assert.Len(script.Versions, 2)
+	assert.Equal(3, script.Latest().Version)
+
+	_, v, err := s.GetVersion(script.ID, 2)
+	assert.NoError(err)
+	assert.Equal("sleep(2)", v.Source)
+	_, _, err = s.GetVersion(script.ID, 1)
+	assert.ErrorIs(err, ErrNotFound)
+
+	list, err = s.List()
+	assert.NoError(err)
+	assert.Equal([]Summary{{ID: script.ID, Name: "boot", Version: 3, UpdatedAt: script.UpdatedAt}}, list)
+
+	_, err = s.Get("../../etc/passwd")
+	assert.Error(err)
+
+	assert.NoError(s.Delete(script.ID))
+	assert.ErrorIs(s.Delete(script.ID), ErrNotFound)
+}
@@ -0,0 +1,138 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// stdlib are the functions available to every script.
+var stdlib = map[string]Func{
+	"tostring": func(_ context.Context, args []Value) (Value, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("argument #1: value expected")
+		}
+		return ToString(args[0]), nil
+	},
+	"tonumber": func(_ context.Context, args []Value) (Value, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("argument #1: value expected")
+		}
+		switch v := args[0].(type) {
+		case float64:
+			return v, nil
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, nil
+			}
+			return n, nil
+		}
+		return nil, nil
+	},
+	"floor": func(_ context.Context, args []Value) (Value, error) {
+		n, err := NumberArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return math.Floor(n), nil
+	},
+	"contains": func(_ context.Context, args []Value) (Value, error) {
+		s, err := StringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		substr, err := StringArg(args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return strings.Contains(s, substr), nil
+	},
+	"lower": func(_ context.Context, args []Value) (Value, error) {
+		s, err := StringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return strings.ToLower(s), nil
+	},
+	"upper": func(_ context.Context, args []Value) (Value, error) {
+		s, err := StringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return strings.ToUpper(s), nil
+	},
+	"trim": func(_ context.Context, args []Value) (Value, error) {
+		s, err := StringArg(args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return strings.TrimSpace(s), nil
+	},
+	"error": func(_ context.Context, args []Value) (Value, error) {
+		msg := "error"
+		if len(args) > 0 {
+			msg = ToString(args[0])
+		}
+		return nil, fmt.Errorf("%s", msg)
+	},
+}
+
+func argError(i int, expected string, args []Value) error {
+	got := "no value"
+	if i < len(args) {
+		got = TypeName(args[i])
+	}
+	return fmt.Errorf("argument #%d: %s expected, got %s", i+1, expected, got)
+}
+
+// StringArg returns argument i as a string.
+func StringArg(args []Value, i int) (string, error) {
+	if i < len(args) {
+		switch v := args[i].(type) {
+		case string:
+			return v, nil
+		case float64:
+			return formatNumber(v), nil
+		}
+	}
+	return "", argError(i, "string", args)
+}
+
+// NumberArg returns argument i as a number.
+func NumberArg(args []Value, i int) (float64, error) {
+	if i < len(args) {
+		if v, ok := args[i].(float64); ok {
+			return v, nil
+		}
+	}
+	return 0, argError(i, "number", args)
+}
+
+// BoolArg returns argument i as a boolean.
+func BoolArg(args []Value, i int) (bool, error) {
+	if i < len(args) {
+		if v, ok := args[i].(bool); ok {
+			return v, nil
+		}
+	}
+	return false, argError(i, "boolean", args)
+}
+
+// OptStringArg returns argument i as a string, or def if it's missing or nil.
+func OptStringArg(args []Value, i int, def string) (string, error) {
+	if i >= len(args) || args[i] == nil {
+		return def, nil
+	}
+	return StringArg(args, i)
+}
+
+// OptNumberArg returns argument i as a number, or def if it's missing or nil.
+func OptNumberArg(args []Value, i int, def float64) (float64, error) {
+	if i >= len(args) || args[i] == nil {
+		return def, nil
+	}
+	return NumberArg(args, i)
+}
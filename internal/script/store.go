@@ -0,0 +1,279 @@
+package script
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxVersions is the number of versions kept for each script.
+const DefaultMaxVersions = 20
+
+// ErrNotFound is returned when a script or version doesn't exist.
+var ErrNotFound = errors.New("script not found")
+
+var idPattern = regexp.MustCompile(`^[a-f0-9]{16}$`)
+
+// Version is a saved revision of a script.
+type Version struct {
+	Version   int       `json:"version"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Script is a stored script with its versions, oldest first.
+type Script struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Versions    []Version `json:"versions"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// Latest returns the most recent version of the script.
+func (s *Script) Latest() Version {
+	return s.Versions[len(s.Versions)-1]
+}
+
+// Summary is a script without its sources.
+type Summary struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Version     int       `json:"version"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// StoreOptions are the options for a new Store.
+type StoreOptions struct {
+	Dir         string
+	MaxVersions int
+}
+
+// Store keeps scripts as JSON files in a directory, one file per script.
+type Store struct {
+	dir         string
+	maxVersions int
+	lock        sync.Mutex
+}
+
+// NewStore creates a new Store, the directory is created on the first save.
+func NewStore(opts *StoreOptions) *Store {
+	if opts.MaxVersions <= 0 {
+		opts.MaxVersions = DefaultMaxVersions
+	}
+
+	return &Store{
+		dir:         opts.Dir,
+		maxVersions: opts.MaxVersions,
+	}
+}
+
+func (s *Store) path(id string) (string, error) {
+	if !idPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid script id: %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+func (s *Store) read(id string) (*Script, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read script: %w", err)
+	}
+
+	var script Script
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, fmt.Errorf("failed to parse script %s: %w", id, err)
+	}
+	if len(script.Versions) == 0 {
+		return nil, fmt.Errorf("script %s has no versions", id)
+	}
+	return &script, nil
+}
+
+func (s *Store) write(script *Script) error {
+	path, err := s.path(script.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create script directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(script, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal script: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write script: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write script: %w", err)
+	}
+	return nil
+}
+
+// List returns all scripts, sorted by name.
+func (s *Store) List() ([]Summary, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Summary{}, nil
+		}
+		return nil, fmt.Errorf("failed to read script directory: %w", err)
+	}
+
+	summaries := make([]Summary, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !idPattern.MatchString(id) {
+			continue
+		}
+
+		script, err := s.read(id)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, Summary{
+			ID:          script.ID,
+			Name:        script.Name,
+			Description: script.Description,
+			Version:     script.Latest().Version,
+			UpdatedAt:   script.UpdatedAt,
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+	return summaries, nil
+}
+
+// Get returns a script with all its versions.
+func (s *Store) Get(id string) (*Script, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.read(id)
+}
+
+// GetVersion returns a version of a script, 0 for the latest one.
+func (s *Store) GetVersion(id string, version int) (*Script, Version, error) {
+	script, err := s.Get(id)
+	if err != nil {
+		return nil, Version{}, err
+	}
+
+	if version == 0 {
+		return script, script.Latest(), nil
+	}
+	for _, v := range script.Versions {
+		if v.Version == version {
+			return script, v, nil
+		}
+	}
+	return nil, Version{}, fmt.Errorf("version %d of script %s: %w", version, id, ErrNotFound)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate script id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Save creates a script if id is empty, otherwise it updates it. A new version
+// is added when the source changed. The source must compile.
+func (s *Store) Save(id string, name string, description string, source string) (*Script, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("script name is required")
+	}
+	if _, err := Compile(source); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now().UTC()
+
+	var script *Script
+	if id == "" {
+		newID, err := newID()
+		if err != nil {
+			return nil, err
+		}
+		script = &Script{ID: newID}
+	} else {
+		var err error
+		if script, err = s.read(id); err != nil {
+			return nil, err
+		}
+	}
+
+	script.Name = name
+	script.Description = description
+	script.UpdatedAt = now
+
+	if len(script.Versions) == 0 || script.Latest().Source != source {
+		next := 1
+		if len(script.Versions) > 0 {
+			next = script.Latest().Version + 1
+		}
+		script.Versions = append(script.Versions, Version{Version: next, Source: source, CreatedAt: now})
+
+		if len(script.Versions) > s.maxVersions {
+			script.Versions = script.Versions[len(script.Versions)-s.maxVersions:]
+		}
+	}
+
+	if err := s.write(script); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Delete removes a script with all its versions.
+func (s *Store) Delete(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete script: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+// Package serialconsole shares a serial console between several clients.
+//
+// The output of the console is kept in a scrollback buffer that is replayed to
+// every client that attaches, and optionally logged to disk with timestamps.
+// All clients see the output, but only one of them can write at a time: the
+// first to send input holds the writer lock until it disconnects or stays idle
+// for longer than the writer idle timeout.
+package serialconsole
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultScrollbackSize is the size of the scrollback buffer in bytes.
+	DefaultScrollbackSize = 64 * 1024
+	// DefaultWriterIdleTimeout is how long the writer lock is kept without input.
+	DefaultWriterIdleTimeout = 30 * time.Second
+
+	// clientQueueSize is the number of chunks buffered for a slow client
+	clientQueueSize = 256
+	// writeLockedNoticeInterval is how often a client is told that its input is ignored
+	writeLockedNoticeInterval = 10 * time.Second
+)
+
+var (
+	defaultLogger = logging.GetSubsystemLogger("serialconsole")
+
+	// ErrWriteLocked is returned when another client holds the writer lock.
+	ErrWriteLocked = errors.New("serial console is used by another client")
+	// ErrReadOnly is returned when the console has no input.
+	ErrReadOnly = errors.New("serial console is read-only")
+	// ErrClosed is returned when writing to a detached client.
+	ErrClosed = errors.New("serial console client is closed")
+)
+
+// Options are the options for a new Console.
+type Options struct {
+	ScrollbackSize    int
+	WriterIdleTimeout time.Duration
+	Logger            *zerolog.Logger
+}
+
+// Console is a serial console shared by several clients.
+type Console struct {
+	writerIdleTimeout time.Duration
+	l                 *zerolog.Logger
+
+	lock       sync.Mutex
+	scrollback *ring
+	log        *diskLog
+	input      func([]byte) error
+	clients    map[int]*Client
+	nextID     int
+	writer     *Client
+}
+
+// ClientInfo describes an attached client.
+type ClientInfo struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	ConnectedAt time.Time `json:"connectedAt"`
+	Writer      bool      `json:"writer"`
+}
+
+// Client is attached to a console, it receives the output and can send input.
+type Client struct {
+	c           *Console
+	id          int
+	name        string
+	connectedAt time.Time
+
+	queue chan []byte
+	done  chan struct{}
+
+	// guarded by the console lock
+	closed     bool
+	lastWrite  time.Time
+	lastNotice time.Time
+}
+
+// NewConsole creates a new Console.
+func NewConsole(opts *Options) *Console {
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+	if opts.ScrollbackSize <= 0 {
+		opts.ScrollbackSize = DefaultScrollbackSize
+	}
+	if opts.WriterIdleTimeout <= 0 {
+		opts.WriterIdleTimeout = DefaultWriterIdleTimeout
+	}
+
+	return &Console{
+		writerIdleTimeout: opts.WriterIdleTimeout,
+		l:                 opts.Logger,
+		scrollback:        newRing(opts.ScrollbackSize),
+		clients:           make(map[int]*Client),
+	}
+}
+
+// SetInput sets where the input of the clients is written to, nil makes the
+// console read-only.
+func (c *Console) SetInput(fn func([]byte) error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.input = fn
+}
+
+// SetScrollbackSize resizes the scrollback buffer, keeping the most recent output.
+func (c *Console) SetScrollbackSize(size int) {
+	if size <= 0 {
+		size = DefaultScrollbackSize
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if size == c.scrollback.size() {
+		return
+	}
+	r := newRing(size)
+	r.Write(c.scrollback.Bytes())
+	c.scrollback = r
+}
+
+// SetLog starts logging the output to path, rotating the file when it
+// exceeds maxSize. An empty path stops logging.
+func (c *Console) SetLog(path string, maxSize int64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.log != nil {
+		if c.log.path == path {
+			c.log.maxSize = maxSize
+			return nil
+		}
+		if err := c.log.Close(); err != nil {
+			c.l.Warn().Err(err).Str("path", c.log.path).Msg("failed to close serial console log")
+		}
+		c.log = nil
+	}
+	if path == "" {
+		return nil
+	}
+
+	log, err := openDiskLog(path, maxSize)
+	if err != nil {
+		return err
+	}
+	c.log = log
+	return nil
+}
+
+// Write adds output of the serial port to the console.
+func (c *Console) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.scrollback.Write(p)
+	if c.log != nil {
+		if err := c.log.Write(time.Now(), p); err != nil {
+			c.l.Warn().Err(err).Str("path", c.log.path).Msg("failed to write serial console log, logging stopped")
+			_ = c.log.Close()
+			c.log = nil
+		}
+	}
+
+	for _, client := range c.clients {
+		client.send(append([]byte{}, p...))
+	}
+	return len(p), nil
+}
+
+// Scrollback returns the buffered output.
+func (c *Console) Scrollback() []byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.scrollback.Bytes()
+}
+
+// ClearScrollback empties the scrollback buffer.
+func (c *Console) ClearScrollback() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.scrollback.Reset()
+}
+
+// Clients returns the attached clients.
+func (c *Console) Clients() []ClientInfo {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	infos := make([]ClientInfo, 0, len(c.clients))
+	for _, client := range c.clients {
+		infos = append(infos, ClientInfo{
+			ID:          client.id,
+			Name:        client.name,
+			ConnectedAt: client.connectedAt,
+			Writer:      c.writer == client,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// Attach adds a client, out is called with the scrollback first and then with
+// the output as it arrives. Calls to out are made from a single goroutine.
+func (c *Console) Attach(name string, out func([]byte)) *Client {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	client := &Client{
+		c:           c,
+		id:          c.nextID,
+		name:        name,
+		connectedAt: time.Now(),
+		queue:       make(chan []byte, clientQueueSize),
+		done:        make(chan struct{}),
+	}
+	c.nextID++
+	c.clients[client.id] = client
+
+	if scrollback := c.scrollback.Bytes(); len(scrollback) > 0 {
+		client.queue <- scrollback
+	}
+	go client.run(out)
+
+	c.l.Info().Int("id", client.id).Str("name", name).Msg("serial console client attached")
+	return client
+}
+
+// Close detaches all clients and stops logging.
+func (c *Console) Close() error {
+	c.lock.Lock()
+	clients := make([]*Client, 0, len(c.clients))
+	for _, client := range c.clients {
+		clients = append(clients, client)
+	}
+	c.lock.Unlock()
+
+	for _, client := range clients {
+		client.Close()
+	}
+	return c.SetLog("", 0)
+}
+
+func (cl *Client) run(out func([]byte)) {
+	for {
+		select {
+		case data := <-cl.queue:
+			out(data)
+		case <-cl.done:
+			return
+		}
+	}
+}
+
+// send queues data for the client, it's called with the console lock held.
+func (cl *Client) send(data []byte) {
+	select {
+	case cl.queue <- data:
+	default:
+		cl.c.l.Warn().Int("id", cl.id).Str("name", cl.name).Int("bytes", len(data)).
+			Msg("serial console client is too slow, dropping output")
+	}
+}
+
+// Write sends input to the serial port. The client takes the writer lock if
+// it's free, otherwise it's told who holds it and ErrWriteLocked is returned.
+func (cl *Client) Write(p []byte) (int, error) {
+	c := cl.c
+	c.lock.Lock()
+
+	if cl.closed {
+		c.lock.Unlock()
+		return 0, ErrClosed
+	}
+	if c.input == nil {
+		c.lock.Unlock()
+		return 0, ErrReadOnly
+	}
+
+	now := time.Now()
+	if c.writer != nil && c.writer != cl && now.Sub(c.writer.lastWrite) < c.writerIdleTimeout {
+		if now.Sub(cl.lastNotice) >= writeLockedNoticeInterval {
+			cl.lastNotice = now
+			cl.send(fmt.Appendf(nil, "\r\n[serial console is used by %s, input ignored]\r\n", c.writer.name))
+		}
+		c.lock.Unlock()
+		return 0, ErrWriteLocked
+	}
+	if c.writer != cl {
+		c.l.Info().Int("id", cl.id).Str("name", cl.name).Msg("serial console writer lock taken")
+	}
+	c.writer = cl
+	cl.lastWrite = now
+	input := c.input
+	c.lock.Unlock()
+
+	// the port is written without the lock so slow writes don't hold up the output
+	if err := input(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Name returns the name the client was attached with.
+func (cl *Client) Name() string {
+	return cl.name
+}
+
+// Close detaches the client and releases the writer lock.
+func (cl *Client) Close() {
+	c := cl.c
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if cl.closed {
+		return
+	}
+	cl.closed = true
+	close(cl.done)
+	delete(c.clients, cl.id)
+	if c.writer == cl {
+		c.writer = nil
+	}
+
+	c.l.Info().Int("id", cl.id).Str("name", cl.name).Msg("serial console client detached")
+}
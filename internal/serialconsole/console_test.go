@@ -0,0 +1,264 @@
+package serialconsole
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestRing(t *testing.T) {
+	r := newRing(8)
+	assert.Empty(t, r.Bytes())
+
+	r.Write([]byte("abc"))
+	assert.Equal(t, "abc", string(r.Bytes()))
+
+	r.Write([]byte("defgh"))
+	assert.Equal(t, "abcdefgh", string(r.Bytes()))
+
+	r.Write([]byte("ij"))
+	assert.Equal(t, "cdefghij", string(r.Bytes()))
+
+	r.Write([]byte("0123456789"))
+	assert.Equal(t, "23456789", string(r.Bytes()))
+
+	r.Reset()
+	assert.Empty(t, r.Bytes())
+}
+
+// collector gathers the output sent to a client.
+type collector struct {
+	lock sync.Mutex
+	data []byte
+}
+
+func (c *collector) out(p []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.data = append(c.data, p...)
+}
+
+func (c *collector) String() string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return string(c.data)
+}
+
+func TestConsole(t *testing.T) {
+	c := NewConsole(&Options{ScrollbackSize: 16, WriterIdleTimeout: 100 * time.Millisecond})
+
+	var input []string
+	c.SetInput(func(p []byte) error {
+		input = append(input, string(p))
+		return nil
+	})
+
+	_, _ = c.Write([]byte("booting\r\n"))
+
+	var a, b collector
+	clientA := c.Attach("a", a.out)
+	clientB := c.Attach("b", b.out)
+	_, _ = c.Write([]byte("login: "))
+
+	// the scrollback is replayed before new output
+	assert.Eventually(t, func() bool { return a.String() == "booting\r\nlogin: " }, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return b.String() == "booting\r\nlogin: " }, time.Second, 10*time.Millisecond)
+
+	// a takes the writer lock, b is told it's in use
+	_, err := clientA.Write([]byte("root\r"))
+	require.NoError(t, err)
+	_, err = clientB.Write([]byte("x"))
+	assert.ErrorIs(t, err, ErrWriteLocked)
+	assert.Eventually(t, func() bool { return strings.Contains(b.String(), "used by a") }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"root\r"}, input)
+
+	clients := c.Clients()
+	require.Len(t, clients, 2)
+	assert.True(t, clients[0].Writer)
+	assert.False(t, clients[1].Writer)
+
+	// the lock is released when the writer is idle
+	time.Sleep(150 * time.Millisecond)
+	_, err = clientB.Write([]byte("y"))
+	require.NoError(t, err)
+
+	// and when it detaches
+	clientB.Close()
+	_, err = clientA.Write([]byte("z"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"root\r", "y", "z"}, input)
+
+	_, err = clientB.Write([]byte("closed"))
+	assert.ErrorIs(t, err, ErrClosed)
+
+	c.SetInput(nil)
+	_, err = clientA.Write([]byte("read-only"))
+	assert.ErrorIs(t, err, ErrReadOnly)
+
+	_, _ = c.Write([]byte("root"))
+	assert.Equal(t, "ing\r\nlogin: root", string(c.Scrollback()))
+	c.SetScrollbackSize(6)
+	assert.Equal(t, ": root", string(c.Scrollback()))
+	c.ClearScrollback()
+	assert.Empty(t, c.Scrollback())
+}
+
+func TestDiskLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "serial", "console.log")
+	l, err := openDiskLog(path, 60)
+	require.NoError(t, err)
+
+	t1 := time.Date(2025, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	t2 := t1.Add(time.Second)
+	require.NoError(t, l.Write(t1, []byte("first\r\nsec")))
+	require.NoError(t, l.Write(t2, []byte("ond\r\n")))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-02T03:04:05.006Z first\n2025-01-02T03:04:05.006Z second\n", string(data))
+
+	// the file is rotated at the next line once it's too large
+	require.NoError(t, l.Write(t2, []byte("third\n")))
+	require.NoError(t, l.Close())
+
+	rotated, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(rotated))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-02T03:04:06.006Z third\n", string(data))
+}
+
+func TestTelnetDecoder(t *testing.T) {
+	d := &telnetDecoder{}
+	assert.Equal(t, "ls\r", string(d.decode([]byte("ls\r\n"))))
+	assert.Equal(t, "a\rb", string(d.decode([]byte("a\r\x00b"))))
+	// negotiation and subnegotiation are dropped
+	assert.Equal(t, "xy", string(d.decode([]byte{'x', telnetIAC, telnetDO, telnetOptionEcho, telnetIAC, telnetSB, 24, 0, 'v', 't', telnetIAC, telnetSE, 'y'})))
+	// an escaped IAC is data
+	assert.Equal(t, []byte{telnetIAC}, d.decode([]byte{telnetIAC, telnetIAC}))
+	// sequences can span reads
+	assert.Equal(t, "", string(d.decode([]byte{'\r'}))[1:])
+	assert.Equal(t, "", string(d.decode([]byte{'\n'})))
+
+	assert.Equal(t, []byte{'a', telnetIAC, telnetIAC}, telnetEncode([]byte{'a', telnetIAC}))
+}
+
+func TestTelnet(t *testing.T) {
+	c := NewConsole(&Options{})
+	var input collector
+	c.SetInput(func(p []byte) error {
+		input.out(p)
+		return nil
+	})
+	_, _ = c.Write([]byte("hello"))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		_ = c.ServeTelnet(l, func(_, password string) bool { return password == "secret" })
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	read := func(want string) {
+		var got []byte
+		buf := make([]byte, 256)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for !strings.Contains(string(got), want) {
+			n, err := conn.Read(buf)
+			require.NoError(t, err, "got %q", got)
+			got = append(got, buf[:n]...)
+		}
+	}
+
+	read("Password: ")
+	_, _ = conn.Write([]byte("wrong\r\n"))
+	read("Login incorrect")
+	_, _ = conn.Write([]byte("secret\r\n"))
+	read("hello")
+
+	_, _ = conn.Write([]byte("ls\r\n"))
+	assert.Eventually(t, func() bool { return input.String() == "ls\r" }, time.Second, 10*time.Millisecond)
+}
+
+func TestSSH(t *testing.T) {
+	hostKeyPath := filepath.Join(t.TempDir(), "ssh_host_key")
+	hostKey, err := LoadOrCreateHostKey(hostKeyPath)
+	require.NoError(t, err)
+	loaded, err := LoadOrCreateHostKey(hostKeyPath)
+	require.NoError(t, err)
+	assert.Equal(t, hostKey.PublicKey().Marshal(), loaded.PublicKey().Marshal())
+
+	c := NewConsole(&Options{})
+	var input collector
+	c.SetInput(func(p []byte) error {
+		input.out(p)
+		return nil
+	})
+	_, _ = c.Write([]byte("hello"))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		_ = c.ServeSSH(l, hostKey, func(user, password string) bool { return user == "admin" && password == "secret" })
+	}()
+
+	dial := func(password string) (*ssh.Client, error) {
+		return ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+			User:            "admin",
+			Auth:            []ssh.AuthMethod{ssh.Password(password)},
+			HostKeyCallback: ssh.FixedHostKey(hostKey.PublicKey()),
+			Timeout:         time.Second,
+		})
+	}
+
+	_, err = dial("wrong")
+	require.Error(t, err)
+
+	client, err := dial("secret")
+	require.NoError(t, err)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	require.NoError(t, err)
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	require.NoError(t, err)
+	stdoutPipe, err := session.StdoutPipe()
+	require.NoError(t, err)
+	var stdout collector
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := stdoutPipe.Read(buf)
+			if err != nil {
+				return
+			}
+			stdout.out(buf[:n])
+		}
+	}()
+	require.NoError(t, session.RequestSubsystem(SSHSubsystem))
+
+	assert.Eventually(t, func() bool { return stdout.String() == "hello" }, time.Second, 10*time.Millisecond)
+	_, _ = c.Write([]byte(" world"))
+	assert.Eventually(t, func() bool { return stdout.String() == "hello world" }, time.Second, 10*time.Millisecond)
+
+	_, err = stdin.Write([]byte("ls\r"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return input.String() == "ls\r" }, time.Second, 10*time.Millisecond)
+	assert.Eventually(t, func() bool { return len(c.Clients()) == 1 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,116 @@
+package serialconsole
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultLogMaxSize is the size at which the log file is rotated.
+const DefaultLogMaxSize = 1024 * 1024
+
+const logTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// diskLog writes the console output to a file, each line prefixed with the
+// time it started. When the file exceeds maxSize it's renamed to path.1,
+// replacing the previous one, so at most twice maxSize is used.
+type diskLog struct {
+	path    string
+	maxSize int64
+
+	f           *os.File
+	size        int64
+	atLineStart bool
+}
+
+func openDiskLog(path string, maxSize int64) (*diskLog, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultLogMaxSize
+	}
+	l := &diskLog{path: path, maxSize: maxSize, atLineStart: true}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *diskLog) open() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	l.f = f
+	l.size = info.Size()
+	return nil
+}
+
+func (l *diskLog) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return l.open()
+}
+
+// Write logs p, received at t. Carriage returns are dropped so the log reads
+// well with line based tools.
+func (l *diskLog) Write(t time.Time, p []byte) error {
+	var buf bytes.Buffer
+	for len(p) > 0 {
+		if l.atLineStart {
+			if l.size+int64(buf.Len()) >= l.maxSize {
+				if err := l.flush(&buf); err != nil {
+					return err
+				}
+				if err := l.rotate(); err != nil {
+					return err
+				}
+			}
+			buf.WriteString(t.Format(logTimeFormat))
+			buf.WriteByte(' ')
+			l.atLineStart = false
+		}
+
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line = p[:i+1]
+			l.atLineStart = true
+		}
+		for _, b := range line {
+			if b != '\r' {
+				buf.WriteByte(b)
+			}
+		}
+		p = p[len(line):]
+	}
+	return l.flush(&buf)
+}
+
+func (l *diskLog) flush(buf *bytes.Buffer) error {
+	if buf.Len() == 0 {
+		return nil
+	}
+	n, err := l.f.Write(buf.Bytes())
+	l.size += int64(n)
+	buf.Reset()
+	if err != nil {
+		return fmt.Errorf("failed to write log file: %w", err)
+	}
+	return nil
+}
+
+func (l *diskLog) Close() error {
+	return l.f.Close()
+}
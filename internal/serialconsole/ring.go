@@ -0,0 +1,50 @@
+package serialconsole
+
+// ring is a fixed size buffer that keeps the most recent bytes written to it.
+type ring struct {
+	buf  []byte
+	pos  int
+	full bool
+}
+
+func newRing(size int) *ring {
+	return &ring{buf: make([]byte, size)}
+}
+
+func (r *ring) size() int {
+	return len(r.buf)
+}
+
+func (r *ring) Write(p []byte) {
+	if len(p) >= len(r.buf) {
+		copy(r.buf, p[len(p)-len(r.buf):])
+		r.pos = 0
+		r.full = true
+		return
+	}
+
+	n := copy(r.buf[r.pos:], p)
+	if n < len(p) {
+		copy(r.buf, p[n:])
+		r.full = true
+	}
+	r.pos = (r.pos + len(p)) % len(r.buf)
+	if r.pos == 0 {
+		r.full = true
+	}
+}
+
+// Bytes returns a copy of the buffered bytes, oldest first.
+func (r *ring) Bytes() []byte {
+	if !r.full {
+		return append([]byte{}, r.buf[:r.pos]...)
+	}
+	out := make([]byte, 0, len(r.buf))
+	out = append(out, r.buf[r.pos:]...)
+	return append(out, r.buf[:r.pos]...)
+}
+
+func (r *ring) Reset() {
+	r.pos = 0
+	r.full = false
+}
@@ -0,0 +1,152 @@
+package serialconsole
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHSubsystem is the subsystem name clients can request instead of a shell,
+// e.g. ssh -s host serial.
+const SSHSubsystem = "serial"
+
+// LoadOrCreateHostKey loads the SSH host key from path, generating an ed25519
+// key if it doesn't exist yet.
+func LoadOrCreateHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host key: %w", err)
+		}
+		return signer, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read host key: %w", err)
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate host key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal host key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create host key directory: %w", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save host key: %w", err)
+	}
+	return ssh.NewSignerFromKey(key)
+}
+
+// ServeSSH accepts SSH connections on l and attaches their sessions to the
+// console until l is closed. Sessions can request a shell or the serial
+// subsystem, both connect to the console. If password is nil, no
+// authentication is required.
+func (c *Console) ServeSSH(l net.Listener, hostKey ssh.Signer, password PasswordFunc) error {
+	config := &ssh.ServerConfig{NoClientAuth: password == nil}
+	if password != nil {
+		config.PasswordCallback = func(meta ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if password(meta.User(), string(pass)) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", meta.User())
+		}
+	}
+	config.AddHostKey(hostKey)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go c.handleSSH(conn, config)
+	}
+}
+
+func (c *Console) handleSSH(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	l := c.l.With().Str("remote", conn.RemoteAddr().String()).Logger()
+
+	sconn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		l.Warn().Err(err).Msg("ssh handshake failed")
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			l.Warn().Err(err).Msg("failed to accept ssh channel")
+			continue
+		}
+		go c.handleSSHSession(channel, requests, fmt.Sprintf("ssh %s@%s", sconn.User(), sconn.RemoteAddr()))
+	}
+}
+
+func (c *Console) handleSSHSession(channel ssh.Channel, requests <-chan *ssh.Request, name string) {
+	defer channel.Close()
+
+	attached := false
+	for req := range requests {
+		ok := false
+		switch req.Type {
+		case "pty-req", "env", "window-change":
+			// the terminal is the host's, there's nothing to set up
+			ok = true
+		case "shell":
+			ok = !attached
+		case "subsystem":
+			var payload struct{ Name string }
+			ok = !attached && ssh.Unmarshal(req.Payload, &payload) == nil && payload.Name == SSHSubsystem
+		}
+		if req.WantReply {
+			_ = req.Reply(ok, nil)
+		}
+
+		if ok && (req.Type == "shell" || req.Type == "subsystem") {
+			attached = true
+			go func() {
+				c.bridgeSSH(channel, name)
+				_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				channel.Close()
+			}()
+		}
+	}
+}
+
+func (c *Console) bridgeSSH(channel ssh.Channel, name string) {
+	client := c.Attach(name, func(p []byte) {
+		if _, err := channel.Write(p); err != nil {
+			channel.Close()
+		}
+	})
+	defer client.Close()
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := channel.Read(buf)
+		if err != nil {
+			return
+		}
+		if _, err := client.Write(buf[:n]); err != nil && err != ErrWriteLocked {
+			fmt.Fprintf(channel, "\r\n[%s]\r\n", err)
+		}
+	}
+}
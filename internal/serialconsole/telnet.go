@@ -0,0 +1,210 @@
+package serialconsole
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// telnet commands and options, RFC 854 and RFC 857/858
+const (
+	telnetIAC  = 255
+	telnetDONT = 254
+	telnetDO   = 253
+	telnetWONT = 252
+	telnetWILL = 251
+	telnetSB   = 250
+	telnetSE   = 240
+
+	telnetOptionEcho = 1
+	telnetOptionSGA  = 3
+)
+
+const (
+	telnetLoginAttempts = 3
+	telnetLoginTimeout  = time.Minute
+)
+
+// PasswordFunc checks a password for a user, the user is empty for telnet.
+type PasswordFunc func(user, password string) bool
+
+type telnetState int
+
+const (
+	telnetStateData telnetState = iota
+	telnetStateIAC
+	telnetStateOption
+	telnetStateSB
+	telnetStateSBIAC
+	telnetStateCR
+)
+
+// telnetDecoder strips the telnet protocol from the input of a client. A
+// carriage return followed by a line feed or NUL, which is how telnet clients
+// send the enter key, is passed on as a single carriage return.
+type telnetDecoder struct {
+	state telnetState
+}
+
+func (d *telnetDecoder) decode(in []byte) []byte {
+	out := make([]byte, 0, len(in))
+	for _, b := range in {
+		switch d.state {
+		case telnetStateCR:
+			d.state = telnetStateData
+			if b == '\n' || b == 0 {
+				continue
+			}
+			fallthrough
+		case telnetStateData:
+			switch b {
+			case telnetIAC:
+				d.state = telnetStateIAC
+			case '\r':
+				out = append(out, b)
+				d.state = telnetStateCR
+			default:
+				out = append(out, b)
+			}
+		case telnetStateIAC:
+			switch b {
+			case telnetIAC:
+				out = append(out, b)
+				d.state = telnetStateData
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				d.state = telnetStateOption
+			case telnetSB:
+				d.state = telnetStateSB
+			default:
+				d.state = telnetStateData
+			}
+		case telnetStateOption:
+			// the negotiation is fixed, the client's answers are ignored
+			d.state = telnetStateData
+		case telnetStateSB:
+			if b == telnetIAC {
+				d.state = telnetStateSBIAC
+			}
+		case telnetStateSBIAC:
+			if b == telnetSE {
+				d.state = telnetStateData
+			} else {
+				d.state = telnetStateSB
+			}
+		}
+	}
+	return out
+}
+
+// telnetEncode escapes IAC in the output.
+func telnetEncode(p []byte) []byte {
+	if bytes.IndexByte(p, telnetIAC) < 0 {
+		return p
+	}
+	return bytes.ReplaceAll(p, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})
+}
+
+// ServeTelnet accepts telnet connections on l and attaches them to the
+// console until l is closed. If password isn't nil, clients have to log in.
+func (c *Console) ServeTelnet(l net.Listener, password PasswordFunc) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go c.handleTelnet(conn, password)
+	}
+}
+
+func (c *Console) handleTelnet(conn net.Conn, password PasswordFunc) {
+	defer conn.Close()
+	remote := conn.RemoteAddr().String()
+	l := c.l.With().Str("remote", remote).Logger()
+
+	// the host echoes and the client sends every character as it's typed
+	if _, err := conn.Write([]byte{
+		telnetIAC, telnetWILL, telnetOptionEcho,
+		telnetIAC, telnetWILL, telnetOptionSGA,
+		telnetIAC, telnetDO, telnetOptionSGA,
+	}); err != nil {
+		return
+	}
+
+	decoder := &telnetDecoder{}
+	if password != nil {
+		_ = conn.SetReadDeadline(time.Now().Add(telnetLoginTimeout))
+		if !telnetLogin(conn, decoder, password) {
+			l.Warn().Msg("telnet login failed")
+			return
+		}
+		_ = conn.SetReadDeadline(time.Time{})
+	}
+
+	client := c.Attach("telnet "+remote, func(p []byte) {
+		if _, err := conn.Write(telnetEncode(p)); err != nil {
+			conn.Close()
+		}
+	})
+	defer client.Close()
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if err != io.EOF {
+				l.Debug().Err(err).Msg("telnet connection closed")
+			}
+			return
+		}
+		if input := decoder.decode(buf[:n]); len(input) > 0 {
+			if _, err := client.Write(input); err != nil && err != ErrWriteLocked {
+				fmt.Fprintf(conn, "\r\n[%s]\r\n", err)
+			}
+		}
+	}
+}
+
+// telnetLogin asks for the password, echoing asterisks.
+func telnetLogin(conn net.Conn, decoder *telnetDecoder, password PasswordFunc) bool {
+	buf := make([]byte, 64)
+	for attempt := 0; attempt < telnetLoginAttempts; attempt++ {
+		if _, err := conn.Write([]byte("Password: ")); err != nil {
+			return false
+		}
+
+		var line []byte
+	read:
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return false
+			}
+			for _, b := range decoder.decode(buf[:n]) {
+				switch b {
+				case '\r', '\n':
+					break read
+				case 0x7f, '\b':
+					if len(line) > 0 {
+						line = line[:len(line)-1]
+						_, _ = conn.Write([]byte("\b \b"))
+					}
+				default:
+					if len(line) < 256 {
+						line = append(line, b)
+						_, _ = conn.Write([]byte("*"))
+					}
+				}
+			}
+		}
+
+		if password("", string(line)) {
+			_, _ = conn.Write([]byte("\r\n"))
+			return true
+		}
+		_, _ = conn.Write([]byte("\r\nLogin incorrect\r\n"))
+		time.Sleep(time.Second)
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+// Package turnserver is an embedded TURN server for relaying WebRTC sessions
+// on the site network. Clients authenticate with ephemeral TURN REST
+// credentials derived from a shared secret.
+package turnserver
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/pion/turn/v4"
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultPort          = 3478
+	DefaultRealm         = "jetkvm"
+	DefaultRelayPortMin  = 49152
+	DefaultRelayPortMax  = 49407
+	DefaultCredentialTTL = 12 * time.Hour
+)
+
+var defaultLogger = logging.GetSubsystemLogger("turn")
+
+type Options struct {
+	// ListenAddr is the UDP address to listen on, :3478 without it.
+	ListenAddr string
+	Realm      string
+	// Secret is the TURN REST shared secret.
+	Secret string
+	// RelayAddress is the address of the relayed candidates.
+	RelayAddress net.IP
+	RelayPortMin uint16
+	RelayPortMax uint16
+	// AllowPeer limits the peers clients may relay to, e.g. to the device
+	// itself. All peers are allowed without it.
+	AllowPeer func(ip net.IP) bool
+	Logger    *zerolog.Logger
+}
+
+type Server struct {
+	server *turn.Server
+	conn   net.PacketConn
+	opts   Options
+}
+
+func NewServer(opts Options) (*Server, error) {
+	if opts.Secret == "" {
+		return nil, fmt.Errorf("secret is required")
+	}
+	if opts.RelayAddress == nil {
+		return nil, fmt.Errorf("relay address is required")
+	}
+	if opts.ListenAddr == "" {
+		opts.ListenAddr = fmt.Sprintf(":%d", DefaultPort)
+	}
+	if opts.Realm == "" {
+		opts.Realm = DefaultRealm
+	}
+	if opts.RelayPortMin == 0 && opts.RelayPortMax == 0 {
+		opts.RelayPortMin = DefaultRelayPortMin
+		opts.RelayPortMax = DefaultRelayPortMax
+	}
+	if opts.RelayPortMin > opts.RelayPortMax {
+		return nil, fmt.Errorf("invalid relay port range %d-%d", opts.RelayPortMin, opts.RelayPortMax)
+	}
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+
+	conn, err := net.ListenPacket("udp", opts.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", opts.ListenAddr, err)
+	}
+
+	loggerFactory := logging.GetPionDefaultLoggerFactory()
+	permission := turn.DefaultPermissionHandler
+	if opts.AllowPeer != nil {
+		permission = func(_ net.Addr, peerIP net.IP) bool {
+			return opts.AllowPeer(peerIP)
+		}
+	}
+
+	server, err := turn.NewServer(turn.ServerConfig{
+		Realm:         opts.Realm,
+		AuthHandler:   turn.LongTermTURNRESTAuthHandler(opts.Secret, loggerFactory.NewLogger("turn")),
+		LoggerFactory: loggerFactory,
+		PacketConnConfigs: []turn.PacketConnConfig{
+			{
+				PacketConn: conn,
+				RelayAddressGenerator: &turn.RelayAddressGeneratorPortRange{
+					RelayAddress: opts.RelayAddress,
+					Address:      "0.0.0.0",
+					MinPort:      opts.RelayPortMin,
+					MaxPort:      opts.RelayPortMax,
+				},
+				PermissionHandler: permission,
+			},
+		},
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start TURN server: %w", err)
+	}
+
+	opts.Logger.Info().
+		Str("listen", conn.LocalAddr().String()).
+		Str("relay_address", opts.RelayAddress.String()).
+		Msg("TURN server started")
+	return &Server{server: server, conn: conn, opts: opts}, nil
+}
+
+// Addr is the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Credentials returns ephemeral credentials for user, valid for ttl.
+func (s *Server) Credentials(user string, ttl time.Duration) (username, password string, err error) {
+	return Credentials(s.opts.Secret, user, ttl)
+}
+
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// Credentials derives TURN REST credentials from a shared secret, as
+// understood by coturn's use-auth-secret and this server.
+func Credentials(secret, user string, ttl time.Duration) (username, password string, err error) {
+	if ttl <= 0 {
+		ttl = DefaultCredentialTTL
+	}
+	return turn.GenerateLongTermTURNRESTCredentials(secret, user, ttl)
+}
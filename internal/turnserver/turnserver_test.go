@@ -0,0 +1,110 @@
+package turnserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/turn/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startServer(t *testing.T, allowPeer func(net.IP) bool) *Server {
+	t.Helper()
+	s, err := NewServer(Options{
+		ListenAddr:   "127.0.0.1:0",
+		Secret:       "shared-secret",
+		RelayAddress: net.ParseIP("127.0.0.1"),
+		AllowPeer:    allowPeer,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func newClient(t *testing.T, s *Server, username, password string) *turn.Client {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	client, err := turn.NewClient(&turn.ClientConfig{
+		STUNServerAddr: s.Addr().String(),
+		TURNServerAddr: s.Addr().String(),
+		Username:       username,
+		Password:       password,
+		Realm:          DefaultRealm,
+		Conn:           conn,
+	})
+	require.NoError(t, err)
+	require.NoError(t, client.Listen())
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestRelay(t *testing.T) {
+	s := startServer(t, nil)
+
+	peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer peer.Close()
+
+	username, password, err := s.Credentials("tester", time.Minute)
+	require.NoError(t, err)
+	relayConn, err := newClient(t, s, username, password).Allocate()
+	require.NoError(t, err)
+	defer relayConn.Close()
+
+	_, err = relayConn.WriteTo([]byte("hello"), peer.LocalAddr())
+	require.NoError(t, err)
+
+	buf := make([]byte, 64)
+	require.NoError(t, peer.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, from, err := peer.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+	assert.Equal(t, relayConn.LocalAddr().String(), from.String())
+}
+
+func TestRejectsBadCredentials(t *testing.T) {
+	s := startServer(t, nil)
+
+	_, password, err := s.Credentials("tester", time.Minute)
+	require.NoError(t, err)
+	_, err = newClient(t, s, "9999999999:tester", password).Allocate()
+	assert.Error(t, err, "password of another username")
+
+	username, password, err := Credentials("other-secret", "tester", time.Minute)
+	require.NoError(t, err)
+	_, err = newClient(t, s, username, password).Allocate()
+	assert.Error(t, err, "other secret")
+
+	username, password, err = s.Credentials("tester", -time.Minute)
+	require.NoError(t, err)
+	assert.NotEmpty(t, username, "non-positive ttl falls back to the default")
+	_, err = newClient(t, s, username, password).Allocate()
+	assert.NoError(t, err)
+}
+
+func TestAllowPeer(t *testing.T) {
+	s := startServer(t, func(ip net.IP) bool { return false })
+
+	username, password, err := s.Credentials("tester", time.Minute)
+	require.NoError(t, err)
+	relayConn, err := newClient(t, s, username, password).Allocate()
+	require.NoError(t, err)
+	defer relayConn.Close()
+
+	_, err = relayConn.WriteTo([]byte("hello"), &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 9})
+	assert.Error(t, err, "permission for the peer is refused")
+}
+
+func TestNewServerValidation(t *testing.T) {
+	_, err := NewServer(Options{RelayAddress: net.ParseIP("127.0.0.1")})
+	assert.ErrorContains(t, err, "secret")
+	_, err = NewServer(Options{Secret: "s"})
+	assert.ErrorContains(t, err, "relay address")
+	_, err = NewServer(Options{Secret: "s", RelayAddress: net.ParseIP("127.0.0.1"), RelayPortMin: 10, RelayPortMax: 5})
+	assert.ErrorContains(t, err, "port range")
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -210,3 +211,52 @@ func (c *DHCPClient) Renew() error {
 func (c *DHCPClient) Release() error {
 	return c.signalProcess(syscall.SIGUSR2)
 }
+
+// StartProcess runs udhcpc for an interface the system doesn't run it for,
+// e.g. a VLAN interface. Its script writes the lease file like for the main
+// interface.
+func (c *DHCPClient) StartProcess(hostname string) error {
+	if c.cmd != nil {
+		return nil
+	}
+	if c.pidFile == "" {
+		c.pidFile = fmt.Sprintf(DHCPPidFile, c.InterfaceName)
+	}
+
+	args := []string{"-f", "-R", "-i", c.InterfaceName, "-p", c.pidFile}
+	if hostname != "" {
+		args = append(args, "-x", "hostname:"+hostname)
+	}
+	cmd := exec.Command("udhcpc", args...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start udhcpc: %w", err)
+	}
+	c.cmd = cmd
+	c.process = cmd.Process
+	c.logger.Info().Int("pid", cmd.Process.Pid).Msg("udhcpc started")
+
+	go func() {
+		err := cmd.Wait()
+		c.logger.Info().Err(err).Msg("udhcpc exited")
+	}()
+	return nil
+}
+
+// StopProcess stops the udhcpc process started by StartProcess, it releases
+// the lease on exit.
+func (c *DHCPClient) StopProcess() error {
+	if c.cmd == nil {
+		return nil
+	}
+	cmd := c.cmd
+	c.cmd = nil
+	c.process = nil
+
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	// the interface may be gone, don't load the lease on the next start
+	_ = os.Remove(c.pidFile)
+	_ = os.Remove(c.leaseFile)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
 	"time"
@@ -25,6 +26,10 @@ type DHCPClient struct {
 	logger        *zerolog.Logger
 	process       *os.Process
 	onLeaseChange func(lease *Lease)
+
+	// cmd is the udhcpc process started by StartProcess
+	cmd  *exec.Cmd
+	stop chan struct{}
 }
 
 type DHCPClientOptions struct {
@@ -48,6 +53,7 @@ func NewDHCPClient(options *DHCPClientOptions) *DHCPClient {
 		leaseFile:     fmt.Sprintf(DHCPLeaseFile, options.InterfaceName),
 		pidFile:       options.PidFile,
 		onLeaseChange: options.OnLeaseChange,
+		stop:          make(chan struct{}),
 	}
 }
 
@@ -85,7 +91,7 @@ func (c *DHCPClient) Run() error {
 			select {
 			case event, ok := <-watcher.Events:
 				if !ok {
-					continue
+					return
 				}
 				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
 					continue
@@ -125,12 +131,26 @@ func (c *DHCPClient) Run() error {
 	// 	c.logger.Error().Msg("udhcpc process not found")
 	// }
 
-	// block the goroutine until the lease file is updated
-	<-make(chan struct{})
+	// block the goroutine until the client is stopped
+	<-c.stop
 
 	return nil
 }
 
+// Stop stops watching the lease file and the udhcpc process started by
+// StartProcess.
+func (c *DHCPClient) Stop() {
+	select {
+	case <-c.stop:
+		return
+	default:
+		close(c.stop)
+	}
+	if err := c.StopProcess(); err != nil {
+		c.logger.Warn().Err(err).Msg("failed to stop udhcpc")
+	}
+}
+
 func (c *DHCPClient) loadLeaseFile() error {
 	file, err := os.ReadFile(c.leaseFile)
 	if err != nil {
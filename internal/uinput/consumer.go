@@ -0,0 +1,78 @@
+package uinput
+
+import "github.com/jetkvm/kvm/internal/usbgadget"
+
+// 多媒体与系统控制键对应的 Linux 键码
+const (
+	KEY_MUTE         = 113
+	KEY_VOLUMEDOWN   = 114
+	KEY_VOLUMEUP     = 115
+	KEY_POWER        = 116
+	KEY_SLEEP        = 142
+	KEY_WAKEUP       = 143
+	KEY_NEXTSONG     = 163
+	KEY_PLAYPAUSE    = 164
+	KEY_PREVIOUSSONG = 165
+	KEY_STOPCD       = 166
+)
+
+// Consumer Page usage 到 Linux 键码的映射
+var consumerUsageToLinux = map[uint16]uint16{
+	usbgadget.ConsumerUsageScanNextTrack:     KEY_NEXTSONG,
+	usbgadget.ConsumerUsageScanPreviousTrack: KEY_PREVIOUSSONG,
+	usbgadget.ConsumerUsageStop:              KEY_STOPCD,
+	usbgadget.ConsumerUsagePlayPause:         KEY_PLAYPAUSE,
+	usbgadget.ConsumerUsageMute:              KEY_MUTE,
+	usbgadget.ConsumerUsageVolumeIncrement:   KEY_VOLUMEUP,
+	usbgadget.ConsumerUsageVolumeDecrement:   KEY_VOLUMEDOWN,
+}
+
+// System Control usage 到 Linux 键码的映射
+var systemUsageToLinux = map[uint8]uint16{
+	usbgadget.SystemUsagePowerDown: KEY_POWER,
+	usbgadget.SystemUsageSleep:     KEY_SLEEP,
+	usbgadget.SystemUsageWakeUp:    KEY_WAKEUP,
+}
+
+// registerControlKeys 在键盘设备上注册多媒体与系统控制键
+func (u *UInputBackend) registerControlKeys() {
+	for _, code := range consumerUsageToLinux {
+		_ = u.ioctl(UI_SET_KEYBIT, uint64(code))
+	}
+	for _, code := range systemUsageToLinux {
+		_ = u.ioctl(UI_SET_KEYBIT, uint64(code))
+	}
+}
+
+// pressControlKey 释放上一次按下的控制键，再按下新的（code 为 0 表示仅释放）
+func (u *UInputBackend) pressControlKey(last *uint16, code uint16) {
+	if *last != 0 && *last != code {
+		_ = u.writeEvent(EV_KEY, *last, 0)
+	}
+	if code != 0 && *last != code {
+		_ = u.writeEvent(EV_KEY, code, 1)
+	}
+	*last = code
+	u.sync()
+}
+
+func (u *UInputBackend) ConsumerControlReport(usage uint16) error {
+	u.controlKeyLock.Lock()
+	defer u.controlKeyLock.Unlock()
+
+	// 未映射的 usage 视为释放
+	code := consumerUsageToLinux[usage]
+	u.pressControlKey(&u.consumerKey, code)
+	u.resetUserInputTime()
+	return nil
+}
+
+func (u *UInputBackend) SystemControlReport(usage uint8) error {
+	u.controlKeyLock.Lock()
+	defer u.controlKeyLock.Unlock()
+
+	code := systemUsageToLinux[usage]
+	u.pressControlKey(&u.systemKey, code)
+	u.resetUserInputTime()
+	return nil
+}
@@ -0,0 +1,282 @@
+package uinput
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// 鼠标相关的 evdev/uinput 常量
+const (
+	UI_SET_RELBIT = 0x40045566
+	UI_SET_ABSBIT = 0x40045567
+
+	EV_REL = 0x02
+	EV_ABS = 0x03
+
+	REL_X      = 0x00
+	REL_Y      = 0x01
+	REL_HWHEEL = 0x06
+	REL_WHEEL  = 0x08
+
+	ABS_X = 0x00
+	ABS_Y = 0x01
+
+	BTN_LEFT   = 0x110
+	BTN_RIGHT  = 0x111
+	BTN_MIDDLE = 0x112
+	BTN_SIDE   = 0x113 // 后退（按键 4）
+	BTN_EXTRA  = 0x114 // 前进（按键 5）
+
+	// 与 usbgadget 绝对鼠标描述符的逻辑最大值保持一致
+	absMouseMax = 32767
+)
+
+// HID 按键位到 Linux 按键的映射（bit0 左键 … bit4 前进）
+var hidButtonToLinux = []uint16{BTN_LEFT, BTN_RIGHT, BTN_MIDDLE, BTN_SIDE, BTN_EXTRA}
+
+// mouseDevice 是一个独立的 uinput 指针设备。
+// 绝对与相对指针分开创建，避免桌面环境把混合设备误判为触摸板。
+type mouseDevice struct {
+	fd      *os.File
+	lock    sync.Mutex
+	buttons uint8
+}
+
+func ioctlFile(f *os.File, request uintptr, arg uint64) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// newMouseDevice 创建指针设备；absolute 为 true 时使用 ABS_X/ABS_Y，否则使用 REL_X/REL_Y
+func newMouseDevice(name string, product uint16, absolute bool) (*mouseDevice, error) {
+	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open /dev/uinput failed: %w", err)
+	}
+
+	setup := func() error {
+		for _, ev := range []uint64{EV_SYN, EV_KEY, EV_REL} {
+			if err := ioctlFile(f, UI_SET_EVBIT, ev); err != nil {
+				return fmt.Errorf("ioctl UI_SET_EVBIT %d failed: %w", ev, err)
+			}
+		}
+		for _, btn := range hidButtonToLinux {
+			if err := ioctlFile(f, UI_SET_KEYBIT, uint64(btn)); err != nil {
+				return fmt.Errorf("ioctl UI_SET_KEYBIT %d failed: %w", btn, err)
+			}
+		}
+
+		rels := []uint64{REL_WHEEL, REL_HWHEEL}
+		if !absolute {
+			rels = append(rels, REL_X, REL_Y)
+		}
+		for _, rel := range rels {
+			if err := ioctlFile(f, UI_SET_RELBIT, rel); err != nil {
+				return fmt.Errorf("ioctl UI_SET_RELBIT %d failed: %w", rel, err)
+			}
+		}
+
+		var dev uinput_user_dev
+		copy(dev.Name[:], []byte(name))
+		dev.Id = input_id{
+			Bustype: 0x03, // BUS_USB
+			Vendor:  0x1209,
+			Product: product,
+			Version: 0x0001,
+		}
+
+		if absolute {
+			if err := ioctlFile(f, UI_SET_EVBIT, EV_ABS); err != nil {
+				return fmt.Errorf("ioctl UI_SET_EVBIT EV_ABS failed: %w", err)
+			}
+			for _, abs := range []uint64{ABS_X, ABS_Y} {
+				if err := ioctlFile(f, UI_SET_ABSBIT, abs); err != nil {
+					return fmt.Errorf("ioctl UI_SET_ABSBIT %d failed: %w", abs, err)
+				}
+				dev.Absmin[abs] = 0
+				dev.Absmax[abs] = absMouseMax
+			}
+		}
+
+		if err := binary.Write(f, binary.LittleEndian, &dev); err != nil {
+			return fmt.Errorf("write uinput_user_dev failed: %w", err)
+		}
+
+		if err := ioctlFile(f, UI_DEV_CREATE, 0); err != nil {
+			return fmt.Errorf("ioctl UI_DEV_CREATE failed: %w", err)
+		}
+		return nil
+	}
+
+	if err := setup(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &mouseDevice{fd: f}, nil
+}
+
+func (m *mouseDevice) close() {
+	if m == nil || m.fd == nil {
+		return
+	}
+	_ = ioctlFile(m.fd, UI_DEV_DESTROY, 0)
+	_ = m.fd.Close()
+	m.fd = nil
+}
+
+func (m *mouseDevice) writeEvent(typ, code uint16, val int32) error {
+	ev := input_event{
+		Type:  typ,
+		Code:  code,
+		Value: val,
+	}
+	return binary.Write(m.fd, binary.LittleEndian, &ev)
+}
+
+// writeButtons 只为状态发生变化的按键发送事件，调用方需持有锁
+func (m *mouseDevice) writeButtons(buttons uint8) error {
+	changed := m.buttons ^ buttons
+	for i, btn := range hidButtonToLinux {
+		mask := uint8(1) << i
+		if changed&mask == 0 {
+			continue
+		}
+		val := int32(0)
+		if buttons&mask != 0 {
+			val = 1
+		}
+		if err := m.writeEvent(EV_KEY, btn, val); err != nil {
+			return err
+		}
+	}
+	m.buttons = buttons
+	return nil
+}
+
+func (m *mouseDevice) sync() error {
+	return m.writeEvent(EV_SYN, SYN_REPORT, 0)
+}
+
+func (m *mouseDevice) absReport(x int, y int, buttons uint8) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.writeEvent(EV_ABS, ABS_X, int32(x)); err != nil {
+		return err
+	}
+	if err := m.writeEvent(EV_ABS, ABS_Y, int32(y)); err != nil {
+		return err
+	}
+	if err := m.writeButtons(buttons); err != nil {
+		return err
+	}
+	return m.sync()
+}
+
+func (m *mouseDevice) relReport(dx int8, dy int8, buttons uint8) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if dx != 0 {
+		if err := m.writeEvent(EV_REL, REL_X, int32(dx)); err != nil {
+			return err
+		}
+	}
+	if dy != 0 {
+		if err := m.writeEvent(EV_REL, REL_Y, int32(dy)); err != nil {
+			return err
+		}
+	}
+	if err := m.writeButtons(buttons); err != nil {
+		return err
+	}
+	return m.sync()
+}
+
+func (m *mouseDevice) scrollReport(wheelY int8, wheelX int8) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if wheelY == 0 && wheelX == 0 {
+		return nil
+	}
+	if wheelY != 0 {
+		if err := m.writeEvent(EV_REL, REL_WHEEL, int32(wheelY)); err != nil {
+			return err
+		}
+	}
+	if wheelX != 0 {
+		if err := m.writeEvent(EV_REL, REL_HWHEEL, int32(wheelX)); err != nil {
+			return err
+		}
+	}
+	return m.sync()
+}
+
+// initMice 创建绝对与相对两个指针设备，失败时仅记录日志，键盘仍可使用
+func (u *UInputBackend) initMice() {
+	var err error
+	u.absMouse, err = newMouseDevice("jetkvm-uinput-tablet", 0x0002, true)
+	if err != nil {
+		u.log.Warn().Err(err).Msg("failed to create uinput absolute mouse")
+	}
+	u.relMouse, err = newMouseDevice("jetkvm-uinput-mouse", 0x0003, false)
+	if err != nil {
+		u.log.Warn().Err(err).Msg("failed to create uinput relative mouse")
+	}
+}
+
+func (u *UInputBackend) AbsMouseReport(x int, y int, buttons uint8) error {
+	if u.absMouse == nil {
+		return nil
+	}
+	if err := u.absMouse.absReport(x, y, buttons); err != nil {
+		return err
+	}
+	u.resetUserInputTime()
+	return nil
+}
+
+func (u *UInputBackend) RelMouseReport(dx int8, dy int8, buttons uint8) error {
+	if u.relMouse == nil {
+		return nil
+	}
+	if err := u.relMouse.relReport(dx, dy, buttons); err != nil {
+		return err
+	}
+	u.resetUserInputTime()
+	return nil
+}
+
+func (u *UInputBackend) AbsMouseWheelReport(wheelY int8) error {
+	return u.AbsMouseScrollReport(wheelY, 0)
+}
+
+func (u *UInputBackend) AbsMouseScrollReport(wheelY int8, wheelX int8) error {
+	if u.absMouse == nil {
+		return nil
+	}
+	if err := u.absMouse.scrollReport(wheelY, wheelX); err != nil {
+		return err
+	}
+	u.resetUserInputTime()
+	return nil
+}
+
+func (u *UInputBackend) RelMouseScrollReport(wheelY int8, wheelX int8) error {
+	if u.relMouse == nil {
+		return nil
+	}
+	if err := u.relMouse.scrollReport(wheelY, wheelX); err != nil {
+		return err
+	}
+	u.resetUserInputTime()
+	return nil
+}
@@ -24,6 +24,13 @@ type UInputBackend struct {
 	keysDownState     usbgadget.KeysDownState
 
 	lastUserInput time.Time
+
+	absMouse *mouseDevice
+	relMouse *mouseDevice
+
+	controlKeyLock sync.Mutex
+	consumerKey    uint16
+	systemKey      uint16
 }
 
 var defaultLogger = zerolog.New(os.Stdout).With().Str("subsystem", "uinput").Logger()
@@ -99,6 +106,7 @@ func NewUInputBackend(logger *zerolog.Logger) (*UInputBackend, error) {
 	for _, code := range hidModifierToLinux {
 		_ = u.ioctl(UI_SET_KEYBIT, uint64(code))
 	}
+	u.registerControlKeys()
 
 	// 写入设备基本信息（名称与 input_id），符合 uinput 要求
 	var dev uinput_user_dev
@@ -119,10 +127,15 @@ func NewUInputBackend(logger *zerolog.Logger) (*UInputBackend, error) {
 		return nil, fmt.Errorf("ioctl UI_DEV_CREATE failed: %w", err)
 	}
 
+	// 鼠标设备单独创建
+	u.initMice()
+
 	return u, nil
 }
 
 func (u *UInputBackend) Close() error {
+	u.absMouse.close()
+	u.relMouse.close()
 	if u.fd != nil {
 		_ = u.ioctl(UI_DEV_DESTROY, 0)
 		_ = u.fd.Close()
@@ -201,8 +214,12 @@ func (u *UInputBackend) GetKeysDownState() usbgadget.KeysDownState {
 }
 
 func (u *UInputBackend) updateKeysDown(modifier byte, keys []byte) usbgadget.KeysDownState {
-	// 复制并规范长度
-	k := make([]byte, 6)
+	// 复制并规范长度：至少 6 个，uinput 本身不限制同时按下的键数（NKRO）
+	n := len(keys)
+	if n < 6 {
+		n = 6
+	}
+	k := make([]byte, n)
 	copy(k, keys)
 	state := usbgadget.KeysDownState{Modifier: modifier, Keys: k}
 	u.keyboardStateLock.Lock()
@@ -321,7 +338,8 @@ func (u *UInputBackend) KeypressReport(key byte, press bool) error {
 			}
 		}
 		if !placed {
-			// 溢出忽略
+			// 超过 6 个键时追加，不做截断
+			ks = append(ks, key)
 		}
 	} else {
 		for i := range ks {
@@ -342,10 +360,7 @@ func (u *UInputBackend) DelayAutoReleaseWithDuration(resetDuration time.Duration
 
 func (u *UInputBackend) GetPath(subpath string) (string, error) { return "", nil }
 
-// 鼠标在 uinput 下暂不实现，保留空实现以兼容编译与调用
-func (u *UInputBackend) AbsMouseReport(x int, y int, buttons uint8) error { return nil }
-func (u *UInputBackend) RelMouseReport(dx int8, dy int8, buttons uint8) error { return nil }
-func (u *UInputBackend) AbsMouseWheelReport(wheelY int8) error { return nil }
+// 鼠标实现见 mouse.go
 
  // gadget 相关操作在 uinput 下无意义，均返回 no-op
 func (u *UInputBackend) IsUDCBound() (bool, error) { return false, nil }
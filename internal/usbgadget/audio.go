@@ -0,0 +1,23 @@
+package usbgadget
+
+// AudioDevice is the ALSA device exposed on the device side by the UAC2 function.
+// Writing to it plays into the host's microphone, reading from it captures the
+// host's speaker output.
+const AudioDevice = "hw:UAC2Gadget,0"
+
+var audioConfig = gadgetConfigItem{
+	order:      4000,
+	device:     "uac2.usb0",
+	path:       []string{"functions", "uac2.usb0"},
+	configPath: []string{"uac2.usb0"},
+	attrs: gadgetAttributes{
+		// capture: device -> host (microphone)
+		"c_chmask": "3", // stereo
+		"c_srate":  "48000",
+		"c_ssize":  "2", // 16-bit
+		// playback: host -> device (speaker)
+		"p_chmask": "3", // stereo
+		"p_srate":  "48000",
+		"p_ssize":  "2", // 16-bit
+	},
+}
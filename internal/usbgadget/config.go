@@ -56,9 +56,18 @@ var defaultGadgetConfig = map[string]gadgetConfigItem{
 	"absolute_mouse": absoluteMouseConfig,
 	// relative mouse HID
 	"relative_mouse": relativeMouseConfig,
+	// consumer and system control HID
+	"consumer_control": consumerControlConfig,
 	// mass storage
 	"mass_storage_base": massStorageBaseConfig,
 	"mass_storage_lun0": massStorageLun0Config,
+	// USB audio (UAC2)
+	"audio": audioConfig,
+	// USB serial (CDC-ACM)
+	"serial": serialConfig,
+	// USB network (CDC-NCM or CDC-ECM)
+	"network_ncm": networkNcmConfig,
+	"network_ecm": networkEcmConfig,
 }
 
 func (u *UsbGadget) isGadgetConfigItemEnabled(itemKey string) bool {
@@ -69,10 +78,20 @@ func (u *UsbGadget) isGadgetConfigItemEnabled(itemKey string) bool {
 		return u.enabledDevices.RelativeMouse
 	case "keyboard":
 		return u.enabledDevices.Keyboard
+	case "consumer_control":
+		return u.enabledDevices.ConsumerControl
 	case "mass_storage_base":
 		return u.enabledDevices.MassStorage
 	case "mass_storage_lun0":
 		return u.enabledDevices.MassStorage
+	case "audio":
+		return u.enabledDevices.Audio
+	case "serial":
+		return u.enabledDevices.Serial
+	case "network_ncm":
+		return u.enabledDevices.Network && !u.enabledDevices.NetworkECM
+	case "network_ecm":
+		return u.enabledDevices.Network && u.enabledDevices.NetworkECM
 	default:
 		return true
 	}
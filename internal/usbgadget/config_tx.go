@@ -113,6 +113,9 @@ func (tx *UsbGadgetTransaction) Commit() error {
 func (u *UsbGadget) getOrderedConfigItems() orderedGadgetConfigItems {
 	items := make([]gadgetConfigItemWithKey, 0)
 	for key, item := range u.configMap {
+		if key == "keyboard" {
+			item = u.keyboardConfigItem(item)
+		}
 		items = append(items, gadgetConfigItemWithKey{key, item})
 	}
 
@@ -1,6 +1,12 @@
 package usbgadget
 
-import "time"
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
 
 func (u *UsbGadget) resetUserInputTime() {
 	u.lastUserInput = time.Now()
@@ -9,3 +15,23 @@ func (u *UsbGadget) resetUserInputTime() {
 func (u *UsbGadget) GetLastUserInputTime() time.Time {
 	return u.lastUserInput
 }
+
+// hidDevicePath returns the /dev/hidgN node of a HID function. The minor number is
+// allocated when the function is created, so it is read from the function's `dev`
+// attribute instead of being derived from the function name.
+func (u *UsbGadget) hidDevicePath(item gadgetConfigItem, fallback string) string {
+	devPath := path.Join(joinPath(u.kvmGadgetPath, item.path), "dev")
+
+	data, err := os.ReadFile(devPath)
+	if err != nil {
+		return fallback
+	}
+
+	// the content is "major:minor"
+	parts := strings.Split(strings.TrimSpace(string(data)), ":")
+	if len(parts) != 2 || parts[1] == "" {
+		return fallback
+	}
+
+	return fmt.Sprintf("/dev/hidg%s", parts[1])
+}
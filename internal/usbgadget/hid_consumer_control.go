@@ -0,0 +1,123 @@
+package usbgadget
+
+import (
+	"fmt"
+	"os"
+)
+
+var consumerControlConfig = gadgetConfigItem{
+	order:      1003,
+	device:     "hid.usb3",
+	path:       []string{"functions", "hid.usb3"},
+	configPath: []string{"hid.usb3"},
+	attrs: gadgetAttributes{
+		"protocol":        "0",
+		"subclass":        "0",
+		"report_length":   "3",
+		"no_out_endpoint": "1",
+	},
+	reportDesc: consumerControlCombinedReportDesc,
+}
+
+// Consumer Control and System Control usages, see https://www.usb.org/sites/default/files/hut1_2.pdf
+const (
+	// Consumer Page (0x0C)
+	ConsumerUsageScanNextTrack     = 0x00B5
+	ConsumerUsageScanPreviousTrack = 0x00B6
+	ConsumerUsageStop              = 0x00B7
+	ConsumerUsagePlayPause         = 0x00CD
+	ConsumerUsageMute              = 0x00E2
+	ConsumerUsageVolumeIncrement   = 0x00E9
+	ConsumerUsageVolumeDecrement   = 0x00EA
+
+	// Generic Desktop Page (0x01)
+	SystemUsagePowerDown = 0x81
+	SystemUsageSleep     = 0x82
+	SystemUsageWakeUp    = 0x83
+)
+
+var consumerControlCombinedReportDesc = []byte{
+	// Report ID 1: Consumer Control, a single 16-bit usage
+	0x05, 0x0c, /* USAGE_PAGE (Consumer Devices)    */
+	0x09, 0x01, /* USAGE (Consumer Control)         */
+	0xa1, 0x01, /* COLLECTION (Application)         */
+	0x85, 0x01, /*   REPORT_ID (1)                  */
+	0x15, 0x00, /*   LOGICAL_MINIMUM (0)            */
+	0x26, 0xff, 0x03, /*   LOGICAL_MAXIMUM (1023)   */
+	0x19, 0x00, /*   USAGE_MINIMUM (0)              */
+	0x2a, 0xff, 0x03, /*   USAGE_MAXIMUM (1023)     */
+	0x75, 0x10, /*   REPORT_SIZE (16)               */
+	0x95, 0x01, /*   REPORT_COUNT (1)               */
+	0x81, 0x00, /*   INPUT (Data,Ary,Abs)           */
+	0xc0, /* END_COLLECTION                         */
+
+	// Report ID 2: System Control, a single 8-bit usage
+	0x05, 0x01, /* USAGE_PAGE (Generic Desktop)     */
+	0x09, 0x80, /* USAGE (System Control)           */
+	0xa1, 0x01, /* COLLECTION (Application)         */
+	0x85, 0x02, /*   REPORT_ID (2)                  */
+	0x15, 0x00, /*   LOGICAL_MINIMUM (0)            */
+	0x26, 0xff, 0x00, /*   LOGICAL_MAXIMUM (255)    */
+	0x19, 0x00, /*   USAGE_MINIMUM (0)              */
+	0x29, 0xff, /*   USAGE_MAXIMUM (255)            */
+	0x75, 0x08, /*   REPORT_SIZE (8)                */
+	0x95, 0x01, /*   REPORT_COUNT (1)               */
+	0x81, 0x00, /*   INPUT (Data,Ary,Abs)           */
+	0xc0, /* END_COLLECTION                         */
+}
+
+func (u *UsbGadget) consumerControlWriteHidFile(data []byte) error {
+	if u.consumerControlHidFile == nil {
+		var err error
+		devicePath := u.hidDevicePath(consumerControlConfig, "/dev/hidg3")
+		u.consumerControlHidFile, err = os.OpenFile(devicePath, os.O_RDWR, 0666)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", devicePath, err)
+		}
+	}
+
+	_, err := u.writeWithTimeout(u.consumerControlHidFile, data)
+	if err != nil {
+		u.logWithSuppression("consumerControlWriteHidFile", 100, u.log, err, "failed to write to consumer control device")
+		u.consumerControlHidFile.Close()
+		u.consumerControlHidFile = nil
+		return err
+	}
+	u.resetLogSuppressionCounter("consumerControlWriteHidFile")
+	return nil
+}
+
+// ConsumerControlReport reports the currently pressed Consumer Page usage, 0 releases it.
+func (u *UsbGadget) ConsumerControlReport(usage uint16) error {
+	u.consumerControlLock.Lock()
+	defer u.consumerControlLock.Unlock()
+
+	err := u.consumerControlWriteHidFile([]byte{
+		1,                // Report ID 1
+		byte(usage),      // Usage Low Byte
+		byte(usage >> 8), // Usage High Byte
+	})
+	if err != nil {
+		return err
+	}
+
+	u.resetUserInputTime()
+	return nil
+}
+
+// SystemControlReport reports the currently pressed System Control usage, 0 releases it.
+func (u *UsbGadget) SystemControlReport(usage uint8) error {
+	u.consumerControlLock.Lock()
+	defer u.consumerControlLock.Unlock()
+
+	err := u.consumerControlWriteHidFile([]byte{
+		2,     // Report ID 2
+		usage, // Usage
+	})
+	if err != nil {
+		return err
+	}
+
+	u.resetUserInputTime()
+	return nil
+}
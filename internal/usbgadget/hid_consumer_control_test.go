@@ -0,0 +1,50 @@
+package usbgadget
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumerControlReports(t *testing.T) {
+	// a pipe stands in for hidg3, regular files don't support write deadlines
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+
+	log := zerolog.Nop()
+	u := &UsbGadget{
+		log:                    &log,
+		logSuppressionCounter:  make(map[string]int),
+		consumerControlHidFile: w,
+	}
+	defer w.Close()
+
+	read := func(n int) []byte {
+		buf := make([]byte, n)
+		_, err := r.Read(buf)
+		require.NoError(t, err)
+		return buf
+	}
+
+	// Volume Up, 0x00E9, little-endian after the report ID
+	require.NoError(t, u.ConsumerControlReport(0x00E9))
+	assert.Equal(t, []byte{1, 0xE9, 0x00}, read(3))
+
+	// AL Calculator, 0x0192, needs the high byte
+	require.NoError(t, u.ConsumerControlReport(0x0192))
+	assert.Equal(t, []byte{1, 0x92, 0x01}, read(3))
+
+	// System Power Down
+	require.NoError(t, u.SystemControlReport(0x81))
+	assert.Equal(t, []byte{2, 0x81}, read(2))
+
+	// releases
+	require.NoError(t, u.ConsumerControlReport(0))
+	assert.Equal(t, []byte{1, 0, 0}, read(3))
+	require.NoError(t, u.SystemControlReport(0))
+	assert.Equal(t, []byte{2, 0}, read(2))
+}
@@ -318,7 +318,17 @@ func (u *UsbGadget) keyboardWriteHidFile(modifier byte, keys []byte) error {
 		return err
 	}
 
-	_, err := u.writeWithTimeout(u.keyboardHidFile, append([]byte{modifier, 0x00}, keys[:hidKeyBufferSize]...))
+	var report []byte
+	if u.enabledDevices.KeyboardNKRO {
+		var err error
+		if report, err = buildNKROKeyboardReport(modifier, keys); err != nil {
+			return err
+		}
+	} else {
+		report = append([]byte{modifier, 0x00}, keys[:hidKeyBufferSize]...)
+	}
+
+	_, err := u.writeWithTimeout(u.keyboardHidFile, report)
 	if err != nil {
 		u.logWithSuppression("keyboardWriteHidFile", 100, u.log, err, "failed to write to hidg0")
 		u.keyboardHidFile.Close()
@@ -329,9 +339,20 @@ func (u *UsbGadget) keyboardWriteHidFile(modifier byte, keys []byte) error {
 	return nil
 }
 
+// normalizeKeys pads or truncates keys to the given buffer size.
+func normalizeKeys(keys []byte, size int) []byte {
+	if len(keys) > size {
+		return keys[:size]
+	}
+	if len(keys) < size {
+		return append(keys, make([]byte, size-len(keys))...)
+	}
+	return keys
+}
+
 func (u *UsbGadget) UpdateKeysDown(modifier byte, keys []byte) KeysDownState {
 	// if we just reported an error roll over, we should clear the keys
-	if keys[0] == hidErrorRollOver {
+	if len(keys) > 0 && keys[0] == hidErrorRollOver {
 		for i := range keys {
 			keys[i] = 0
 		}
@@ -362,11 +383,11 @@ func (u *UsbGadget) UpdateKeysDown(modifier byte, keys []byte) KeysDownState {
 func (u *UsbGadget) KeyboardReport(modifier byte, keys []byte) error {
 	defer u.resetUserInputTime()
 
-	if len(keys) > hidKeyBufferSize {
-		keys = keys[:hidKeyBufferSize]
-	}
-	if len(keys) < hidKeyBufferSize {
-		keys = append(keys, make([]byte, hidKeyBufferSize-len(keys))...)
+	keys = normalizeKeys(keys, u.keyBufferSize())
+	if u.enabledDevices.KeyboardNKRO {
+		if err := checkNKROKeys(keys); err != nil {
+			return err
+		}
 	}
 
 	err := u.keyboardWriteHidFile(modifier, keys)
@@ -432,8 +453,15 @@ func (u *UsbGadget) keypressReport(key byte, press bool) (KeysDownState, error)
 	var state = u.GetKeysDownState()
 	l.Trace().Interface("state", state).Msg("got keys down state")
 
+	if _, isModifier := KeyCodeToMaskMap[key]; press && !isModifier && u.enabledDevices.KeyboardNKRO {
+		if err := checkNKROKeys([]byte{key}); err != nil {
+			return state, err
+		}
+	}
+
 	modifier := state.Modifier
-	keys := append([]byte(nil), state.Keys...)
+	bufferSize := u.keyBufferSize()
+	keys := normalizeKeys(append([]byte(nil), state.Keys...), bufferSize)
 
 	if mask, exists := KeyCodeToMaskMap[key]; exists {
 		// If the key is a modifier key, we update the keyboardModifier state
@@ -449,7 +477,7 @@ func (u *UsbGadget) keypressReport(key byte, press bool) (KeysDownState, error)
 		// handle other keys that are not modifier keys by placing or removing them
 		// from the key buffer since the buffer tracks currently pressed keys
 		overrun := true
-		for i := range hidKeyBufferSize {
+		for i := range bufferSize {
 			// If we find the key in the buffer the buffer, we either remove it (if press is false)
 			// or do nothing (if down is true) because the buffer tracks currently pressed keys
 			// and if we find a zero byte, we can place the key there (if press is true)
@@ -460,7 +488,7 @@ func (u *UsbGadget) keypressReport(key byte, press bool) (KeysDownState, error)
 					// we are releasing the key, remove it from the buffer
 					if keys[i] != 0 {
 						copy(keys[i:], keys[i+1:])
-						keys[hidKeyBufferSize-1] = 0 // Clear the last byte
+						keys[bufferSize-1] = 0 // Clear the last byte
 					}
 				}
 				overrun = false // We found a slot for the key
@@ -0,0 +1,137 @@
+package usbgadget
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// hidNKROKeyBufferSize is the number of keys tracked in KeysDownState in NKRO mode.
+	hidNKROKeyBufferSize = 32
+	// hidNKROMaxUsage is the highest key usage covered by the NKRO bitmap (Keyboard ExSel).
+	hidNKROMaxUsage = 0xA4
+	// hidNKROBitmapSize is the size of the key bitmap in bytes.
+	hidNKROBitmapSize = (hidNKROMaxUsage + 8) / 8
+	// hidNKROReportLength is the boot report (8 bytes) followed by the key bitmap.
+	hidNKROReportLength = 8 + hidNKROBitmapSize
+)
+
+// keyboardNKROReportDesc describes a hybrid report: the first 8 bytes are a regular
+// boot keyboard report, followed by a bitmap with one bit per key.
+//
+// The kernel answers SET_PROTOCOL itself and doesn't tell us which protocol the host
+// selected, so there's no switching to 8-byte boot reports and both parts are always
+// filled. A host in report protocol ignores the boot key array (declared as constant
+// below) and reads the bitmap. A host in boot protocol gets a 6KRO report if it only
+// reads the first 8 bytes, which is up to the host: strict BIOS parsers expecting
+// exactly 8 bytes may misread the longer report, hence NKRO is off by default.
+var keyboardNKROReportDesc = []byte{
+	0x05, 0x01, /* USAGE_PAGE (Generic Desktop)	          */
+	0x09, 0x06, /* USAGE (Keyboard)                       */
+	0xa1, 0x01, /* COLLECTION (Application)               */
+	0x05, 0x07, /*   USAGE_PAGE (Keyboard)                */
+	0x19, 0xe0, /*   USAGE_MINIMUM (Keyboard LeftControl) */
+	0x29, 0xe7, /*   USAGE_MAXIMUM (Keyboard Right GUI)   */
+	0x15, 0x00, /*   LOGICAL_MINIMUM (0)                  */
+	0x25, 0x01, /*   LOGICAL_MAXIMUM (1)                  */
+	0x75, 0x01, /*   REPORT_SIZE (1)                      */
+	0x95, 0x08, /*   REPORT_COUNT (8)                     */
+	0x81, 0x02, /*   INPUT (Data,Var,Abs)                 */
+	0x95, 0x01, /*   REPORT_COUNT (1)                     */
+	0x75, 0x08, /*   REPORT_SIZE (8)                      */
+	0x81, 0x03, /*   INPUT (Cnst,Var,Abs)                 */
+	0x95, 0x05, /*   REPORT_COUNT (5)                     */
+	0x75, 0x01, /*   REPORT_SIZE (1)                      */
+
+	0x05, 0x08, /*   USAGE_PAGE (LEDs)                    */
+	0x19, 0x01, /*   USAGE_MINIMUM (Num Lock)             */
+	0x29, 0x05, /*   USAGE_MAXIMUM (Kana)                 */
+	0x91, 0x02, /*   OUTPUT (Data,Var,Abs)                */
+	0x95, 0x01, /*   REPORT_COUNT (1)                     */
+	0x75, 0x03, /*   REPORT_SIZE (3)                      */
+	0x91, 0x03, /*   OUTPUT (Cnst,Var,Abs)                */
+
+	0x95, 0x06, /*   REPORT_COUNT (6)                     */
+	0x75, 0x08, /*   REPORT_SIZE (8)                      */
+	0x81, 0x01, /*   INPUT (Cnst,Ary,Abs) boot key array  */
+
+	0x05, 0x07, /*   USAGE_PAGE (Keyboard)                */
+	0x19, 0x00, /*   USAGE_MINIMUM (Reserved)             */
+	0x29, hidNKROMaxUsage, /*   USAGE_MAXIMUM (Keyboard ExSel)       */
+	0x15, 0x00, /*   LOGICAL_MINIMUM (0)                  */
+	0x25, 0x01, /*   LOGICAL_MAXIMUM (1)                  */
+	0x75, 0x01, /*   REPORT_SIZE (1)                      */
+	0x95, hidNKROMaxUsage + 1, /*   REPORT_COUNT (165)                   */
+	0x81, 0x02, /*   INPUT (Data,Var,Abs) key bitmap      */
+	0x95, 0x01, /*   REPORT_COUNT (1)                     */
+	0x75, hidNKROBitmapSize*8 - (hidNKROMaxUsage + 1), /*   REPORT_SIZE (padding)                */
+	0x81, 0x03, /*   INPUT (Cnst,Var,Abs)                 */
+	0xc0, /* END_COLLECTION                         */
+}
+
+// keyboardConfigItem returns the keyboard function config for the selected keyboard mode.
+func (u *UsbGadget) keyboardConfigItem(item gadgetConfigItem) gadgetConfigItem {
+	if !u.enabledDevices.KeyboardNKRO {
+		return item
+	}
+
+	attrs := make(gadgetAttributes, len(item.attrs))
+	for k, v := range item.attrs {
+		attrs[k] = v
+	}
+	attrs["report_length"] = strconv.Itoa(hidNKROReportLength)
+
+	item.attrs = attrs
+	item.reportDesc = keyboardNKROReportDesc
+	return item
+}
+
+// keyBufferSize returns the number of keys tracked in KeysDownState.
+func (u *UsbGadget) keyBufferSize() int {
+	if u.enabledDevices.KeyboardNKRO {
+		return hidNKROKeyBufferSize
+	}
+	return hidKeyBufferSize
+}
+
+// checkNKROKeys rejects usages above the key bitmap, a host in report
+// protocol would never see them.
+func checkNKROKeys(keys []byte) error {
+	for _, key := range keys {
+		if key > hidNKROMaxUsage {
+			return fmt.Errorf("key 0x%02x can't be sent in NKRO mode, the key bitmap ends at 0x%02x", key, hidNKROMaxUsage)
+		}
+	}
+	return nil
+}
+
+// buildNKROKeyboardReport builds the hybrid boot + bitmap report from the pressed keys.
+func buildNKROKeyboardReport(modifier byte, keys []byte) ([]byte, error) {
+	if err := checkNKROKeys(keys); err != nil {
+		return nil, err
+	}
+	report := make([]byte, hidNKROReportLength)
+	report[0] = modifier
+
+	pressed := 0
+	for _, key := range keys {
+		if key == 0 || key == hidErrorRollOver {
+			continue
+		}
+
+		if pressed < hidKeyBufferSize {
+			report[2+pressed] = key
+		}
+		pressed++
+		report[8+key/8] |= 1 << (key % 8)
+	}
+
+	// more keys than the boot report can hold
+	if pressed > hidKeyBufferSize {
+		for i := range hidKeyBufferSize {
+			report[2+i] = hidErrorRollOver
+		}
+	}
+
+	return report, nil
+}
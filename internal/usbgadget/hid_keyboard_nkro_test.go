@@ -0,0 +1,61 @@
+package usbgadget
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildNKROKeyboardReport(t *testing.T) {
+	assert := assert.New(t)
+
+	// A, B, C
+	report, err := buildNKROKeyboardReport(ModifierMaskLeftShift, []byte{0x04, 0x05, 0x06, 0, 0, 0})
+	require.NoError(t, err)
+	assert.Equal(hidNKROReportLength, len(report))
+	assert.Equal(byte(ModifierMaskLeftShift), report[0])
+	assert.Equal([]byte{0x04, 0x05, 0x06, 0, 0, 0}, report[2:8])
+	assert.Equal(byte(0b01110000), report[8])
+
+	// seven keys overflow the boot report but not the bitmap
+	report, err = buildNKROKeyboardReport(0, []byte{0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A})
+	require.NoError(t, err)
+	assert.Equal([]byte{1, 1, 1, 1, 1, 1}, report[2:8])
+	assert.Equal(byte(0b11110000), report[8])
+	assert.Equal(byte(0b00000111), report[9])
+}
+
+func TestNKROKeyboardReportRollover(t *testing.T) {
+	// a full key buffer, up to the highest usage of the bitmap
+	keys := make([]byte, hidNKROKeyBufferSize)
+	for i := range keys {
+		keys[i] = hidNKROMaxUsage - byte(i)
+	}
+	report, err := buildNKROKeyboardReport(0, keys)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 1, 1, 1, 1, 1}, report[2:8], "boot protocol hosts see a rollover")
+
+	pressed := 0
+	for usage := 0; usage <= hidNKROMaxUsage; usage++ {
+		if report[8+usage/8]&(1<<(usage%8)) != 0 {
+			pressed++
+		}
+	}
+	assert.Equal(t, hidNKROKeyBufferSize, pressed, "report protocol hosts see every key")
+	assert.NotZero(t, report[8+hidNKROMaxUsage/8]&(1<<(hidNKROMaxUsage%8)))
+}
+
+func TestNKROKeyboardReportOverflow(t *testing.T) {
+	// Keypad 00 is beyond the bitmap
+	_, err := buildNKROKeyboardReport(0, []byte{0x04, 0xB0})
+	assert.Error(t, err)
+
+	log := zerolog.Nop()
+	u := &UsbGadget{enabledDevices: Devices{KeyboardNKRO: true}, log: &log}
+	_, err = u.keypressReport(0xB0, true)
+	assert.Error(t, err)
+	assert.Empty(t, u.GetKeysDownState().Keys, "the key isn't recorded as pressed")
+	assert.Error(t, u.KeyboardReport(0, []byte{0xB0}))
+}
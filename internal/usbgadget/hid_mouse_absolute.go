@@ -30,14 +30,14 @@ var absoluteMouseCombinedReportDesc = []byte{
 	0xA1, 0x00, //     Collection (Physical)
 	0x05, 0x09, //         Usage Page (Button)
 	0x19, 0x01, //         Usage Minimum (0x01)
-	0x29, 0x03, //         Usage Maximum (0x03)
+	0x29, 0x05, //         Usage Maximum (0x05) = Left, Right, Middle, Back, Forward
 	0x15, 0x00, //         Logical Minimum (0)
 	0x25, 0x01, //         Logical Maximum (1)
 	0x75, 0x01, //         Report Size (1)
-	0x95, 0x03, //         Report Count (3)
+	0x95, 0x05, //         Report Count (5)
 	0x81, 0x02, //         Input (Data, Var, Abs)
 	0x95, 0x01, //         Report Count (1)
-	0x75, 0x05, //         Report Size (5)
+	0x75, 0x03, //         Report Size (3)
 	0x81, 0x03, //         Input (Cnst, Var, Abs)
 	0x05, 0x01, //         Usage Page (Generic Desktop Ctrls)
 	0x09, 0x30, //         Usage (X)
@@ -61,6 +61,10 @@ var absoluteMouseCombinedReportDesc = []byte{
 	0x75, 0x08, //     Report Size (8)
 	0x95, 0x01, //     Report Count (1)
 	0x81, 0x06, //     Input (Data, Var, Rel)
+	0x05, 0x0C, //     Usage Page (Consumer)
+	0x0A, 0x38, 0x02, //     Usage (AC Pan)
+	0x95, 0x01, //     Report Count (1)
+	0x81, 0x06, //     Input (Data, Var, Rel)
 
 	0xC0, // End Collection
 }
@@ -106,17 +110,23 @@ func (u *UsbGadget) AbsMouseReport(x int, y int, buttons uint8) error {
 }
 
 func (u *UsbGadget) AbsMouseWheelReport(wheelY int8) error {
+	return u.AbsMouseScrollReport(wheelY, 0)
+}
+
+// AbsMouseScrollReport sends a vertical (wheel) and horizontal (AC Pan) scroll report.
+func (u *UsbGadget) AbsMouseScrollReport(wheelY int8, wheelX int8) error {
 	u.absMouseLock.Lock()
 	defer u.absMouseLock.Unlock()
 
 	// Only send a report if the value is non-zero
-	if wheelY == 0 {
+	if wheelY == 0 && wheelX == 0 {
 		return nil
 	}
 
 	err := u.absMouseWriteHidFile([]byte{
 		2,            // Report ID 2
 		byte(wheelY), // Wheel Y (signed)
+		byte(wheelX), // AC Pan (signed)
 	})
 
 	u.resetUserInputTime()
@@ -13,7 +13,7 @@ var relativeMouseConfig = gadgetConfigItem{
 	attrs: gadgetAttributes{
 		"protocol":        "2",
 		"subclass":        "1",
-		"report_length":   "4",
+		"report_length":   "5",
 		"no_out_endpoint": "1",
 	},
 	reportDesc: relativeMouseCombinedReportDesc,
@@ -50,6 +50,12 @@ var relativeMouseCombinedReportDesc = []byte{
 	0x95, 0x03, // REPORT_COUNT (3)
 	0x81, 0x06, // INPUT (Data,Var,Rel)
 
+	// Horizontal scroll, hosts using the boot protocol only read the first 3 bytes
+	0x05, 0x0c, // USAGE_PAGE (Consumer)
+	0x0a, 0x38, 0x02, // USAGE (AC Pan)
+	0x95, 0x01, // REPORT_COUNT (1)
+	0x81, 0x06, // INPUT (Data,Var,Rel)
+
 	// End
 	0xc0, //       End Collection (Physical)
 	0xc0, //       End Collection
@@ -84,6 +90,34 @@ func (u *UsbGadget) RelMouseReport(mx int8, my int8, buttons uint8) error {
 		byte(mx), // X
 		byte(my), // Y
 		0,        // Wheel
+		0,        // AC Pan
+	})
+	if err != nil {
+		return err
+	}
+
+	u.relMouseButtons = buttons
+	u.resetUserInputTime()
+	return nil
+}
+
+// RelMouseScrollReport sends a vertical (wheel) and horizontal (AC Pan) scroll report,
+// keeping the buttons pressed by the last RelMouseReport.
+func (u *UsbGadget) RelMouseScrollReport(wheelY int8, wheelX int8) error {
+	u.relMouseLock.Lock()
+	defer u.relMouseLock.Unlock()
+
+	// Only send a report if the value is non-zero
+	if wheelY == 0 && wheelX == 0 {
+		return nil
+	}
+
+	err := u.relMouseWriteHidFile([]byte{
+		u.relMouseButtons, // Buttons
+		0,                 // X
+		0,                 // Y
+		byte(wheelY),      // Wheel
+		byte(wheelX),      // AC Pan
 	})
 	if err != nil {
 		return err
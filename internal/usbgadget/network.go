@@ -0,0 +1,70 @@
+package usbgadget
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// NCM is the default, ECM is for hosts without an NCM driver, e.g. older macOS
+// and Windows versions.
+var networkNcmConfig = gadgetConfigItem{
+	order:      6000,
+	device:     "ncm.usb0",
+	path:       []string{"functions", "ncm.usb0"},
+	configPath: []string{"ncm.usb0"},
+	attrs:      gadgetAttributes{},
+}
+
+var networkEcmConfig = gadgetConfigItem{
+	order:      6001,
+	device:     "ecm.usb0",
+	path:       []string{"functions", "ecm.usb0"},
+	configPath: []string{"ecm.usb0"},
+	attrs:      gadgetAttributes{},
+}
+
+// networkConfigKey returns the key of the enabled USB network function.
+func (u *UsbGadget) networkConfigKey() string {
+	if u.enabledDevices.NetworkECM {
+		return "network_ecm"
+	}
+	return "network_ncm"
+}
+
+// SetNetworkAddresses sets the MAC addresses of the USB network function,
+// devAddr is the device's side and hostAddr the host's. They're applied the
+// next time the gadget is configured.
+func (u *UsbGadget) SetNetworkAddresses(devAddr, hostAddr string) {
+	u.configLock.Lock()
+	defer u.configLock.Unlock()
+
+	for _, key := range []string{"network_ncm", "network_ecm"} {
+		u.configMap[key].attrs["dev_addr"] = devAddr
+		u.configMap[key].attrs["host_addr"] = hostAddr
+	}
+}
+
+// NetworkInterfaceName returns the device side network interface of the USB
+// network function, it's only known once the gadget is bound.
+func (u *UsbGadget) NetworkInterfaceName() (string, error) {
+	u.configLock.Lock()
+	key := u.networkConfigKey()
+	u.configLock.Unlock()
+
+	itemPath, err := u.GetPath(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path.Join(itemPath, "ifname"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read network interface name: %w", err)
+	}
+	name := strings.TrimSpace(string(data))
+	if name == "" || strings.HasPrefix(name, "(") {
+		// "(unnamed net_device)" until the function is bound
+		return "", fmt.Errorf("network interface not created yet")
+	}
+	return name, nil
+}
@@ -0,0 +1,13 @@
+package usbgadget
+
+// SerialDevice is the tty exposed on the device side by the ACM function, the
+// host sees the other end as /dev/ttyACM0.
+const SerialDevice = "/dev/ttyGS0"
+
+var serialConfig = gadgetConfigItem{
+	order:      5000,
+	device:     "acm.usb0",
+	path:       []string{"functions", "acm.usb0"},
+	configPath: []string{"acm.usb0"},
+	attrs:      gadgetAttributes{},
+}
@@ -15,10 +15,21 @@ import (
 
 // Devices is a struct that represents the USB devices that can be enabled on a USB gadget.
 type Devices struct {
-	AbsoluteMouse bool `json:"absolute_mouse"`
-	RelativeMouse bool `json:"relative_mouse"`
-	Keyboard      bool `json:"keyboard"`
-	MassStorage   bool `json:"mass_storage"`
+	AbsoluteMouse   bool `json:"absolute_mouse"`
+	RelativeMouse   bool `json:"relative_mouse"`
+	Keyboard        bool `json:"keyboard"`
+	MassStorage     bool `json:"mass_storage"`
+	Audio           bool `json:"audio"`
+	ConsumerControl bool `json:"consumer_control"`
+	// KeyboardNKRO reports any number of keys up to Keyboard ExSel (0xA4).
+	// The boot protocol fallback depends on the host: the report starts with
+	// a boot report but is longer than 8 bytes, which lenient hosts accept
+	// and strict BIOS parsers may misread. It's off by default for that.
+	KeyboardNKRO bool `json:"keyboard_nkro"`
+	Serial       bool `json:"serial"`
+	Network      bool `json:"network"`
+	// NetworkECM uses CDC-ECM instead of CDC-NCM for the network function
+	NetworkECM bool `json:"network_ecm"`
 }
 
 // Config is a struct that represents the customizations for a USB gadget.
@@ -30,15 +41,26 @@ type Config struct {
 	Manufacturer string `json:"manufacturer"`
 	Product      string `json:"product"`
 
+	// NetworkDevAddr and NetworkHostAddr are applied before the gadget is
+	// first bound, see SetNetworkAddresses.
+	NetworkDevAddr  string `json:"-"`
+	NetworkHostAddr string `json:"-"`
+
 	strictMode bool // when it's enabled, all warnings will be converted to errors
 	isEmpty    bool
 }
 
 var defaultUsbGadgetDevices = Devices{
-	AbsoluteMouse: true,
-	RelativeMouse: true,
-	Keyboard:      true,
-	MassStorage:   true,
+	AbsoluteMouse:   true,
+	RelativeMouse:   true,
+	Keyboard:        true,
+	MassStorage:     true,
+	Audio:           false,
+	ConsumerControl: false,
+	KeyboardNKRO:    false, // some BIOSes can't parse it, see Devices
+	Serial:          false,
+	Network:         false,
+	NetworkECM:      false,
 }
 
 type KeysDownState struct {
@@ -64,6 +86,10 @@ type UsbGadget struct {
 	absMouseLock    sync.Mutex
 	relMouseHidFile *os.File
 	relMouseLock    sync.Mutex
+	relMouseButtons uint8
+
+	consumerControlHidFile *os.File
+	consumerControlLock    sync.Mutex
 
 	keyboardState byte          // keyboard latched state (NumLock, CapsLock, ScrollLock, Compose, Kana)
 	keysDownState KeysDownState // keyboard dynamic state (modifier keys and pressed keys)
@@ -147,6 +173,9 @@ func newUsbGadget(name string, configMap map[string]gadgetConfigItem, enabledDev
 
 		absMouseAccumulatedWheelY: 0,
 	}
+	if config.NetworkDevAddr != "" {
+		g.SetNetworkAddresses(config.NetworkDevAddr, config.NetworkHostAddr)
+	}
 	if err := g.Init(); err != nil {
 		logger.Error().Err(err).Msg("failed to init USB gadget")
 		return nil
@@ -185,6 +214,10 @@ func (u *UsbGadget) Close() error {
 		u.relMouseHidFile.Close()
 		u.relMouseHidFile = nil
 	}
+	if u.consumerControlHidFile != nil {
+		u.consumerControlHidFile.Close()
+		u.consumerControlHidFile = nil
+	}
 
 	return nil
 }
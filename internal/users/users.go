@@ -0,0 +1,729 @@
+// Package users is the local account store: bcrypt passwords, optional TOTP,
+// lockout after failed logins and per-user sessions.
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Roles, only admins may manage users.
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// SourceLocal is the source of sessions of local users, sessions of other
+// sources belong to identities verified elsewhere, e.g. by OIDC.
+const SourceLocal = "local"
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrTOTPRequired       = errors.New("two-factor code required")
+	ErrInvalidTOTP        = errors.New("invalid two-factor code")
+	ErrLocked             = errors.New("account is locked")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrLastAdmin          = errors.New("at least one enabled admin is required")
+)
+
+var (
+	usernameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,31}$`)
+	// dummyHash is compared for unknown users so they take as long as known ones.
+	dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+)
+
+var defaultLogger = logging.GetSubsystemLogger("users")
+
+// User is a stored account.
+type User struct {
+	Username        string    `json:"username"`
+	PasswordHash    string    `json:"password_hash"`
+	Role            string    `json:"role"`
+	Disabled        bool      `json:"disabled"`
+	TOTPSecret      string    `json:"totp_secret,omitempty"`
+	TOTPEnabled     bool      `json:"totp_enabled"`
+	TOTPLastCounter int64     `json:"totp_last_counter,omitempty"`
+	FailedAttempts  int       `json:"failed_attempts"`
+	LockedUntil     time.Time `json:"locked_until"`
+	CreatedAt       time.Time `json:"created_at"`
+	LastLogin       time.Time `json:"last_login"`
+}
+
+// UserInfo is a user without its secrets.
+type UserInfo struct {
+	Username    string    `json:"username"`
+	Role        string    `json:"role"`
+	Disabled    bool      `json:"disabled"`
+	TOTPEnabled bool      `json:"totpEnabled"`
+	LockedUntil time.Time `json:"lockedUntil"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastLogin   time.Time `json:"lastLogin"`
+	Sessions    int       `json:"sessions"`
+}
+
+// Session is a login. Only the hash of its token is stored.
+type Session struct {
+	ID         string    `json:"id"`
+	TokenHash  string    `json:"token_hash,omitempty"`
+	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	Source     string    `json:"source"`
+	RemoteAddr string    `json:"remote_addr"`
+	Created    time.Time `json:"created"`
+	LastSeen   time.Time `json:"last_seen"`
+	Expires    time.Time `json:"expires"`
+}
+
+type Options struct {
+	MaxFailedAttempts  int
+	LockoutDuration    time.Duration
+	SessionTTL         time.Duration
+	MaxSessionsPerUser int
+	// TOTPIssuer is shown in authenticator apps.
+	TOTPIssuer string
+	// Save persists the store, it's called with the lock held after every
+	// change.
+	Save   func(users []User, sessions []Session) error
+	Logger *zerolog.Logger
+
+	now func() time.Time
+}
+
+// Store keeps the users and their sessions.
+type Store struct {
+	lock     sync.Mutex
+	opts     Options
+	users    map[string]*User
+	sessions map[string]*Session // by token hash
+	l        *zerolog.Logger
+}
+
+func NewStore(opts Options, users []User, sessions []Session) *Store {
+	if opts.MaxFailedAttempts == 0 {
+		opts.MaxFailedAttempts = 5
+	}
+	if opts.LockoutDuration == 0 {
+		opts.LockoutDuration = 5 * time.Minute
+	}
+	if opts.SessionTTL == 0 {
+		opts.SessionTTL = 7 * 24 * time.Hour
+	}
+	if opts.MaxSessionsPerUser == 0 {
+		opts.MaxSessionsPerUser = 10
+	}
+	if opts.TOTPIssuer == "" {
+		opts.TOTPIssuer = "JetKVM"
+	}
+	if opts.Logger == nil {
+		opts.Logger = defaultLogger
+	}
+	if opts.now == nil {
+		opts.now = time.Now
+	}
+
+	s := &Store{
+		opts:     opts,
+		users:    make(map[string]*User),
+		sessions: make(map[string]*Session),
+		l:        opts.Logger,
+	}
+	for _, u := range users {
+		s.users[u.Username] = &u
+	}
+	for _, session := range sessions {
+		if session.TokenHash != "" {
+			s.sessions[session.TokenHash] = &session
+		}
+	}
+	return s
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func validRole(role string) bool {
+	return role == RoleAdmin || role == RoleUser
+}
+
+// ValidateUsername checks that username is lower case and safe to log.
+func ValidateUsername(username string) error {
+	if !usernameRegex.MatchString(username) {
+		return fmt.Errorf("invalid username: %q", username)
+	}
+	return nil
+}
+
+func (s *Store) saveLocked() error {
+	if s.opts.Save == nil {
+		return nil
+	}
+	users := make([]User, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, *u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	sessions := make([]Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Created.Before(sessions[j].Created) })
+	return s.opts.Save(users, sessions)
+}
+
+func (s *Store) info(u *User) UserInfo {
+	info := UserInfo{
+		Username:    u.Username,
+		Role:        u.Role,
+		Disabled:    u.Disabled,
+		TOTPEnabled: u.TOTPEnabled,
+		CreatedAt:   u.CreatedAt,
+		LastLogin:   u.LastLogin,
+	}
+	if s.opts.now().Before(u.LockedUntil) {
+		info.LockedUntil = u.LockedUntil
+	}
+	for _, session := range s.sessions {
+		if session.Source == SourceLocal && session.Username == u.Username {
+			info.Sessions++
+		}
+	}
+	return info
+}
+
+// Len returns the number of users.
+func (s *Store) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.users)
+}
+
+// Users returns all users sorted by name.
+func (s *Store) Users() []UserInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	users := make([]UserInfo, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, s.info(u))
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
+}
+
+// User returns the user username.
+func (s *Store) User(username string) (UserInfo, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return UserInfo{}, ErrUserNotFound
+	}
+	return s.info(u), nil
+}
+
+// AddUser creates a user with password.
+func (s *Store) AddUser(username, password, role string) error {
+	if password == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+	return s.AddUserWithHash(username, string(hash), role)
+}
+
+// AddUserWithHash creates a user with an existing bcrypt hash, e.g. the
+// device password of older versions.
+func (s *Store) AddUserWithHash(username, passwordHash, role string) error {
+	if err := ValidateUsername(username); err != nil {
+		return err
+	}
+	if !validRole(role) {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.users[username]; ok {
+		return ErrUserExists
+	}
+	s.users[username] = &User{
+		Username:     username,
+		PasswordHash: passwordHash,
+		Role:         role,
+		CreatedAt:    s.opts.now(),
+	}
+	s.l.Info().Str("username", username).Str("role", role).Msg("user created")
+	return s.saveLocked()
+}
+
+// enabledAdminsLocked counts the enabled admins, except the user except.
+func (s *Store) enabledAdminsLocked(except string) int {
+	n := 0
+	for _, u := range s.users {
+		if u.Username != except && u.Role == RoleAdmin && !u.Disabled {
+			n++
+		}
+	}
+	return n
+}
+
+// DeleteUser deletes a user and its sessions.
+func (s *Store) DeleteUser(username string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if u.Role == RoleAdmin && s.enabledAdminsLocked(username) == 0 {
+		return ErrLastAdmin
+	}
+	delete(s.users, username)
+	s.revokeUserLocked(username)
+	s.l.Info().Str("username", username).Msg("user deleted")
+	return s.saveLocked()
+}
+
+// Reset deletes all users and sessions.
+func (s *Store) Reset() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	clear(s.users)
+	clear(s.sessions)
+	s.l.Info().Msg("all users deleted")
+	return s.saveLocked()
+}
+
+// SetPassword changes the password of a user and ends its sessions.
+func (s *Store) SetPassword(username, password string) error {
+	if password == "" {
+		return fmt.Errorf("password cannot be empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	u.PasswordHash = string(hash)
+	s.revokeUserLocked(username)
+	s.l.Info().Str("username", username).Msg("password changed")
+	return s.saveLocked()
+}
+
+// SetRole changes the role of a user.
+func (s *Store) SetRole(username, role string) error {
+	if !validRole(role) {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if role != RoleAdmin && u.Role == RoleAdmin && !u.Disabled && s.enabledAdminsLocked(username) == 0 {
+		return ErrLastAdmin
+	}
+	u.Role = role
+	for _, session := range s.sessions {
+		if session.Source == SourceLocal && session.Username == username {
+			session.Role = role
+		}
+	}
+	s.l.Info().Str("username", username).Str("role", role).Msg("role changed")
+	return s.saveLocked()
+}
+
+// SetDisabled disables or enables a user, disabling ends its sessions.
+func (s *Store) SetDisabled(username string, disabled bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if disabled && u.Role == RoleAdmin && s.enabledAdminsLocked(username) == 0 {
+		return ErrLastAdmin
+	}
+	u.Disabled = disabled
+	if disabled {
+		s.revokeUserLocked(username)
+	}
+	s.l.Info().Str("username", username).Bool("disabled", disabled).Msg("user updated")
+	return s.saveLocked()
+}
+
+// Unlock clears the lockout of a user.
+func (s *Store) Unlock(username string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	u.FailedAttempts = 0
+	u.LockedUntil = time.Time{}
+	return s.saveLocked()
+}
+
+// failLocked records a failed login and locks the user after too many.
+func (s *Store) failLocked(u *User) {
+	u.FailedAttempts++
+	if u.FailedAttempts >= s.opts.MaxFailedAttempts {
+		u.FailedAttempts = 0
+		u.LockedUntil = s.opts.now().Add(s.opts.LockoutDuration)
+		s.l.Warn().Str("username", u.Username).Time("until", u.LockedUntil).Msg("user locked after failed logins")
+	}
+	if err := s.saveLocked(); err != nil {
+		s.l.Warn().Err(err).Msg("failed to save users")
+	}
+}
+
+// authenticate checks the password, and the code if requireTOTP is set and
+// the user has TOTP enabled. Failures count towards the lockout.
+func (s *Store) authenticate(username, password, code string, requireTOTP bool) (*UserInfo, error) {
+	s.lock.Lock()
+	u, ok := s.users[username]
+	var hash string
+	if ok {
+		if s.opts.now().Before(u.LockedUntil) {
+			until := u.LockedUntil
+			s.lock.Unlock()
+			return nil, fmt.Errorf("%w until %s", ErrLocked, until.Format(time.RFC3339))
+		}
+		hash = u.PasswordHash
+	}
+	s.lock.Unlock()
+
+	// bcrypt is slow, compare without holding the lock
+	if !ok {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	passwordErr := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the user may have been deleted meanwhile
+	if u, ok = s.users[username]; !ok {
+		return nil, ErrInvalidCredentials
+	}
+	if passwordErr != nil {
+		s.failLocked(u)
+		return nil, ErrInvalidCredentials
+	}
+	if u.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+	if requireTOTP && u.TOTPEnabled {
+		if code == "" {
+			return nil, ErrTOTPRequired
+		}
+		counter, valid := validateTOTP(u.TOTPSecret, code, s.opts.now(), u.TOTPLastCounter)
+		if !valid {
+			s.failLocked(u)
+			return nil, ErrInvalidTOTP
+		}
+		u.TOTPLastCounter = counter
+	}
+
+	u.FailedAttempts = 0
+	u.LastLogin = s.opts.now()
+	if err := s.saveLocked(); err != nil {
+		s.l.Warn().Err(err).Msg("failed to save users")
+	}
+	info := s.info(u)
+	return &info, nil
+}
+
+// Authenticate checks the credentials of a login.
+func (s *Store) Authenticate(username, password, code string) (*UserInfo, error) {
+	return s.authenticate(username, password, code, true)
+}
+
+// AuthenticateCombined is for protocols without a separate field for the
+// code, like HTTP basic auth. Users with TOTP append the code to the password.
+func (s *Store) AuthenticateCombined(username, passwordAndCode string) (*UserInfo, error) {
+	s.lock.Lock()
+	u, ok := s.users[username]
+	totp := ok && u.TOTPEnabled
+	s.lock.Unlock()
+
+	if !totp {
+		return s.authenticate(username, passwordAndCode, "", false)
+	}
+	if len(passwordAndCode) <= totpDigits {
+		return nil, ErrTOTPRequired
+	}
+	split := len(passwordAndCode) - totpDigits
+	return s.authenticate(username, passwordAndCode[:split], passwordAndCode[split:], true)
+}
+
+// VerifyPassword checks the password of a user without the code, for
+// sessions that already passed it, e.g. before disabling TOTP.
+func (s *Store) VerifyPassword(username, password string) error {
+	_, err := s.authenticate(username, password, "", false)
+	return err
+}
+
+// VerifyCredentials checks the password and, if the user has TOTP enabled,
+// the code, e.g. before changing the password.
+func (s *Store) VerifyCredentials(username, password, code string) error {
+	_, err := s.authenticate(username, password, code, true)
+	return err
+}
+
+// BeginTOTP generates a new secret for a user, it's used once EnableTOTP
+// confirms a code of it.
+func (s *Store) BeginTOTP(username string) (secret string, uri string, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return "", "", ErrUserNotFound
+	}
+	if u.TOTPEnabled {
+		return "", "", fmt.Errorf("two-factor authentication is already enabled")
+	}
+	u.TOTPSecret = GenerateTOTPSecret()
+	u.TOTPLastCounter = 0
+	if err := s.saveLocked(); err != nil {
+		return "", "", err
+	}
+	return u.TOTPSecret, TOTPURI(s.opts.TOTPIssuer, username, u.TOTPSecret), nil
+}
+
+// EnableTOTP enables TOTP for a user once code matches the secret of
+// BeginTOTP.
+func (s *Store) EnableTOTP(username, code string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if u.TOTPSecret == "" {
+		return fmt.Errorf("two-factor setup has not been started")
+	}
+	counter, valid := validateTOTP(u.TOTPSecret, code, s.opts.now(), u.TOTPLastCounter)
+	if !valid {
+		return ErrInvalidTOTP
+	}
+	u.TOTPEnabled = true
+	u.TOTPLastCounter = counter
+	s.l.Info().Str("username", username).Msg("two-factor authentication enabled")
+	return s.saveLocked()
+}
+
+// DisableTOTP turns TOTP off for a user.
+func (s *Store) DisableTOTP(username string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	u.TOTPEnabled = false
+	u.TOTPSecret = ""
+	u.TOTPLastCounter = 0
+	s.l.Info().Str("username", username).Msg("two-factor authentication disabled")
+	return s.saveLocked()
+}
+
+func (s *Store) expireLocked() {
+	now := s.opts.now()
+	for hash, session := range s.sessions {
+		if now.After(session.Expires) {
+			delete(s.sessions, hash)
+		}
+	}
+}
+
+func (s *Store) revokeUserLocked(username string) {
+	for hash, session := range s.sessions {
+		if session.Source == SourceLocal && session.Username == username {
+			delete(s.sessions, hash)
+		}
+	}
+}
+
+// CreateSession starts a session and returns its token. For local sessions
+// the role is the user's. The oldest sessions of a user are ended if it has
+// too many.
+func (s *Store) CreateSession(username, role, source, remoteAddr string) (string, Session, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if source == SourceLocal {
+		u, ok := s.users[username]
+		if !ok {
+			return "", Session{}, ErrUserNotFound
+		}
+		role = u.Role
+	}
+	if !validRole(role) {
+		return "", Session{}, fmt.Errorf("invalid role: %s", role)
+	}
+
+	s.expireLocked()
+	var existing []*Session
+	for _, session := range s.sessions {
+		if session.Source == source && session.Username == username {
+			existing = append(existing, session)
+		}
+	}
+	if len(existing) >= s.opts.MaxSessionsPerUser {
+		slices.SortFunc(existing, func(a, b *Session) int { return a.LastSeen.Compare(b.LastSeen) })
+		for _, session := range existing[:len(existing)-s.opts.MaxSessionsPerUser+1] {
+			delete(s.sessions, session.TokenHash)
+		}
+	}
+
+	now := s.opts.now()
+	token := randomToken(32)
+	session := &Session{
+		ID:         randomToken(9),
+		TokenHash:  hashToken(token),
+		Username:   username,
+		Role:       role,
+		Source:     source,
+		RemoteAddr: remoteAddr,
+		Created:    now,
+		LastSeen:   now,
+		Expires:    now.Add(s.opts.SessionTTL),
+	}
+	s.sessions[session.TokenHash] = session
+	if err := s.saveLocked(); err != nil {
+		return "", Session{}, err
+	}
+
+	public := *session
+	public.TokenHash = ""
+	return token, public, nil
+}
+
+// Session returns the session of token. Sessions of local users end when the
+// user is deleted or disabled.
+func (s *Store) Session(token string) (Session, bool) {
+	if token == "" {
+		return Session{}, false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	session, ok := s.sessions[hashToken(token)]
+	if !ok {
+		return Session{}, false
+	}
+	now := s.opts.now()
+	if now.After(session.Expires) {
+		delete(s.sessions, session.TokenHash)
+		return Session{}, false
+	}
+	if session.Source == SourceLocal {
+		if u, ok := s.users[session.Username]; !ok || u.Disabled {
+			delete(s.sessions, session.TokenHash)
+			return Session{}, false
+		}
+	}
+	session.LastSeen = now
+
+	public := *session
+	public.TokenHash = ""
+	return public, true
+}
+
+// DeleteSession ends the session of token, e.g. on logout.
+func (s *Store) DeleteSession(token string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.sessions, hashToken(token))
+	return s.saveLocked()
+}
+
+// RevokeSession ends the session with id.
+func (s *Store) RevokeSession(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for hash, session := range s.sessions {
+		if session.ID == id {
+			delete(s.sessions, hash)
+			return s.saveLocked()
+		}
+	}
+	return fmt.Errorf("session not found")
+}
+
+// RevokeUserSessions ends all sessions of a local user.
+func (s *Store) RevokeUserSessions(username string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.revokeUserLocked(username)
+	return s.saveLocked()
+}
+
+// Sessions returns the sessions of username, of everyone if it's empty.
+func (s *Store) Sessions(username string) []Session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expireLocked()
+	sessions := []Session{}
+	for _, session := range s.sessions {
+		if username != "" && session.Username != username {
+			continue
+		}
+		public := *session
+		public.TokenHash = ""
+		sessions = append(sessions, public)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Created.Before(sessions[j].Created) })
+	return sessions
+}
@@ -0,0 +1,219 @@
+package users
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time {
+	return c.t
+}
+
+func newTestStore(t *testing.T) (*Store, *testClock, *[]User) {
+	clock := &testClock{t: time.Unix(1700000000, 0)}
+	saved := &[]User{}
+	s := NewStore(Options{
+		MaxFailedAttempts:  3,
+		LockoutDuration:    time.Minute,
+		SessionTTL:         time.Hour,
+		MaxSessionsPerUser: 2,
+		Save: func(users []User, _ []Session) error {
+			*saved = users
+			return nil
+		},
+		now: clock.now,
+	}, nil, nil)
+	require.NoError(t, s.AddUser("admin", "secret", RoleAdmin))
+	return s, clock, saved
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 test vectors, truncated to 6 digits
+	key := []byte("12345678901234567890")
+	assert.Equal(t, "287082", totpCode(key, 59/30))
+	assert.Equal(t, "081804", totpCode(key, 1111111109/30))
+	assert.Equal(t, "050471", totpCode(key, 1111111111/30))
+
+	secret := totpEncoding.EncodeToString(key)
+	now := time.Unix(1111111109, 0)
+	counter, ok := validateTOTP(secret, "081804", now, 0)
+	assert.True(t, ok)
+	// late by one period is accepted, the same code twice isn't
+	_, ok = validateTOTP(secret, "081804", now.Add(30*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = validateTOTP(secret, "081804", now, counter)
+	assert.False(t, ok)
+	_, ok = validateTOTP(secret, "081804", now.Add(2*time.Minute), 0)
+	assert.False(t, ok)
+
+	uri := TOTPURI("JetKVM", "admin", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/JetKVM:admin?"))
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, clock, saved := newTestStore(t)
+	require.Len(t, *saved, 1)
+	assert.NotEqual(t, "secret", (*saved)[0].PasswordHash)
+
+	user, err := s.Authenticate("admin", "secret", "")
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, user.Role)
+
+	_, err = s.Authenticate("nobody", "secret", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// lockout after 3 failures, a correct password doesn't help
+	for range 3 {
+		_, err = s.Authenticate("admin", "wrong", "")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, err = s.Authenticate("admin", "secret", "")
+	assert.ErrorIs(t, err, ErrLocked)
+
+	clock.t = clock.t.Add(2 * time.Minute)
+	_, err = s.Authenticate("admin", "secret", "")
+	require.NoError(t, err)
+
+	require.NoError(t, s.AddUser("bob", "pw", RoleUser))
+	require.NoError(t, s.SetDisabled("bob", true))
+	_, err = s.Authenticate("bob", "pw", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestAuthenticateTOTP(t *testing.T) {
+	s, clock, _ := newTestStore(t)
+
+	secret, uri, err := s.BeginTOTP("admin")
+	require.NoError(t, err)
+	assert.Contains(t, uri, secret)
+	key, err := decodeTOTPSecret(secret)
+	require.NoError(t, err)
+	code := func() string { return totpCode(key, clock.t.Unix()/totpPeriod) }
+
+	assert.ErrorIs(t, s.EnableTOTP("admin", "000000"), ErrInvalidTOTP)
+	require.NoError(t, s.EnableTOTP("admin", code()))
+
+	_, err = s.Authenticate("admin", "secret", "")
+	assert.ErrorIs(t, err, ErrTOTPRequired)
+	// the code used to enable TOTP can't be used again
+	_, err = s.Authenticate("admin", "secret", code())
+	assert.ErrorIs(t, err, ErrInvalidTOTP)
+
+	clock.t = clock.t.Add(30 * time.Second)
+	_, err = s.Authenticate("admin", "secret", code())
+	require.NoError(t, err)
+
+	clock.t = clock.t.Add(30 * time.Second)
+	_, err = s.AuthenticateCombined("admin", "secret")
+	assert.Error(t, err)
+	_, err = s.AuthenticateCombined("admin", "secret"+code())
+	require.NoError(t, err)
+
+	// password checks in a session don't need the code, password changes do
+	require.NoError(t, s.VerifyPassword("admin", "secret"))
+	assert.ErrorIs(t, s.VerifyCredentials("admin", "secret", ""), ErrTOTPRequired)
+	clock.t = clock.t.Add(30 * time.Second)
+	require.NoError(t, s.VerifyCredentials("admin", "secret", code()))
+
+	require.NoError(t, s.DisableTOTP("admin"))
+	_, err = s.Authenticate("admin", "secret", "")
+	require.NoError(t, err)
+}
+
+func TestSessions(t *testing.T) {
+	s, clock, _ := newTestStore(t)
+	require.NoError(t, s.AddUser("bob", "pw", RoleUser))
+
+	token, session, err := s.CreateSession("bob", RoleAdmin, SourceLocal, "10.0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, RoleUser, session.Role, "local sessions have the user's role")
+	assert.Empty(t, session.TokenHash)
+
+	got, ok := s.Session(token)
+	require.True(t, ok)
+	assert.Equal(t, session.ID, got.ID)
+	_, ok = s.Session("other")
+	assert.False(t, ok)
+
+	// role changes apply to existing sessions
+	require.NoError(t, s.SetRole("bob", RoleAdmin))
+	got, _ = s.Session(token)
+	assert.Equal(t, RoleAdmin, got.Role)
+
+	// only two sessions per user, the oldest ends
+	clock.t = clock.t.Add(time.Second)
+	token2, _, err := s.CreateSession("bob", "", SourceLocal, "")
+	require.NoError(t, err)
+	clock.t = clock.t.Add(time.Second)
+	_, _, err = s.CreateSession("bob", "", SourceLocal, "")
+	require.NoError(t, err)
+	_, ok = s.Session(token)
+	assert.False(t, ok)
+	assert.Len(t, s.Sessions("bob"), 2)
+
+	require.NoError(t, s.SetPassword("bob", "new"))
+	_, ok = s.Session(token2)
+	assert.False(t, ok, "changing the password ends the sessions")
+
+	external, _, err := s.CreateSession("alice@example.com", RoleUser, "oidc", "")
+	require.NoError(t, err)
+	_, ok = s.Session(external)
+	assert.True(t, ok)
+	clock.t = clock.t.Add(2 * time.Hour)
+	_, ok = s.Session(external)
+	assert.False(t, ok, "sessions expire")
+
+	token, _, err = s.CreateSession("bob", "", SourceLocal, "")
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteUser("bob"))
+	_, ok = s.Session(token)
+	assert.False(t, ok)
+
+	_, _, err = s.CreateSession("nobody", "", SourceLocal, "")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestLastAdmin(t *testing.T) {
+	s, _, _ := newTestStore(t)
+
+	assert.ErrorIs(t, s.DeleteUser("admin"), ErrLastAdmin)
+	assert.ErrorIs(t, s.SetRole("admin", RoleUser), ErrLastAdmin)
+	assert.ErrorIs(t, s.SetDisabled("admin", true), ErrLastAdmin)
+
+	require.NoError(t, s.AddUser("root", "pw", RoleAdmin))
+	require.NoError(t, s.SetDisabled("admin", true))
+	assert.ErrorIs(t, s.DeleteUser("root"), ErrLastAdmin)
+
+	assert.ErrorIs(t, s.AddUser("root", "pw", RoleUser), ErrUserExists)
+	assert.Error(t, s.AddUser("Bad Name", "pw", RoleUser))
+	assert.Error(t, s.AddUser("carol", "pw", "owner"))
+	assert.True(t, errors.Is(s.SetPassword("nobody", "pw"), ErrUserNotFound))
+}
+
+func TestNewStoreRestores(t *testing.T) {
+	s, clock, saved := newTestStore(t)
+	var sessions []Session
+	s.opts.Save = func(u []User, sess []Session) error {
+		*saved = u
+		sessions = sess
+		return nil
+	}
+	token, _, err := s.CreateSession("admin", "", SourceLocal, "")
+	require.NoError(t, err)
+
+	restored := NewStore(Options{now: clock.now}, *saved, sessions)
+	_, ok := restored.Session(token)
+	assert.True(t, ok)
+	_, err = restored.Authenticate("admin", "secret", "")
+	assert.NoError(t, err)
+}
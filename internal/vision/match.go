@@ -0,0 +1,169 @@
+package vision
+
+import (
+	"errors"
+	"image"
+	"math"
+)
+
+const (
+	// coarseTemplateSize is the smallest template dimension the coarse search scales down to.
+	coarseTemplateSize = 6
+	maxCoarseFactor    = 8
+	// coarseCandidates is how many coarse positions are refined at full resolution.
+	coarseCandidates = 8
+)
+
+var (
+	ErrTemplateTooLarge = errors.New("template is larger than the screen")
+	ErrTemplateFlat     = errors.New("template has no contrast")
+)
+
+// ImageMatch is the position where a template fits the screen best. Score is
+// the normalized cross-correlation from -1 to 1, 1 being a perfect match.
+type ImageMatch struct {
+	Bounds image.Rectangle `json:"bounds"`
+	Score  float64         `json:"score"`
+}
+
+// matcher correlates a template with a screen of the same scale.
+type matcher struct {
+	screen *grayImage
+	in     *integral
+	// tpl is the template minus its mean
+	tpl   []float64
+	tw    int
+	th    int
+	tNorm float64
+}
+
+func newMatcher(screen, tpl *grayImage) (*matcher, error) {
+	m := &matcher{screen: screen, in: newIntegral(screen), tw: tpl.w, th: tpl.h}
+
+	mean := 0.0
+	for _, v := range tpl.pix {
+		mean += float64(v)
+	}
+	mean /= float64(len(tpl.pix))
+
+	m.tpl = make([]float64, len(tpl.pix))
+	for i, v := range tpl.pix {
+		m.tpl[i] = float64(v) - mean
+		m.tNorm += m.tpl[i] * m.tpl[i]
+	}
+	if m.tNorm < 1 {
+		return nil, ErrTemplateFlat
+	}
+	return m, nil
+}
+
+// score returns the correlation of the template placed at x, y.
+func (m *matcher) score(x, y int) float64 {
+	n := float64(m.tw * m.th)
+	sum, sq := m.in.rect(x, y, x+m.tw, y+m.th)
+	variance := sq - sum*sum/n
+	if variance < 1 {
+		// a flat area can't match a template with contrast
+		return 0
+	}
+
+	dot := 0.0
+	for ty := 0; ty < m.th; ty++ {
+		row := m.screen.pix[(y+ty)*m.screen.w+x:]
+		tpl := m.tpl[ty*m.tw:]
+		for tx := 0; tx < m.tw; tx++ {
+			dot += float64(row[tx]) * tpl[tx]
+		}
+	}
+	return dot / math.Sqrt(variance*m.tNorm)
+}
+
+type candidate struct {
+	x, y  int
+	score float64
+}
+
+// bestCandidates returns the highest scoring positions that are at least
+// radius apart.
+func bestCandidates(scores []float64, w, h, radius, count int) []candidate {
+	var result []candidate
+	for len(result) < count {
+		best := candidate{score: math.Inf(-1)}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if s := scores[y*w+x]; s > best.score {
+					best = candidate{x, y, s}
+				}
+			}
+		}
+		if math.IsInf(best.score, -1) {
+			break
+		}
+		result = append(result, best)
+
+		for y := max(0, best.y-radius); y < min(h, best.y+radius+1); y++ {
+			for x := max(0, best.x-radius); x < min(w, best.x+radius+1); x++ {
+				scores[y*w+x] = math.Inf(-1)
+			}
+		}
+	}
+	return result
+}
+
+// FindImage returns where tpl matches screen best. The search runs on scaled
+// down images first, then refines the best candidates at full resolution.
+func FindImage(screen, tpl image.Image) (ImageMatch, error) {
+	s, t := toGray(screen), toGray(tpl)
+	if t.w > s.w || t.h > s.h {
+		return ImageMatch{}, ErrTemplateTooLarge
+	}
+	if t.w == 0 || t.h == 0 {
+		return ImageMatch{}, ErrTemplateFlat
+	}
+
+	full, err := newMatcher(s, t)
+	if err != nil {
+		return ImageMatch{}, err
+	}
+
+	f := min(t.w, t.h) / coarseTemplateSize
+	f = max(1, min(f, maxCoarseFactor))
+
+	candidates := []candidate{{0, 0, 0}}
+	if f > 1 {
+		coarse, err := newMatcher(downscale(s, f), downscale(t, f))
+		if err == nil {
+			w, h := coarse.screen.w-coarse.tw+1, coarse.screen.h-coarse.th+1
+			scores := make([]float64, w*h)
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					scores[y*w+x] = coarse.score(x, y)
+				}
+			}
+			candidates = bestCandidates(scores, w, h, max(1, min(coarse.tw, coarse.th)/2), coarseCandidates)
+		} else {
+			// the template's detail is lost when scaled down
+			f = 1
+		}
+	}
+
+	best := ImageMatch{Score: math.Inf(-1)}
+	maxX, maxY := s.w-t.w, s.h-t.h
+	for _, c := range candidates {
+		x0, y0, x1, y1 := 0, 0, maxX, maxY
+		if f > 1 {
+			x0, y0 = max(0, c.x*f-f), max(0, c.y*f-f)
+			x1, y1 = min(maxX, c.x*f+f), min(maxY, c.y*f+f)
+		}
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				if score := full.score(x, y); score > best.Score {
+					best = ImageMatch{Bounds: image.Rect(x, y, x+t.w, y+t.h), Score: score}
+				}
+			}
+		}
+	}
+
+	best.Bounds = best.Bounds.Add(screen.Bounds().Min)
+	return best, nil
+}
@@ -0,0 +1,496 @@
+package vision
+
+import (
+	"image"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// inkContrast is how much a pixel must differ from its surroundings to be ink.
+	inkContrast = 40
+	// inkWindow is the radius of the window the surroundings are averaged over.
+	inkWindow = 10
+	// maxStrokeLength removes longer strokes, e.g. frames and dividers, before segmentation.
+	maxStrokeLength = 48
+
+	minLineHeight = 5
+	maxLineHeight = 80
+
+	// minGlyphScore is the score below which a glyph is reported as unknown.
+	minGlyphScore = 0.6
+
+	gridRows = 12
+	gridCols = 8
+	// samples per grid cell in each direction
+	gridSamples = 3
+)
+
+// UnknownChar is used for glyphs that don't match the font.
+const UnknownChar = '?'
+
+// Line is a line of recognized text.
+type Line struct {
+	Text   string          `json:"text"`
+	Bounds image.Rectangle `json:"bounds"`
+	// boxes are the bounds of each rune in Text, spaces cover the gap
+	boxes []image.Rectangle
+}
+
+// TextMatch is text found on the screen.
+type TextMatch struct {
+	Text     string          `json:"text"`
+	Line     string          `json:"line"`
+	Bounds   image.Rectangle `json:"bounds"`
+	Distance int             `json:"distance"`
+}
+
+// inkMask marks the pixels that are much darker (or lighter, for light text
+// on a dark background) than their surroundings.
+func inkMask(g *grayImage, in *integral, light bool) []bool {
+	mask := make([]bool, g.w*g.h)
+	for y := 0; y < g.h; y++ {
+		y0, y1 := max(0, y-inkWindow), min(g.h, y+inkWindow+1)
+		for x := 0; x < g.w; x++ {
+			x0, x1 := max(0, x-inkWindow), min(g.w, x+inkWindow+1)
+			sum, _ := in.rect(x0, y0, x1, y1)
+			diff := float64(g.pix[y*g.w+x]) - sum/float64((x1-x0)*(y1-y0))
+			if light {
+				mask[y*g.w+x] = diff > inkContrast
+			} else {
+				mask[y*g.w+x] = diff < -inkContrast
+			}
+		}
+	}
+
+	removeLongStrokes(mask, g.w, g.h)
+	return mask
+}
+
+// removeLongStrokes clears horizontal and vertical runs longer than any glyph,
+// otherwise frames around dialogs would join all lines into one.
+func removeLongStrokes(mask []bool, w, h int) {
+	var clear [][2]int
+
+	for y := 0; y < h; y++ {
+		start := -1
+		for x := 0; x <= w; x++ {
+			if x < w && mask[y*w+x] {
+				if start < 0 {
+					start = x
+				}
+				continue
+			}
+			if start >= 0 && x-start > maxStrokeLength {
+				for i := start; i < x; i++ {
+					clear = append(clear, [2]int{i, y})
+				}
+			}
+			start = -1
+		}
+	}
+
+	for x := 0; x < w; x++ {
+		start := -1
+		for y := 0; y <= h; y++ {
+			if y < h && mask[y*w+x] {
+				if start < 0 {
+					start = y
+				}
+				continue
+			}
+			if start >= 0 && y-start > maxStrokeLength {
+				for i := start; i < y; i++ {
+					clear = append(clear, [2]int{x, i})
+				}
+			}
+			start = -1
+		}
+	}
+
+	for _, p := range clear {
+		mask[p[1]*w+p[0]] = false
+	}
+}
+
+// runs returns the [start, end) ranges where counts is non-zero.
+func runs(counts []int) [][2]int {
+	var r [][2]int
+	start := -1
+	for i := 0; i <= len(counts); i++ {
+		if i < len(counts) && counts[i] > 0 {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			r = append(r, [2]int{start, i})
+			start = -1
+		}
+	}
+	return r
+}
+
+// hypothesis maps the rows of a text line to the rows of the font: a line may
+// or may not have capitals/ascenders (row 0) and descenders (row 7).
+type hypothesis struct {
+	top, bottom int
+}
+
+var hypotheses = []hypothesis{{0, 6}, {0, 7}, {2, 6}, {2, 7}}
+
+type template struct {
+	char   rune
+	aspect float64
+	grid   [gridRows * gridCols]float64
+}
+
+var (
+	templatesOnce sync.Once
+	// templates for each hypothesis
+	templates [][]template
+)
+
+func glyphBit(bitmap [8]byte, x, y int) bool {
+	return bitmap[y]&(1<<x) != 0
+}
+
+func buildTemplates() {
+	templates = make([][]template, len(hypotheses))
+	for i, h := range hypotheses {
+		for char, bitmap := range glyphs {
+			// ink columns within the rows of the hypothesis
+			x0, x1 := 8, 0
+			for y := h.top; y <= h.bottom; y++ {
+				for x := 0; x < 8; x++ {
+					if glyphBit(bitmap, x, y) {
+						x0, x1 = min(x0, x), max(x1, x+1)
+					}
+				}
+			}
+			if x0 >= x1 {
+				continue
+			}
+
+			rows := h.bottom - h.top + 1
+			t := template{char: char, aspect: float64(x1-x0) / float64(rows)}
+			t.grid = sampleGrid(func(x, y float64) bool {
+				return glyphBit(bitmap, x0+int(x*float64(x1-x0)), h.top+int(y*float64(rows)))
+			})
+			templates[i] = append(templates[i], t)
+		}
+		sort.Slice(templates[i], func(a, b int) bool { return templates[i][a].char < templates[i][b].char })
+	}
+}
+
+// sampleGrid returns the ink coverage of each grid cell, ink is called with
+// coordinates in [0, 1).
+func sampleGrid(ink func(x, y float64) bool) [gridRows * gridCols]float64 {
+	var grid [gridRows * gridCols]float64
+	for r := 0; r < gridRows; r++ {
+		for c := 0; c < gridCols; c++ {
+			hits := 0
+			for sy := 0; sy < gridSamples; sy++ {
+				for sx := 0; sx < gridSamples; sx++ {
+					x := (float64(c) + (float64(sx)+0.5)/gridSamples) / gridCols
+					y := (float64(r) + (float64(sy)+0.5)/gridSamples) / gridRows
+					if ink(x, y) {
+						hits++
+					}
+				}
+			}
+			grid[r*gridCols+c] = float64(hits) / (gridSamples * gridSamples)
+		}
+	}
+	return grid
+}
+
+// classify returns the best matching character for a glyph grid and its score in [0, 1].
+func classify(grid [gridRows * gridCols]float64, aspect float64, candidates []template) (rune, float64) {
+	best, bestScore := UnknownChar, 0.0
+	for i := range candidates {
+		t := &candidates[i]
+		diff := 0.0
+		for j := range grid {
+			diff += math.Abs(grid[j] - t.grid[j])
+		}
+		score := 1 - diff/float64(len(grid)) - math.Abs(aspect-t.aspect)*0.25
+		if score > bestScore {
+			best, bestScore = t.char, score
+		}
+	}
+	return best, bestScore
+}
+
+type glyphBox struct {
+	x0, x1 int
+}
+
+// recognizeLine classifies the glyphs of a line with the hypothesis that fits best.
+func recognizeLine(mask []bool, w int, y0, y1 int, boxes []glyphBox) ([]rune, float64) {
+	height := float64(y1 - y0)
+
+	grids := make([][gridRows * gridCols]float64, len(boxes))
+	aspects := make([]float64, len(boxes))
+	for i, b := range boxes {
+		width := float64(b.x1 - b.x0)
+		aspects[i] = width / height
+		grids[i] = sampleGrid(func(x, y float64) bool {
+			return mask[(y0+int(y*height))*w+b.x0+int(x*width)]
+		})
+	}
+
+	var bestChars []rune
+	bestScore := -1.0
+	for i := range hypotheses {
+		chars := make([]rune, len(boxes))
+		total := 0.0
+		for j := range boxes {
+			char, score := classify(grids[j], aspects[j], templates[i])
+			if score < minGlyphScore {
+				char = UnknownChar
+			}
+			chars[j] = char
+			total += score
+		}
+		if avg := total / float64(len(boxes)); avg > bestScore {
+			bestChars, bestScore = chars, avg
+		}
+	}
+	return bestChars, bestScore
+}
+
+// charPitch estimates the distance from one character to the next, words are
+// separated by at least one pitch more.
+func charPitch(boxes []glyphBox, height int) float64 {
+	var steps []int
+	for i := 1; i < len(boxes); i++ {
+		if step := boxes[i].x0 - boxes[i-1].x0; step*10 <= height*13 {
+			steps = append(steps, step)
+		}
+	}
+	if len(steps) == 0 {
+		// the font's cells are 8 pixels wide for 7 rows without descenders
+		return float64(height) * 8 / 7
+	}
+	sort.Ints(steps)
+	return float64(steps[len(steps)/2])
+}
+
+// recognizeMask returns the lines of text in mask and their scores.
+func recognizeMask(mask []bool, w, h int) ([]Line, []float64) {
+	rowInk := make([]int, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if mask[y*w+x] {
+				rowInk[y]++
+			}
+		}
+	}
+
+	var lines []Line
+	var scores []float64
+	for _, row := range runs(rowInk) {
+		y0, y1 := row[0], row[1]
+		height := y1 - y0
+		if height < minLineHeight || height > maxLineHeight {
+			continue
+		}
+
+		colInk := make([]int, w)
+		for y := y0; y < y1; y++ {
+			for x := 0; x < w; x++ {
+				if mask[y*w+x] {
+					colInk[x]++
+				}
+			}
+		}
+
+		var boxes []glyphBox
+		for _, col := range runs(colInk) {
+			// glyphs are never much wider than tall, anything else isn't text
+			if col[1]-col[0] > height*2 {
+				continue
+			}
+			boxes = append(boxes, glyphBox{col[0], col[1]})
+		}
+		if len(boxes) == 0 {
+			continue
+		}
+
+		chars, score := recognizeLine(mask, w, y0, y1, boxes)
+
+		var text []rune
+		var rects []image.Rectangle
+		pitch := charPitch(boxes, height)
+		for i, b := range boxes {
+			if i > 0 && math.Round(float64(b.x0-boxes[i-1].x0)/pitch) > 1 {
+				text = append(text, ' ')
+				rects = append(rects, image.Rect(boxes[i-1].x1, y0, b.x0, y1))
+			}
+			text = append(text, chars[i])
+			rects = append(rects, image.Rect(b.x0, y0, b.x1, y1))
+		}
+
+		lines = append(lines, Line{
+			Text:   string(text),
+			Bounds: image.Rect(boxes[0].x0, y0, boxes[len(boxes)-1].x1, y1),
+			boxes:  rects,
+		})
+		scores = append(scores, score)
+	}
+	return lines, scores
+}
+
+// Recognize returns the lines of text found in img, top to bottom.
+//
+// Dark and light text are recognized separately. Each also finds the
+// background around the other's glyphs, so where lines overlap the one that
+// matches the font better wins.
+func Recognize(img image.Image) []Line {
+	templatesOnce.Do(buildTemplates)
+
+	g := toGray(img)
+	in := newIntegral(g)
+
+	type scoredLine struct {
+		Line
+		score float64
+	}
+	var candidates []scoredLine
+	for _, light := range []bool{false, true} {
+		lines, scores := recognizeMask(inkMask(g, in, light), g.w, g.h)
+		for i := range lines {
+			candidates = append(candidates, scoredLine{lines[i], scores[i]})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	var lines []Line
+	for _, c := range candidates {
+		overlaps := false
+		for _, line := range lines {
+			if line.Bounds.Overlaps(c.Bounds) {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			lines = append(lines, c.Line)
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].Bounds.Min.Y != lines[j].Bounds.Min.Y {
+			return lines[i].Bounds.Min.Y < lines[j].Bounds.Min.Y
+		}
+		return lines[i].Bounds.Min.X < lines[j].Bounds.Min.X
+	})
+
+	origin := img.Bounds().Min
+	for i := range lines {
+		lines[i].Bounds = lines[i].Bounds.Add(origin)
+		for j := range lines[i].boxes {
+			lines[i].boxes[j] = lines[i].boxes[j].Add(origin)
+		}
+	}
+	return lines
+}
+
+func normalizeText(s string) []rune {
+	return []rune(strings.ToLower(strings.Join(strings.Fields(s), " ")))
+}
+
+// approximateMatch finds the substring of text closest to pattern by edit
+// distance, it returns the distance and the [start, end) of the substring.
+func approximateMatch(pattern, text []rune) (int, int, int) {
+	m, n := len(pattern), len(text)
+
+	// prev[j] is the distance of pattern[:i] to a substring of text ending at j,
+	// starts[j] is where that substring starts
+	prev, cur := make([]int, n+1), make([]int, n+1)
+	prevStart, curStart := make([]int, n+1), make([]int, n+1)
+	for j := 0; j <= n; j++ {
+		prevStart[j] = j
+	}
+
+	for i := 1; i <= m; i++ {
+		cur[0] = i
+		curStart[0] = 0
+		for j := 1; j <= n; j++ {
+			cost := 1
+			if pattern[i-1] == text[j-1] {
+				cost = 0
+			}
+			cur[j], curStart[j] = prev[j-1]+cost, prevStart[j-1]
+			if prev[j]+1 < cur[j] {
+				cur[j], curStart[j] = prev[j]+1, prevStart[j]
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j], curStart[j] = cur[j-1]+1, curStart[j-1]
+			}
+		}
+		prev, cur = cur, prev
+		prevStart, curStart = curStart, prevStart
+	}
+
+	best, end := m, 0
+	for j := 0; j <= n; j++ {
+		if prev[j] < best {
+			best, end = prev[j], j
+		}
+	}
+	return best, prevStart[end], end
+}
+
+// MaxDistance is the number of misrecognized characters FindText tolerates by default.
+func MaxDistance(text string) int {
+	return len(normalizeText(text)) / 5
+}
+
+// FindText searches the recognized lines for text, ignoring case and allowing
+// up to maxDistance wrong, missing or extra characters.
+func FindText(lines []Line, text string, maxDistance int) (TextMatch, bool) {
+	pattern := normalizeText(text)
+	if len(pattern) == 0 {
+		return TextMatch{}, false
+	}
+
+	var best TextMatch
+	found := false
+	for _, line := range lines {
+		lineText := []rune(strings.ToLower(line.Text))
+		distance, start, end := approximateMatch(pattern, lineText)
+		if distance > maxDistance || start >= end || (found && distance >= best.Distance) {
+			continue
+		}
+
+		bounds := line.boxes[start]
+		for _, r := range line.boxes[start+1 : end] {
+			bounds = bounds.Union(r)
+		}
+		best = TextMatch{
+			Text:     strings.TrimSpace(string([]rune(line.Text)[start:end])),
+			Line:     line.Text,
+			Bounds:   bounds,
+			Distance: distance,
+		}
+		found = true
+	}
+	return best, found
+}
+
+// Text returns the recognized lines as a single string.
+func Text(lines []Line) string {
+	var sb strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(strings.TrimRightFunc(line.Text, unicode.IsSpace))
+	}
+	return sb.String()
+}
@@ -0,0 +1,124 @@
+// Package vision finds text and images on screenshots of the host.
+//
+// It's pure Go and tuned for what a KVM sees while automating firmware and
+// installers: text recognition matches glyphs against a built-in 8x8 bitmap
+// font, which works well for the fixed width fonts of BIOS/UEFI setup screens
+// and text consoles, but not for proportional desktop fonts. Image matching
+// uses normalized cross-correlation and works for any screen content.
+package vision
+
+import (
+	"image"
+	"image/color"
+)
+
+// grayImage is an 8 bit luminance image with its origin at 0,0.
+type grayImage struct {
+	w, h int
+	pix  []uint8
+}
+
+func (g *grayImage) at(x, y int) uint8 {
+	return g.pix[y*g.w+x]
+}
+
+func luma(r, gr, b uint32) uint8 {
+	// ITU-R BT.601, inputs are 16 bit
+	return uint8((19595*r + 38470*gr + 7471*b + 1<<15) >> 24)
+}
+
+// toGray converts img, with fast paths for the types returned by the image decoders.
+func toGray(img image.Image) *grayImage {
+	b := img.Bounds()
+	g := &grayImage{w: b.Dx(), h: b.Dy(), pix: make([]uint8, b.Dx()*b.Dy())}
+
+	switch src := img.(type) {
+	case *image.Gray:
+		for y := 0; y < g.h; y++ {
+			copy(g.pix[y*g.w:(y+1)*g.w], src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):])
+		}
+	case *image.YCbCr:
+		for y := 0; y < g.h; y++ {
+			for x := 0; x < g.w; x++ {
+				g.pix[y*g.w+x] = src.Y[src.YOffset(b.Min.X+x, b.Min.Y+y)]
+			}
+		}
+	case *image.RGBA:
+		for y := 0; y < g.h; y++ {
+			row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+			for x := 0; x < g.w; x++ {
+				p := row[x*4:]
+				g.pix[y*g.w+x] = luma(uint32(p[0])*0x101, uint32(p[1])*0x101, uint32(p[2])*0x101)
+			}
+		}
+	case *image.NRGBA:
+		for y := 0; y < g.h; y++ {
+			row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+			for x := 0; x < g.w; x++ {
+				p := row[x*4:]
+				g.pix[y*g.w+x] = luma(uint32(p[0])*0x101, uint32(p[1])*0x101, uint32(p[2])*0x101)
+			}
+		}
+	default:
+		for y := 0; y < g.h; y++ {
+			for x := 0; x < g.w; x++ {
+				c := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray)
+				g.pix[y*g.w+x] = c.Y
+			}
+		}
+	}
+	return g
+}
+
+// integral is a summed-area table of a gray image and of its squares.
+type integral struct {
+	w, h int
+	sum  []float64
+	sq   []float64
+}
+
+func newIntegral(g *grayImage) *integral {
+	w, h := g.w+1, g.h+1
+	in := &integral{w: w, h: h, sum: make([]float64, w*h), sq: make([]float64, w*h)}
+	for y := 1; y < h; y++ {
+		var rowSum, rowSq float64
+		for x := 1; x < w; x++ {
+			v := float64(g.pix[(y-1)*g.w+x-1])
+			rowSum += v
+			rowSq += v * v
+			in.sum[y*w+x] = in.sum[(y-1)*w+x] + rowSum
+			in.sq[y*w+x] = in.sq[(y-1)*w+x] + rowSq
+		}
+	}
+	return in
+}
+
+// rect returns the sum and sum of squares of the pixels in [x0,x1)x[y0,y1).
+func (in *integral) rect(x0, y0, x1, y1 int) (float64, float64) {
+	a, b, c, d := y0*in.w+x0, y0*in.w+x1, y1*in.w+x0, y1*in.w+x1
+	return in.sum[d] - in.sum[b] - in.sum[c] + in.sum[a], in.sq[d] - in.sq[b] - in.sq[c] + in.sq[a]
+}
+
+// downscale shrinks g by an integer factor, averaging the pixels.
+func downscale(g *grayImage, f int) *grayImage {
+	if f <= 1 {
+		return g
+	}
+
+	out := &grayImage{w: g.w / f, h: g.h / f}
+	out.pix = make([]uint8, out.w*out.h)
+	n := f * f
+	for y := 0; y < out.h; y++ {
+		for x := 0; x < out.w; x++ {
+			sum := 0
+			for dy := 0; dy < f; dy++ {
+				row := g.pix[(y*f+dy)*g.w+x*f:]
+				for dx := 0; dx < f; dx++ {
+					sum += int(row[dx])
+				}
+			}
+			out.pix[y*out.w+x] = uint8(sum / n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package vision
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// drawText renders text with the glyph font at the given scale.
+func drawText(img *image.RGBA, x, y, scale int, text string, c color.Color) {
+	for i, char := range text {
+		bitmap := glyphs[char]
+		for gy := 0; gy < 8; gy++ {
+			for gx := 0; gx < 8; gx++ {
+				if !glyphBit(bitmap, gx, gy) {
+					continue
+				}
+				px, py := x+(i*8+gx)*scale, y+gy*scale
+				draw.Draw(img, image.Rect(px, py, px+scale, py+scale), image.NewUniform(c), image.Point{}, draw.Src)
+			}
+		}
+	}
+}
+
+func newScreen(w, h int, bg color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	return img
+}
+
+func TestRecognize(t *testing.T) {
+	screen := newScreen(640, 200, color.RGBA{0, 0, 170, 255})
+	// a frame around the text like BIOS setup screens draw
+	draw.Draw(screen, image.Rect(10, 10, 630, 12), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(screen, image.Rect(10, 10, 12, 190), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	drawText(screen, 30, 30, 2, "Boot Menu", color.White)
+	drawText(screen, 30, 70, 2, "Press F12 to continue", color.RGBA{255, 255, 0, 255})
+	drawText(screen, 30, 110, 3, "UEFI: Disk 1", color.White)
+
+	lines := Recognize(screen)
+	require.Len(t, lines, 3)
+	assert.Equal(t, "Boot Menu", lines[0].Text)
+	assert.Equal(t, "Press F12 to continue", lines[1].Text)
+	assert.Equal(t, "UEFI: Disk 1", lines[2].Text)
+	assert.Equal(t, "Boot Menu\nPress F12 to continue\nUEFI: Disk 1", Text(lines))
+
+	assert.Equal(t, 30, lines[0].Bounds.Min.X)
+	assert.Equal(t, 30, lines[0].Bounds.Min.Y)
+}
+
+func TestRecognizeSubImage(t *testing.T) {
+	screen := newScreen(400, 100, color.White)
+	drawText(screen, 20, 20, 2, "left", color.Black)
+	drawText(screen, 220, 20, 2, "right", color.Black)
+
+	lines := Recognize(screen.SubImage(image.Rect(200, 0, 400, 100)))
+	require.Len(t, lines, 1)
+	assert.Equal(t, "right", lines[0].Text)
+	assert.Equal(t, 220, lines[0].Bounds.Min.X)
+}
+
+func TestFindText(t *testing.T) {
+	screen := newScreen(640, 120, color.Black)
+	drawText(screen, 16, 16, 2, "Install Ubuntu Server", color.White)
+	drawText(screen, 16, 64, 2, "Try or Install", color.White)
+	lines := Recognize(screen)
+
+	match, ok := FindText(lines, "ubuntu  server", MaxDistance("ubuntu server"))
+	require.True(t, ok)
+	assert.Equal(t, "Ubuntu Server", match.Text)
+	assert.Equal(t, "Install Ubuntu Server", match.Line)
+	assert.Equal(t, 0, match.Distance)
+	assert.Equal(t, image.Rect(16+8*8*2, 16, 16+21*8*2-2, 30), match.Bounds)
+
+	match, ok = FindText(lines, "Ubuntu Serwer", 1)
+	require.True(t, ok)
+	assert.Equal(t, 1, match.Distance)
+
+	_, ok = FindText(lines, "Windows", MaxDistance("Windows"))
+	assert.False(t, ok)
+	_, ok = FindText(lines, " ", 0)
+	assert.False(t, ok)
+}
+
+func TestApproximateMatch(t *testing.T) {
+	distance, start, end := approximateMatch([]rune("abc"), []rune("xxabcxx"))
+	assert.Equal(t, 0, distance)
+	assert.Equal(t, 2, start)
+	assert.Equal(t, 5, end)
+
+	distance, start, end = approximateMatch([]rune("abcd"), []rune("xabdx"))
+	assert.Equal(t, 1, distance)
+	assert.Equal(t, "abd", string([]rune("xabdx")[start:end]))
+}
+
+func TestFindImage(t *testing.T) {
+	// a desktop of random overlapping windows and icons
+	rng := rand.New(rand.NewSource(1))
+	screen := image.NewGray(image.Rect(0, 0, 320, 240))
+	for i := 0; i < 200; i++ {
+		x, y := rng.Intn(320), rng.Intn(240)
+		r := image.Rect(x, y, x+4+rng.Intn(40), y+4+rng.Intn(30))
+		draw.Draw(screen, r, image.NewUniform(color.Gray{uint8(rng.Intn(256))}), image.Point{}, draw.Src)
+	}
+	// an icon in the corner
+	for i := 0; i < 10; i++ {
+		screen.SetGray(i, i, color.Gray{255})
+		screen.SetGray(9-i, i, color.Gray{0})
+	}
+
+	for _, r := range []image.Rectangle{
+		image.Rect(100, 50, 148, 90),
+		image.Rect(0, 0, 10, 10),
+		image.Rect(271, 203, 320, 240),
+	} {
+		tpl := image.NewGray(image.Rect(0, 0, r.Dx(), r.Dy()))
+		draw.Draw(tpl, tpl.Bounds(), screen, r.Min, draw.Src)
+
+		match, err := FindImage(screen, tpl)
+		require.NoError(t, err)
+		assert.Equal(t, r, match.Bounds)
+		assert.InDelta(t, 1, match.Score, 0.001)
+	}
+
+	_, err := FindImage(screen.SubImage(image.Rect(0, 0, 10, 10)), screen)
+	assert.ErrorIs(t, err, ErrTemplateTooLarge)
+
+	_, err = FindImage(screen, image.NewGray(image.Rect(0, 0, 10, 10)))
+	assert.ErrorIs(t, err, ErrTemplateFlat)
+}
@@ -0,0 +1,466 @@
+package websecure
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/acme"
+)
+
+const (
+	ACMEChallengeHTTP01 = "http-01"
+	ACMEChallengeDNS01  = "dns-01"
+
+	// ACMEChallengePath is where HTTP-01 challenges are served, followed by the token.
+	ACMEChallengePath = "/.well-known/acme-challenge/"
+
+	acmeAccountKeyFile = "acme-account.key"
+)
+
+// ACME issuer statuses.
+const (
+	ACMEStatusIdle    = "idle"
+	ACMEStatusIssuing = "issuing"
+	ACMEStatusValid   = "valid"
+	ACMEStatusError   = "error"
+)
+
+// DNSProvider publishes the TXT records of DNS-01 challenges. fqdn is the
+// record name with a trailing dot, e.g. _acme-challenge.kvm.example.com.
+type DNSProvider interface {
+	Present(ctx context.Context, fqdn, value string) error
+	CleanUp(ctx context.Context, fqdn, value string) error
+}
+
+type ACMEOptions struct {
+	// DirectoryURL is the ACME directory of the CA.
+	DirectoryURL string
+	Email        string
+	Domains      []string
+	// Challenge is ACMEChallengeHTTP01 or ACMEChallengeDNS01.
+	Challenge   string
+	DNSProvider DNSProvider
+	// DNSPropagation is how long to wait after presenting a TXT record.
+	DNSPropagation time.Duration
+	// RenewBefore renews the certificate this long before it expires, by
+	// default when a third of its lifetime is left.
+	RenewBefore time.Duration
+	// CertificateName is the name the certificate is stored under.
+	CertificateName string
+	// HTTPClient talks to the CA, e.g. with the root of a private CA.
+	HTTPClient *http.Client
+	Logger     *zerolog.Logger
+}
+
+// ACMEState is the state of an ACMEIssuer.
+type ACMEState struct {
+	Status      string     `json:"status"`
+	Domains     []string   `json:"domains"`
+	Issuer      string     `json:"issuer,omitempty"`
+	NotBefore   *time.Time `json:"notBefore,omitempty"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+	RenewAt     *time.Time `json:"renewAt,omitempty"`
+	LastAttempt *time.Time `json:"lastAttempt,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+// ACMEIssuer obtains and renews a certificate from an ACME CA and stores it
+// in a CertStore.
+type ACMEIssuer struct {
+	store *CertStore
+	opts  ACMEOptions
+	log   *zerolog.Logger
+
+	lock        sync.Mutex
+	client      *acme.Client
+	tokens      map[string]string
+	status      string
+	lastAttempt time.Time
+	lastError   error
+}
+
+func NewACMEIssuer(store *CertStore, opts ACMEOptions) (*ACMEIssuer, error) {
+	if opts.DirectoryURL == "" {
+		return nil, fmt.Errorf("ACME directory URL is required")
+	}
+	if len(opts.Domains) == 0 {
+		return nil, fmt.Errorf("at least one domain is required")
+	}
+	switch opts.Challenge {
+	case "":
+		opts.Challenge = ACMEChallengeHTTP01
+	case ACMEChallengeHTTP01:
+	case ACMEChallengeDNS01:
+		if opts.DNSProvider == nil {
+			return nil, fmt.Errorf("dns-01 challenge requires a DNS provider")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported ACME challenge: %s", opts.Challenge)
+	}
+	if opts.CertificateName == "" {
+		opts.CertificateName = "acme"
+	}
+	if opts.Logger == nil {
+		opts.Logger = store.log
+	}
+
+	return &ACMEIssuer{
+		store:  store,
+		opts:   opts,
+		log:    opts.Logger,
+		tokens: make(map[string]string),
+		status: ACMEStatusIdle,
+	}, nil
+}
+
+// Certificate returns the issued certificate, nil if there's none yet.
+func (a *ACMEIssuer) Certificate() *tls.Certificate {
+	return a.store.GetCertificate(a.opts.CertificateName)
+}
+
+// HTTPChallengeResponse returns the key authorization for an HTTP-01 token.
+func (a *ACMEIssuer) HTTPChallengeResponse(token string) (string, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	resp, ok := a.tokens[token]
+	return resp, ok
+}
+
+// certificateMatches reports whether cert covers all configured domains.
+func (a *ACMEIssuer) certificateMatches(cert *tls.Certificate) bool {
+	if cert == nil || cert.Leaf == nil {
+		return false
+	}
+	for _, domain := range a.opts.Domains {
+		if !strings.HasPrefix(domain, "*.") {
+			if cert.Leaf.VerifyHostname(domain) != nil {
+				return false
+			}
+			continue
+		}
+		found := false
+		for _, name := range cert.Leaf.DNSNames {
+			if name == domain {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// renewAt returns when cert needs to be renewed.
+func (a *ACMEIssuer) renewAt(cert *tls.Certificate) time.Time {
+	leaf := cert.Leaf
+	before := a.opts.RenewBefore
+	if before <= 0 {
+		before = leaf.NotAfter.Sub(leaf.NotBefore) / 3
+	}
+	return leaf.NotAfter.Add(-before)
+}
+
+// NeedsRenewal reports whether a certificate has to be obtained, because
+// there's none, it doesn't match the domains or it's due for renewal.
+func (a *ACMEIssuer) NeedsRenewal(now time.Time) bool {
+	cert := a.Certificate()
+	if !a.certificateMatches(cert) {
+		return true
+	}
+	return !now.Before(a.renewAt(cert))
+}
+
+func (a *ACMEIssuer) State() ACMEState {
+	a.lock.Lock()
+	state := ACMEState{
+		Status:  a.status,
+		Domains: append([]string{}, a.opts.Domains...),
+	}
+	if !a.lastAttempt.IsZero() {
+		t := a.lastAttempt
+		state.LastAttempt = &t
+	}
+	if a.lastError != nil {
+		state.LastError = a.lastError.Error()
+	}
+	a.lock.Unlock()
+
+	cert := a.Certificate()
+	if cert != nil && cert.Leaf != nil {
+		notBefore, notAfter, renewAt := cert.Leaf.NotBefore, cert.Leaf.NotAfter, a.renewAt(cert)
+		state.Issuer = cert.Leaf.Issuer.CommonName
+		state.NotBefore = &notBefore
+		state.NotAfter = &notAfter
+		state.RenewAt = &renewAt
+		if state.Status == ACMEStatusIdle && a.certificateMatches(cert) {
+			state.Status = ACMEStatusValid
+		}
+	}
+	return state
+}
+
+func (a *ACMEIssuer) setStatus(status string, err error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.status = status
+	if status == ACMEStatusIssuing {
+		a.lastAttempt = time.Now().UTC()
+	}
+	if status != ACMEStatusIssuing {
+		a.lastError = err
+	}
+}
+
+// accountKey loads the account key from the store, creating it on first use.
+func (a *ACMEIssuer) accountKey() (crypto.Signer, error) {
+	if err := a.store.ensureStorePath(); err != nil {
+		return nil, err
+	}
+	keyFile := path.Join(a.store.storePath, acmeAccountKeyFile)
+
+	data, err := os.ReadFile(keyFile)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("invalid ACME account key")
+		}
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read ACME account key: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ACME account key: %w", err)
+	}
+	if err := keyToFile(&tls.Certificate{PrivateKey: key}, keyFile); err != nil {
+		return nil, err
+	}
+	a.log.Info().Msg("created ACME account key")
+	return key, nil
+}
+
+func (a *ACMEIssuer) acmeClient(ctx context.Context) (*acme.Client, error) {
+	a.lock.Lock()
+	client := a.client
+	a.lock.Unlock()
+	if client != nil {
+		return client, nil
+	}
+
+	key, err := a.accountKey()
+	if err != nil {
+		return nil, err
+	}
+	client = &acme.Client{
+		Key:          key,
+		DirectoryURL: a.opts.DirectoryURL,
+		HTTPClient:   a.opts.HTTPClient,
+		UserAgent:    "jetkvm",
+	}
+
+	account := &acme.Account{}
+	if a.opts.Email != "" {
+		account.Contact = []string{"mailto:" + a.opts.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, fmt.Errorf("failed to register ACME account: %w", err)
+	}
+
+	a.lock.Lock()
+	a.client = client
+	a.lock.Unlock()
+	return client, nil
+}
+
+// authorize fulfills a single authorization with the configured challenge.
+func (a *ACMEIssuer) authorize(ctx context.Context, client *acme.Client, authzURL string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return fmt.Errorf("failed to get authorization: %w", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == a.opts.Challenge {
+			challenge = c
+			break
+		}
+	}
+	domain := authz.Identifier.Value
+	if challenge == nil {
+		return fmt.Errorf("CA offers no %s challenge for %s", a.opts.Challenge, domain)
+	}
+
+	switch a.opts.Challenge {
+	case ACMEChallengeHTTP01:
+		resp, err := client.HTTP01ChallengeResponse(challenge.Token)
+		if err != nil {
+			return err
+		}
+		a.lock.Lock()
+		a.tokens[challenge.Token] = resp
+		a.lock.Unlock()
+		defer func() {
+			a.lock.Lock()
+			delete(a.tokens, challenge.Token)
+			a.lock.Unlock()
+		}()
+	case ACMEChallengeDNS01:
+		value, err := client.DNS01ChallengeRecord(challenge.Token)
+		if err != nil {
+			return err
+		}
+		fqdn := "_acme-challenge." + strings.TrimPrefix(domain, "*.") + "."
+		if err := a.opts.DNSProvider.Present(ctx, fqdn, value); err != nil {
+			return fmt.Errorf("failed to present DNS record: %w", err)
+		}
+		defer func() {
+			if err := a.opts.DNSProvider.CleanUp(context.Background(), fqdn, value); err != nil {
+				a.log.Warn().Err(err).Str("fqdn", fqdn).Msg("failed to clean up DNS record")
+			}
+		}()
+		if a.opts.DNSPropagation > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(a.opts.DNSPropagation):
+			}
+		}
+	}
+
+	a.log.Info().Str("domain", domain).Str("challenge", a.opts.Challenge).Msg("accepting ACME challenge")
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("failed to accept challenge: %w", err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("authorization of %s failed: %w", domain, err)
+	}
+	return nil
+}
+
+// Obtain orders a certificate for the configured domains and stores it.
+func (a *ACMEIssuer) Obtain(ctx context.Context) (err error) {
+	a.setStatus(ACMEStatusIssuing, nil)
+	defer func() {
+		if err != nil {
+			a.setStatus(ACMEStatusError, err)
+			return
+		}
+		a.setStatus(ACMEStatusIdle, nil)
+	}()
+
+	client, err := a.acmeClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(a.opts.Domains...))
+	if err != nil {
+		return fmt.Errorf("failed to create order: %w", err)
+	}
+	for _, authzURL := range order.AuthzURLs {
+		if err := a.authorize(ctx, client, authzURL); err != nil {
+			return err
+		}
+	}
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return fmt.Errorf("order failed: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate private key: %w", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: strings.TrimPrefix(a.opts.Domains[0], "*.")},
+		DNSNames: a.opts.Domains,
+	}, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate request: %w", err)
+	}
+	der, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return fmt.Errorf("failed to finalize order: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	name := a.opts.CertificateName
+	a.store.certLock.Lock()
+	a.store.certificates[name] = &tls.Certificate{
+		Certificate: der,
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	a.store.saveCertificate(name)
+	a.store.certLock.Unlock()
+
+	a.log.Info().
+		Strs("domains", a.opts.Domains).
+		Time("not_after", leaf.NotAfter).
+		Msg("obtained ACME certificate")
+	return nil
+}
+
+// Run obtains the certificate when needed and renews it until ctx is done.
+// Failed attempts are retried with a backoff of up to an hour.
+func (a *ACMEIssuer) Run(ctx context.Context) {
+	const (
+		minRetry = time.Minute
+		maxRetry = time.Hour
+		maxSleep = time.Hour
+	)
+	retry := minRetry
+
+	for {
+		wait := maxSleep
+		if a.NeedsRenewal(time.Now()) {
+			if err := a.Obtain(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				a.log.Warn().Err(err).Dur("retry", retry).Msg("failed to obtain ACME certificate")
+				wait = retry
+				retry = min(retry*2, maxRetry)
+			} else {
+				retry = minRetry
+			}
+		}
+		if cert := a.Certificate(); wait == maxSleep && a.certificateMatches(cert) {
+			wait = min(max(time.Until(a.renewAt(cert)), time.Second), maxSleep)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
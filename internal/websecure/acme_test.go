@@ -0,0 +1,186 @@
+package websecure
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// testLeafCertificate returns a self-signed certificate for dnsNames.
+func testLeafCertificate(t *testing.T, notBefore, notAfter time.Time, dnsNames ...string) *tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestNewACMEIssuer(t *testing.T) {
+	store := NewCertStore(t.TempDir(), nil)
+
+	tests := []struct {
+		name    string
+		opts    ACMEOptions
+		wantErr bool
+	}{
+		{name: "no directory", opts: ACMEOptions{Domains: []string{"kvm.test"}}, wantErr: true},
+		{name: "no domains", opts: ACMEOptions{DirectoryURL: "https://ca.test/dir"}, wantErr: true},
+		{name: "dns without provider", opts: ACMEOptions{DirectoryURL: "https://ca.test/dir", Domains: []string{"kvm.test"}, Challenge: ACMEChallengeDNS01}, wantErr: true},
+		{name: "unknown challenge", opts: ACMEOptions{DirectoryURL: "https://ca.test/dir", Domains: []string{"kvm.test"}, Challenge: "tls-alpn-01"}, wantErr: true},
+		{name: "http", opts: ACMEOptions{DirectoryURL: "https://ca.test/dir", Domains: []string{"kvm.test"}}},
+		{name: "dns", opts: ACMEOptions{DirectoryURL: "https://ca.test/dir", Domains: []string{"*.kvm.test"}, Challenge: ACMEChallengeDNS01, DNSProvider: NewDNSWebhook("http://dns.test", "")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewACMEIssuer(store, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewACMEIssuer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestACMENeedsRenewal(t *testing.T) {
+	store := NewCertStore(t.TempDir(), nil)
+	issuer, err := NewACMEIssuer(store, ACMEOptions{
+		DirectoryURL: "https://ca.test/dir",
+		Domains:      []string{"kvm.test", "*.kvm.test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if !issuer.NeedsRenewal(now) {
+		t.Error("expected renewal without a certificate")
+	}
+	if state := issuer.State(); state.Status != ACMEStatusIdle || state.NotAfter != nil {
+		t.Errorf("unexpected state without a certificate: %+v", state)
+	}
+
+	// a 90 day certificate is renewed after 60 days
+	store.certificates["acme"] = testLeafCertificate(t, now, now.Add(90*24*time.Hour), "kvm.test", "*.kvm.test")
+	if issuer.NeedsRenewal(now.Add(59 * 24 * time.Hour)) {
+		t.Error("expected no renewal after 59 days")
+	}
+	if !issuer.NeedsRenewal(now.Add(61 * 24 * time.Hour)) {
+		t.Error("expected renewal after 61 days")
+	}
+	state := issuer.State()
+	if state.Status != ACMEStatusValid || state.RenewAt == nil || state.Issuer != "kvm.test" {
+		t.Errorf("unexpected state: %+v", state)
+	}
+
+	// a certificate for other domains is replaced right away
+	store.certificates["acme"] = testLeafCertificate(t, now, now.Add(90*24*time.Hour), "kvm.test")
+	if !issuer.NeedsRenewal(now) {
+		t.Error("expected renewal of a certificate missing a domain")
+	}
+}
+
+func TestDNSWebhook(t *testing.T) {
+	var got []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		got = append(got, body)
+	}))
+	defer server.Close()
+
+	provider := NewDNSWebhook(server.URL, "secret")
+	ctx := context.Background()
+	if err := provider.Present(ctx, "_acme-challenge.kvm.test.", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.CleanUp(ctx, "_acme-challenge.kvm.test.", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0]["action"] != "present" || got[1]["action"] != "cleanup" || got[0]["fqdn"] != "_acme-challenge.kvm.test." || got[0]["value"] != "abc" {
+		t.Errorf("unexpected webhook calls: %v", got)
+	}
+
+	if err := NewDNSWebhook(server.URL, "wrong").Present(ctx, "_acme-challenge.kvm.test.", "abc"); err == nil {
+		t.Error("expected an error for a rejected call")
+	}
+}
+
+// TestACMEPebble obtains a certificate from a local pebble instance. Run
+// pebble with PEBBLE_VA_ALWAYS_VALID=1 and set JETKVM_PEBBLE_DIRECTORY, e.g.
+// to https://localhost:14000/dir.
+func TestACMEPebble(t *testing.T) {
+	directory := os.Getenv("JETKVM_PEBBLE_DIRECTORY")
+	if directory == "" {
+		t.Skip("JETKVM_PEBBLE_DIRECTORY not set")
+	}
+
+	store := NewCertStore(t.TempDir(), nil)
+	issuer, err := NewACMEIssuer(store, ACMEOptions{
+		DirectoryURL: directory,
+		Email:        "admin@kvm.test",
+		Domains:      []string{"kvm.test"},
+		HTTPClient: &http.Client{Transport: &http.Transport{
+			// pebble serves its directory with a test certificate
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := issuer.Obtain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if issuer.NeedsRenewal(time.Now()) {
+		t.Error("expected a fresh certificate")
+	}
+	if state := issuer.State(); state.Status != ACMEStatusValid {
+		t.Errorf("unexpected state: %+v", state)
+	}
+
+	// the certificate and the account survive a restart
+	reloaded := NewCertStore(store.storePath, nil)
+	reloaded.LoadCertificates()
+	if reloaded.GetCertificate("acme") == nil {
+		t.Error("certificate wasn't saved")
+	}
+	if _, err := os.Stat(store.storePath + "/" + acmeAccountKeyFile); err != nil {
+		t.Errorf("account key wasn't saved: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package websecure
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// Client certificate rule fields.
+const (
+	ClientCertFieldSubject = "subject"
+	ClientCertFieldCN      = "cn"
+	ClientCertFieldDNS     = "dns"
+	ClientCertFieldEmail   = "email"
+	ClientCertFieldURI     = "uri"
+	ClientCertFieldIP      = "ip"
+)
+
+// ClientCertRule allows client certificates whose Field matches Pattern. In
+// patterns * matches any characters, the match is case insensitive.
+type ClientCertRule struct {
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+}
+
+type compiledClientCertRule struct {
+	field string
+	re    *regexp.Regexp
+}
+
+// ClientAuthenticator verifies client certificates against a CA bundle and
+// allow rules. A certificate signed by the CA is allowed if it matches any
+// rule, or if there are no rules.
+type ClientAuthenticator struct {
+	pool     *x509.CertPool
+	rules    []compiledClientCertRule
+	required bool
+	log      *zerolog.Logger
+}
+
+func NewClientAuthenticator(caBundle string, rules []ClientCertRule, required bool, log *zerolog.Logger) (*ClientAuthenticator, error) {
+	if log == nil {
+		log = &defaultLogger
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(caBundle)) {
+		return nil, fmt.Errorf("CA bundle contains no certificates")
+	}
+
+	a := &ClientAuthenticator{pool: pool, required: required, log: log}
+	for _, rule := range rules {
+		switch rule.Field {
+		case ClientCertFieldSubject, ClientCertFieldCN, ClientCertFieldDNS, ClientCertFieldEmail, ClientCertFieldURI, ClientCertFieldIP:
+		default:
+			return nil, fmt.Errorf("invalid rule field: %s", rule.Field)
+		}
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("rule pattern cannot be empty")
+		}
+		pattern := strings.ReplaceAll(regexp.QuoteMeta(rule.Pattern), `\*`, ".*")
+		a.rules = append(a.rules, compiledClientCertRule{
+			field: rule.Field,
+			re:    regexp.MustCompile("(?i)^" + pattern + "$"),
+		})
+	}
+	return a, nil
+}
+
+// ClientCertSubject returns the subject of cert for logging.
+func ClientCertSubject(cert *x509.Certificate) string {
+	return cert.Subject.String()
+}
+
+func clientCertValues(cert *x509.Certificate, field string) []string {
+	switch field {
+	case ClientCertFieldSubject:
+		return []string{cert.Subject.String()}
+	case ClientCertFieldCN:
+		return []string{cert.Subject.CommonName}
+	case ClientCertFieldDNS:
+		return cert.DNSNames
+	case ClientCertFieldEmail:
+		return cert.EmailAddresses
+	case ClientCertFieldURI:
+		values := make([]string, 0, len(cert.URIs))
+		for _, u := range cert.URIs {
+			values = append(values, u.String())
+		}
+		return values
+	case ClientCertFieldIP:
+		values := make([]string, 0, len(cert.IPAddresses))
+		for _, ip := range cert.IPAddresses {
+			values = append(values, ip.String())
+		}
+		return values
+	}
+	return nil
+}
+
+// Allowed checks cert, which has been verified against the CA bundle, against the rules.
+func (a *ClientAuthenticator) Allowed(cert *x509.Certificate) error {
+	if len(a.rules) == 0 {
+		return nil
+	}
+	for _, rule := range a.rules {
+		for _, value := range clientCertValues(cert, rule.field) {
+			if rule.re.MatchString(value) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("client certificate %s is not allowed", ClientCertSubject(cert))
+}
+
+// Required tells if clients without a certificate are rejected.
+func (a *ClientAuthenticator) Required() bool {
+	return a.required
+}
+
+// Verify returns the allowed client certificate of a connection, nil if the
+// client didn't present a verified one.
+func (a *ClientAuthenticator) Verify(state *tls.ConnectionState) *x509.Certificate {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := state.PeerCertificates[0]
+	if a.Allowed(cert) != nil {
+		return nil
+	}
+	return cert
+}
+
+// TLSConfig returns a copy of base asking for client certificates. A client
+// presenting a certificate that isn't allowed is rejected, without one it's
+// only rejected if certificates are required. remote is used for logging.
+func (a *ClientAuthenticator) TLSConfig(base *tls.Config, remote string) *tls.Config {
+	c := base.Clone()
+	c.ClientCAs = a.pool
+	c.ClientAuth = tls.VerifyClientCertIfGiven
+	if a.required {
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	c.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return nil
+		}
+		cert := state.PeerCertificates[0]
+		if err := a.Allowed(cert); err != nil {
+			a.log.Warn().Str("remote", remote).Str("subject", ClientCertSubject(cert)).Msg("rejected client certificate")
+			return err
+		}
+		a.log.Info().Str("remote", remote).Str("subject", ClientCertSubject(cert)).Msg("client certificate accepted")
+		return nil
+	}
+	return c
+}
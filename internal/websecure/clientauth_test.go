@@ -0,0 +1,189 @@
+package websecure
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	}
+}
+
+func (ca *testCA) issue(t *testing.T, template *x509.Certificate) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestClientAuthenticatorRules(t *testing.T) {
+	ca := newTestCA(t)
+	spiffe, _ := url.Parse("spiffe://corp.example.com/ops/alice")
+
+	alice := ca.issue(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "alice", Organization: []string{"Ops"}},
+		EmailAddresses: []string{"alice@corp.example.com"},
+		URIs:           []*url.URL{spiffe},
+	}).Leaf
+	bob := ca.issue(t, &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "bob"},
+		DNSNames: []string{"bob.lab.example.com"},
+	}).Leaf
+
+	tests := []struct {
+		name  string
+		rules []ClientCertRule
+		alice bool
+		bob   bool
+	}{
+		{name: "no rules", alice: true, bob: true},
+		{name: "cn", rules: []ClientCertRule{{Field: ClientCertFieldCN, Pattern: "ALICE"}}, alice: true},
+		{name: "subject", rules: []ClientCertRule{{Field: ClientCertFieldSubject, Pattern: "*O=Ops"}}, alice: true},
+		{name: "email", rules: []ClientCertRule{{Field: ClientCertFieldEmail, Pattern: "*@corp.example.com"}}, alice: true},
+		{name: "uri", rules: []ClientCertRule{{Field: ClientCertFieldURI, Pattern: "spiffe://corp.example.com/ops/*"}}, alice: true},
+		{name: "dns", rules: []ClientCertRule{{Field: ClientCertFieldDNS, Pattern: "*.lab.example.com"}}, bob: true},
+		{name: "any rule", rules: []ClientCertRule{
+			{Field: ClientCertFieldCN, Pattern: "alice"},
+			{Field: ClientCertFieldCN, Pattern: "bob"},
+		}, alice: true, bob: true},
+		{name: "no match", rules: []ClientCertRule{{Field: ClientCertFieldIP, Pattern: "10.*"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewClientAuthenticator(ca.pem, tt.rules, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.Allowed(alice) == nil; got != tt.alice {
+				t.Errorf("alice allowed = %v, want %v", got, tt.alice)
+			}
+			if got := a.Allowed(bob) == nil; got != tt.bob {
+				t.Errorf("bob allowed = %v, want %v", got, tt.bob)
+			}
+		})
+	}
+
+	if _, err := NewClientAuthenticator("not a pem", nil, false, nil); err == nil {
+		t.Error("expected an error for an invalid CA bundle")
+	}
+	if _, err := NewClientAuthenticator(ca.pem, []ClientCertRule{{Field: "serial", Pattern: "1"}}, false, nil); err == nil {
+		t.Error("expected an error for an invalid rule field")
+	}
+}
+
+func TestClientAuthenticatorTLS(t *testing.T) {
+	ca := newTestCA(t)
+	alice := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}})
+	mallory := ca.issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "mallory"}})
+	other := newTestCA(t).issue(t, &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}})
+
+	rules := []ClientCertRule{{Field: ClientCertFieldCN, Pattern: "alice"}}
+	for _, required := range []bool{false, true} {
+		a, err := NewClientAuthenticator(ca.pem, rules, required, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cert := a.Verify(r.TLS); cert != nil {
+				_, _ = io.WriteString(w, cert.Subject.CommonName)
+				return
+			}
+			_, _ = io.WriteString(w, "anonymous")
+		}))
+		server.TLS = a.TLSConfig(&tls.Config{}, "test")
+		server.StartTLS()
+
+		get := func(cert *tls.Certificate) (string, error) {
+			transport := server.Client().Transport.(*http.Transport).Clone()
+			if cert != nil {
+				transport.TLSClientConfig.Certificates = []tls.Certificate{*cert}
+			}
+			resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+			if err != nil {
+				return "", err
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			return string(body), err
+		}
+
+		if body, err := get(&alice); err != nil || body != "alice" {
+			t.Errorf("required=%v: alice got %q, %v", required, body, err)
+		}
+		if _, err := get(&mallory); err == nil {
+			t.Errorf("required=%v: expected mallory to be rejected", required)
+		}
+		if _, err := get(&other); err == nil {
+			t.Errorf("required=%v: expected a certificate of another CA to be rejected", required)
+		}
+		body, err := get(nil)
+		if required && err == nil {
+			t.Error("expected a connection without certificate to be rejected")
+		}
+		if !required && (err != nil || body != "anonymous") {
+			t.Errorf("expected an anonymous connection, got %q, %v", body, err)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,70 @@
+package websecure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DNSWebhook is a DNSProvider that hands the TXT records to a webhook, which
+// updates the DNS server. It POSTs
+//
+//	{"action":"present","fqdn":"_acme-challenge.kvm.example.com.","value":"..."}
+//
+// and the same with "cleanup" once the challenge is done. A 2xx status means
+// the record was handled.
+type DNSWebhook struct {
+	URL string
+	// Token is sent as a bearer token if set.
+	Token  string
+	Client *http.Client
+}
+
+func NewDNSWebhook(url, token string) *DNSWebhook {
+	return &DNSWebhook{
+		URL:    url,
+		Token:  token,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (d *DNSWebhook) call(ctx context.Context, action, fqdn, value string) error {
+	body, err := json.Marshal(map[string]string{
+		"action": action,
+		"fqdn":   fqdn,
+		"value":  value,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+d.Token)
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call DNS webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("DNS webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func (d *DNSWebhook) Present(ctx context.Context, fqdn, value string) error {
+	return d.call(ctx, "present", fqdn, value)
+}
+
+func (d *DNSWebhook) CleanUp(ctx context.Context, fqdn, value string) error {
+	return d.call(ctx, "cleanup", fqdn, value)
+}
@@ -2,6 +2,8 @@ package websecure
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"os"
 	"path"
@@ -112,6 +114,68 @@ func (s *CertStore) GetCertificate(hostname string) *tls.Certificate {
 	return s.certificates[hostname]
 }
 
+// CertificateFiles returns the certificate and key files of the given hostname
+// returns false if the certificate is not found
+func (s *CertStore) CertificateFiles(hostname string) (crtFile string, keyFile string, ok bool) {
+	if s.GetCertificate(hostname) == nil {
+		return "", "", false
+	}
+	return path.Join(s.storePath, hostname+".crt"), path.Join(s.storePath, hostname+".key"), true
+}
+
+// CABundleFile returns the CA bundle file of the given name
+// returns an empty string if the bundle is not found
+func (s *CertStore) CABundleFile(name string) string {
+	caFile := path.Join(s.storePath, name+".ca.pem")
+	if _, err := os.Stat(caFile); err != nil {
+		return ""
+	}
+	return caFile
+}
+
+// ValidateAndSaveCABundle validates the PEM encoded CA certificates and saves them to the store,
+// the bundle is removed if it's empty
+func (s *CertStore) ValidateAndSaveCABundle(name string, bundle string) error {
+	caFile := path.Join(s.storePath, name+".ca.pem")
+	if strings.TrimSpace(bundle) == "" {
+		if err := os.Remove(caFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove CA bundle: %w", err)
+		}
+		s.log.Info().Str("name", name).Msg("Removed CA bundle")
+		return nil
+	}
+
+	rest := []byte(bundle)
+	count := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return fmt.Errorf("unexpected PEM block %s in CA bundle", block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return fmt.Errorf("failed to parse CA certificate: %w", err)
+		}
+		count++
+	}
+	if count == 0 {
+		return fmt.Errorf("no certificates found in CA bundle")
+	}
+
+	if err := s.ensureStorePath(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(caFile, []byte(bundle), 0644); err != nil {
+		return fmt.Errorf("failed to save CA bundle: %w", err)
+	}
+
+	s.log.Info().Str("name", name).Int("certificates", count).Msg("Saved CA bundle")
+	return nil
+}
+
 // ValidateAndSaveCertificate validates the certificate and saves it to the store
 // returns are:
 // - error: if the certificate is invalid or if there's any error during saving the certificate
@@ -0,0 +1,46 @@
+package websecure
+
+import (
+	"testing"
+)
+
+func TestSaveCABundle(t *testing.T) {
+	if err := certStore.ValidateAndSaveCABundle("ca-test", "not a certificate"); err == nil {
+		t.Fatal("expected an error for an invalid bundle")
+	}
+	if err := certStore.ValidateAndSaveCABundle("ca-test", fixtureEd25519PrivateKey); err == nil {
+		t.Fatal("expected an error for a private key")
+	}
+	if certStore.CABundleFile("ca-test") != "" {
+		t.Fatal("invalid bundle should not be saved")
+	}
+
+	if err := certStore.ValidateAndSaveCABundle("ca-test", fixtureEd25519Certificate); err != nil {
+		t.Fatalf("failed to save CA bundle: %v", err)
+	}
+	if certStore.CABundleFile("ca-test") == "" {
+		t.Fatal("expected the CA bundle file")
+	}
+
+	if err := certStore.ValidateAndSaveCABundle("ca-test", ""); err != nil {
+		t.Fatalf("failed to remove CA bundle: %v", err)
+	}
+	if certStore.CABundleFile("ca-test") != "" {
+		t.Fatal("CA bundle should be removed")
+	}
+}
+
+func TestCertificateFiles(t *testing.T) {
+	if _, _, ok := certStore.CertificateFiles("missing.jetkvm.com"); ok {
+		t.Fatal("expected no files for a missing certificate")
+	}
+
+	err, _ := certStore.ValidateAndSaveCertificate("files-test.jetkvm.com", fixtureEd25519Certificate, fixtureEd25519PrivateKey, true)
+	if err != nil {
+		t.Fatalf("failed to save certificate: %v", err)
+	}
+	crtFile, keyFile, ok := certStore.CertificateFiles("files-test.jetkvm.com")
+	if !ok || crtFile == "" || keyFile == "" {
+		t.Fatal("expected the certificate files")
+	}
+}
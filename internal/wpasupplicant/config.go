@@ -0,0 +1,94 @@
+package wpasupplicant
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	MethodTLS  = "tls"
+	MethodPEAP = "peap"
+)
+
+// Config are the 802.1X credentials, the file paths point to PEM files.
+type Config struct {
+	Method            string
+	Identity          string
+	AnonymousIdentity string
+	// Password is the PEAP-MSCHAPv2 password.
+	Password string
+	// CACertFile verifies the authentication server, it isn't verified
+	// without it.
+	CACertFile string
+	// ClientCertFile and PrivateKeyFile are the EAP-TLS client certificate.
+	ClientCertFile    string
+	PrivateKeyFile    string
+	DomainSuffixMatch string
+}
+
+func (c *Config) Validate() error {
+	switch c.Method {
+	case MethodTLS:
+		if c.ClientCertFile == "" || c.PrivateKeyFile == "" {
+			return fmt.Errorf("EAP-TLS needs a client certificate")
+		}
+	case MethodPEAP:
+		if c.Password == "" {
+			return fmt.Errorf("PEAP needs a password")
+		}
+	default:
+		return fmt.Errorf("unsupported EAP method: %s", c.Method)
+	}
+	if c.Identity == "" {
+		return fmt.Errorf("identity is required")
+	}
+	for _, file := range []string{c.CACertFile, c.ClientCertFile, c.PrivateKeyFile} {
+		if strings.ContainsAny(file, "\"\n") {
+			return fmt.Errorf("invalid file name: %s", file)
+		}
+	}
+	return nil
+}
+
+// hexString encodes a value for the config, wpa_supplicant reads strings
+// as hex when they aren't quoted, which saves escaping.
+func hexString(s string) string {
+	return hex.EncodeToString([]byte(s))
+}
+
+// Render returns the wpa_supplicant.conf for a wired interface.
+func (c *Config) Render(ctrlDir string) (string, error) {
+	if err := c.Validate(); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "ctrl_interface=%s\n", ctrlDir)
+	b.WriteString("ap_scan=0\n")
+	b.WriteString("network={\n")
+	b.WriteString("\tkey_mgmt=IEEE8021X\n")
+	b.WriteString("\teapol_flags=0\n")
+	fmt.Fprintf(&b, "\tidentity=%s\n", hexString(c.Identity))
+	if c.AnonymousIdentity != "" {
+		fmt.Fprintf(&b, "\tanonymous_identity=%s\n", hexString(c.AnonymousIdentity))
+	}
+	switch c.Method {
+	case MethodTLS:
+		b.WriteString("\teap=TLS\n")
+		fmt.Fprintf(&b, "\tclient_cert=\"%s\"\n", c.ClientCertFile)
+		fmt.Fprintf(&b, "\tprivate_key=\"%s\"\n", c.PrivateKeyFile)
+	case MethodPEAP:
+		b.WriteString("\teap=PEAP\n")
+		fmt.Fprintf(&b, "\tpassword=%s\n", hexString(c.Password))
+		b.WriteString("\tphase2=\"auth=MSCHAPV2\"\n")
+	}
+	if c.CACertFile != "" {
+		fmt.Fprintf(&b, "\tca_cert=\"%s\"\n", c.CACertFile)
+	}
+	if c.DomainSuffixMatch != "" {
+		fmt.Fprintf(&b, "\tdomain_suffix_match=%s\n", hexString(c.DomainSuffixMatch))
+	}
+	b.WriteString("}\n")
+	return b.String(), nil
+}
@@ -0,0 +1,317 @@
+// Package wpasupplicant runs wpa_supplicant to authenticate a wired
+// interface with 802.1X, and reports the state read from its control socket.
+package wpasupplicant
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	ConfigFile = "/run/wpa_supplicant.%s.conf"
+	CtrlDir    = "/run/wpa_supplicant"
+
+	pollInterval   = 2 * time.Second
+	restartDelay   = 5 * time.Second
+	commandTimeout = 2 * time.Second
+)
+
+const (
+	StatusStopped       = "stopped"
+	StatusConnecting    = "connecting"
+	StatusAuthenticated = "authenticated"
+	StatusFailed        = "failed"
+)
+
+// State is the authentication state of the interface.
+type State struct {
+	Status string `json:"status"`
+	// PAEState is the IEEE 802.1X supplicant PAE state, e.g. CONNECTING,
+	// AUTHENTICATING, AUTHENTICATED or HELD.
+	PAEState   string `json:"pae_state,omitempty"`
+	EAPState   string `json:"eap_state,omitempty"`
+	PortStatus string `json:"port_status,omitempty"`
+	// Method is the EAP method selected by the server, e.g. 25 (EAP-PEAP).
+	Method            string     `json:"method,omitempty"`
+	LastAuthenticated *time.Time `json:"last_authenticated,omitempty"`
+	Error             string     `json:"error,omitempty"`
+}
+
+type Supplicant struct {
+	InterfaceName string
+	binary        string
+	configFile    string
+	ctrlDir       string
+	logger        *zerolog.Logger
+	onStateChange func(state State)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	state  State
+	lock   sync.Mutex
+}
+
+type SupplicantOptions struct {
+	InterfaceName string
+	// Binary is the wpa_supplicant executable, looked up in PATH.
+	Binary        string
+	Logger        *zerolog.Logger
+	OnStateChange func(state State)
+}
+
+var defaultLogger = zerolog.New(os.Stdout).Level(zerolog.InfoLevel)
+
+func NewSupplicant(options *SupplicantOptions) *Supplicant {
+	if options.Logger == nil {
+		options.Logger = &defaultLogger
+	}
+	if options.Binary == "" {
+		options.Binary = "wpa_supplicant"
+	}
+
+	l := options.Logger.With().Str("interface", options.InterfaceName).Logger()
+	return &Supplicant{
+		InterfaceName: options.InterfaceName,
+		binary:        options.Binary,
+		configFile:    fmt.Sprintf(ConfigFile, options.InterfaceName),
+		ctrlDir:       CtrlDir,
+		logger:        &l,
+		onStateChange: options.OnStateChange,
+		state:         State{Status: StatusStopped},
+	}
+}
+
+// Start writes the config and (re)starts wpa_supplicant, it's restarted
+// when it exits until Stop is called.
+func (s *Supplicant) Start(config Config) error {
+	data, err := config.Render(s.ctrlDir)
+	if err != nil {
+		return err
+	}
+
+	s.Stop()
+
+	if err := os.MkdirAll(filepath.Dir(s.configFile), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	// the config holds the password, keep it readable by root only
+	if err := os.WriteFile(s.configFile, []byte(data), 0600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	s.lock.Lock()
+	s.cancel = cancel
+	s.done = done
+	s.lock.Unlock()
+
+	s.setState(State{Status: StatusConnecting})
+	go s.run(ctx, done)
+	return nil
+}
+
+// Stop stops wpa_supplicant and removes the config.
+func (s *Supplicant) Stop() {
+	s.lock.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.lock.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+
+	if err := os.Remove(s.configFile); err != nil && !os.IsNotExist(err) {
+		s.logger.Warn().Err(err).Msg("failed to remove wpa_supplicant config")
+	}
+	s.setState(State{Status: StatusStopped})
+}
+
+// Reauthenticate starts a new authentication, e.g. after the link came back.
+func (s *Supplicant) Reauthenticate() error {
+	reply, err := s.command("REAUTHENTICATE")
+	if err != nil {
+		return err
+	}
+	if reply != "OK" {
+		return fmt.Errorf("REAUTHENTICATE failed: %s", reply)
+	}
+	return nil
+}
+
+func (s *Supplicant) State() State {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.state
+}
+
+func (s *Supplicant) setState(state State) {
+	s.lock.Lock()
+	old := s.state
+	if state.Status == StatusAuthenticated {
+		if old.Status == StatusAuthenticated {
+			state.LastAuthenticated = old.LastAuthenticated
+		} else {
+			now := time.Now()
+			state.LastAuthenticated = &now
+		}
+	} else if state.LastAuthenticated == nil {
+		state.LastAuthenticated = old.LastAuthenticated
+	}
+	s.state = state
+	s.lock.Unlock()
+
+	if old.Status == state.Status && old.PAEState == state.PAEState && old.EAPState == state.EAPState && old.Error == state.Error {
+		return
+	}
+	s.logger.Info().
+		Str("status", state.Status).
+		Str("pae_state", state.PAEState).
+		Str("eap_state", state.EAPState).
+		Str("error", state.Error).
+		Msg("802.1X state changed")
+	if s.onStateChange != nil {
+		s.onStateChange(state)
+	}
+}
+
+func (s *Supplicant) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		err := s.runOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Warn().Err(err).Msg("wpa_supplicant exited, restarting")
+		s.setState(State{Status: StatusFailed, Error: fmt.Sprintf("wpa_supplicant exited: %v", err)})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(restartDelay):
+		}
+	}
+}
+
+func (s *Supplicant) runOnce(ctx context.Context) error {
+	// a stale socket from a previous run would make wpa_supplicant fail
+	_ = os.Remove(s.ctrlSocket())
+
+	cmd := exec.CommandContext(ctx, s.binary,
+		"-D", "wired",
+		"-i", s.InterfaceName,
+		"-c", s.configFile,
+	)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start wpa_supplicant: %w", err)
+	}
+	s.logger.Info().Int("pid", cmd.Process.Pid).Msg("wpa_supplicant started")
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-exited:
+			return err
+		case <-ticker.C:
+			reply, err := s.command("STATUS")
+			if err != nil {
+				s.logger.Debug().Err(err).Msg("failed to read wpa_supplicant status")
+				continue
+			}
+			s.setState(parseStatus(reply))
+		}
+	}
+}
+
+func (s *Supplicant) ctrlSocket() string {
+	return filepath.Join(s.ctrlDir, s.InterfaceName)
+}
+
+// command sends a command to the control socket and returns the reply.
+func (s *Supplicant) command(cmd string) (string, error) {
+	local, err := os.CreateTemp("", "jetkvm-wpa-ctrl-*")
+	if err != nil {
+		return "", err
+	}
+	localPath := local.Name()
+	local.Close()
+	_ = os.Remove(localPath)
+	defer os.Remove(localPath)
+
+	conn, err := net.DialUnix("unixgram",
+		&net.UnixAddr{Name: localPath, Net: "unixgram"},
+		&net.UnixAddr{Name: s.ctrlSocket(), Net: "unixgram"},
+	)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(commandTimeout)); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write([]byte(cmd)); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		// skip unsolicited event messages, they start with <level>
+		if n > 0 && buf[0] == '<' {
+			continue
+		}
+		return strings.TrimSpace(string(buf[:n])), nil
+	}
+}
+
+// parseStatus parses the reply of the STATUS command.
+func parseStatus(reply string) State {
+	state := State{Status: StatusConnecting}
+	for _, line := range strings.Split(reply, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Supplicant PAE state":
+			state.PAEState = value
+		case "suppPortStatus":
+			state.PortStatus = value
+		case "EAP state":
+			state.EAPState = value
+		case "selectedMethod":
+			state.Method = value
+		}
+	}
+
+	switch {
+	case state.PortStatus == "Authorized":
+		state.Status = StatusAuthenticated
+	case state.PAEState == "HELD" || state.EAPState == "FAILURE":
+		state.Status = StatusFailed
+		state.Error = "authentication rejected"
+	}
+	return state
+}
@@ -0,0 +1,91 @@
+package wpasupplicant
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTLS(t *testing.T) {
+	c := Config{
+		Method:            MethodTLS,
+		Identity:          "kvm01",
+		CACertFile:        "/userdata/jetkvm/tls/ieee8021x-ca.ca.pem",
+		ClientCertFile:    "/userdata/jetkvm/tls/ieee8021x-client.crt",
+		PrivateKeyFile:    "/userdata/jetkvm/tls/ieee8021x-client.key",
+		DomainSuffixMatch: "radius.example.com",
+	}
+	data, err := c.Render(CtrlDir)
+	require.NoError(t, err)
+	assert.Equal(t, "ctrl_interface=/run/wpa_supplicant\n"+
+		"ap_scan=0\n"+
+		"network={\n"+
+		"\tkey_mgmt=IEEE8021X\n"+
+		"\teapol_flags=0\n"+
+		"\tidentity=6b766d3031\n"+
+		"\teap=TLS\n"+
+		"\tclient_cert=\"/userdata/jetkvm/tls/ieee8021x-client.crt\"\n"+
+		"\tprivate_key=\"/userdata/jetkvm/tls/ieee8021x-client.key\"\n"+
+		"\tca_cert=\"/userdata/jetkvm/tls/ieee8021x-ca.ca.pem\"\n"+
+		"\tdomain_suffix_match=7261646975732e6578616d706c652e636f6d\n"+
+		"}\n", data)
+}
+
+func TestRenderPEAP(t *testing.T) {
+	c := Config{
+		Method:            MethodPEAP,
+		Identity:          "kvm01",
+		AnonymousIdentity: "anonymous",
+		Password:          "p\"w\n",
+	}
+	data, err := c.Render(CtrlDir)
+	require.NoError(t, err)
+	assert.Contains(t, data, "\teap=PEAP\n")
+	assert.Contains(t, data, "\tanonymous_identity=616e6f6e796d6f7573\n")
+	assert.Contains(t, data, "\tpassword=7022770a\n", "quotes and newlines are hex encoded")
+	assert.Contains(t, data, "\tphase2=\"auth=MSCHAPV2\"\n")
+	assert.NotContains(t, data, "ca_cert")
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]Config{
+		"method":      {Method: "md5", Identity: "kvm01"},
+		"identity":    {Method: MethodPEAP, Password: "secret"},
+		"password":    {Method: MethodPEAP, Identity: "kvm01"},
+		"certificate": {Method: MethodTLS, Identity: "kvm01"},
+		"file name":   {Method: MethodPEAP, Identity: "kvm01", Password: "secret", CACertFile: "/ca\".pem"},
+	}
+	for name, c := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, c.Validate())
+		})
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	state := parseStatus("bssid=01:80:c2:00:00:03\n" +
+		"freq=0\n" +
+		"ssid=\n" +
+		"id=0\n" +
+		"mode=station\n" +
+		"pairwise_cipher=NONE\n" +
+		"group_cipher=NONE\n" +
+		"key_mgmt=IEEE 802.1X (no WPA)\n" +
+		"wpa_state=COMPLETED\n" +
+		"Supplicant PAE state=AUTHENTICATED\n" +
+		"suppPortStatus=Authorized\n" +
+		"EAP state=SUCCESS\n" +
+		"selectedMethod=25 (EAP-PEAP)\n")
+	assert.Equal(t, StatusAuthenticated, state.Status)
+	assert.Equal(t, "AUTHENTICATED", state.PAEState)
+	assert.Equal(t, "SUCCESS", state.EAPState)
+	assert.Equal(t, "25 (EAP-PEAP)", state.Method)
+
+	state = parseStatus("Supplicant PAE state=HELD\nsuppPortStatus=Unauthorized\nEAP state=FAILURE\n")
+	assert.Equal(t, StatusFailed, state.Status)
+	assert.NotEmpty(t, state.Error)
+
+	state = parseStatus("Supplicant PAE state=CONNECTING\nsuppPortStatus=Unauthorized\nEAP state=IDLE\n")
+	assert.Equal(t, StatusConnecting, state.Status)
+}
@@ -18,7 +18,9 @@ import (
 	"github.com/rs/zerolog"
 	"go.bug.st/serial"
 
+	"github.com/jetkvm/kvm/internal/extension"
 	"github.com/jetkvm/kvm/internal/hidrpc"
+	"github.com/jetkvm/kvm/internal/keyboardlayout"
 	"github.com/jetkvm/kvm/internal/usbgadget"
 	"github.com/jetkvm/kvm/internal/utils"
 )
@@ -138,6 +140,23 @@ func onRPCMessage(message webrtc.DataChannelMessage, session *Session) {
 		return
 	}
 
+	if handler.AdminOnly {
+		if err := requireAdmin(session); err != nil {
+			scopedLogger.Warn().Err(err).Msg("RPC request refused")
+			errorResponse := JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error: map[string]any{
+					"code":    -32603,
+					"message": "Internal error",
+					"data":    err.Error(),
+				},
+				ID: request.ID,
+			}
+			writeJSONRPCResponse(errorResponse, session)
+			return
+		}
+	}
+
 	result, err := callRPCHandler(scopedLogger, handler, request.Params)
 	if err != nil {
 		scopedLogger.Error().Err(err).Msg("Error calling RPC handler")
@@ -504,6 +523,9 @@ func rpcSetTLSState(state TLSState) error {
 type RPCHandler struct {
 	Func   any
 	Params []string
+	// AdminOnly refuses the method to users without the admin role, for
+	// methods that change the device or read its secrets.
+	AdminOnly bool
 }
 
 // call the handler but recover from a panic to ensure our RPC thread doesn't collapse on malformed calls
@@ -721,6 +743,9 @@ func rpcSetWakeOnLanDevices(params SetWakeOnLanDevicesParams) error {
 
 func rpcResetConfig() error {
 	config = defaultConfig
+	if err := getUserStore().Reset(); err != nil {
+		return fmt.Errorf("failed to reset users: %w", err)
+	}
 	if err := SaveConfig(); err != nil {
 		return fmt.Errorf("failed to reset config: %w", err)
 	}
@@ -729,16 +754,8 @@ func rpcResetConfig() error {
 	return nil
 }
 
-type DCPowerState struct {
-	IsOn         bool    `json:"isOn"`
-	Voltage      float64 `json:"voltage"`
-	Current      float64 `json:"current"`
-	Power        float64 `json:"power"`
-	RestoreState int     `json:"restoreState"`
-}
-
 func rpcGetDCPowerState() (DCPowerState, error) {
-	return dcState, nil
+	return dcExtension.DCPowerState(), nil
 }
 
 func rpcSetDCPowerState(enabled bool) error {
@@ -763,25 +780,46 @@ func rpcGetActiveExtension() (string, error) {
 	return config.ActiveExtension, nil
 }
 
+// rpcSetActiveExtension mounts the extension extensionId, "" unmounts the
+// active one and "auto" mounts the one detected on the port.
 func rpcSetActiveExtension(extensionId string) error {
 	if config.ActiveExtension == extensionId {
 		return nil
 	}
-	switch config.ActiveExtension {
-	case "atx-power":
-		_ = unmountATXControl()
-	case "dc-power":
-		_ = unmountDCControl()
+	if extensionId != "" && extensionId != "auto" {
+		if _, ok := extensionRegistry.Get(extensionId); !ok {
+			return fmt.Errorf("unknown extension: %s", extensionId)
+		}
+	}
+	if config.ActiveExtension != "" {
+		unmountExtension()
+		config.ActiveExtension = ""
 	}
+
+	if extensionId == "auto" {
+		// the port is free again, the console server keeps reading it while detecting
+		updateSerialConsoleReader()
+		detected, err := detectExtension(context.Background())
+		if err != nil {
+			if saveErr := SaveConfig(); saveErr != nil {
+				return fmt.Errorf("failed to save config: %w", saveErr)
+			}
+			return fmt.Errorf("failed to detect extension: %w", err)
+		}
+		logger.Info().Str("extension", detected).Msg("Detected extension")
+		extensionId = detected
+	}
+
 	config.ActiveExtension = extensionId
 	if err := SaveConfig(); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
-	switch extensionId {
-	case "atx-power":
-		_ = mountATXControl()
-	case "dc-power":
-		_ = mountDCControl()
+	// the console server stops reading before an extension takes the port over
+	updateSerialConsoleReader()
+	if extensionId != "" {
+		if err := mountExtension(extensionId); err != nil {
+			return fmt.Errorf("failed to mount extension: %w", err)
+		}
 	}
 	return nil
 }
@@ -791,29 +829,20 @@ func rpcSetATXPowerAction(action string) error {
 	switch action {
 	case "power-short":
 		logger.Debug().Msg("Simulating short power button press")
-		return pressATXPowerButton(200 * time.Millisecond)
+		return pressATXPowerButton(extension.ATXShortPress)
 	case "power-long":
 		logger.Debug().Msg("Simulating long power button press")
-		return pressATXPowerButton(5 * time.Second)
+		return pressATXPowerButton(extension.ATXLongPress)
 	case "reset":
 		logger.Debug().Msg("Simulating reset button press")
-		return pressATXResetButton(200 * time.Millisecond)
+		return pressATXResetButton(extension.ATXShortPress)
 	default:
 		return fmt.Errorf("invalid action: %s", action)
 	}
 }
 
-type ATXState struct {
-	Power bool `json:"power"`
-	HDD   bool `json:"hdd"`
-}
-
 func rpcGetATXState() (ATXState, error) {
-	state := ATXState{
-		Power: ledPWRState,
-		HDD:   ledHDDState,
-	}
-	return state, nil
+	return atxExtension.ATXState(), nil
 }
 
 type SerialSettings struct {
@@ -858,14 +887,15 @@ func rpcGetSerialSettings() (SerialSettings, error) {
 
 var serialPortMode = defaultMode
 
-func rpcSetSerialSettings(settings SerialSettings) error {
+// parseSerialSettings converts the settings to a serial port mode.
+func parseSerialSettings(settings SerialSettings) (*serial.Mode, error) {
 	baudRate, err := strconv.Atoi(settings.BaudRate)
-	if err != nil {
-		return fmt.Errorf("invalid baud rate: %v", err)
+	if err != nil || baudRate <= 0 {
+		return nil, fmt.Errorf("invalid baud rate: %s", settings.BaudRate)
 	}
 	dataBits, err := strconv.Atoi(settings.DataBits)
-	if err != nil {
-		return fmt.Errorf("invalid data bits: %v", err)
+	if err != nil || dataBits < 5 || dataBits > 8 {
+		return nil, fmt.Errorf("invalid data bits: %s", settings.DataBits)
 	}
 
 	var stopBits serial.StopBits
@@ -877,7 +907,7 @@ func rpcSetSerialSettings(settings SerialSettings) error {
 	case "2":
 		stopBits = serial.TwoStopBits
 	default:
-		return fmt.Errorf("invalid stop bits: %s", settings.StopBits)
+		return nil, fmt.Errorf("invalid stop bits: %s", settings.StopBits)
 	}
 
 	var parity serial.Parity
@@ -893,16 +923,35 @@ func rpcSetSerialSettings(settings SerialSettings) error {
 	case "space":
 		parity = serial.SpaceParity
 	default:
-		return fmt.Errorf("invalid parity: %s", settings.Parity)
+		return nil, fmt.Errorf("invalid parity: %s", settings.Parity)
 	}
-	serialPortMode = &serial.Mode{
+
+	return &serial.Mode{
 		BaudRate: baudRate,
 		DataBits: dataBits,
 		StopBits: stopBits,
 		Parity:   parity,
+	}, nil
+}
+
+func rpcSetSerialSettings(settings SerialSettings) error {
+	mode, err := parseSerialSettings(settings)
+	if err != nil {
+		return err
 	}
 
-	_ = port.SetMode(serialPortMode)
+	config.SerialSettings = &settings
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	serialPortMode = mode
+
+	// the extensions need their own mode, the settings apply once they're unmounted
+	if port != nil && config.ActiveExtension == "" {
+		if err := port.SetMode(serialPortMode); err != nil {
+			return fmt.Errorf("failed to set serial mode: %w", err)
+		}
+	}
 
 	return nil
 }
@@ -924,7 +973,13 @@ func updateUsbRelatedConfig() error {
 func rpcSetUsbDevices(usbDevices usbgadget.Devices) error {
 	config.UsbDevices = &usbDevices
 	gadget.SetGadgetDevices(config.UsbDevices)
-	return updateUsbRelatedConfig()
+	if err := updateUsbRelatedConfig(); err != nil {
+		return err
+	}
+	syncUsbAudio()
+	updateSerialConsoleReader()
+	syncUsbNetwork()
+	return nil
 }
 
 func rpcSetUsbDeviceState(device string, enabled bool) error {
@@ -937,11 +992,29 @@ func rpcSetUsbDeviceState(device string, enabled bool) error {
 		config.UsbDevices.Keyboard = enabled
 	case "massStorage":
 		config.UsbDevices.MassStorage = enabled
+	case "consumerControl":
+		config.UsbDevices.ConsumerControl = enabled
+	case "keyboardNkro":
+		config.UsbDevices.KeyboardNKRO = enabled
+	case "audio":
+		config.UsbDevices.Audio = enabled
+	case "serial":
+		config.UsbDevices.Serial = enabled
+	case "network":
+		config.UsbDevices.Network = enabled
+	case "networkEcm":
+		config.UsbDevices.NetworkECM = enabled
 	default:
 		return fmt.Errorf("invalid device: %s", device)
 	}
 	gadget.SetGadgetDevices(config.UsbDevices)
-	return updateUsbRelatedConfig()
+	if err := updateUsbRelatedConfig(); err != nil {
+		return err
+	}
+	syncUsbAudio()
+	updateSerialConsoleReader()
+	syncUsbNetwork()
+	return nil
 }
 
 func rpcSetCloudUrl(apiUrl string, appUrl string) error {
@@ -965,6 +1038,10 @@ func rpcGetKeyboardLayout() (string, error) {
 }
 
 func rpcSetKeyboardLayout(layout string) error {
+	if _, err := keyboardlayout.Get(layout); err != nil {
+		return err
+	}
+
 	config.KeyboardLayout = layout
 	if err := SaveConfig(); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
@@ -973,8 +1050,10 @@ func rpcSetKeyboardLayout(layout string) error {
 }
 
 func getKeyboardMacros() (any, error) {
+	configLock.Lock()
 	macros := make([]KeyboardMacro, len(config.KeyboardMacros))
 	copy(macros, config.KeyboardMacros)
+	configLock.Unlock()
 
 	return macros, nil
 }
@@ -1056,7 +1135,10 @@ func setKeyboardMacros(params KeyboardMacrosParams) (any, error) {
 		newMacros = append(newMacros, macro)
 	}
 
+	// macros run from triggers and scheduled tasks read them concurrently
+	configLock.Lock()
 	config.KeyboardMacros = newMacros
+	configLock.Unlock()
 
 	if err := SaveConfig(); err != nil {
 		return nil, err
@@ -1182,89 +1264,169 @@ func rpcDoExecuteKeyboardMacro(ctx context.Context, macro []hidrpc.KeyboardMacro
 }
 
 var rpcHandlers = map[string]RPCHandler{
-	"ping":                   {Func: rpcPing},
-	"reboot":                 {Func: rpcReboot, Params: []string{"force"}},
-	"getDeviceID":            {Func: rpcGetDeviceID},
-	"deregisterDevice":       {Func: rpcDeregisterDevice},
-	"getCloudState":          {Func: rpcGetCloudState},
-	"getNetworkState":        {Func: rpcGetNetworkState},
-	"getNetworkSettings":     {Func: rpcGetNetworkSettings},
-	"setNetworkSettings":     {Func: rpcSetNetworkSettings, Params: []string{"settings"}},
-	"renewDHCPLease":         {Func: rpcRenewDHCPLease},
-	"getKeyboardLedState":    {Func: rpcGetKeyboardLedState},
-	"getKeyDownState":        {Func: rpcGetKeysDownState},
-	"keyboardReport":         {Func: rpcKeyboardReport, Params: []string{"modifier", "keys"}},
-	"keypressReport":         {Func: rpcKeypressReport, Params: []string{"key", "press"}},
-	"absMouseReport":         {Func: rpcAbsMouseReport, Params: []string{"x", "y", "buttons"}},
-	"relMouseReport":         {Func: rpcRelMouseReport, Params: []string{"dx", "dy", "buttons"}},
-	"wheelReport":            {Func: rpcWheelReport, Params: []string{"wheelY"}},
-	"getVideoState":          {Func: rpcGetVideoState},
-	"getUSBState":            {Func: rpcGetUSBState},
-	"unmountImage":           {Func: rpcUnmountImage},
-	"rpcMountBuiltInImage":   {Func: rpcMountBuiltInImage, Params: []string{"filename"}},
-	"setJigglerState":        {Func: rpcSetJigglerState, Params: []string{"enabled"}},
-	"getJigglerState":        {Func: rpcGetJigglerState},
-	"setJigglerConfig":       {Func: rpcSetJigglerConfig, Params: []string{"jigglerConfig"}},
-	"getJigglerConfig":       {Func: rpcGetJigglerConfig},
-	"getTimezones":           {Func: rpcGetTimezones},
-	"sendWOLMagicPacket":     {Func: rpcSendWOLMagicPacket, Params: []string{"macAddress"}},
-	"getStreamQualityFactor": {Func: rpcGetStreamQualityFactor},
-	"setStreamQualityFactor": {Func: rpcSetStreamQualityFactor, Params: []string{"factor"}},
-	"getAutoUpdateState":     {Func: rpcGetAutoUpdateState},
-	"setAutoUpdateState":     {Func: rpcSetAutoUpdateState, Params: []string{"enabled"}},
-	"getEDID":                {Func: rpcGetEDID},
-	"setEDID":                {Func: rpcSetEDID, Params: []string{"edid"}},
-	"getVideoLogStatus":      {Func: rpcGetVideoLogStatus},
-	"getDevChannelState":     {Func: rpcGetDevChannelState},
-	"setDevChannelState":     {Func: rpcSetDevChannelState, Params: []string{"enabled"}},
-	"getLocalVersion":        {Func: rpcGetLocalVersion},
-	"getUpdateStatus":        {Func: rpcGetUpdateStatus},
-	"tryUpdate":              {Func: rpcTryUpdate},
-	"getDevModeState":        {Func: rpcGetDevModeState},
-	"setDevModeState":        {Func: rpcSetDevModeState, Params: []string{"enabled"}},
-	"getSSHKeyState":         {Func: rpcGetSSHKeyState},
-	"setSSHKeyState":         {Func: rpcSetSSHKeyState, Params: []string{"sshKey"}},
-	"getTLSState":            {Func: rpcGetTLSState},
-	"setTLSState":            {Func: rpcSetTLSState, Params: []string{"state"}},
-	"setMassStorageMode":     {Func: rpcSetMassStorageMode, Params: []string{"mode"}},
-	"getMassStorageMode":     {Func: rpcGetMassStorageMode},
-	"isUpdatePending":        {Func: rpcIsUpdatePending},
-	"getUsbEmulationState":   {Func: rpcGetUsbEmulationState},
-	"setUsbEmulationState":   {Func: rpcSetUsbEmulationState, Params: []string{"enabled"}},
-	"getUsbConfig":           {Func: rpcGetUsbConfig},
-	"setUsbConfig":           {Func: rpcSetUsbConfig, Params: []string{"usbConfig"}},
-	"checkMountUrl":          {Func: rpcCheckMountUrl, Params: []string{"url"}},
-	"getVirtualMediaState":   {Func: rpcGetVirtualMediaState},
-	"getStorageSpace":        {Func: rpcGetStorageSpace},
-	"mountWithHTTP":          {Func: rpcMountWithHTTP, Params: []string{"url", "mode"}},
-	"mountWithStorage":       {Func: rpcMountWithStorage, Params: []string{"filename", "mode"}},
-	"listStorageFiles":       {Func: rpcListStorageFiles},
-	"deleteStorageFile":      {Func: rpcDeleteStorageFile, Params: []string{"filename"}},
-	"startStorageFileUpload": {Func: rpcStartStorageFileUpload, Params: []string{"filename", "size"}},
-	"getWakeOnLanDevices":    {Func: rpcGetWakeOnLanDevices},
-	"setWakeOnLanDevices":    {Func: rpcSetWakeOnLanDevices, Params: []string{"params"}},
-	"resetConfig":            {Func: rpcResetConfig},
-	"setDisplayRotation":     {Func: rpcSetDisplayRotation, Params: []string{"params"}},
-	"getDisplayRotation":     {Func: rpcGetDisplayRotation},
-	"setBacklightSettings":   {Func: rpcSetBacklightSettings, Params: []string{"params"}},
-	"getBacklightSettings":   {Func: rpcGetBacklightSettings},
-	"getDCPowerState":        {Func: rpcGetDCPowerState},
-	"setDCPowerState":        {Func: rpcSetDCPowerState, Params: []string{"enabled"}},
-	"setDCRestoreState":      {Func: rpcSetDCRestoreState, Params: []string{"state"}},
-	"getActiveExtension":     {Func: rpcGetActiveExtension},
-	"setActiveExtension":     {Func: rpcSetActiveExtension, Params: []string{"extensionId"}},
-	"getATXState":            {Func: rpcGetATXState},
-	"setATXPowerAction":      {Func: rpcSetATXPowerAction, Params: []string{"action"}},
-	"getSerialSettings":      {Func: rpcGetSerialSettings},
-	"setSerialSettings":      {Func: rpcSetSerialSettings, Params: []string{"settings"}},
-	"getUsbDevices":          {Func: rpcGetUsbDevices},
-	"setUsbDevices":          {Func: rpcSetUsbDevices, Params: []string{"devices"}},
-	"setUsbDeviceState":      {Func: rpcSetUsbDeviceState, Params: []string{"device", "enabled"}},
-	"setCloudUrl":            {Func: rpcSetCloudUrl, Params: []string{"apiUrl", "appUrl"}},
-	"getKeyboardLayout":      {Func: rpcGetKeyboardLayout},
-	"setKeyboardLayout":      {Func: rpcSetKeyboardLayout, Params: []string{"layout"}},
-	"getKeyboardMacros":      {Func: getKeyboardMacros},
-	"setKeyboardMacros":      {Func: setKeyboardMacros, Params: []string{"params"}},
-	"getLocalLoopbackOnly":   {Func: rpcGetLocalLoopbackOnly},
-	"setLocalLoopbackOnly":   {Func: rpcSetLocalLoopbackOnly, Params: []string{"enabled"}},
+	"ping":                      {Func: rpcPing},
+	"reboot":                    {Func: rpcReboot, Params: []string{"force"}, AdminOnly: true},
+	"getDeviceID":               {Func: rpcGetDeviceID},
+	"deregisterDevice":          {Func: rpcDeregisterDevice, AdminOnly: true},
+	"getCloudState":             {Func: rpcGetCloudState},
+	"getNetworkState":           {Func: rpcGetNetworkState},
+	"getNetworkSettings":        {Func: rpcGetNetworkSettings, AdminOnly: true},
+	"setNetworkSettings":        {Func: rpcSetNetworkSettings, Params: []string{"settings"}, AdminOnly: true},
+	"renewDHCPLease":            {Func: rpcRenewDHCPLease, AdminOnly: true},
+	"getKeyboardLedState":       {Func: rpcGetKeyboardLedState},
+	"getKeyDownState":           {Func: rpcGetKeysDownState},
+	"keyboardReport":            {Func: rpcKeyboardReport, Params: []string{"modifier", "keys"}},
+	"keypressReport":            {Func: rpcKeypressReport, Params: []string{"key", "press"}},
+	"absMouseReport":            {Func: rpcAbsMouseReport, Params: []string{"x", "y", "buttons"}},
+	"relMouseReport":            {Func: rpcRelMouseReport, Params: []string{"dx", "dy", "buttons"}},
+	"wheelReport":               {Func: rpcWheelReport, Params: []string{"wheelY"}},
+	"scrollReport":              {Func: rpcScrollReport, Params: []string{"wheelY", "wheelX"}},
+	"relScrollReport":           {Func: rpcRelScrollReport, Params: []string{"wheelY", "wheelX"}},
+	"consumerControlReport":     {Func: rpcConsumerControlReport, Params: []string{"usage"}},
+	"systemControlReport":       {Func: rpcSystemControlReport, Params: []string{"usage"}},
+	"sendConsumerControl":       {Func: rpcSendConsumerControl, Params: []string{"usage"}},
+	"sendSystemControl":         {Func: rpcSendSystemControl, Params: []string{"usage"}},
+	"getVideoState":             {Func: rpcGetVideoState},
+	"getUSBState":               {Func: rpcGetUSBState},
+	"unmountImage":              {Func: rpcUnmountImage},
+	"rpcMountBuiltInImage":      {Func: rpcMountBuiltInImage, Params: []string{"filename"}},
+	"setJigglerState":           {Func: rpcSetJigglerState, Params: []string{"enabled"}, AdminOnly: true},
+	"getJigglerState":           {Func: rpcGetJigglerState},
+	"setJigglerConfig":          {Func: rpcSetJigglerConfig, Params: []string{"jigglerConfig"}, AdminOnly: true},
+	"getJigglerConfig":          {Func: rpcGetJigglerConfig},
+	"getTimezones":              {Func: rpcGetTimezones},
+	"sendWOLMagicPacket":        {Func: rpcSendWOLMagicPacket, Params: []string{"macAddress"}},
+	"getStreamQualityFactor":    {Func: rpcGetStreamQualityFactor},
+	"setStreamQualityFactor":    {Func: rpcSetStreamQualityFactor, Params: []string{"factor"}, AdminOnly: true},
+	"getAutoUpdateState":        {Func: rpcGetAutoUpdateState},
+	"setAutoUpdateState":        {Func: rpcSetAutoUpdateState, Params: []string{"enabled"}, AdminOnly: true},
+	"getEDID":                   {Func: rpcGetEDID},
+	"setEDID":                   {Func: rpcSetEDID, Params: []string{"edid"}, AdminOnly: true},
+	"getVideoLogStatus":         {Func: rpcGetVideoLogStatus},
+	"getDevChannelState":        {Func: rpcGetDevChannelState},
+	"setDevChannelState":        {Func: rpcSetDevChannelState, Params: []string{"enabled"}, AdminOnly: true},
+	"getLocalVersion":           {Func: rpcGetLocalVersion},
+	"getUpdateStatus":           {Func: rpcGetUpdateStatus},
+	"tryUpdate":                 {Func: rpcTryUpdate, AdminOnly: true},
+	"getDevModeState":           {Func: rpcGetDevModeState},
+	"setDevModeState":           {Func: rpcSetDevModeState, Params: []string{"enabled"}, AdminOnly: true},
+	"getSSHKeyState":            {Func: rpcGetSSHKeyState, AdminOnly: true},
+	"setSSHKeyState":            {Func: rpcSetSSHKeyState, Params: []string{"sshKey"}, AdminOnly: true},
+	"getTLSState":               {Func: rpcGetTLSState, AdminOnly: true},
+	"setTLSState":               {Func: rpcSetTLSState, Params: []string{"state"}, AdminOnly: true},
+	"getClientCertAuth":         {Func: rpcGetClientCertAuth},
+	"setClientCertAuth":         {Func: rpcSetClientCertAuth, Params: []string{"clientCertAuth"}, AdminOnly: true},
+	"getCloudOIDCConfig":        {Func: rpcGetCloudOIDCConfig, AdminOnly: true},
+	"setCloudOIDCConfig":        {Func: rpcSetCloudOIDCConfig, Params: []string{"oidcConfig"}, AdminOnly: true},
+	"getLocalOIDCConfig":        {Func: rpcGetLocalOIDCConfig, AdminOnly: true},
+	"setLocalOIDCConfig":        {Func: rpcSetLocalOIDCConfig, Params: []string{"oidcConfig"}, AdminOnly: true},
+	"getCurrentUser":            {Func: rpcGetCurrentUser},
+	"getUsers":                  {Func: rpcGetUsers, AdminOnly: true},
+	"createUser":                {Func: rpcCreateUser, Params: []string{"username", "password", "role"}, AdminOnly: true},
+	"deleteUser":                {Func: rpcDeleteUser, Params: []string{"username"}, AdminOnly: true},
+	"setUserPassword":           {Func: rpcSetUserPassword, Params: []string{"username", "password"}, AdminOnly: true},
+	"setUserRole":               {Func: rpcSetUserRole, Params: []string{"username", "role"}, AdminOnly: true},
+	"setUserDisabled":           {Func: rpcSetUserDisabled, Params: []string{"username", "disabled"}, AdminOnly: true},
+	"unlockUser":                {Func: rpcUnlockUser, Params: []string{"username"}, AdminOnly: true},
+	"resetUserTOTP":             {Func: rpcResetUserTOTP, Params: []string{"username"}, AdminOnly: true},
+	"getUserSessions":           {Func: rpcGetUserSessions, Params: []string{"username"}},
+	"revokeUserSession":         {Func: rpcRevokeUserSession, Params: []string{"id"}},
+	"beginTOTPSetup":            {Func: rpcBeginTOTPSetup},
+	"enableTOTP":                {Func: rpcEnableTOTP, Params: []string{"code"}},
+	"disableTOTP":               {Func: rpcDisableTOTP, Params: []string{"password"}},
+	"getLDAPConfig":             {Func: rpcGetLDAPConfig, AdminOnly: true},
+	"setLDAPConfig":             {Func: rpcSetLDAPConfig, Params: []string{"ldapConfig"}, AdminOnly: true},
+	"testLDAPLogin":             {Func: rpcTestLDAPLogin, Params: []string{"username", "password"}, AdminOnly: true},
+	"getRelayConfig":            {Func: rpcGetRelayConfig, AdminOnly: true},
+	"setRelayConfig":            {Func: rpcSetRelayConfig, Params: []string{"relayConfig"}, AdminOnly: true},
+	"getRelayState":             {Func: rpcGetRelayState},
+	"getICEServers":             {Func: rpcGetICEServers, AdminOnly: true},
+	"setICEServers":             {Func: rpcSetICEServers, Params: []string{"iceServers"}, AdminOnly: true},
+	"getTURNServerConfig":       {Func: rpcGetTURNServerConfig, AdminOnly: true},
+	"setTURNServerConfig":       {Func: rpcSetTURNServerConfig, Params: []string{"turnServerConfig"}, AdminOnly: true},
+	"getTURNServerState":        {Func: rpcGetTURNServerState},
+	"getIEEE8021XCertificates":  {Func: rpcGetIEEE8021XCertificates, AdminOnly: true},
+	"setIEEE8021XCertificate":   {Func: rpcSetIEEE8021XCertificate, Params: []string{"certificate", "privateKey"}, AdminOnly: true},
+	"setIEEE8021XCACertificate": {Func: rpcSetIEEE8021XCACertificate, Params: []string{"certificate"}, AdminOnly: true},
+	"setMassStorageMode":        {Func: rpcSetMassStorageMode, Params: []string{"mode"}, AdminOnly: true},
+	"getMassStorageMode":        {Func: rpcGetMassStorageMode},
+	"isUpdatePending":           {Func: rpcIsUpdatePending},
+	"getUsbEmulationState":      {Func: rpcGetUsbEmulationState},
+	"setUsbEmulationState":      {Func: rpcSetUsbEmulationState, Params: []string{"enabled"}, AdminOnly: true},
+	"getUsbConfig":              {Func: rpcGetUsbConfig},
+	"setUsbConfig":              {Func: rpcSetUsbConfig, Params: []string{"usbConfig"}, AdminOnly: true},
+	"checkMountUrl":             {Func: rpcCheckMountUrl, Params: []string{"url"}},
+	"getVirtualMediaState":      {Func: rpcGetVirtualMediaState},
+	"getStorageSpace":           {Func: rpcGetStorageSpace},
+	"mountWithHTTP":             {Func: rpcMountWithHTTP, Params: []string{"url", "mode"}},
+	"mountWithStorage":          {Func: rpcMountWithStorage, Params: []string{"filename", "mode"}},
+	"listStorageFiles":          {Func: rpcListStorageFiles},
+	"deleteStorageFile":         {Func: rpcDeleteStorageFile, Params: []string{"filename"}},
+	"startStorageFileUpload":    {Func: rpcStartStorageFileUpload, Params: []string{"filename", "size"}},
+	"getWakeOnLanDevices":       {Func: rpcGetWakeOnLanDevices},
+	"setWakeOnLanDevices":       {Func: rpcSetWakeOnLanDevices, Params: []string{"params"}, AdminOnly: true},
+	"resetConfig":               {Func: rpcResetConfig, AdminOnly: true},
+	"setDisplayRotation":        {Func: rpcSetDisplayRotation, Params: []string{"params"}, AdminOnly: true},
+	"getDisplayRotation":        {Func: rpcGetDisplayRotation},
+	"setBacklightSettings":      {Func: rpcSetBacklightSettings, Params: []string{"params"}, AdminOnly: true},
+	"getBacklightSettings":      {Func: rpcGetBacklightSettings},
+	"getDCPowerState":           {Func: rpcGetDCPowerState},
+	"setDCPowerState":           {Func: rpcSetDCPowerState, Params: []string{"enabled"}},
+	"setDCRestoreState":         {Func: rpcSetDCRestoreState, Params: []string{"state"}, AdminOnly: true},
+	"getActiveExtension":        {Func: rpcGetActiveExtension},
+	"setActiveExtension":        {Func: rpcSetActiveExtension, Params: []string{"extensionId"}, AdminOnly: true},
+	"getExtensions":             {Func: rpcGetExtensions},
+	"getExtensionState":         {Func: rpcGetExtensionState},
+	"executeExtensionCommand":   {Func: rpcExecuteExtensionCommand, Params: []string{"command", "params"}},
+	"detectExtension":           {Func: rpcDetectExtension},
+	"getATXState":               {Func: rpcGetATXState},
+	"setATXPowerAction":         {Func: rpcSetATXPowerAction, Params: []string{"action"}},
+	"startPowerAction":          {Func: rpcStartPowerAction, Params: []string{"action"}},
+	"cancelPowerAction":         {Func: rpcCancelPowerAction},
+	"getPowerAction":            {Func: rpcGetPowerAction},
+	"getScheduledTasks":         {Func: rpcGetScheduledTasks},
+	"createScheduledTask":       {Func: rpcCreateScheduledTask, Params: []string{"task"}, AdminOnly: true},
+	"setScheduledTaskPaused":    {Func: rpcSetScheduledTaskPaused, Params: []string{"id", "paused"}, AdminOnly: true},
+	"deleteScheduledTask":       {Func: rpcDeleteScheduledTask, Params: []string{"id"}, AdminOnly: true},
+	"getSerialSettings":         {Func: rpcGetSerialSettings},
+	"setSerialSettings":         {Func: rpcSetSerialSettings, Params: []string{"settings"}, AdminOnly: true},
+	"getSerialConsoleSettings":  {Func: rpcGetSerialConsoleSettings},
+	"setSerialConsoleSettings":  {Func: rpcSetSerialConsoleSettings, Params: []string{"settings"}, AdminOnly: true},
+	"getSerialConsoleClients":   {Func: rpcGetSerialConsoleClients},
+	"getSerialScrollback":       {Func: rpcGetSerialScrollback},
+	"clearSerialScrollback":     {Func: rpcClearSerialScrollback},
+	"getSerialTriggers":         {Func: rpcGetSerialTriggers},
+	"setSerialTriggers":         {Func: rpcSetSerialTriggers, Params: []string{"triggers"}, AdminOnly: true},
+	"getSerialTriggerHistory":   {Func: rpcGetSerialTriggerHistory},
+	"clearSerialTriggerHistory": {Func: rpcClearSerialTriggerHistory, AdminOnly: true},
+	"getUsbNetworkSettings":     {Func: rpcGetUsbNetworkSettings},
+	"setUsbNetworkSettings":     {Func: rpcSetUsbNetworkSettings, Params: []string{"settings"}, AdminOnly: true},
+	"getUsbNetworkState":        {Func: rpcGetUsbNetworkState},
+	"getUsbDevices":             {Func: rpcGetUsbDevices},
+	"setUsbDevices":             {Func: rpcSetUsbDevices, Params: []string{"devices"}, AdminOnly: true},
+	"setUsbDeviceState":         {Func: rpcSetUsbDeviceState, Params: []string{"device", "enabled"}, AdminOnly: true},
+	"setCloudUrl":               {Func: rpcSetCloudUrl, Params: []string{"apiUrl", "appUrl"}, AdminOnly: true},
+	"getKeyboardLayout":         {Func: rpcGetKeyboardLayout},
+	"setKeyboardLayout":         {Func: rpcSetKeyboardLayout, Params: []string{"layout"}, AdminOnly: true},
+	"getKeyboardLayouts":        {Func: rpcGetKeyboardLayouts},
+	"typeText":                  {Func: rpcTypeText, Params: []string{"text", "delay"}},
+	"getScreenshot":             {Func: rpcGetScreenshot},
+	"getScreenText":             {Func: rpcGetScreenText},
+	"waitForText":               {Func: rpcWaitForText, Params: []string{"text", "timeout"}},
+	"waitForImage":              {Func: rpcWaitForImage, Params: []string{"image", "timeout"}},
+	"getScripts":                {Func: rpcGetScripts},
+	"getScript":                 {Func: rpcGetScript, Params: []string{"id"}},
+	"saveScript":                {Func: rpcSaveScript, Params: []string{"id", "name", "description", "source"}, AdminOnly: true},
+	"deleteScript":              {Func: rpcDeleteScript, Params: []string{"id"}, AdminOnly: true},
+	"runScript":                 {Func: rpcRunScript, Params: []string{"id", "version"}},
+	"cancelScript":              {Func: rpcCancelScript},
+	"getScriptRun":              {Func: rpcGetScriptRun},
+	"getKeyboardMacros":         {Func: getKeyboardMacros},
+	"setKeyboardMacros":         {Func: setKeyboardMacros, Params: []string{"params"}, AdminOnly: true},
+	"getLocalLoopbackOnly":      {Func: rpcGetLocalLoopbackOnly},
+	"setLocalLoopbackOnly":      {Func: rpcSetLocalLoopbackOnly, Params: []string{"enabled"}, AdminOnly: true},
+	"getAudioState":             {Func: rpcGetAudioState},
+	"setAudioEnabled":           {Func: rpcSetAudioEnabled, Params: []string{"enabled"}, AdminOnly: true},
+	"getAudioDevices":           {Func: rpcGetAudioDevices},
+	"setAudioDevice":            {Func: rpcSetAudioDevice, Params: []string{"device"}, AdminOnly: true},
 }
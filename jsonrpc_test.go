@@ -0,0 +1,22 @@
+package kvm
+
+import (
+	"testing"
+
+	"github.com/jetkvm/kvm/internal/users"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	handler := rpcHandlers["resetConfig"]
+	assert.True(t, handler.AdminOnly)
+
+	user := &Session{User: &users.Session{Username: "operator", Role: users.RoleUser}}
+	assert.Error(t, requireAdmin(user), "users can't reset the config")
+
+	admin := &Session{User: &users.Session{Username: "admin", Role: users.RoleAdmin}}
+	assert.NoError(t, requireAdmin(admin))
+	assert.NoError(t, requireAdmin(&Session{}), "sessions without a user, e.g. cloud")
+
+	assert.False(t, rpcHandlers["keyboardReport"].AdminOnly)
+}
@@ -0,0 +1,73 @@
+package kvm
+
+import (
+	"fmt"
+
+	"github.com/jetkvm/kvm/internal/hidrpc"
+	"github.com/jetkvm/kvm/internal/keyboardlayout"
+)
+
+const (
+	// typeTextPressDelay is how long each key is held down, same as the UI paste.
+	typeTextPressDelay = 20
+	// typeTextDefaultDelay is the delay after each key is released when none is given.
+	typeTextDefaultDelay = 100
+	typeTextMaxDelay     = 10000
+)
+
+func currentKeyboardLayout() (*keyboardlayout.Layout, error) {
+	layout := config.KeyboardLayout
+	if layout == "" {
+		layout = keyboardlayout.DefaultLayout
+	}
+	return keyboardlayout.Get(layout)
+}
+
+// textToMacroSteps converts text into keyboard macro steps for the given layout,
+// every key stroke is followed by a step releasing all keys.
+func textToMacroSteps(layout *keyboardlayout.Layout, text string, delay uint16) ([]hidrpc.KeyboardMacroStep, error) {
+	strokes, invalid := layout.Strokes(text)
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("characters not supported by keyboard layout %s: %q", layout.ISOCode, string(invalid))
+	}
+
+	steps := make([]hidrpc.KeyboardMacroStep, 0, len(strokes)*2)
+	for _, stroke := range strokes {
+		keys := make([]byte, hidrpc.HidKeyBufferSize)
+		keys[0] = stroke.Key
+
+		steps = append(steps,
+			hidrpc.KeyboardMacroStep{Modifier: stroke.Modifier, Keys: keys, Delay: typeTextPressDelay},
+			hidrpc.KeyboardMacroStep{Modifier: 0, Keys: keyboardClearStateKeys, Delay: delay},
+		)
+	}
+	return steps, nil
+}
+
+func rpcGetKeyboardLayouts() ([]string, error) {
+	return keyboardlayout.List(), nil
+}
+
+// rpcTypeText types text on the host using the configured keyboard layout,
+// delay is the time in milliseconds between key strokes (0 for the default).
+func rpcTypeText(text string, delay int) error {
+	if delay < 0 || delay > typeTextMaxDelay {
+		return fmt.Errorf("invalid delay: %d, must be between 0 and %d", delay, typeTextMaxDelay)
+	}
+	if delay == 0 {
+		delay = typeTextDefaultDelay
+	}
+
+	layout, err := currentKeyboardLayout()
+	if err != nil {
+		return err
+	}
+
+	steps, err := textToMacroSteps(layout, text, uint16(delay))
+	if err != nil {
+		return err
+	}
+
+	logger.Info().Str("layout", layout.ISOCode).Int("length", len(text)).Msg("typing text")
+	return rpcExecuteKeyboardMacro(steps)
+}
@@ -0,0 +1,78 @@
+package kvm
+
+import (
+	"fmt"
+
+	"github.com/jetkvm/kvm/internal/hidrpc"
+	"github.com/jetkvm/kvm/internal/keyboardlayout"
+)
+
+// keyboardMacroSteps converts a saved keyboard macro into macro steps the same
+// way the UI does: every step is pressed briefly, then all keys are released
+// for the step's delay.
+func keyboardMacroSteps(macro *KeyboardMacro) ([]hidrpc.KeyboardMacroStep, error) {
+	steps := make([]hidrpc.KeyboardMacroStep, 0, len(macro.Steps)*2)
+	for i, step := range macro.Steps {
+		var modifier byte
+		for _, name := range step.Modifiers {
+			code, ok := keyboardlayout.KeyCode(name)
+			// ControlLeft (0xE0) to MetaRight (0xE7) are modifiers
+			if !ok || code < 0xE0 || code > 0xE7 {
+				return nil, fmt.Errorf("step %d: unknown modifier: %s", i+1, name)
+			}
+			modifier |= 1 << (code - 0xE0)
+		}
+
+		if len(step.Keys) > hidrpc.HidKeyBufferSize {
+			return nil, fmt.Errorf("step %d: too many keys, at most %d can be pressed together", i+1, hidrpc.HidKeyBufferSize)
+		}
+		keys := make([]byte, hidrpc.HidKeyBufferSize)
+		for j, name := range step.Keys {
+			code, ok := keyboardlayout.KeyCode(name)
+			if !ok {
+				return nil, fmt.Errorf("step %d: unknown key: %s", i+1, name)
+			}
+			keys[j] = code
+		}
+
+		if len(step.Keys) == 0 && modifier == 0 {
+			continue
+		}
+		delay := step.Delay
+		if delay <= 0 {
+			delay = typeTextDefaultDelay
+		}
+		steps = append(steps,
+			hidrpc.KeyboardMacroStep{Modifier: modifier, Keys: keys, Delay: typeTextPressDelay},
+			hidrpc.KeyboardMacroStep{Modifier: 0, Keys: keyboardClearStateKeys, Delay: uint16(delay)},
+		)
+	}
+	return steps, nil
+}
+
+// findKeyboardMacro returns a copy of the saved keyboard macro with the given
+// ID, it's safe to use while the macros are replaced.
+func findKeyboardMacro(id string) (KeyboardMacro, bool) {
+	configLock.Lock()
+	defer configLock.Unlock()
+
+	for _, macro := range config.KeyboardMacros {
+		if macro.ID == id {
+			return macro, true
+		}
+	}
+	return KeyboardMacro{}, false
+}
+
+// runKeyboardMacro executes the saved keyboard macro with the given ID.
+func runKeyboardMacro(id string) error {
+	macro, ok := findKeyboardMacro(id)
+	if !ok {
+		return fmt.Errorf("keyboard macro not found: %s", id)
+	}
+	steps, err := keyboardMacroSteps(&macro)
+	if err != nil {
+		return fmt.Errorf("invalid keyboard macro %s: %w", macro.Name, err)
+	}
+	return rpcExecuteKeyboardMacro(steps)
+}
@@ -0,0 +1,127 @@
+package kvm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/ldapauth"
+	"github.com/jetkvm/kvm/internal/users"
+)
+
+const ldapSessionSource = "ldap"
+
+// LDAPConfig lets users log in with directory credentials. Members of
+// AdminGroup are admins, other users of RequiredGroup are users.
+type LDAPConfig struct {
+	Enabled            bool   `json:"enabled"`
+	URL                string `json:"url"`
+	StartTLS           bool   `json:"start_tls"`
+	CACert             string `json:"ca_cert,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	BindDN             string `json:"bind_dn"`
+	BindPassword       string `json:"bind_password,omitempty"`
+	BaseDN             string `json:"base_dn"`
+	UserFilter         string `json:"user_filter"`
+	GroupAttribute     string `json:"group_attribute"`
+	RequiredGroup      string `json:"required_group"`
+	AdminGroup         string `json:"admin_group"`
+	TimeoutSeconds     int    `json:"timeout_seconds,omitempty"`
+}
+
+func (c *LDAPConfig) authenticator() (*ldapauth.Authenticator, error) {
+	return ldapauth.NewAuthenticator(ldapauth.Options{
+		URL:                c.URL,
+		StartTLS:           c.StartTLS,
+		CACert:             c.CACert,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		BindDN:             c.BindDN,
+		BindPassword:       c.BindPassword,
+		BaseDN:             c.BaseDN,
+		UserFilter:         c.UserFilter,
+		GroupAttribute:     c.GroupAttribute,
+		RequiredGroup:      c.RequiredGroup,
+		AdminGroup:         c.AdminGroup,
+		Timeout:            time.Duration(c.TimeoutSeconds) * time.Second,
+		Logger:             authLogger,
+	})
+}
+
+// loginIdentity is who a web login authenticated as.
+type loginIdentity struct {
+	Username string
+	Role     string
+	Source   string
+}
+
+// authenticateLogin checks the credentials of a web login. With LDAP enabled
+// the directory is asked first. Local users are only used if the directory
+// is unreachable or doesn't know the user, a directory rejecting the
+// password or group isn't overridden by a local password.
+func authenticateLogin(username, password, totpCode string) (*loginIdentity, error) {
+	if config.LDAP.Enabled {
+		id, err := authenticateLDAP(username, password)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, ldapauth.ErrUnavailable) && !errors.Is(err, ldapauth.ErrUserNotFound) {
+			return nil, err
+		}
+		if errors.Is(err, ldapauth.ErrUnavailable) {
+			authLogger.Warn().Err(err).Str("username", username).Msg("directory unavailable, falling back to local users")
+		}
+	}
+
+	user, err := getUserStore().Authenticate(username, password, totpCode)
+	if err != nil {
+		return nil, err
+	}
+	return &loginIdentity{Username: user.Username, Role: user.Role, Source: users.SourceLocal}, nil
+}
+
+func authenticateLDAP(username, password string) (*loginIdentity, error) {
+	a, err := config.LDAP.authenticator()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ldapauth.ErrUnavailable, err)
+	}
+	id, err := a.Authenticate(username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	role := users.RoleUser
+	if id.Admin {
+		role = users.RoleAdmin
+	}
+	authLogger.Info().Str("username", username).Str("dn", id.DN).Str("role", role).Msg("directory login")
+	return &loginIdentity{Username: id.Username, Role: role, Source: ldapSessionSource}, nil
+}
+
+func rpcGetLDAPConfig() (*LDAPConfig, error) {
+	return config.LDAP, nil
+}
+
+func rpcSetLDAPConfig(ldapConfig LDAPConfig) error {
+	if ldapConfig.Enabled {
+		if _, err := ldapConfig.authenticator(); err != nil {
+			return fmt.Errorf("invalid LDAP settings: %w", err)
+		}
+	}
+
+	config.LDAP = &ldapConfig
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	authLogger.Info().Bool("enabled", ldapConfig.Enabled).Str("url", ldapConfig.URL).Msg("LDAP settings updated")
+	return nil
+}
+
+// rpcTestLDAPLogin checks credentials against the directory without logging
+// in, to verify the settings.
+func rpcTestLDAPLogin(username, password string) (*ldapauth.Identity, error) {
+	a, err := config.LDAP.authenticator()
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP settings: %w", err)
+	}
+	return a.Authenticate(username, password)
+}
@@ -28,6 +28,9 @@ var (
 	displayLogger   = logging.GetSubsystemLogger("display")
 	wolLogger       = logging.GetSubsystemLogger("wol")
 	usbLogger       = logging.GetSubsystemLogger("usb")
+	audioLogger     = logging.GetSubsystemLogger("audio")
+	authLogger      = logging.GetSubsystemLogger("auth")
+	relayLogger     = logging.GetSubsystemLogger("relay")
 	// external components
 	ginLogger = logging.GetSubsystemLogger("gin")
 )
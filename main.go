@@ -76,6 +76,9 @@ func Main() {
 		logger.Warn().Err(err).Msg("failed to init images folder")
 	}
 	initJiggler()
+	initAudio()
+	initUsbAudio()
+	initUsbNetwork()
 
 	// initialize display
 	initDisplay()
@@ -120,8 +123,11 @@ func Main() {
 
 	// As websocket client already checks if the cloud token is set, we can start it here.
 	go RunWebsocketClient()
+	go RunRelayClient()
 
 	initSerialPort()
+	// tasks may need the power extension mounted by initSerialPort
+	initScheduledTasks()
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	<-sigs
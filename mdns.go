@@ -13,7 +13,7 @@ func initMdns() error {
 			networkState.GetHostname(),
 			networkState.GetFQDN(),
 		},
-		ListenOptions: config.NetworkConfig.GetMDNSMode(),
+		ListenOptions: networkState.GetMDNSMode(),
 	})
 	if err != nil {
 		return err
@@ -31,13 +31,17 @@ func networkStateChanged(isOnline bool) {
 
 	// always restart mDNS when the network state changes
 	if mDNS != nil {
-		_ = mDNS.SetListenOptions(config.NetworkConfig.GetMDNSMode())
+		_ = mDNS.SetListenOptions(networkState.GetMDNSMode())
 		_ = mDNS.SetLocalNames([]string{
 			networkState.GetHostname(),
 			networkState.GetFQDN(),
 		}, true)
 	}
 
+	updateVLANFirewall()
+	updateWireGuardWebServer()
+	updateTURNServer()
+
 	// if the network is now online, trigger an NTP sync if still needed
 	if isOnline && timeSync != nil && (isTimeSyncNeeded() || !timeSync.IsSyncSuccess()) {
 		if err := timeSync.Sync(); err != nil {
@@ -54,6 +58,9 @@ func initNetwork() error {
 		InterfaceName:   NetIfName,
 		NetworkConfig:   config.NetworkConfig,
 		Logger:          networkLogger,
+
+		IEEE8021XCertificates:  ieee8021xCertificates,
+		OnIEEE8021XStateChange: ieee8021xStateChanged,
 		OnStateChange: func(state *network.NetworkInterfaceState) {
 			networkStateChanged(state.IsOnline())
 		},
@@ -74,7 +81,7 @@ func initNetwork() error {
 			networkStateChanged(false)
 
 			if mDNS != nil {
-				_ = mDNS.SetListenOptions(networkConfig.GetMDNSMode())
+				_ = mDNS.SetListenOptions(networkState.GetMDNSMode())
 				_ = mDNS.SetLocalNames([]string{
 					networkState.GetHostname(),
 					networkState.GetFQDN(),
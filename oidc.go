@@ -0,0 +1,301 @@
+package kvm
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jetkvm/kvm/internal/oidcauth"
+	"github.com/jetkvm/kvm/internal/users"
+)
+
+const (
+	defaultCloudOIDCIssuer = "https://accounts.google.com"
+	oidcCallbackPath       = "/auth/oidc/callback"
+	oidcLoginTimeout       = 10 * time.Minute
+	oidcMaxPendingLogins   = 32
+	oidcSessionSource      = "oidc"
+	oidcStateCookie        = "oidcState"
+)
+
+// OIDCConfig configures an OpenID Connect issuer. For cloud sessions the
+// issuer is always used and Enabled is ignored. Without allow rules a cloud
+// session must come from the identity that registered the device, local
+// logins always need at least one rule.
+type OIDCConfig struct {
+	Enabled         bool     `json:"enabled"`
+	IssuerURL       string   `json:"issuer_url"`
+	ClientID        string   `json:"client_id"`
+	ClientSecret    string   `json:"client_secret,omitempty"`
+	Scopes          []string `json:"scopes,omitempty"`
+	GroupsClaim     string   `json:"groups_claim,omitempty"`
+	AllowedSubjects []string `json:"allowed_subjects,omitempty"`
+	AllowedEmails   []string `json:"allowed_emails,omitempty"`
+	AllowedGroups   []string `json:"allowed_groups,omitempty"`
+	// RedirectURL overrides the callback URL derived from the request, for
+	// devices behind a reverse proxy.
+	RedirectURL string `json:"redirect_url,omitempty"`
+}
+
+func (c *OIDCConfig) hasRules() bool {
+	return len(c.AllowedSubjects)+len(c.AllowedEmails)+len(c.AllowedGroups) > 0
+}
+
+// Validate checks the settings, local is set for the local login issuer.
+func (c *OIDCConfig) Validate(local bool) error {
+	u, err := url.Parse(c.IssuerURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("invalid issuer url: %s", c.IssuerURL)
+	}
+	if c.RedirectURL != "" {
+		u, err := url.Parse(c.RedirectURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("invalid redirect url: %s", c.RedirectURL)
+		}
+	}
+	if !local {
+		// with allow rules tokens of any client of the issuer would be
+		// accepted otherwise
+		if c.hasRules() && c.ClientID == "" {
+			return fmt.Errorf("client id is required with allow rules")
+		}
+		return nil
+	}
+	if !c.Enabled {
+		return nil
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("client id is required for local login")
+	}
+	if !c.hasRules() {
+		return fmt.Errorf("local login requires at least one allowed subject, email or group")
+	}
+	return nil
+}
+
+func (c *OIDCConfig) options(clientID string) oidcauth.Options {
+	return oidcauth.Options{
+		IssuerURL:       c.IssuerURL,
+		ClientID:        clientID,
+		ClientSecret:    c.ClientSecret,
+		Scopes:          c.Scopes,
+		GroupsClaim:     c.GroupsClaim,
+		AllowedSubjects: c.AllowedSubjects,
+		AllowedEmails:   c.AllowedEmails,
+		AllowedGroups:   c.AllowedGroups,
+		Logger:          authLogger,
+	}
+}
+
+var (
+	oidcProviders     = make(map[string]*oidcauth.Provider)
+	oidcProvidersLock sync.Mutex
+	oidcLogins        = oidcauth.NewLoginStore(oidcLoginTimeout, oidcMaxPendingLogins)
+)
+
+// getOIDCProvider returns the provider of c, discovery is only done once per
+// settings.
+func getOIDCProvider(ctx context.Context, c *OIDCConfig, clientID string) (*oidcauth.Provider, error) {
+	settings, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	key := clientID + "\n" + string(settings)
+
+	oidcProvidersLock.Lock()
+	defer oidcProvidersLock.Unlock()
+	if p, ok := oidcProviders[key]; ok {
+		return p, nil
+	}
+	p, err := oidcauth.NewProvider(ctx, c.options(clientID))
+	if err != nil {
+		return nil, err
+	}
+	oidcProviders[key] = p
+	return p, nil
+}
+
+// registeredAudience returns the client ID of the identity that registered
+// the device, empty if it isn't registered.
+func registeredAudience() string {
+	if i := strings.LastIndex(config.GoogleIdentity, ":"); i > 0 {
+		return config.GoogleIdentity[:i]
+	}
+	return ""
+}
+
+// verifyCloudIdentity verifies the ID token of a cloud session or
+// registration, issued for clientID unless a client ID is configured. It
+// returns the identity the device is bound to, aud:sub.
+func verifyCloudIdentity(ctx context.Context, rawIDToken string, clientID string) (string, error) {
+	if config.CloudOIDC.ClientID != "" {
+		clientID = config.CloudOIDC.ClientID
+	} else if config.CloudOIDC.hasRules() {
+		return "", errors.New("cloud OIDC allow rules require a client ID")
+	}
+	if clientID == "" {
+		return "", errors.New("no client ID to check the token audience")
+	}
+	provider, err := getOIDCProvider(ctx, config.CloudOIDC, clientID)
+	if err != nil {
+		return "", err
+	}
+	id, err := provider.Verify(ctx, rawIDToken)
+	if err != nil {
+		return "", err
+	}
+	if provider.HasRules() {
+		if err := provider.Allowed(id); err != nil {
+			return "", err
+		}
+	}
+	if len(id.Audience) == 0 {
+		return "", fmt.Errorf("ID token has no audience")
+	}
+	return id.Audience[0] + ":" + id.Subject, nil
+}
+
+func oidcLoginEnabled() bool {
+	return config.LocalOIDC.Enabled && config.LocalAuthMode == "password"
+}
+
+func oidcRedirectURL(c *gin.Context) string {
+	if config.LocalOIDC.RedirectURL != "" {
+		return config.LocalOIDC.RedirectURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + oidcCallbackPath
+}
+
+func handleOIDCLogin(c *gin.Context) {
+	if !oidcLoginEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OIDC login is disabled"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c, CloudOidcRequestTimeout)
+	defer cancel()
+	provider, err := getOIDCProvider(ctx, config.LocalOIDC, config.LocalOIDC.ClientID)
+	if err != nil {
+		authLogger.Warn().Err(err).Msg("failed to initialize OIDC provider")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to initialize OIDC provider"})
+		return
+	}
+
+	req, authURL := provider.StartLogin(oidcRedirectURL(c))
+	oidcLogins.Add(req)
+
+	// ties the login to this browser, the callback is a top level
+	// navigation from the issuer, which lax cookies are sent with
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, req.State, int(oidcLoginTimeout.Seconds()), oidcCallbackPath, "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func handleOIDCCallback(c *gin.Context) {
+	if !oidcLoginEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OIDC login is disabled"})
+		return
+	}
+
+	state := c.Query("state")
+	cookieState, _ := c.Cookie(oidcStateCookie)
+	c.SetCookie(oidcStateCookie, "", -1, oidcCallbackPath, "", c.Request.TLS != nil, true)
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(cookieState)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login wasn't started in this browser"})
+		return
+	}
+
+	req, ok := oidcLogins.Take(state)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown or expired login"})
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed: " + e})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c, CloudOidcRequestTimeout)
+	defer cancel()
+	provider, err := getOIDCProvider(ctx, config.LocalOIDC, config.LocalOIDC.ClientID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to initialize OIDC provider"})
+		return
+	}
+	id, err := provider.FinishLogin(ctx, req, c.Query("code"))
+	if err != nil {
+		authLogger.Warn().Err(err).Str("remote", c.ClientIP()).Msg("OIDC login failed")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+	if err := provider.Allowed(id); err != nil {
+		authLogger.Warn().Str("subject", id.Subject).Str("email", id.Email).Str("remote", c.ClientIP()).Msg("OIDC identity not allowed")
+		c.JSON(http.StatusForbidden, gin.H{"error": "Identity is not allowed"})
+		return
+	}
+
+	// OIDC identities get user sessions, only local admins manage users
+	username := "oidc:" + id.Subject
+	if id.Email != "" {
+		username = "oidc:" + id.Email
+	}
+	if err := startLocalSession(c, username, users.RoleUser, oidcSessionSource); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+func rpcGetCloudOIDCConfig() (OIDCConfig, error) {
+	return *config.CloudOIDC, nil
+}
+
+func rpcSetCloudOIDCConfig(oidcConfig OIDCConfig) error {
+	if err := oidcConfig.Validate(false); err != nil {
+		return err
+	}
+	if config.CloudToken != "" && oidcConfig.IssuerURL != config.CloudOIDC.IssuerURL && !oidcConfig.hasRules() {
+		return errors.New("changing the issuer of a registered device requires allow rules, or registering again")
+	}
+
+	config.CloudOIDC = &oidcConfig
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
+
+func rpcGetLocalOIDCConfig() (OIDCConfig, error) {
+	return *config.LocalOIDC, nil
+}
+
+func rpcSetLocalOIDCConfig(oidcConfig OIDCConfig) error {
+	if err := oidcConfig.Validate(true); err != nil {
+		return err
+	}
+	if oidcConfig.Enabled {
+		ctx, cancel := context.WithTimeout(context.Background(), CloudOidcRequestTimeout)
+		defer cancel()
+		if _, err := getOIDCProvider(ctx, &oidcConfig, oidcConfig.ClientID); err != nil {
+			return fmt.Errorf("failed to discover OIDC issuer: %w", err)
+		}
+	}
+
+	config.LocalOIDC = &oidcConfig
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package kvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/extension"
+	"github.com/jetkvm/kvm/internal/power"
+)
+
+var powerController = power.NewController(power.Options{Logger: logger})
+
+// atxPowerBackend switches the power with the ATX buttons and reads the power LED.
+type atxPowerBackend struct{}
+
+func (atxPowerBackend) IsOn() bool {
+	return atxExtension.ATXState().Power
+}
+
+func (atxPowerBackend) PowerOn(ctx context.Context) error {
+	return atxExtension.Press(ctx, "PWR", extension.ATXShortPress)
+}
+
+func (atxPowerBackend) Shutdown(ctx context.Context) error {
+	return atxExtension.Press(ctx, "PWR", extension.ATXShortPress)
+}
+
+func (atxPowerBackend) ForceOff(ctx context.Context) error {
+	return atxExtension.Press(ctx, "PWR", extension.ATXLongPress)
+}
+
+// dcPowerBackend switches the DC power, the host can't be asked to shut down
+// so graceful off isn't supported.
+type dcPowerBackend struct{}
+
+func (dcPowerBackend) IsOn() bool {
+	return dcExtension.DCPowerState().IsOn
+}
+
+func (dcPowerBackend) PowerOn(context.Context) error {
+	return dcExtension.SetPower(true)
+}
+
+func (dcPowerBackend) Shutdown(context.Context) error {
+	return fmt.Errorf("%w: DC power can only be cut, use %s", power.ErrUnsupportedAction, power.ActionForceOff)
+}
+
+func (dcPowerBackend) ForceOff(context.Context) error {
+	return dcExtension.SetPower(false)
+}
+
+// currentPowerBackend returns the backend of the active power extension.
+func currentPowerBackend() (power.Backend, error) {
+	switch config.ActiveExtension {
+	case extension.ATXID:
+		return atxPowerBackend{}, nil
+	case extension.DCID:
+		return dcPowerBackend{}, nil
+	}
+	return nil, fmt.Errorf("no power extension active")
+}
+
+// PowerActionState is the state of a power action, it's sent as the
+// powerActionState event whenever it changes.
+type PowerActionState struct {
+	RunID      string       `json:"runId"`
+	Action     string       `json:"action"`
+	Backend    string       `json:"backend"`
+	State      string       `json:"state"` // running, succeeded, failed or cancelled
+	Steps      []power.Step `json:"steps"`
+	Error      string       `json:"error,omitempty"`
+	StartedAt  time.Time    `json:"startedAt"`
+	FinishedAt *time.Time   `json:"finishedAt,omitempty"`
+}
+
+type powerActionRun struct {
+	lock   sync.Mutex
+	state  PowerActionState
+	cancel context.CancelFunc
+}
+
+var (
+	powerActionLock sync.Mutex
+	lastPowerAction *powerActionRun
+)
+
+func writePowerActionEvent(state PowerActionState) {
+	if currentSession != nil {
+		writeJSONRPCEvent("powerActionState", state, currentSession)
+	}
+}
+
+func (r *powerActionRun) snapshot() PowerActionState {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	state := r.state
+	state.Steps = append([]power.Step{}, r.state.Steps...)
+	return state
+}
+
+func (r *powerActionRun) running() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.state.State == "running"
+}
+
+func (r *powerActionRun) onStep(step power.Step) {
+	r.lock.Lock()
+	r.state.Steps = append(r.state.Steps, step)
+	r.lock.Unlock()
+
+	writePowerActionEvent(r.snapshot())
+}
+
+func (r *powerActionRun) finish(err error) {
+	r.lock.Lock()
+	now := time.Now().UTC()
+	r.state.FinishedAt = &now
+	switch {
+	case err == nil:
+		r.state.State = "succeeded"
+	case errors.Is(err, context.Canceled):
+		r.state.State = "cancelled"
+	default:
+		r.state.State = "failed"
+		r.state.Error = err.Error()
+	}
+	r.lock.Unlock()
+
+	state := r.snapshot()
+	logger.Info().
+		Str("action", state.Action).
+		Str("state", state.State).
+		Str("error", state.Error).
+		Msg("power action finished")
+	writePowerActionEvent(state)
+}
+
+// beginPowerAction registers a run of action, only one action runs at a time.
+func beginPowerAction(parent context.Context, action string) (*powerActionRun, context.Context, power.Action, power.Backend, error) {
+	a, err := power.ParseAction(action)
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+	backend, err := currentPowerBackend()
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+
+	powerActionLock.Lock()
+	defer powerActionLock.Unlock()
+
+	if lastPowerAction != nil && lastPowerAction.running() {
+		return nil, nil, "", nil, fmt.Errorf("power action %s is already running", lastPowerAction.snapshot().Action)
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	run := &powerActionRun{
+		state: PowerActionState{
+			RunID:     newRunID(),
+			Action:    string(a),
+			Backend:   config.ActiveExtension,
+			State:     "running",
+			Steps:     []power.Step{},
+			StartedAt: time.Now().UTC(),
+		},
+		cancel: cancel,
+	}
+	lastPowerAction = run
+
+	logger.Info().Str("action", action).Str("backend", config.ActiveExtension).Msg("starting power action")
+	writePowerActionEvent(run.snapshot())
+	return run, ctx, a, backend, nil
+}
+
+// runPowerAction runs action and waits for it to finish.
+func runPowerAction(ctx context.Context, action string) (PowerActionState, error) {
+	run, ctx, a, backend, err := beginPowerAction(ctx, action)
+	if err != nil {
+		return PowerActionState{}, err
+	}
+	defer run.cancel()
+
+	err = powerController.Run(ctx, backend, a, run.onStep)
+	run.finish(err)
+	return run.snapshot(), err
+}
+
+// startPowerAction runs action in the background.
+func startPowerAction(action string) (PowerActionState, error) {
+	run, ctx, a, backend, err := beginPowerAction(context.Background(), action)
+	if err != nil {
+		return PowerActionState{}, err
+	}
+
+	go func() {
+		defer run.cancel()
+		run.finish(powerController.Run(ctx, backend, a, run.onStep))
+	}()
+	return run.snapshot(), nil
+}
+
+// rpcStartPowerAction starts a power action: on, graceful-off, force-off or power-cycle.
+func rpcStartPowerAction(action string) (PowerActionState, error) {
+	return startPowerAction(action)
+}
+
+func rpcCancelPowerAction() error {
+	powerActionLock.Lock()
+	defer powerActionLock.Unlock()
+
+	if lastPowerAction == nil || !lastPowerAction.running() {
+		return fmt.Errorf("no power action is running")
+	}
+	lastPowerAction.cancel()
+	return nil
+}
+
+// rpcGetPowerAction returns the current or last power action, nil if none ran yet.
+func rpcGetPowerAction() (*PowerActionState, error) {
+	powerActionLock.Lock()
+	defer powerActionLock.Unlock()
+
+	if lastPowerAction == nil {
+		return nil, nil
+	}
+	state := lastPowerAction.snapshot()
+	return &state, nil
+}
@@ -0,0 +1,205 @@
+package kvm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/relay"
+)
+
+// RelayConfig makes the device keep a tunnel to a self-hosted relay (see
+// cmd/jetkvm-relay), which serves the web interface to clients that can't
+// reach the device directly. WebRTC media still needs a direct path or TURN.
+type RelayConfig struct {
+	Enabled bool `json:"enabled"`
+	// URL is the tunnel endpoint, e.g. wss://relay.example.com/tunnel.
+	URL   string `json:"url"`
+	Token string `json:"token,omitempty"`
+	// CABundle holds the roots to verify the relay with, the system roots
+	// are used without it.
+	CABundle           string `json:"ca_bundle,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+func (c *RelayConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return fmt.Errorf("relay URL must be a ws:// or wss:// URL")
+	}
+	if c.Token == "" {
+		return fmt.Errorf("relay token is required")
+	}
+	if _, err := c.tlsConfig(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c *RelayConfig) tlsConfig() (*tls.Config, error) {
+	if c.CABundle == "" && !c.InsecureSkipVerify {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify, //nolint:gosec
+		MinVersion:         tls.VersionTLS12,
+	}
+	if c.CABundle != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(c.CABundle)) {
+			return nil, fmt.Errorf("relay CA bundle contains no certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+type RelayState struct {
+	Enabled   bool       `json:"enabled"`
+	Connected bool       `json:"connected"`
+	URL       string     `json:"url"`
+	Since     *time.Time `json:"since,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+var (
+	relayState  RelayState
+	relayTunnel *relay.Tunnel
+	relayLock   sync.Mutex
+)
+
+func setRelayState(tunnel *relay.Tunnel, err error) {
+	relayLock.Lock()
+	relayTunnel = tunnel
+	relayState = RelayState{Enabled: config.Relay.Enabled, Connected: tunnel != nil, URL: config.Relay.URL}
+	if tunnel != nil {
+		now := time.Now()
+		relayState.Since = &now
+	}
+	if err != nil {
+		relayState.Error = err.Error()
+	}
+	state := relayState
+	relayLock.Unlock()
+
+	if currentSession != nil {
+		writeJSONRPCEvent("relayState", state, currentSession)
+	}
+}
+
+// restartRelayClient drops the current tunnel, RunRelayClient reconnects with
+// the new settings.
+func restartRelayClient() {
+	relayLock.Lock()
+	tunnel := relayTunnel
+	relayLock.Unlock()
+	if tunnel != nil {
+		_ = tunnel.Close()
+	}
+}
+
+func runRelayClient() error {
+	tlsConfig, err := config.Relay.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tunnel, err := relay.Dial(ctx, relay.ClientOptions{
+		URL:        config.Relay.URL,
+		DeviceID:   GetDeviceID(),
+		Token:      config.Relay.Token,
+		AppVersion: builtAppVersion,
+		TLSConfig:  tlsConfig,
+	})
+	if err != nil {
+		return err
+	}
+	defer tunnel.Close()
+
+	relayLogger.Info().Str("url", config.Relay.URL).Msg("connected to relay")
+	setRelayState(tunnel, nil)
+
+	server := &http.Server{
+		Handler:           setupRouter(),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	go func() {
+		<-tunnel.Done()
+		_ = server.Close()
+	}()
+	if err := server.Serve(tunnel); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return fmt.Errorf("relay connection closed")
+}
+
+// RunRelayClient keeps the tunnel to the relay up while it's enabled.
+func RunRelayClient() {
+	backoff := 5 * time.Second
+	for {
+		if !config.Relay.Enabled {
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		if !networkState.IsOnline() {
+			relayLogger.Warn().Msg("waiting for network to be online, will retry in 3 seconds")
+			time.Sleep(3 * time.Second)
+			continue
+		}
+
+		if isTimeSyncNeeded() && !timeSync.IsSyncSuccess() {
+			relayLogger.Warn().Msg("system time is not synced, will retry in 3 seconds")
+			time.Sleep(3 * time.Second)
+			continue
+		}
+
+		start := time.Now()
+		err := runRelayClient()
+		setRelayState(nil, err)
+		if time.Since(start) > time.Minute {
+			backoff = 5 * time.Second
+		}
+		relayLogger.Warn().Err(err).Dur("retry_in", backoff).Msg("relay connection lost")
+		time.Sleep(backoff)
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+func rpcGetRelayConfig() (*RelayConfig, error) {
+	return config.Relay, nil
+}
+
+func rpcSetRelayConfig(relayConfig RelayConfig) error {
+	if err := relayConfig.Validate(); err != nil {
+		return err
+	}
+
+	config.Relay = &relayConfig
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	relayLogger.Info().Bool("enabled", relayConfig.Enabled).Str("url", relayConfig.URL).Msg("relay settings updated")
+	restartRelayClient()
+	return nil
+}
+
+func rpcGetRelayState() RelayState {
+	relayLock.Lock()
+	defer relayLock.Unlock()
+	state := relayState
+	state.Enabled = config.Relay.Enabled
+	state.URL = config.Relay.URL
+	return state
+}
@@ -0,0 +1,436 @@
+package kvm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+	"github.com/jetkvm/kvm/internal/power"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	MaxScheduledTasks        = 50
+	MaxScheduledTaskNameSize = 64
+
+	// scheduledTaskTimeout bounds a single run, a graceful shutdown may take minutes
+	scheduledTaskTimeout = 10 * time.Minute
+	// scheduledTaskSyncTimeout is how long tasks wait for time sync, offline
+	// devices fall back to their clock
+	scheduledTaskSyncTimeout = 10 * time.Minute
+)
+
+// Scheduled task actions.
+const (
+	ScheduledTaskPower     = "power"
+	ScheduledTaskMount     = "mount"
+	ScheduledTaskUnmount   = "unmount"
+	ScheduledTaskWakeOnLan = "wake-on-lan"
+	ScheduledTaskMacro     = "macro"
+)
+
+// ScheduledTask runs an action on a cron schedule. CronTab has five fields,
+// or six with seconds, and is evaluated in Timezone. The run state is stored
+// with the task so the next run is known across reboots.
+type ScheduledTask struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	CronTab  string `json:"cron_tab"`
+	Timezone string `json:"timezone,omitempty"`
+	Paused   bool   `json:"paused"`
+	// RunMissed runs the task once after boot if a run was missed while the
+	// device was off
+	RunMissed bool `json:"run_missed"`
+
+	Action       string           `json:"action"`
+	PowerAction  string           `json:"power_action,omitempty"`
+	MediaURL     string           `json:"media_url,omitempty"`
+	MediaFile    string           `json:"media_file,omitempty"`
+	MediaMode    VirtualMediaMode `json:"media_mode,omitempty"`
+	WakeOnLanMAC string           `json:"wake_on_lan_mac,omitempty"`
+	MacroID      string           `json:"macro_id,omitempty"`
+
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+}
+
+func (t *ScheduledTask) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("task name cannot be empty")
+	}
+	if len(t.Name) > MaxScheduledTaskNameSize {
+		return fmt.Errorf("task name is too long (max %d)", MaxScheduledTaskNameSize)
+	}
+
+	fields := strings.Fields(t.CronTab)
+	if len(fields) != 5 && len(fields) != 6 {
+		return fmt.Errorf("cron tab must have 5 or 6 fields")
+	}
+	if strings.Contains(fields[0], "TZ=") {
+		return fmt.Errorf("set the timezone of the task instead of TZ= in the cron tab")
+	}
+	if t.Timezone != "" {
+		if _, err := time.LoadLocation(t.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %s", t.Timezone)
+		}
+	}
+	schedule, err := t.cronSchedule()
+	if err != nil {
+		return fmt.Errorf("invalid cron tab: %w", err)
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("cron tab never matches")
+	}
+
+	switch t.Action {
+	case ScheduledTaskPower:
+		if _, err := power.ParseAction(t.PowerAction); err != nil {
+			return err
+		}
+	case ScheduledTaskMount:
+		if (t.MediaURL == "") == (t.MediaFile == "") {
+			return fmt.Errorf("mount needs either a media url or a media file")
+		}
+		if t.MediaMode != "" && t.MediaMode != CDROM && t.MediaMode != Disk {
+			return fmt.Errorf("invalid media mode: %s", t.MediaMode)
+		}
+	case ScheduledTaskUnmount:
+	case ScheduledTaskWakeOnLan:
+		if _, err := net.ParseMAC(t.WakeOnLanMAC); err != nil {
+			return fmt.Errorf("invalid wake on lan mac address: %s", t.WakeOnLanMAC)
+		}
+	case ScheduledTaskMacro:
+		if _, ok := findKeyboardMacro(t.MacroID); !ok {
+			return fmt.Errorf("keyboard macro not found: %s", t.MacroID)
+		}
+	default:
+		return fmt.Errorf("invalid task action: %s", t.Action)
+	}
+	return nil
+}
+
+var (
+	taskScheduler gocron.Scheduler
+	// scheduledTaskLock guards config.ScheduledTasks
+	scheduledTaskLock sync.Mutex
+)
+
+// cronSpec returns the cron tab with the timezone prefix gocron expects, and
+// whether it has a seconds field.
+func (t *ScheduledTask) cronSpec() (string, bool) {
+	cronTab := strings.TrimSpace(t.CronTab)
+	withSeconds := len(strings.Fields(cronTab)) == 6
+	if t.Timezone != "" && t.Timezone != "UTC" {
+		cronTab = fmt.Sprintf("TZ=%s %s", t.Timezone, cronTab)
+	}
+	return cronTab, withSeconds
+}
+
+// cronSchedule parses the cron tab with the parser gocron uses, so schedules
+// it would refuse are refused when they're saved.
+func (t *ScheduledTask) cronSchedule() (cron.Schedule, error) {
+	spec, withSeconds := t.cronSpec()
+	if withSeconds {
+		parser := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+		return parser.Parse(spec)
+	}
+	return cron.ParseStandard(spec)
+}
+
+// cronJob returns the gocron definition of the task's schedule.
+func (t *ScheduledTask) cronJob() gocron.JobDefinition {
+	return gocron.CronJob(t.cronSpec())
+}
+
+func scheduleTask(t *ScheduledTask) error {
+	_, err := taskScheduler.NewJob(
+		t.cronJob(),
+		gocron.NewTask(runScheduledTask, t.ID),
+		gocron.WithName(t.Name),
+		gocron.WithTags(t.ID),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	return nil
+}
+
+func unscheduleTask(id string) {
+	taskScheduler.RemoveByTags(id)
+}
+
+// taskNextRun returns when the scheduled task id runs next, nil if it's not scheduled.
+func taskNextRun(id string) *time.Time {
+	for _, j := range taskScheduler.Jobs() {
+		for _, tag := range j.Tags() {
+			if tag != id {
+				continue
+			}
+			next, err := j.NextRun()
+			if err != nil || next.IsZero() {
+				return nil
+			}
+			next = next.UTC()
+			return &next
+		}
+	}
+	return nil
+}
+
+// findScheduledTask returns the index of task id, -1 if there's none.
+// scheduledTaskLock must be held.
+func findScheduledTask(id string) int {
+	for i := range config.ScheduledTasks {
+		if config.ScheduledTasks[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func initScheduledTasks() {
+	ensureConfigLoaded()
+
+	s, err := gocron.NewScheduler()
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to create task scheduler")
+		return
+	}
+	taskScheduler = s
+
+	go startScheduledTasks()
+}
+
+// startScheduledTasks schedules the tasks and starts the scheduler once the
+// clock is synced, runs computed from the clock before would be off. Devices
+// without a reachable time source start after scheduledTaskSyncTimeout if the
+// clock is plausible. Tasks created before are scheduled again.
+func startScheduledTasks() {
+	deadline := time.Now().Add(scheduledTaskSyncTimeout)
+	for isTimeSyncNeeded() || !timeSync.IsSyncSuccess() {
+		if !isTimeSyncNeeded() && time.Now().After(deadline) {
+			logger.Warn().Msg("time sync didn't succeed, scheduling tasks with the device clock")
+			break
+		}
+		time.Sleep(30 * time.Second)
+	}
+
+	scheduledTaskLock.Lock()
+	for i := range config.ScheduledTasks {
+		t := &config.ScheduledTasks[i]
+		if t.Paused {
+			continue
+		}
+		unscheduleTask(t.ID)
+		if err := scheduleTask(t); err != nil {
+			logger.Warn().Err(err).Str("task", t.Name).Msg("failed to schedule task")
+		}
+	}
+	scheduledTaskLock.Unlock()
+	taskScheduler.Start()
+
+	runMissedScheduledTasks()
+}
+
+// missedScheduledTasks returns the tasks that run missed runs and whose
+// stored next run is before now.
+func missedScheduledTasks(tasks []ScheduledTask, now time.Time) []string {
+	var missed []string
+	for _, t := range tasks {
+		if !t.Paused && t.RunMissed && t.NextRun != nil && t.NextRun.Before(now) {
+			missed = append(missed, t.ID)
+		}
+	}
+	return missed
+}
+
+// runMissedScheduledTasks runs the tasks whose stored next run passed while
+// the device was off, it's called once the clock is synced.
+func runMissedScheduledTasks() {
+	scheduledTaskLock.Lock()
+	missed := missedScheduledTasks(config.ScheduledTasks, time.Now())
+	for i := range config.ScheduledTasks {
+		t := &config.ScheduledTasks[i]
+		if !t.Paused {
+			t.NextRun = taskNextRun(t.ID)
+		}
+	}
+	if err := SaveConfig(); err != nil {
+		logger.Warn().Err(err).Msg("failed to save scheduled tasks")
+	}
+	scheduledTaskLock.Unlock()
+
+	for _, id := range missed {
+		logger.Info().Str("task", id).Msg("running missed scheduled task")
+		runScheduledTask(id)
+	}
+}
+
+func executeScheduledTask(ctx context.Context, t *ScheduledTask) error {
+	switch t.Action {
+	case ScheduledTaskPower:
+		_, err := runPowerAction(ctx, t.PowerAction)
+		return err
+	case ScheduledTaskMount:
+		mode := t.MediaMode
+		if mode == "" {
+			mode = CDROM
+		}
+		if t.MediaURL != "" {
+			return rpcMountWithHTTP(t.MediaURL, mode)
+		}
+		return rpcMountWithStorage(t.MediaFile, mode)
+	case ScheduledTaskUnmount:
+		return rpcUnmountImage()
+	case ScheduledTaskWakeOnLan:
+		return rpcSendWOLMagicPacket(t.WakeOnLanMAC)
+	case ScheduledTaskMacro:
+		return runKeyboardMacro(t.MacroID)
+	}
+	return fmt.Errorf("invalid task action: %s", t.Action)
+}
+
+func runScheduledTask(id string) {
+	scheduledTaskLock.Lock()
+	i := findScheduledTask(id)
+	if i < 0 {
+		scheduledTaskLock.Unlock()
+		return
+	}
+	task := config.ScheduledTasks[i]
+	scheduledTaskLock.Unlock()
+
+	logger.Info().Str("task", task.Name).Str("action", task.Action).Msg("running scheduled task")
+	ctx, cancel := context.WithTimeout(context.Background(), scheduledTaskTimeout)
+	err := executeScheduledTask(ctx, &task)
+	cancel()
+	if err != nil {
+		logger.Warn().Err(err).Str("task", task.Name).Msg("scheduled task failed")
+	}
+
+	scheduledTaskLock.Lock()
+	defer scheduledTaskLock.Unlock()
+
+	i = findScheduledTask(id)
+	if i < 0 {
+		return
+	}
+	t := &config.ScheduledTasks[i]
+	now := time.Now().UTC()
+	t.LastRun = &now
+	t.LastError = ""
+	if err != nil {
+		t.LastError = err.Error()
+	}
+	t.NextRun = taskNextRun(id)
+	if err := SaveConfig(); err != nil {
+		logger.Warn().Err(err).Msg("failed to save scheduled tasks")
+	}
+
+	if currentSession != nil {
+		writeJSONRPCEvent("scheduledTaskRun", *t, currentSession)
+	}
+}
+
+func rpcGetScheduledTasks() ([]ScheduledTask, error) {
+	scheduledTaskLock.Lock()
+	defer scheduledTaskLock.Unlock()
+
+	return append([]ScheduledTask{}, config.ScheduledTasks...), nil
+}
+
+func rpcCreateScheduledTask(task ScheduledTask) (ScheduledTask, error) {
+	if taskScheduler == nil {
+		return ScheduledTask{}, fmt.Errorf("task scheduler is not running")
+	}
+	if err := task.Validate(); err != nil {
+		return ScheduledTask{}, err
+	}
+
+	scheduledTaskLock.Lock()
+	defer scheduledTaskLock.Unlock()
+
+	if len(config.ScheduledTasks) >= MaxScheduledTasks {
+		return ScheduledTask{}, fmt.Errorf("too many scheduled tasks (max %d)", MaxScheduledTasks)
+	}
+
+	task.ID = uuid.NewString()
+	task.LastRun = nil
+	task.LastError = ""
+	task.NextRun = nil
+	if !task.Paused {
+		if err := scheduleTask(&task); err != nil {
+			return ScheduledTask{}, err
+		}
+		task.NextRun = taskNextRun(task.ID)
+	}
+
+	config.ScheduledTasks = append(config.ScheduledTasks, task)
+	if err := SaveConfig(); err != nil {
+		config.ScheduledTasks = config.ScheduledTasks[:len(config.ScheduledTasks)-1]
+		unscheduleTask(task.ID)
+		return ScheduledTask{}, fmt.Errorf("failed to save config: %w", err)
+	}
+	logger.Info().Str("task", task.Name).Str("cron_tab", task.CronTab).Msg("scheduled task created")
+	return task, nil
+}
+
+func rpcSetScheduledTaskPaused(id string, paused bool) (ScheduledTask, error) {
+	if taskScheduler == nil {
+		return ScheduledTask{}, fmt.Errorf("task scheduler is not running")
+	}
+
+	scheduledTaskLock.Lock()
+	defer scheduledTaskLock.Unlock()
+
+	i := findScheduledTask(id)
+	if i < 0 {
+		return ScheduledTask{}, fmt.Errorf("scheduled task not found: %s", id)
+	}
+	t := &config.ScheduledTasks[i]
+	if t.Paused == paused {
+		return *t, nil
+	}
+
+	if paused {
+		unscheduleTask(id)
+		t.NextRun = nil
+	} else {
+		if err := scheduleTask(t); err != nil {
+			return ScheduledTask{}, err
+		}
+		t.NextRun = taskNextRun(id)
+	}
+	t.Paused = paused
+	if err := SaveConfig(); err != nil {
+		return ScheduledTask{}, fmt.Errorf("failed to save config: %w", err)
+	}
+	return *t, nil
+}
+
+func rpcDeleteScheduledTask(id string) error {
+	if taskScheduler == nil {
+		return fmt.Errorf("task scheduler is not running")
+	}
+
+	scheduledTaskLock.Lock()
+	defer scheduledTaskLock.Unlock()
+
+	i := findScheduledTask(id)
+	if i < 0 {
+		return fmt.Errorf("scheduled task not found: %s", id)
+	}
+	unscheduleTask(id)
+	config.ScheduledTasks = append(config.ScheduledTasks[:i], config.ScheduledTasks[i+1:]...)
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package kvm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledTaskValidate(t *testing.T) {
+	valid := ScheduledTask{Name: "nightly", CronTab: "0 3 * * *", Action: ScheduledTaskPower, PowerAction: "power-cycle"}
+	assert.NoError(t, valid.Validate())
+
+	withSeconds := valid
+	withSeconds.CronTab = "30 0 3 * * *"
+	assert.NoError(t, withSeconds.Validate())
+
+	tests := map[string]func(*ScheduledTask){
+		"name":         func(task *ScheduledTask) { task.Name = "" },
+		"fields":       func(task *ScheduledTask) { task.CronTab = "0 3 * *" },
+		"tz prefix":    func(task *ScheduledTask) { task.CronTab = "TZ=Europe/Berlin 0 3 * * *" },
+		"syntax":       func(task *ScheduledTask) { task.CronTab = "0 25 * * *" },
+		"never":        func(task *ScheduledTask) { task.CronTab = "0 0 30 2 *" },
+		"timezone":     func(task *ScheduledTask) { task.Timezone = "Mars/Olympus" },
+		"power action": func(task *ScheduledTask) { task.PowerAction = "explode" },
+		"mac":          func(task *ScheduledTask) { task.Action, task.WakeOnLanMAC = ScheduledTaskWakeOnLan, "nope" },
+		"mount":        func(task *ScheduledTask) { task.Action = ScheduledTaskMount },
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			task := valid
+			modify(&task)
+			assert.Error(t, task.Validate())
+		})
+	}
+}
+
+func TestScheduledTaskCronSpec(t *testing.T) {
+	task := &ScheduledTask{CronTab: " 0 3 * * * "}
+	spec, withSeconds := task.cronSpec()
+	assert.Equal(t, "0 3 * * *", spec)
+	assert.False(t, withSeconds)
+
+	task.Timezone = "UTC"
+	spec, _ = task.cronSpec()
+	assert.Equal(t, "0 3 * * *", spec, "UTC needs no prefix")
+
+	task = &ScheduledTask{CronTab: "30 0 3 * * 1-5", Timezone: "Europe/Berlin"}
+	spec, withSeconds = task.cronSpec()
+	assert.Equal(t, "TZ=Europe/Berlin 30 0 3 * * 1-5", spec)
+	assert.True(t, withSeconds)
+
+	// gocron accepts the definitions
+	s, err := gocron.NewScheduler()
+	require.NoError(t, err)
+	defer s.Shutdown() // nolint:errcheck
+	for _, task := range []*ScheduledTask{task, {CronTab: "*/5 * * * *", Timezone: "America/New_York"}} {
+		_, err := s.NewJob(task.cronJob(), gocron.NewTask(func() {}))
+		assert.NoError(t, err, task.CronTab)
+	}
+}
+
+func TestMissedScheduledTasks(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	tasks := []ScheduledTask{
+		{ID: "missed", RunMissed: true, NextRun: &past},
+		{ID: "not-run-missed", NextRun: &past},
+		{ID: "paused", RunMissed: true, Paused: true, NextRun: &past},
+		{ID: "upcoming", RunMissed: true, NextRun: &future},
+		{ID: "never-scheduled", RunMissed: true},
+	}
+	assert.Equal(t, []string{"missed"}, missedScheduledTasks(tasks, now))
+}
@@ -0,0 +1,102 @@
+package kvm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/screenshot"
+)
+
+// screenshotTimeout is how long a capture waits for a keyframe.
+const screenshotTimeout = 10 * time.Second
+
+var (
+	screenGrabber = screenshot.NewGrabber(&screenshot.Options{})
+
+	// screenCaptures counts the captures that keep the video running without a session
+	screenCapturesLock sync.Mutex
+	screenCaptures     int
+)
+
+func isScreenCaptureActive() bool {
+	screenCapturesLock.Lock()
+	defer screenCapturesLock.Unlock()
+
+	return screenCaptures > 0
+}
+
+// holdScreenCapture keeps the video stream running until release is called,
+// it's started if no session is connected.
+func holdScreenCapture() (release func(), err error) {
+	if nativeInstance == nil {
+		return nil, fmt.Errorf("video is not available")
+	}
+
+	screenCapturesLock.Lock()
+	defer screenCapturesLock.Unlock()
+
+	screenCaptures++
+	if actionSessions == 0 && screenCaptures == 1 {
+		if err := nativeInstance.VideoStart(); err != nil {
+			screenCaptures--
+			return nil, fmt.Errorf("failed to start video: %w", err)
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			screenCapturesLock.Lock()
+			defer screenCapturesLock.Unlock()
+
+			screenCaptures--
+			if screenCaptures == 0 && actionSessions == 0 {
+				_ = nativeInstance.VideoStop()
+				screenGrabber.Reset()
+			}
+		})
+	}, nil
+}
+
+// captureScreen decodes the current video frame. The video stream is started
+// for the capture if no session is connected.
+func captureScreen(ctx context.Context) (image.Image, error) {
+	release, err := holdScreenCapture()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, screenshotTimeout)
+	defer cancel()
+
+	return screenGrabber.Capture(ctx)
+}
+
+func captureScreenPNG(ctx context.Context) ([]byte, error) {
+	img, err := captureScreen(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode screenshot: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// rpcGetScreenshot returns the current screen as a base64 encoded PNG.
+func rpcGetScreenshot() (string, error) {
+	data, err := captureScreenPNG(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
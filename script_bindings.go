@@ -0,0 +1,436 @@
+package kvm
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/extension"
+	"github.com/jetkvm/kvm/internal/hidrpc"
+	"github.com/jetkvm/kvm/internal/keyboardlayout"
+	"github.com/jetkvm/kvm/internal/script"
+	"github.com/jetkvm/kvm/internal/vision"
+)
+
+const (
+	screenshotsDir = "/userdata/jetkvm/screenshots"
+
+	// scriptKeyPressDuration is how long key() and mouse clicks hold the keys down
+	scriptKeyPressDuration = 100 * time.Millisecond
+	// scriptPowerPollInterval is how often wait_power checks the power state
+	scriptPowerPollInterval = 500 * time.Millisecond
+)
+
+var scriptMouseButtons = map[string]uint8{
+	"left":    1 << 0,
+	"right":   1 << 1,
+	"middle":  1 << 2,
+	"back":    1 << 3,
+	"forward": 1 << 4,
+}
+
+var screenshotNamePattern = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// sleepContext sleeps for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func durationArg(args []script.Value, i int, def time.Duration) (time.Duration, error) {
+	ms, err := script.OptNumberArg(args, i, float64(def.Milliseconds()))
+	if err != nil {
+		return 0, err
+	}
+	if ms < 0 {
+		return 0, fmt.Errorf("argument #%d: duration must not be negative", i+1)
+	}
+	return time.Duration(ms * float64(time.Millisecond)), nil
+}
+
+// scriptKeyCombo presses the named keys together and releases them.
+func scriptKeyCombo(ctx context.Context, names []string) error {
+	var modifier byte
+	keys := make([]byte, hidrpc.HidKeyBufferSize)
+	n := 0
+
+	for _, name := range names {
+		code, ok := keyboardlayout.KeyCode(name)
+		if !ok {
+			return fmt.Errorf("unknown key: %s", name)
+		}
+
+		// ControlLeft (0xE0) to MetaRight (0xE7) are modifiers
+		if code >= 0xE0 && code <= 0xE7 {
+			modifier |= 1 << (code - 0xE0)
+			continue
+		}
+		if n >= len(keys) {
+			return fmt.Errorf("too many keys, at most %d can be pressed together", len(keys))
+		}
+		keys[n] = code
+		n++
+	}
+
+	return rpcDoExecuteKeyboardMacro(ctx, []hidrpc.KeyboardMacroStep{
+		{Modifier: modifier, Keys: keys, Delay: uint16(scriptKeyPressDuration.Milliseconds())},
+		{Modifier: 0, Keys: keyboardClearStateKeys, Delay: 0},
+	})
+}
+
+// powerState returns the host power state from the active power extension.
+func powerState() (bool, error) {
+	switch config.ActiveExtension {
+	case extension.ATXID:
+		return atxExtension.ATXState().Power, nil
+	case extension.DCID:
+		return dcExtension.DCPowerState().IsOn, nil
+	}
+	return false, fmt.Errorf("no power extension active")
+}
+
+func scriptBuiltins(run *scriptRun) map[string]script.Func {
+	return map[string]script.Func{
+		"log": func(_ context.Context, args []script.Value) (script.Value, error) {
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				parts[i] = script.ToString(arg)
+			}
+			run.logf("%s", strings.Join(parts, " "))
+			return nil, nil
+		},
+
+		// sleep(ms)
+		"sleep": func(ctx context.Context, args []script.Value) (script.Value, error) {
+			d, err := durationArg(args, 0, 0)
+			if err != nil {
+				return nil, err
+			}
+			return nil, sleepContext(ctx, d)
+		},
+
+		// key(name, ...) presses a key combination, e.g. key("ControlLeft", "AltLeft", "Delete")
+		"key": func(ctx context.Context, args []script.Value) (script.Value, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("argument #1: key name expected")
+			}
+			names := make([]string, len(args))
+			for i := range args {
+				name, err := script.StringArg(args, i)
+				if err != nil {
+					return nil, err
+				}
+				names[i] = name
+			}
+			return nil, scriptKeyCombo(ctx, names)
+		},
+
+		// type(text[, delay]) types text with the configured keyboard layout
+		"type": func(ctx context.Context, args []script.Value) (script.Value, error) {
+			text, err := script.StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			delay, err := durationArg(args, 1, typeTextDefaultDelay*time.Millisecond)
+			if err != nil {
+				return nil, err
+			}
+			if delay > typeTextMaxDelay*time.Millisecond {
+				return nil, fmt.Errorf("argument #2: delay must be at most %d", typeTextMaxDelay)
+			}
+
+			layout, err := currentKeyboardLayout()
+			if err != nil {
+				return nil, err
+			}
+			steps, err := textToMacroSteps(layout, text, uint16(delay.Milliseconds()))
+			if err != nil {
+				return nil, err
+			}
+			return nil, rpcDoExecuteKeyboardMacro(ctx, steps)
+		},
+
+		// mouse_move(x, y) moves the pointer, coordinates are 0 to 32767
+		"mouse_move": func(_ context.Context, args []script.Value) (script.Value, error) {
+			x, err := script.NumberArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			y, err := script.NumberArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			return nil, rpcAbsMouseReport(int(x), int(y), 0)
+		},
+
+		// mouse_click(x, y[, button]) clicks at a position, button is left, right, middle, back or forward
+		"mouse_click": func(ctx context.Context, args []script.Value) (script.Value, error) {
+			x, err := script.NumberArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			y, err := script.NumberArg(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			name, err := script.OptStringArg(args, 2, "left")
+			if err != nil {
+				return nil, err
+			}
+			button, ok := scriptMouseButtons[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown mouse button: %s", name)
+			}
+
+			if err := rpcAbsMouseReport(int(x), int(y), button); err != nil {
+				return nil, err
+			}
+			sleepErr := sleepContext(ctx, scriptKeyPressDuration)
+			if err := rpcAbsMouseReport(int(x), int(y), 0); err != nil {
+				return nil, err
+			}
+			return nil, sleepErr
+		},
+
+		// scroll(y[, x]) scrolls the wheel by y steps, negative is down
+		"scroll": func(_ context.Context, args []script.Value) (script.Value, error) {
+			y, err := script.NumberArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			x, err := script.OptNumberArg(args, 1, 0)
+			if err != nil {
+				return nil, err
+			}
+			if y < -127 || y > 127 || x < -127 || x > 127 {
+				return nil, fmt.Errorf("scroll steps must be between -127 and 127")
+			}
+			return nil, rpcScrollReport(int8(y), int8(x))
+		},
+
+		// atx(action) presses an ATX button: power-short, power-long or reset
+		"atx": func(_ context.Context, args []script.Value) (script.Value, error) {
+			action, err := script.StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return nil, rpcSetATXPowerAction(action)
+		},
+
+		// dc(on) switches the DC power
+		"dc": func(_ context.Context, args []script.Value) (script.Value, error) {
+			on, err := script.BoolArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return nil, rpcSetDCPowerState(on)
+		},
+
+		// power_action(action) runs on, graceful-off, force-off or power-cycle and
+		// waits until the host reached the expected state
+		"power_action": func(ctx context.Context, args []script.Value) (script.Value, error) {
+			action, err := script.StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			_, err = runPowerAction(ctx, action)
+			return nil, err
+		},
+
+		// power() returns whether the host is powered on
+		"power": func(_ context.Context, _ []script.Value) (script.Value, error) {
+			return powerState()
+		},
+
+		// wait_power(on, timeout) waits for the power state, it returns false on timeout
+		"wait_power": func(ctx context.Context, args []script.Value) (script.Value, error) {
+			on, err := script.BoolArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			timeout, err := durationArg(args, 1, time.Minute)
+			if err != nil {
+				return nil, err
+			}
+
+			deadline := time.Now().Add(timeout)
+			for {
+				state, err := powerState()
+				if err != nil {
+					return nil, err
+				}
+				if state == on {
+					return true, nil
+				}
+				if time.Now().After(deadline) {
+					return false, nil
+				}
+				if err := sleepContext(ctx, scriptPowerPollInterval); err != nil {
+					return nil, err
+				}
+			}
+		},
+
+		// mount(url[, mode]) mounts an image from a URL, mode is CDROM (default) or Disk
+		"mount": func(_ context.Context, args []script.Value) (script.Value, error) {
+			url, err := script.StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			mode, err := script.OptStringArg(args, 1, string(CDROM))
+			if err != nil {
+				return nil, err
+			}
+			return nil, rpcMountWithHTTP(url, VirtualMediaMode(mode))
+		},
+
+		// mount_file(filename[, mode]) mounts an image uploaded to the device
+		"mount_file": func(_ context.Context, args []script.Value) (script.Value, error) {
+			filename, err := script.StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			mode, err := script.OptStringArg(args, 1, string(CDROM))
+			if err != nil {
+				return nil, err
+			}
+			return nil, rpcMountWithStorage(filename, VirtualMediaMode(mode))
+		},
+
+		"unmount": func(_ context.Context, _ []script.Value) (script.Value, error) {
+			return nil, rpcUnmountImage()
+		},
+
+		// serial_write(text) writes to the serial console
+		"serial_write": func(_ context.Context, args []script.Value) (script.Value, error) {
+			text, err := script.StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return nil, writeSerialConsole([]byte(text))
+		},
+
+		// serial_expect(pattern, timeout) waits for the regular expression on the
+		// serial console, it returns the match or nil on timeout
+		"serial_expect": func(ctx context.Context, args []script.Value) (script.Value, error) {
+			pattern, err := script.StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern: %w", err)
+			}
+			timeout, err := durationArg(args, 1, 30*time.Second)
+			if err != nil {
+				return nil, err
+			}
+
+			match, err := serialExpect(ctx, re, timeout)
+			if err != nil || match == "" {
+				return nil, err
+			}
+			return match, nil
+		},
+
+		// screen_text() returns the text recognized on the screen
+		"screen_text": func(ctx context.Context, _ []script.Value) (script.Value, error) {
+			img, err := captureScreen(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return vision.Text(vision.Recognize(img)), nil
+		},
+
+		// wait_text(text, timeout) waits for text on the screen, it returns the
+		// line the text was found in or nil on timeout
+		"wait_text": func(ctx context.Context, args []script.Value) (script.Value, error) {
+			text, err := script.StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			timeout, err := durationArg(args, 1, screenWaitDefaultTimeout)
+			if err != nil {
+				return nil, err
+			}
+
+			match, err := waitForText(ctx, text, timeout)
+			if err != nil || match == nil {
+				return nil, err
+			}
+			return match.Line, nil
+		},
+
+		// wait_image(path, timeout) waits for a PNG or JPEG image stored on the
+		// device, e.g. a cropped screenshot, it returns false on timeout
+		"wait_image": func(ctx context.Context, args []script.Value) (script.Value, error) {
+			path, err := script.StringArg(args, 0)
+			if err != nil {
+				return nil, err
+			}
+			timeout, err := durationArg(args, 1, screenWaitDefaultTimeout)
+			if err != nil {
+				return nil, err
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open image: %w", err)
+			}
+			tpl, _, err := image.Decode(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode image: %w", err)
+			}
+
+			match, err := waitForImage(ctx, tpl, timeout)
+			if err != nil {
+				return nil, err
+			}
+			return match != nil, nil
+		},
+
+		// screenshot([name]) saves the screen as a PNG on the device and returns its path
+		"screenshot": func(ctx context.Context, args []script.Value) (script.Value, error) {
+			name, err := script.OptStringArg(args, 0, "")
+			if err != nil {
+				return nil, err
+			}
+			if name == "" {
+				name = fmt.Sprintf("%s-%s", run.snapshot().Name, time.Now().UTC().Format("20060102-150405"))
+			}
+			name = strings.Trim(screenshotNamePattern.ReplaceAllString(name, "_"), "._")
+			if name == "" {
+				name = "screenshot"
+			}
+
+			data, err := captureScreenPNG(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := os.MkdirAll(screenshotsDir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create screenshot directory: %w", err)
+			}
+			path := filepath.Join(screenshotsDir, name+".png")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				return nil, fmt.Errorf("failed to save screenshot: %w", err)
+			}
+
+			run.logf("screenshot saved to %s", path)
+			return path, nil
+		},
+	}
+}
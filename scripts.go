@@ -0,0 +1,258 @@
+package kvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/script"
+)
+
+const (
+	scriptsDir = "/userdata/jetkvm/scripts"
+
+	// scriptProgressInterval limits how often the current line is reported
+	scriptProgressInterval = 250 * time.Millisecond
+	// scriptMaxLogEntries is the number of log entries kept for the last run
+	scriptMaxLogEntries = 500
+)
+
+var scriptStore = script.NewStore(&script.StoreOptions{Dir: scriptsDir})
+
+// ScriptRunState is the state of a script run, it's sent as the
+// scriptRunState event whenever it changes.
+type ScriptRunState struct {
+	RunID      string     `json:"runId"`
+	ScriptID   string     `json:"scriptId"`
+	Name       string     `json:"name"`
+	Version    int        `json:"version"`
+	State      string     `json:"state"` // running, finished, failed or cancelled
+	Line       int        `json:"line"`
+	Result     string     `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// ScriptLogEntry is a line logged by a script, it's sent as the scriptLog event.
+type ScriptLogEntry struct {
+	RunID   string    `json:"runId"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// ScriptRun is the state and log of the current or last script run.
+type ScriptRun struct {
+	ScriptRunState
+	Log []ScriptLogEntry `json:"log"`
+}
+
+type scriptRun struct {
+	lock         sync.Mutex
+	state        ScriptRunState
+	log          []ScriptLogEntry
+	cancel       context.CancelFunc
+	lastProgress time.Time
+}
+
+var (
+	scriptRunLock sync.Mutex
+	lastScriptRun *scriptRun
+)
+
+// writeScriptEvent sends a script event to the current session, scripts also run without one.
+func writeScriptEvent(event string, params any) {
+	if currentSession != nil {
+		writeJSONRPCEvent(event, params, currentSession)
+	}
+}
+
+func (r *scriptRun) snapshot() ScriptRun {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return ScriptRun{
+		ScriptRunState: r.state,
+		Log:            append([]ScriptLogEntry{}, r.log...),
+	}
+}
+
+func (r *scriptRun) running() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.state.State == "running"
+}
+
+func (r *scriptRun) logf(format string, args ...any) {
+	entry := ScriptLogEntry{
+		Time:    time.Now().UTC(),
+		Message: fmt.Sprintf(format, args...),
+	}
+
+	r.lock.Lock()
+	entry.RunID = r.state.RunID
+	r.log = append(r.log, entry)
+	if len(r.log) > scriptMaxLogEntries {
+		r.log = r.log[len(r.log)-scriptMaxLogEntries:]
+	}
+	scriptName := r.state.Name
+	r.lock.Unlock()
+
+	logger.Info().Str("script", scriptName).Str("message", entry.Message).Msg("script log")
+	writeScriptEvent("scriptLog", entry)
+}
+
+func (r *scriptRun) onLine(line int) {
+	r.lock.Lock()
+	if r.state.Line == line || time.Since(r.lastProgress) < scriptProgressInterval {
+		r.state.Line = line
+		r.lock.Unlock()
+		return
+	}
+	r.state.Line = line
+	r.lastProgress = time.Now()
+	state := r.state
+	r.lock.Unlock()
+
+	writeScriptEvent("scriptRunState", state)
+}
+
+func (r *scriptRun) finish(result script.Value, err error) {
+	r.lock.Lock()
+	now := time.Now().UTC()
+	r.state.FinishedAt = &now
+	switch {
+	case err == nil:
+		r.state.State = "finished"
+		if result != nil {
+			r.state.Result = script.ToString(result)
+		}
+	case errors.Is(err, context.Canceled):
+		r.state.State = "cancelled"
+	default:
+		r.state.State = "failed"
+		r.state.Error = err.Error()
+	}
+	state := r.state
+	r.lock.Unlock()
+
+	logger.Info().
+		Str("script", state.Name).
+		Int("version", state.Version).
+		Str("state", state.State).
+		Str("error", state.Error).
+		Msg("script run finished")
+	writeScriptEvent("scriptRunState", state)
+}
+
+func newRunID() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano())
+}
+
+// startScript runs a stored script in the background, only one script runs at a time.
+func startScript(id string, version int) (ScriptRunState, error) {
+	s, v, err := scriptStore.GetVersion(id, version)
+	if err != nil {
+		return ScriptRunState{}, err
+	}
+
+	program, err := script.Compile(v.Source)
+	if err != nil {
+		return ScriptRunState{}, fmt.Errorf("failed to compile script: %w", err)
+	}
+
+	scriptRunLock.Lock()
+	defer scriptRunLock.Unlock()
+
+	if lastScriptRun != nil && lastScriptRun.running() {
+		return ScriptRunState{}, fmt.Errorf("script %s is already running", lastScriptRun.snapshot().Name)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &scriptRun{
+		state: ScriptRunState{
+			RunID:     newRunID(),
+			ScriptID:  s.ID,
+			Name:      s.Name,
+			Version:   v.Version,
+			State:     "running",
+			StartedAt: time.Now().UTC(),
+		},
+		cancel: cancel,
+	}
+	lastScriptRun = run
+	state := run.state
+
+	logger.Info().Str("script", s.Name).Int("version", v.Version).Msg("starting script")
+	writeScriptEvent("scriptRunState", state)
+
+	go func() {
+		defer cancel()
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Warn().Interface("recover", r).Str("script", s.Name).Msg("recovered from script panic")
+				run.finish(nil, fmt.Errorf("script panicked: %v", r))
+			}
+		}()
+
+		result, err := program.Run(ctx, &script.RunOptions{
+			Builtins: scriptBuiltins(run),
+			OnLine:   run.onLine,
+		})
+		run.finish(result, err)
+	}()
+
+	return state, nil
+}
+
+func rpcGetScripts() ([]script.Summary, error) {
+	return scriptStore.List()
+}
+
+func rpcGetScript(id string) (*script.Script, error) {
+	return scriptStore.Get(id)
+}
+
+// rpcSaveScript creates a script if id is empty, otherwise it adds a new version.
+func rpcSaveScript(id string, name string, description string, source string) (*script.Script, error) {
+	s, err := scriptStore.Save(id, name, description, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save script: %w", err)
+	}
+	return s, nil
+}
+
+func rpcDeleteScript(id string) error {
+	return scriptStore.Delete(id)
+}
+
+// rpcRunScript runs a version of a script, 0 for the latest one.
+func rpcRunScript(id string, version int) (ScriptRunState, error) {
+	return startScript(id, version)
+}
+
+func rpcCancelScript() error {
+	scriptRunLock.Lock()
+	defer scriptRunLock.Unlock()
+
+	if lastScriptRun == nil || !lastScriptRun.running() {
+		return fmt.Errorf("no script is running")
+	}
+	lastScriptRun.cancel()
+	return nil
+}
+
+// rpcGetScriptRun returns the current or last script run, nil if no script ran yet.
+func rpcGetScriptRun() (*ScriptRun, error) {
+	scriptRunLock.Lock()
+	defer scriptRunLock.Unlock()
+
+	if lastScriptRun == nil {
+		return nil, nil
+	}
+	run := lastScriptRun.snapshot()
+	return &run, nil
+}
@@ -1,13 +1,10 @@
 package kvm
 
 import (
-	"bufio"
-	"fmt"
-	"io"
-	"strconv"
-	"strings"
-	"time"
+	"errors"
+	"sync/atomic"
 
+	"github.com/jetkvm/kvm/internal/serialconsole"
 	"github.com/pion/webrtc/v4"
 	"go.bug.st/serial"
 )
@@ -16,249 +13,6 @@ const serialPortPath = "/dev/ttyS3"
 
 var port serial.Port
 
-func mountATXControl() error {
-	if port == nil {
-		serialLogger.Warn().Msg("Serial port not available, skip ATX control")
-		return fmt.Errorf("serial port not available")
-	}
-	_ = port.SetMode(defaultMode)
-	go runATXControl()
-	return nil
-}
-
-func unmountATXControl() error {
-	_ = reopenSerialPort()
-	return nil
-}
-
-var (
-	ledHDDState bool
-	ledPWRState bool
-	btnRSTState bool
-	btnPWRState bool
-)
-
-func runATXControl() {
-	scopedLogger := serialLogger.With().Str("service", "atx_control").Logger()
-
-	reader := bufio.NewReader(port)
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			scopedLogger.Warn().Err(err).Msg("Error reading from serial port")
-			return
-		}
-
-		// Each line should be 4 binary digits + newline
-		if len(line) != 5 {
-			scopedLogger.Warn().Int("length", len(line)).Msg("Invalid line length")
-			continue
-		}
-
-		// Parse new states
-		newLedHDDState := line[0] == '0'
-		newLedPWRState := line[1] == '0'
-		newBtnRSTState := line[2] == '1'
-		newBtnPWRState := line[3] == '1'
-
-		if currentSession != nil {
-			writeJSONRPCEvent("atxState", ATXState{
-				Power: newLedPWRState,
-				HDD:   newLedHDDState,
-			}, currentSession)
-		}
-
-		if newLedHDDState != ledHDDState ||
-			newLedPWRState != ledPWRState ||
-			newBtnRSTState != btnRSTState ||
-			newBtnPWRState != btnPWRState {
-			scopedLogger.Debug().
-				Bool("hdd", newLedHDDState).
-				Bool("pwr", newLedPWRState).
-				Bool("rst", newBtnRSTState).
-				Bool("pwr", newBtnPWRState).
-				Msg("Status changed")
-
-			// Update states
-			ledHDDState = newLedHDDState
-			ledPWRState = newLedPWRState
-			btnRSTState = newBtnRSTState
-			btnPWRState = newBtnPWRState
-		}
-	}
-}
-
-func pressATXPowerButton(duration time.Duration) error {
-	_, err := port.Write([]byte("\n"))
-	if err != nil {
-		return err
-	}
-
-	_, err = port.Write([]byte("BTN_PWR_ON\n"))
-	if err != nil {
-		return err
-	}
-
-	time.Sleep(duration)
-
-	_, err = port.Write([]byte("BTN_PWR_OFF\n"))
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func pressATXResetButton(duration time.Duration) error {
-	_, err := port.Write([]byte("\n"))
-	if err != nil {
-		return err
-	}
-
-	_, err = port.Write([]byte("BTN_RST_ON\n"))
-	if err != nil {
-		return err
-	}
-
-	time.Sleep(duration)
-
-	_, err = port.Write([]byte("BTN_RST_OFF\n"))
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func mountDCControl() error {
-	if port == nil {
-		serialLogger.Warn().Msg("Serial port not available, skip DC control")
-		return fmt.Errorf("serial port not available")
-	}
-	_ = port.SetMode(defaultMode)
-	registerDCMetrics()
-	go runDCControl()
-	return nil
-}
-
-func unmountDCControl() error {
-	_ = reopenSerialPort()
-	return nil
-}
-
-var dcState DCPowerState
-
-func runDCControl() {
-	scopedLogger := serialLogger.With().Str("service", "dc_control").Logger()
-	reader := bufio.NewReader(port)
-	hasRestoreFeature := false
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			scopedLogger.Warn().Err(err).Msg("Error reading from serial port")
-			return
-		}
-
-		// Split the line by semicolon
-		parts := strings.Split(strings.TrimSpace(line), ";")
-		if len(parts) == 5 {
-			scopedLogger.Debug().Str("line", line).Msg("Detected DC extension with restore feature")
-			hasRestoreFeature = true
-		} else if len(parts) == 4 {
-			scopedLogger.Debug().Str("line", line).Msg("Detected DC extension without restore feature")
-			hasRestoreFeature = false
-		} else {
-			scopedLogger.Warn().Str("line", line).Msg("Invalid line")
-			continue
-		}
-
-		// Parse new states
-		powerState, err := strconv.Atoi(parts[0])
-		if err != nil {
-			scopedLogger.Warn().Err(err).Msg("Invalid power state")
-			continue
-		}
-		dcState.IsOn = powerState == 1
-		if hasRestoreFeature {
-			restoreState, err := strconv.Atoi(parts[4])
-			if err != nil {
-				scopedLogger.Warn().Err(err).Msg("Invalid restore state")
-				continue
-			}
-			dcState.RestoreState = restoreState
-		} else {
-			// -1 means not supported
-			dcState.RestoreState = -1
-		}
-		milliVolts, err := strconv.ParseFloat(parts[1], 64)
-		if err != nil {
-			scopedLogger.Warn().Err(err).Msg("Invalid voltage")
-			continue
-		}
-		volts := milliVolts / 1000 // Convert mV to V
-
-		milliAmps, err := strconv.ParseFloat(parts[2], 64)
-		if err != nil {
-			scopedLogger.Warn().Err(err).Msg("Invalid current")
-			continue
-		}
-		amps := milliAmps / 1000 // Convert mA to A
-
-		milliWatts, err := strconv.ParseFloat(parts[3], 64)
-		if err != nil {
-			scopedLogger.Warn().Err(err).Msg("Invalid power")
-			continue
-		}
-		watts := milliWatts / 1000 // Convert mW to W
-
-		dcState.Voltage = volts
-		dcState.Current = amps
-		dcState.Power = watts
-
-		// Update Prometheus metrics
-		updateDCMetrics(dcState)
-
-		if currentSession != nil {
-			writeJSONRPCEvent("dcState", dcState, currentSession)
-		}
-	}
-}
-
-func setDCPowerState(on bool) error {
-	_, err := port.Write([]byte("\n"))
-	if err != nil {
-		return err
-	}
-	command := "PWR_OFF\n"
-	if on {
-		command = "PWR_ON\n"
-	}
-	_, err = port.Write([]byte(command))
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func setDCRestoreState(state int) error {
-	_, err := port.Write([]byte("\n"))
-	if err != nil {
-		return err
-	}
-	command := "RESTORE_MODE_OFF\n"
-	switch state {
-	case 1:
-		command = "RESTORE_MODE_ON\n"
-	case 2:
-		command = "RESTORE_MODE_LAST_STATE\n"
-	}
-	_, err = port.Write([]byte(command))
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 var defaultMode = &serial.Mode{
 	BaudRate: 115200,
 	DataBits: 8,
@@ -267,17 +21,20 @@ var defaultMode = &serial.Mode{
 }
 
 func initSerialPort() {
+	if mode, err := parseSerialSettings(*config.SerialSettings); err != nil {
+		serialLogger.Warn().Err(err).Msg("Invalid serial settings, using default")
+	} else {
+		serialPortMode = mode
+	}
+
 	_ = reopenSerialPort()
 	if port == nil {
 		serialLogger.Warn().Msg("Serial port unavailable, disabling serial features")
-		return
-	}
-	switch config.ActiveExtension {
-	case "atx-power":
-		_ = mountATXControl()
-	case "dc-power":
-		_ = mountDCControl()
+	} else if config.ActiveExtension != "" {
+		_ = mountExtension(config.ActiveExtension)
 	}
+	// 即使物理串口不可用，USB 串口仍可作为控制台
+	initSerialConsole()
 }
 
 func reopenSerialPort() error {
@@ -285,14 +42,15 @@ func reopenSerialPort() error {
 		port.Close()
 	}
 	var err error
-	port, err = serial.Open(serialPortPath, defaultMode)
+	port, err = serial.Open(serialPortPath, serialPortMode)
 	if err != nil {
 		serialLogger.Error().
 			Err(err).
 			Str("path", serialPortPath).
-			Interface("mode", defaultMode).
+			Interface("mode", serialPortMode).
 			Msg("Error opening serial port")
 	}
+	updateSerialConsoleReader()
 	return nil
 }
 
@@ -300,45 +58,34 @@ func handleSerialChannel(d *webrtc.DataChannel) {
 	scopedLogger := serialLogger.With().
 		Uint16("data_channel_id", *d.ID()).Logger()
 
+	var channelClient atomic.Pointer[serialconsole.Client]
 	d.OnOpen(func() {
 		// 串口不可用时，直接提示并关闭通道，避免后续读写
-		if port == nil {
+		if !serialConsoleAvailable() {
 			scopedLogger.Warn().Msg("Serial port not available, closing serial data channel")
 			_ = d.SendText("serial disabled")
 			d.Close()
 			return
 		}
-		// 抓取串口快照，避免全局变量在其他协程中变化导致竞态
-		p := port
-		go func() {
-			defer func() {
-				if r := recover(); r != nil {
-					scopedLogger.Warn().Interface("recover", r).Msg("Recovered from serial reader panic")
-				}
-			}()
-			buf := make([]byte, 1024)
-			for {
-				n, err := p.Read(buf)
-				if err != nil {
-					if err != io.EOF {
-						scopedLogger.Warn().Err(err).Msg("Failed to read from serial port")
-					}
-					return
-				}
-				if err := d.Send(buf[:n]); err != nil {
-					scopedLogger.Warn().Err(err).Msg("Failed to send serial output")
-					return
-				}
+		// 作为串口控制台服务的客户端接入，先回放缓冲的历史输出，输入需持有写锁
+		client := serialConsoleServer.Attach("webrtc", func(b []byte) {
+			if err := d.Send(b); err != nil {
+				scopedLogger.Warn().Err(err).Msg("Failed to send serial output")
 			}
-		}()
+		})
+		channelClient.Store(client)
+		d.OnClose(func() {
+			client.Close()
+			scopedLogger.Info().Msg("Serial channel closed")
+		})
 	})
 
 	d.OnMessage(func(msg webrtc.DataChannelMessage) {
-		if port == nil {
+		client := channelClient.Load()
+		if client == nil {
 			return
 		}
-		_, err := port.Write(msg.Data)
-		if err != nil {
+		if _, err := client.Write(msg.Data); err != nil && !errors.Is(err, serialconsole.ErrWriteLocked) {
 			scopedLogger.Warn().Err(err).Msg("Failed to write to serial")
 		}
 	})
@@ -346,8 +93,4 @@ func handleSerialChannel(d *webrtc.DataChannel) {
 	d.OnError(func(err error) {
 		scopedLogger.Warn().Err(err).Msg("Serial channel error")
 	})
-
-	d.OnClose(func() {
-		scopedLogger.Info().Msg("Serial channel closed")
-	})
 }
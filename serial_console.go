@@ -0,0 +1,169 @@
+package kvm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// serialConsoleReadTimeout is how often the reader checks whether it's still needed.
+const serialConsoleReadTimeout = 200 * time.Millisecond
+
+// serialConsole reads the serial port once and hands the data to every
+// listener, e.g. the serial data channel and scripts waiting for output.
+type serialConsole struct {
+	lock      sync.Mutex
+	listeners map[int]func([]byte)
+	nextID    int
+	// reader is the port being read, nil if the reader isn't running
+	reader serial.Port
+}
+
+var console = &serialConsole{listeners: make(map[int]func([]byte))}
+
+// subscribe registers fn for the data read from the serial port, the returned
+// function removes it again. The reader runs while there are listeners.
+func (c *serialConsole) subscribe(fn func([]byte)) (func(), error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p := port
+	if p == nil {
+		return nil, fmt.Errorf("serial port not available")
+	}
+
+	id := c.nextID
+	c.nextID++
+	c.listeners[id] = fn
+
+	if c.reader != p {
+		c.reader = p
+		go c.run(p)
+	}
+
+	return func() {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		delete(c.listeners, id)
+	}, nil
+}
+
+// broadcast hands data to the listeners, it returns false when the reader of p should stop.
+func (c *serialConsole) broadcast(p serial.Port, data []byte) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.reader != p {
+		return false
+	}
+	if len(c.listeners) == 0 {
+		c.reader = nil
+		return false
+	}
+	if len(data) == 0 {
+		return true
+	}
+	for _, fn := range c.listeners {
+		fn(data)
+	}
+	return true
+}
+
+func (c *serialConsole) stop(p serial.Port) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.reader == p {
+		c.reader = nil
+	}
+}
+
+func (c *serialConsole) run(p serial.Port) {
+	defer c.stop(p)
+	defer func() {
+		if r := recover(); r != nil {
+			serialLogger.Warn().Interface("recover", r).Msg("Recovered from serial reader panic")
+		}
+	}()
+
+	// the extensions read the port without a timeout, restore it when done
+	if err := p.SetReadTimeout(serialConsoleReadTimeout); err != nil {
+		serialLogger.Warn().Err(err).Msg("Failed to set serial read timeout")
+	}
+	defer func() {
+		_ = p.SetReadTimeout(serial.NoTimeout)
+	}()
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := p.Read(buf)
+		if err != nil {
+			// the port is closed when it's reopened
+			if p == port {
+				serialLogger.Warn().Err(err).Msg("Failed to read from serial port")
+			}
+			return
+		}
+
+		// n is 0 when the read timed out
+		if !c.broadcast(p, append([]byte{}, buf[:n]...)) {
+			return
+		}
+	}
+}
+
+func writeSerial(data []byte) error {
+	if port == nil {
+		return fmt.Errorf("serial port not available")
+	}
+	if _, err := port.Write(data); err != nil {
+		return fmt.Errorf("failed to write to serial port: %w", err)
+	}
+	return nil
+}
+
+// serialExpectBufferSize is how much output is kept for matching.
+const serialExpectBufferSize = 64 * 1024
+
+// serialExpect waits until the output of the console source matches pattern
+// and returns the match, or an empty string if the timeout expired first.
+func serialExpect(ctx context.Context, pattern *regexp.Regexp, timeout time.Duration) (string, error) {
+	data := make(chan []byte, 64)
+	unsubscribe, err := subscribeSerialConsoleOutput(func(b []byte) {
+		select {
+		case data <- b:
+		default:
+			serialLogger.Warn().Int("bytes", len(b)).Msg("serial expect is too slow, dropping output")
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	defer unsubscribe()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var output []byte
+	for {
+		select {
+		case b := <-data:
+			output = append(output, b...)
+			if len(output) > serialExpectBufferSize {
+				output = output[len(output)-serialExpectBufferSize:]
+			}
+			if match := pattern.Find(output); match != nil {
+				return string(match), nil
+			}
+		case <-timer.C:
+			return "", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
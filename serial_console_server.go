@@ -0,0 +1,277 @@
+package kvm
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/jetkvm/kvm/internal/serialconsole"
+)
+
+const (
+	serialConsoleLogPath     = "/userdata/jetkvm/serial/console.log"
+	serialConsoleHostKeyPath = "/userdata/jetkvm/ssh_host_ed25519_key"
+
+	serialConsoleMinScrollback = 1024
+	serialConsoleMaxScrollback = 1024 * 1024
+	serialConsoleMaxLogSize    = 16 * 1024 * 1024
+)
+
+type SerialConsoleConfig struct {
+	ScrollbackSize int  `json:"scrollback_size"`
+	LogEnabled     bool `json:"log_enabled"`
+	LogMaxSize     int  `json:"log_max_size"`
+	TelnetEnabled  bool `json:"telnet_enabled"`
+	TelnetPort     int  `json:"telnet_port"`
+	SSHEnabled     bool `json:"ssh_enabled"`
+	SSHPort        int  `json:"ssh_port"`
+}
+
+func (c *SerialConsoleConfig) Validate() error {
+	if c.ScrollbackSize < serialConsoleMinScrollback || c.ScrollbackSize > serialConsoleMaxScrollback {
+		return fmt.Errorf("scrollback size must be between %d and %d", serialConsoleMinScrollback, serialConsoleMaxScrollback)
+	}
+	if c.LogMaxSize <= 0 || c.LogMaxSize > serialConsoleMaxLogSize {
+		return fmt.Errorf("log size must be between 1 and %d", serialConsoleMaxLogSize)
+	}
+	if c.TelnetPort <= 0 || c.TelnetPort > 65535 {
+		return fmt.Errorf("invalid telnet port: %d", c.TelnetPort)
+	}
+	if c.SSHPort <= 0 || c.SSHPort > 65535 {
+		return fmt.Errorf("invalid ssh port: %d", c.SSHPort)
+	}
+	if c.TelnetEnabled && c.SSHEnabled && c.TelnetPort == c.SSHPort {
+		return fmt.Errorf("telnet and ssh can't use the same port")
+	}
+	return nil
+}
+
+var (
+	// serialConsoleServer shares the serial console between the web UI, telnet and SSH
+	serialConsoleServer = serialconsole.NewConsole(&serialconsole.Options{Logger: serialLogger})
+
+	serialConsoleLock sync.Mutex
+	// serialConsoleUnsubscribe stops feeding the serial output to the console server
+	serialConsoleUnsubscribe func()
+	serialConsoleListeners   []net.Listener
+
+	// serialConsoleOutputListeners get the output of the console source too,
+	// e.g. scripts waiting for output
+	serialConsoleOutputLock      sync.Mutex
+	serialConsoleOutputListeners = make(map[int]func([]byte))
+	serialConsoleOutputNextID    int
+)
+
+func initSerialConsole() {
+	serialConsoleServer.SetInput(writeSerialConsole)
+
+	if err := applySerialConsoleConfig(); err != nil {
+		serialLogger.Warn().Err(err).Msg("failed to start serial console server")
+	}
+	updateSerialConsoleReader()
+}
+
+// writeSerialConsole writes to the console source, the USB serial gadget when
+// it's enabled, otherwise the serial port unless an extension uses it.
+func writeSerialConsole(data []byte) error {
+	if isUsbSerialEnabled() {
+		return writeUsbSerial(data)
+	}
+	if config.ActiveExtension != "" {
+		return fmt.Errorf("serial port is used by the %s extension", config.ActiveExtension)
+	}
+	return writeSerial(data)
+}
+
+// writeSerialConsoleOutput hands the output of the console source to the
+// console server, the triggers and the output listeners.
+func writeSerialConsoleOutput(data []byte) {
+	_, _ = serialConsoleServer.Write(data)
+	serialTriggers.process(data)
+
+	serialConsoleOutputLock.Lock()
+	defer serialConsoleOutputLock.Unlock()
+	for _, fn := range serialConsoleOutputListeners {
+		fn(data)
+	}
+}
+
+// subscribeSerialConsoleOutput registers fn for the output of the console
+// source, the returned function removes it again. fn must not block.
+func subscribeSerialConsoleOutput(fn func([]byte)) (func(), error) {
+	serialConsoleLock.Lock()
+	reading := isUsbSerialEnabled() || serialConsoleUnsubscribe != nil
+	serialConsoleLock.Unlock()
+	if !reading {
+		if config.ActiveExtension != "" {
+			return nil, fmt.Errorf("serial port is used by the %s extension", config.ActiveExtension)
+		}
+		return nil, fmt.Errorf("serial port not available")
+	}
+
+	serialConsoleOutputLock.Lock()
+	defer serialConsoleOutputLock.Unlock()
+
+	id := serialConsoleOutputNextID
+	serialConsoleOutputNextID++
+	serialConsoleOutputListeners[id] = fn
+
+	return func() {
+		serialConsoleOutputLock.Lock()
+		defer serialConsoleOutputLock.Unlock()
+
+		delete(serialConsoleOutputListeners, id)
+	}, nil
+}
+
+// serialConsoleAvailable reports whether the console has a source.
+func serialConsoleAvailable() bool {
+	return isUsbSerialEnabled() || port != nil
+}
+
+// updateSerialConsoleReader feeds the output of the USB serial gadget to the
+// console server when it's enabled, otherwise the serial port's unless an
+// extension uses it. It's called whenever the port is reopened, the extension
+// or the USB devices change.
+func updateSerialConsoleReader() {
+	// the triggers that can see the output depend on the source
+	serialTriggers.load(config.SerialTriggers)
+
+	serialConsoleLock.Lock()
+	defer serialConsoleLock.Unlock()
+
+	if serialConsoleUnsubscribe != nil {
+		serialConsoleUnsubscribe()
+		serialConsoleUnsubscribe = nil
+	}
+	if isUsbSerialEnabled() {
+		startUsbSerial(writeSerialConsoleOutput)
+		return
+	}
+	stopUsbSerial()
+	if port == nil || config.ActiveExtension != "" {
+		return
+	}
+
+	unsubscribe, err := console.subscribe(writeSerialConsoleOutput)
+	if err != nil {
+		serialLogger.Warn().Err(err).Msg("failed to read serial console")
+		return
+	}
+	serialConsoleUnsubscribe = unsubscribe
+}
+
+// checkSerialConsolePassword authenticates telnet and SSH clients as local
+// users. Telnet has no user name and unknown SSH user names like root log in
+// as the admin. Users with two-factor authentication append the code to the
+// password.
+func checkSerialConsolePassword(username, password string) bool {
+	switch config.LocalAuthMode {
+	case "noPassword":
+		return true
+	case "password":
+		if _, err := getUserStore().User(username); err != nil {
+			username = defaultAdminUsername
+		}
+		_, err := getUserStore().AuthenticateCombined(username, password)
+		return err == nil
+	}
+	// the device hasn't been set up yet
+	return false
+}
+
+func serialConsoleListenAddress(port int) string {
+	host := ""
+	if config.LocalLoopbackOnly {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// applySerialConsoleConfig applies the scrollback and log settings and
+// restarts the telnet and SSH listeners.
+func applySerialConsoleConfig() error {
+	serialConsoleLock.Lock()
+	defer serialConsoleLock.Unlock()
+
+	cfg := config.SerialConsole
+	serialConsoleServer.SetScrollbackSize(cfg.ScrollbackSize)
+
+	logPath := ""
+	if cfg.LogEnabled {
+		logPath = serialConsoleLogPath
+	}
+	if err := serialConsoleServer.SetLog(logPath, int64(cfg.LogMaxSize)); err != nil {
+		return fmt.Errorf("failed to open serial console log: %w", err)
+	}
+
+	for _, l := range serialConsoleListeners {
+		_ = l.Close()
+	}
+	serialConsoleListeners = nil
+
+	if cfg.TelnetEnabled {
+		addr := serialConsoleListenAddress(cfg.TelnetPort)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for telnet on %s: %w", addr, err)
+		}
+		l = usbNetworkFilteredListener{l}
+		serialConsoleListeners = append(serialConsoleListeners, l)
+		serialLogger.Info().Str("address", addr).Msg("serial console telnet server started")
+		go func() {
+			_ = serialConsoleServer.ServeTelnet(l, checkSerialConsolePassword)
+		}()
+	}
+
+	if cfg.SSHEnabled {
+		hostKey, err := serialconsole.LoadOrCreateHostKey(serialConsoleHostKeyPath)
+		if err != nil {
+			return err
+		}
+		addr := serialConsoleListenAddress(cfg.SSHPort)
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for ssh on %s: %w", addr, err)
+		}
+		l = usbNetworkFilteredListener{l}
+		serialConsoleListeners = append(serialConsoleListeners, l)
+		serialLogger.Info().Str("address", addr).Msg("serial console ssh server started")
+		go func() {
+			_ = serialConsoleServer.ServeSSH(l, hostKey, checkSerialConsolePassword)
+		}()
+	}
+
+	return nil
+}
+
+func rpcGetSerialConsoleSettings() (SerialConsoleConfig, error) {
+	return *config.SerialConsole, nil
+}
+
+func rpcSetSerialConsoleSettings(settings SerialConsoleConfig) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	config.SerialConsole = &settings
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	return applySerialConsoleConfig()
+}
+
+func rpcGetSerialConsoleClients() ([]serialconsole.ClientInfo, error) {
+	return serialConsoleServer.Clients(), nil
+}
+
+// rpcGetSerialScrollback returns the recent serial console output.
+func rpcGetSerialScrollback() (string, error) {
+	return string(serialConsoleServer.Scrollback()), nil
+}
+
+func rpcClearSerialScrollback() error {
+	serialConsoleServer.ClearScrollback()
+	return nil
+}
@@ -0,0 +1,42 @@
+package kvm
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/usbgadget"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerialExpectConsoleSource(t *testing.T) {
+	savedConfig, savedGadget := config, gadget
+	t.Cleanup(func() {
+		config, gadget = savedConfig, savedGadget
+	})
+	config = &Config{ActiveExtension: "atx-power", UsbDevices: &usbgadget.Devices{}}
+	gadget = &usbgadget.UsbGadget{}
+
+	_, err := serialExpect(context.Background(), regexp.MustCompile("login:"), time.Second)
+	assert.ErrorContains(t, err, "atx-power", "the extension owns the serial port")
+
+	// the USB serial gadget is the console source, the extension doesn't matter
+	config.UsbDevices.Serial = true
+	result := make(chan string, 1)
+	go func() {
+		match, err := serialExpect(context.Background(), regexp.MustCompile(`\w+ login:`), 5*time.Second)
+		assert.NoError(t, err)
+		result <- match
+	}()
+	require.Eventually(t, func() bool {
+		serialConsoleOutputLock.Lock()
+		defer serialConsoleOutputLock.Unlock()
+		return len(serialConsoleOutputListeners) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	writeSerialConsoleOutput([]byte("jetkvm lo"))
+	writeSerialConsoleOutput([]byte("gin: "))
+	assert.Equal(t, "jetkvm login:", <-result)
+}
@@ -0,0 +1,334 @@
+package kvm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jetkvm/kvm/internal/extension"
+)
+
+const (
+	MaxSerialTriggers            = 25
+	MaxSerialTriggerPatternSize  = 1024
+	MinSerialTriggerCooldown     = 1
+	MaxSerialTriggerCooldown     = 24 * 60 * 60
+	DefaultSerialTriggerCooldown = 60
+
+	// serialTriggerHistorySize is the number of fired triggers kept for getSerialTriggerHistory
+	serialTriggerHistorySize = 100
+	// serialTriggerMaxLineLength is where overly long lines are split for matching
+	serialTriggerMaxLineLength  = 4096
+	serialTriggerWebhookTimeout = 10 * time.Second
+)
+
+// SerialTrigger runs actions when a line of the serial console output
+// matches its pattern.
+type SerialTrigger struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Enabled bool   `json:"enabled"`
+	// Cooldown is the minimum number of seconds between two firings
+	Cooldown int `json:"cooldown"`
+
+	WebhookURL  string `json:"webhookUrl,omitempty"`
+	NotifyEvent bool   `json:"notifyEvent"`
+	MacroID     string `json:"macroId,omitempty"`
+	ATXReset    bool   `json:"atxReset"`
+}
+
+func (t *SerialTrigger) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("trigger name cannot be empty")
+	}
+	if t.Pattern == "" {
+		return fmt.Errorf("trigger pattern cannot be empty")
+	}
+	if len(t.Pattern) > MaxSerialTriggerPatternSize {
+		return fmt.Errorf("trigger pattern is too long (max %d)", MaxSerialTriggerPatternSize)
+	}
+	if _, err := regexp.Compile(t.Pattern); err != nil {
+		return fmt.Errorf("invalid trigger pattern: %w", err)
+	}
+
+	if t.Cooldown == 0 {
+		t.Cooldown = DefaultSerialTriggerCooldown
+	}
+	if t.Cooldown < MinSerialTriggerCooldown || t.Cooldown > MaxSerialTriggerCooldown {
+		return fmt.Errorf("trigger cooldown must be between %d and %d seconds", MinSerialTriggerCooldown, MaxSerialTriggerCooldown)
+	}
+
+	if t.WebhookURL != "" {
+		u, err := url.Parse(t.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook url: %s", t.WebhookURL)
+		}
+	}
+	if t.MacroID != "" {
+		if _, ok := findKeyboardMacro(t.MacroID); !ok {
+			return fmt.Errorf("keyboard macro not found: %s", t.MacroID)
+		}
+	}
+	// the ATX extension takes the serial port, so only the output of the USB
+	// serial gadget reaches the triggers while it's active
+	if t.ATXReset && !isUsbSerialEnabled() {
+		return fmt.Errorf("ATX reset requires the USB serial console, the ATX extension takes the serial port")
+	}
+	if t.WebhookURL == "" && !t.NotifyEvent && t.MacroID == "" && !t.ATXReset {
+		return fmt.Errorf("trigger has no actions")
+	}
+
+	return nil
+}
+
+// SerialTriggerAction is the outcome of one action of a fired trigger.
+type SerialTriggerAction struct {
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+}
+
+// SerialTriggerEvent records a fired trigger.
+type SerialTriggerEvent struct {
+	TriggerID   string                `json:"triggerId"`
+	TriggerName string                `json:"triggerName"`
+	Line        string                `json:"line"`
+	Time        time.Time             `json:"time"`
+	Actions     []SerialTriggerAction `json:"actions"`
+}
+
+type compiledSerialTrigger struct {
+	SerialTrigger
+	re        *regexp.Regexp
+	lastFired time.Time
+}
+
+// serialTriggerEngine matches the serial output line by line.
+type serialTriggerEngine struct {
+	lock     sync.Mutex
+	triggers []*compiledSerialTrigger
+	line     []byte
+	history  []*SerialTriggerEvent
+}
+
+var serialTriggers = &serialTriggerEngine{}
+
+// load compiles the triggers, keeping the cooldown of triggers that didn't
+// change. Triggers that aren't valid anymore, e.g. an ATX reset after the
+// console source changed, stay disabled until they're valid again.
+func (e *serialTriggerEngine) load(triggers []SerialTrigger) {
+	var compiled []*compiledSerialTrigger
+	for _, t := range triggers {
+		if !t.Enabled {
+			continue
+		}
+		if err := t.Validate(); err != nil {
+			serialLogger.Warn().Err(err).Str("trigger", t.Name).Msg("serial trigger disabled")
+			continue
+		}
+		compiled = append(compiled, &compiledSerialTrigger{SerialTrigger: t, re: regexp.MustCompile(t.Pattern)})
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	previous := make(map[string]*compiledSerialTrigger, len(e.triggers))
+	for _, t := range e.triggers {
+		previous[t.ID] = t
+	}
+	for _, t := range compiled {
+		if p, ok := previous[t.ID]; ok && p.Pattern == t.Pattern {
+			t.lastFired = p.lastFired
+		}
+	}
+	e.triggers = compiled
+}
+
+// process is called with the serial output as it's read.
+func (e *serialTriggerEngine) process(data []byte) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if len(e.triggers) == 0 {
+		e.line = e.line[:0]
+		return
+	}
+
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			e.line = append(e.line, data...)
+			if len(e.line) >= serialTriggerMaxLineLength {
+				e.match(string(e.line))
+				e.line = e.line[:0]
+			}
+			return
+		}
+		e.line = append(e.line, data[:i]...)
+		e.match(strings.TrimRight(string(e.line), "\r"))
+		e.line = e.line[:0]
+		data = data[i+1:]
+	}
+}
+
+// match fires the triggers matching line, it's called with the lock held.
+func (e *serialTriggerEngine) match(line string) {
+	now := time.Now()
+	for _, t := range e.triggers {
+		if !t.re.MatchString(line) {
+			continue
+		}
+		if !t.lastFired.IsZero() && now.Sub(t.lastFired) < time.Duration(t.Cooldown)*time.Second {
+			continue
+		}
+		t.lastFired = now
+
+		event := &SerialTriggerEvent{
+			TriggerID:   t.ID,
+			TriggerName: t.Name,
+			Line:        line,
+			Time:        now,
+		}
+		e.history = append(e.history, event)
+		if len(e.history) > serialTriggerHistorySize {
+			e.history = e.history[len(e.history)-serialTriggerHistorySize:]
+		}
+
+		serialLogger.Info().Str("trigger", t.Name).Str("line", line).Msg("serial trigger fired")
+		go e.fire(t.SerialTrigger, event)
+	}
+}
+
+// fire runs the actions of a trigger and records their results in event.
+func (e *serialTriggerEngine) fire(t SerialTrigger, event *SerialTriggerEvent) {
+	var actions []SerialTriggerAction
+	run := func(action string, fn func() error) {
+		result := SerialTriggerAction{Action: action}
+		if err := fn(); err != nil {
+			serialLogger.Warn().Err(err).Str("trigger", t.Name).Str("action", action).Msg("serial trigger action failed")
+			result.Error = err.Error()
+		}
+		actions = append(actions, result)
+	}
+
+	if t.WebhookURL != "" {
+		run("webhook", func() error { return sendSerialTriggerWebhook(t.WebhookURL, event) })
+	}
+	if t.MacroID != "" {
+		run("macro", func() error { return runKeyboardMacro(t.MacroID) })
+	}
+	if t.ATXReset {
+		run("atxReset", func() error {
+			if config.ActiveExtension != extension.ATXID {
+				return fmt.Errorf("ATX extension is not active")
+			}
+			return pressATXResetButton(200 * time.Millisecond)
+		})
+	}
+
+	e.lock.Lock()
+	event.Actions = actions
+	snapshot := *event
+	e.lock.Unlock()
+
+	// the event goes out last so it includes the results of the other actions
+	if t.NotifyEvent && currentSession != nil {
+		writeJSONRPCEvent("serialTrigger", snapshot, currentSession)
+	}
+}
+
+func sendSerialTriggerWebhook(webhookURL string, event *SerialTriggerEvent) error {
+	body, err := json.Marshal(map[string]any{
+		"deviceId":    GetDeviceID(),
+		"triggerId":   event.TriggerID,
+		"triggerName": event.TriggerName,
+		"line":        event.Line,
+		"time":        event.Time,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), serialTriggerWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func rpcGetSerialTriggers() ([]SerialTrigger, error) {
+	triggers := make([]SerialTrigger, len(config.SerialTriggers))
+	copy(triggers, config.SerialTriggers)
+	return triggers, nil
+}
+
+func rpcSetSerialTriggers(triggers []SerialTrigger) error {
+	if len(triggers) > MaxSerialTriggers {
+		return fmt.Errorf("too many triggers (max %d)", MaxSerialTriggers)
+	}
+
+	ids := make(map[string]bool, len(triggers))
+	for i := range triggers {
+		t := &triggers[i]
+		if t.ID == "" {
+			t.ID = uuid.NewString()
+		}
+		if ids[t.ID] {
+			return fmt.Errorf("duplicate trigger id: %s", t.ID)
+		}
+		ids[t.ID] = true
+
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("invalid trigger %d: %w", i+1, err)
+		}
+	}
+
+	config.SerialTriggers = triggers
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	serialTriggers.load(triggers)
+	return nil
+}
+
+// rpcGetSerialTriggerHistory returns the fired triggers, most recent first.
+func rpcGetSerialTriggerHistory() ([]SerialTriggerEvent, error) {
+	serialTriggers.lock.Lock()
+	defer serialTriggers.lock.Unlock()
+
+	history := make([]SerialTriggerEvent, 0, len(serialTriggers.history))
+	for i := len(serialTriggers.history) - 1; i >= 0; i-- {
+		history = append(history, *serialTriggers.history[i])
+	}
+	return history, nil
+}
+
+func rpcClearSerialTriggerHistory() error {
+	serialTriggers.lock.Lock()
+	defer serialTriggers.lock.Unlock()
+
+	serialTriggers.history = nil
+	return nil
+}
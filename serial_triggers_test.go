@@ -0,0 +1,41 @@
+package kvm
+
+import (
+	"testing"
+
+	"github.com/jetkvm/kvm/internal/usbgadget"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerialTriggerATXReset(t *testing.T) {
+	savedConfig, savedGadget := config, gadget
+	t.Cleanup(func() {
+		config, gadget = savedConfig, savedGadget
+	})
+	config = &Config{UsbDevices: &usbgadget.Devices{}}
+	gadget = &usbgadget.UsbGadget{}
+
+	trigger := &SerialTrigger{Name: "panic", Pattern: "Kernel panic", ATXReset: true}
+	assert.Error(t, trigger.Validate(), "triggers can't see the serial port while the ATX extension uses it")
+
+	config.UsbDevices.Serial = true
+	assert.NoError(t, trigger.Validate(), "the USB serial console coexists with the ATX extension")
+}
+
+func TestSerialTriggerEngineDisablesInvalid(t *testing.T) {
+	savedConfig, savedGadget := config, gadget
+	t.Cleanup(func() {
+		config, gadget = savedConfig, savedGadget
+		serialTriggers.load(nil)
+	})
+	config = &Config{UsbDevices: &usbgadget.Devices{Serial: true}}
+	gadget = &usbgadget.UsbGadget{}
+
+	triggers := []SerialTrigger{{ID: "1", Name: "panic", Pattern: "Kernel panic", ATXReset: true, Enabled: true}}
+	serialTriggers.load(triggers)
+	assert.Len(t, serialTriggers.triggers, 1)
+
+	config.UsbDevices.Serial = false
+	serialTriggers.load(triggers)
+	assert.Empty(t, serialTriggers.triggers, "the trigger can't see the output after the source changed")
+}
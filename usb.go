@@ -16,6 +16,10 @@ type inputBackend interface {
 	AbsMouseReport(x int, y int, buttons uint8) error
 	RelMouseReport(dx int8, dy int8, buttons uint8) error
 	AbsMouseWheelReport(wheelY int8) error
+	AbsMouseScrollReport(wheelY int8, wheelX int8) error
+	RelMouseScrollReport(wheelY int8, wheelX int8) error
+	ConsumerControlReport(usage uint16) error
+	SystemControlReport(usage uint8) error
 
 	// keyboard state
 	GetKeyboardState() usbgadget.KeyboardState
@@ -60,10 +64,14 @@ func detectUsbDeviceMode() bool {
 func initUsbGadget() {
 	if detectUsbDeviceMode() {
 		usbLogger.Info().Msg("USB Device Mode detected, initializing USB gadget backend")
+		// the network addresses have to be set before the first bind, changing
+		// them later re-enumerates every function
+		usbConfig := *config.UsbConfig
+		usbConfig.NetworkDevAddr, usbConfig.NetworkHostAddr = usbNetworkMACs()
 		gadget = usbgadget.NewUsbGadget(
 			"jetkvm",
 			config.UsbDevices,
-			config.UsbConfig,
+			&usbConfig,
 			usbLogger,
 		)
 		if gadget == nil {
@@ -137,6 +145,42 @@ func rpcWheelReport(wheelY int8) error {
 	return gadget.AbsMouseWheelReport(wheelY)
 }
 
+func rpcScrollReport(wheelY int8, wheelX int8) error {
+	return gadget.AbsMouseScrollReport(wheelY, wheelX)
+}
+
+func rpcRelScrollReport(wheelY int8, wheelX int8) error {
+	return gadget.RelMouseScrollReport(wheelY, wheelX)
+}
+
+func rpcConsumerControlReport(usage uint16) error {
+	return gadget.ConsumerControlReport(usage)
+}
+
+func rpcSystemControlReport(usage uint8) error {
+	return gadget.SystemControlReport(usage)
+}
+
+// controlPressDuration is how long a usage is held by the send* helpers,
+// hosts ignore presses that are released within the same polling interval.
+const controlPressDuration = 100 * time.Millisecond
+
+func rpcSendConsumerControl(usage uint16) error {
+	if err := gadget.ConsumerControlReport(usage); err != nil {
+		return err
+	}
+	time.Sleep(controlPressDuration)
+	return gadget.ConsumerControlReport(0)
+}
+
+func rpcSendSystemControl(usage uint8) error {
+	if err := gadget.SystemControlReport(usage); err != nil {
+		return err
+	}
+	time.Sleep(controlPressDuration)
+	return gadget.SystemControlReport(0)
+}
+
 func rpcGetKeyboardLedState() (state usbgadget.KeyboardState) {
 	return gadget.GetKeyboardState()
 }
@@ -0,0 +1,124 @@
+package kvm
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/audio"
+	"github.com/jetkvm/kvm/internal/usbgadget"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+)
+
+// The USB audio gadget (UAC2) shows up as a microphone and a speaker on the host.
+// Host speaker output is captured and forwarded to the browser, the browser's
+// microphone is played back into the host.
+var (
+	usbAudioCapture  *audio.Capture
+	usbAudioPlayback *audio.Playback
+)
+
+func initUsbAudio() {
+	usbAudioCapture = audio.NewCapture(&audio.CaptureOptions{
+		Device: usbgadget.AudioDevice,
+		Logger: audioLogger,
+		OnFrame: func(frame []byte, duration time.Duration) {
+			session := currentSession
+			if session == nil || session.UsbAudioTrack == nil {
+				return
+			}
+			if err := session.UsbAudioTrack.WriteSample(media.Sample{Data: frame, Duration: duration}); err != nil {
+				audioLogger.Warn().Err(err).Msg("error writing usb audio sample")
+			}
+		},
+	})
+
+	usbAudioPlayback = audio.NewPlayback(&audio.PlaybackOptions{
+		Device: usbgadget.AudioDevice,
+		Logger: audioLogger,
+	})
+}
+
+func isUsbAudioEnabled() bool {
+	return config.UsbDevices != nil && config.UsbDevices.Audio
+}
+
+// startUsbAudio starts capturing the host speaker output if the USB audio
+// function is enabled and a session is active.
+func startUsbAudio() {
+	if usbAudioCapture == nil || !isUsbAudioEnabled() || actionSessions == 0 {
+		return
+	}
+
+	if err := usbAudioCapture.Start(); err != nil {
+		audioLogger.Warn().Err(err).Msg("failed to start usb audio capture")
+	}
+}
+
+func stopUsbAudio() {
+	if usbAudioCapture != nil {
+		usbAudioCapture.Stop()
+	}
+	if usbAudioPlayback != nil {
+		usbAudioPlayback.Stop()
+	}
+}
+
+// syncUsbAudio starts or stops the USB audio pipelines after the gadget devices changed.
+func syncUsbAudio() {
+	if isUsbAudioEnabled() {
+		startUsbAudio()
+	} else {
+		stopUsbAudio()
+	}
+}
+
+// handleMicrophoneTrack plays the remote audio track into the host until the track ends.
+func handleMicrophoneTrack(session *Session, track *webrtc.TrackRemote) {
+	scopedLogger := audioLogger.With().Str("track", track.ID()).Logger()
+
+	if track.Codec().MimeType != webrtc.MimeTypeOpus {
+		scopedLogger.Warn().Str("codec", track.Codec().MimeType).Msg("unsupported microphone codec, ignoring track")
+		return
+	}
+
+	started := false
+	var retryAt time.Time
+	defer func() {
+		if started {
+			usbAudioPlayback.Stop()
+		}
+	}()
+
+	for {
+		packet, _, err := track.ReadRTP()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				scopedLogger.Warn().Err(err).Msg("failed to read microphone track")
+			}
+			return
+		}
+
+		// drop audio unless the gadget function is enabled and this is the active session
+		if usbAudioPlayback == nil || !isUsbAudioEnabled() || session != currentSession {
+			continue
+		}
+
+		if !usbAudioPlayback.IsRunning() {
+			if time.Now().Before(retryAt) {
+				continue
+			}
+			if err := usbAudioPlayback.Start(); err != nil {
+				scopedLogger.Warn().Err(err).Msg("failed to start usb audio playback")
+				retryAt = time.Now().Add(3 * time.Second)
+				continue
+			}
+			started = true
+		}
+
+		if err := usbAudioPlayback.WriteRTP(packet); err != nil {
+			scopedLogger.Warn().Err(err).Msg("failed to write microphone audio")
+		}
+	}
+}
@@ -0,0 +1,597 @@
+package kvm
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jetkvm/kvm/internal/dhcpserver"
+	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/jetkvm/kvm/internal/usbgadget"
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// usbNetworkInterfaceTimeout is how long to wait for the gadget's network
+	// interface after the gadget is bound
+	usbNetworkInterfaceTimeout = 30 * time.Second
+	usbNetworkProxyDialTimeout = 10 * time.Second
+	ipForwardPath              = "/proc/sys/net/ipv4/ip_forward"
+)
+
+var usbNetworkLogger = logging.GetSubsystemLogger("usbnetwork")
+
+// UsbNetworkConfig configures the device side of the USB network gadget.
+type UsbNetworkConfig struct {
+	// Address is the device's address on the link with its prefix length
+	Address    string `json:"address"`
+	DHCPServer bool   `json:"dhcp_server"`
+	// LeaseTime of the DHCP leases in seconds
+	LeaseTime int `json:"lease_time"`
+	// Forwarding gives the host access to the management network:
+	// "disabled", "nat" or "proxy"
+	Forwarding string `json:"forwarding"`
+	ProxyPort  int    `json:"proxy_port"`
+}
+
+func (c *UsbNetworkConfig) Validate() error {
+	ip, subnet, err := net.ParseCIDR(c.Address)
+	if err != nil || ip.To4() == nil {
+		return fmt.Errorf("invalid address: %s", c.Address)
+	}
+	if ones, bits := subnet.Mask.Size(); bits-ones < 2 {
+		return fmt.Errorf("subnet %s is too small", subnet)
+	}
+	if ip.Equal(subnet.IP) || ip.Equal(usbNetworkBroadcast(subnet)) {
+		return fmt.Errorf("address %s can't be the network or broadcast address", ip)
+	}
+	if c.LeaseTime < 60 || c.LeaseTime > 7*24*60*60 {
+		return fmt.Errorf("lease time must be between 60 seconds and 7 days")
+	}
+	switch c.Forwarding {
+	case "disabled", "nat", "proxy":
+	default:
+		return fmt.Errorf("invalid forwarding mode: %s", c.Forwarding)
+	}
+	if c.ProxyPort <= 0 || c.ProxyPort > 65535 {
+		return fmt.Errorf("invalid proxy port: %d", c.ProxyPort)
+	}
+	return nil
+}
+
+type UsbNetworkState struct {
+	Enabled    bool               `json:"enabled"`
+	Interface  string             `json:"interface,omitempty"`
+	Address    string             `json:"address,omitempty"`
+	Forwarding string             `json:"forwarding,omitempty"`
+	Leases     []dhcpserver.Lease `json:"leases"`
+	Error      string             `json:"error,omitempty"`
+}
+
+var (
+	usbNetworkLock sync.Mutex
+	// usbNetworkCancel stops waiting for the interface of the previous setup
+	usbNetworkCancel context.CancelFunc
+	usbNetworkState  = UsbNetworkState{Leases: []dhcpserver.Lease{}}
+	usbNetworkDHCP   *dhcpserver.Server
+	usbNetworkProxy  *http.Server
+	// usbNetworkRules are the iptables rules added for NAT
+	usbNetworkRules []iptablesRule
+	// usbNetworkIPForward is the ip_forward value before NAT enabled it,
+	// empty if it wasn't changed
+	usbNetworkIPForward string
+	// usbNetworkSubnet is the subnet of the link, the web and console servers
+	// refuse clients from it
+	usbNetworkSubnet atomic.Pointer[net.IPNet]
+)
+
+// usbGadget returns the USB gadget, or nil if the uinput backend is used.
+func usbGadget() *usbgadget.UsbGadget {
+	g, ok := gadget.(*usbgadget.UsbGadget)
+	if !ok || g == nil {
+		return nil
+	}
+	return g
+}
+
+func isUsbNetworkEnabled() bool {
+	return usbGadget() != nil && config.UsbDevices != nil && config.UsbDevices.Network
+}
+
+// usbNetworkMACs derives stable MAC addresses for both ends of the link from
+// the device ID, so the host doesn't see a new network adapter every boot.
+func usbNetworkMACs() (string, string) {
+	mac := func(side string) string {
+		sum := sha256.Sum256([]byte(GetDeviceID() + "/usb-network/" + side))
+		// locally administered unicast
+		sum[0] = (sum[0] | 0x02) &^ 0x01
+		return net.HardwareAddr(sum[:6]).String()
+	}
+	return mac("device"), mac("host")
+}
+
+// initUsbNetwork sets up the device side, the gadget was bound with the
+// addresses of usbNetworkMACs by initUsbGadget.
+func initUsbNetwork() {
+	syncUsbNetwork()
+}
+
+// syncUsbNetwork sets up the device side of the USB network after the gadget
+// devices or the settings changed.
+func syncUsbNetwork() {
+	usbNetworkLock.Lock()
+	defer usbNetworkLock.Unlock()
+
+	if usbNetworkCancel != nil {
+		usbNetworkCancel()
+		usbNetworkCancel = nil
+	}
+	stopUsbNetwork()
+
+	if !isUsbNetworkEnabled() {
+		return
+	}
+	usbNetworkState.Enabled = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	usbNetworkCancel = cancel
+	go func() {
+		iface, err := waitUsbNetworkInterface(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				usbNetworkLogger.Warn().Err(err).Msg("USB network interface not available")
+				setUsbNetworkError(err)
+			}
+			return
+		}
+
+		usbNetworkLock.Lock()
+		defer usbNetworkLock.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		if err := startUsbNetwork(iface); err != nil {
+			usbNetworkLogger.Warn().Err(err).Str("interface", iface).Msg("failed to set up USB network")
+			usbNetworkState.Error = err.Error()
+		}
+	}()
+}
+
+func setUsbNetworkError(err error) {
+	usbNetworkLock.Lock()
+	defer usbNetworkLock.Unlock()
+	usbNetworkState.Error = err.Error()
+}
+
+// waitUsbNetworkInterface waits until the gadget is bound and its network interface exists.
+func waitUsbNetworkInterface(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, usbNetworkInterfaceTimeout)
+	defer cancel()
+
+	for {
+		g := usbGadget()
+		if g == nil {
+			return "", fmt.Errorf("USB gadget not available")
+		}
+		name, err := g.NetworkInterfaceName()
+		if err == nil {
+			if _, err = netlink.LinkByName(name); err == nil {
+				return name, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("timed out waiting for the USB network interface: %w", err)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// startUsbNetwork configures the interface and starts the DHCP server and
+// forwarding, it's called with usbNetworkLock held.
+func startUsbNetwork(iface string) error {
+	cfg := config.UsbNetwork
+	ip, subnet, err := net.ParseCIDR(cfg.Address)
+	if err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	}
+
+	usbNetworkSubnet.Store(subnet)
+
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return fmt.Errorf("failed to find interface: %w", err)
+	}
+	addr := &netlink.Addr{IPNet: &net.IPNet{IP: ip, Mask: subnet.Mask}}
+	if err := netlink.AddrReplace(link, addr); err != nil {
+		return fmt.Errorf("failed to set address: %w", err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return fmt.Errorf("failed to bring up interface: %w", err)
+	}
+
+	usbNetworkState.Interface = iface
+	usbNetworkState.Address = cfg.Address
+	usbNetworkState.Forwarding = cfg.Forwarding
+	usbNetworkLogger.Info().Str("interface", iface).Str("address", cfg.Address).Msg("USB network configured")
+
+	// only NAT routes the host's traffic, with the proxy the host keeps its own default route
+	var router net.IP
+	var dns []net.IP
+	switch cfg.Forwarding {
+	case "nat":
+		if err := addUsbNetworkNAT(iface, subnet); err != nil {
+			return err
+		}
+		router = ip
+		dns = systemNameservers()
+	case "proxy":
+		if err := startUsbNetworkProxy(net.JoinHostPort(ip.String(), fmt.Sprint(cfg.ProxyPort))); err != nil {
+			return err
+		}
+	}
+
+	if cfg.DHCPServer {
+		server, err := dhcpserver.NewServer(&dhcpserver.Options{
+			Interface: iface,
+			ServerIP:  ip,
+			Subnet:    subnet,
+			Router:    router,
+			DNS:       dns,
+			LeaseTime: time.Duration(cfg.LeaseTime) * time.Second,
+			Logger:    usbNetworkLogger,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create DHCP server: %w", err)
+		}
+		usbNetworkDHCP = server
+		go func() {
+			if err := server.ListenAndServe(); err != nil {
+				usbNetworkLogger.Warn().Err(err).Msg("DHCP server stopped")
+				setUsbNetworkError(err)
+			}
+		}()
+	}
+
+	return nil
+}
+
+// stopUsbNetwork stops the DHCP server and forwarding, it's called with
+// usbNetworkLock held. The interface goes away with the gadget function.
+func stopUsbNetwork() {
+	if usbNetworkDHCP != nil {
+		_ = usbNetworkDHCP.Close()
+		usbNetworkDHCP = nil
+	}
+	if usbNetworkProxy != nil {
+		_ = usbNetworkProxy.Close()
+		usbNetworkProxy = nil
+	}
+	for i := len(usbNetworkRules) - 1; i >= 0; i-- {
+		if err := usbNetworkRules[i].run("-D"); err != nil {
+			usbNetworkLogger.Warn().Err(err).Msg("failed to remove USB network NAT rule")
+		}
+	}
+	usbNetworkRules = nil
+	if usbNetworkIPForward != "" {
+		if err := os.WriteFile(ipForwardPath, []byte(usbNetworkIPForward), 0644); err != nil {
+			usbNetworkLogger.Warn().Err(err).Msg("failed to restore IP forwarding")
+		}
+		usbNetworkIPForward = ""
+	}
+	usbNetworkSubnet.Store(nil)
+
+	if usbNetworkState.Interface != "" && usbNetworkState.Address != "" {
+		// the address may change while the interface stays
+		if link, err := netlink.LinkByName(usbNetworkState.Interface); err == nil {
+			if addr, err := netlink.ParseAddr(usbNetworkState.Address); err == nil {
+				_ = netlink.AddrDel(link, addr)
+			}
+		}
+	}
+	usbNetworkState = UsbNetworkState{Leases: []dhcpserver.Lease{}}
+}
+
+func usbNetworkBroadcast(subnet *net.IPNet) net.IP {
+	ip := subnet.IP.To4()
+	mask := net.IP(subnet.Mask).To4()
+	b := make(net.IP, 4)
+	for i := range b {
+		b[i] = ip[i] | ^mask[i]
+	}
+	return b
+}
+
+// systemNameservers returns the IPv4 nameservers the device uses, they're
+// handed out to the host when its traffic is NATed.
+func systemNameservers() []net.IP {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var servers []net.IP
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		if ip := net.ParseIP(fields[1]); ip != nil && ip.To4() != nil && !ip.IsLoopback() {
+			servers = append(servers, ip)
+		}
+	}
+	return servers
+}
+
+type iptablesRule struct {
+	table string
+	chain string
+	spec  []string
+}
+
+func (r iptablesRule) run(action string) error {
+	return r.runWith("iptables", action)
+}
+
+// runWith runs the rule with command, iptables or ip6tables.
+func (r iptablesRule) runWith(command, action string) error {
+	args := append([]string{"-t", r.table, action, r.chain}, r.spec...)
+	out, err := exec.Command(command, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", command, action, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// usbNetworkNATRules masquerades the host's traffic and only forwards what
+// comes from the link, and its replies. isolate also drops all other
+// forwarded traffic, for devices that didn't forward before.
+func usbNetworkNATRules(iface string, subnet *net.IPNet, isolate bool) []iptablesRule {
+	rules := []iptablesRule{
+		{"nat", "POSTROUTING", []string{"-s", subnet.String(), "!", "-d", subnet.String(), "-j", "MASQUERADE"}},
+		{"filter", "FORWARD", []string{"-i", iface, "-j", "ACCEPT"}},
+		{"filter", "FORWARD", []string{"-o", iface, "-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "ACCEPT"}},
+		{"filter", "FORWARD", []string{"-o", iface, "-j", "DROP"}},
+	}
+	if isolate {
+		rules = append(rules, iptablesRule{"filter", "FORWARD", []string{"!", "-i", iface, "-j", "DROP"}})
+	}
+	return rules
+}
+
+func addUsbNetworkNAT(iface string, subnet *net.IPNet) error {
+	forward, err := os.ReadFile(ipForwardPath)
+	if err != nil {
+		return fmt.Errorf("failed to read IP forwarding: %w", err)
+	}
+	previous := strings.TrimSpace(string(forward))
+
+	for _, rule := range usbNetworkNATRules(iface, subnet, previous == "0") {
+		// the rule may be left over from a crash
+		if rule.run("-C") != nil {
+			if err := rule.run("-A"); err != nil {
+				return err
+			}
+		}
+		usbNetworkRules = append(usbNetworkRules, rule)
+	}
+
+	if previous != "1" {
+		if err := os.WriteFile(ipForwardPath, []byte("1"), 0644); err != nil {
+			return fmt.Errorf("failed to enable IP forwarding: %w", err)
+		}
+		usbNetworkIPForward = previous
+	}
+	usbNetworkLogger.Info().Str("interface", iface).Str("subnet", subnet.String()).Msg("USB network NAT enabled")
+	return nil
+}
+
+// isUsbNetworkAddr tells if the remote address addr is on the link, i.e.
+// the managed host.
+func isUsbNetworkAddr(addr string) bool {
+	subnet := usbNetworkSubnet.Load()
+	if subnet == nil {
+		return false
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && subnet.Contains(ip)
+}
+
+// usbNetworkAccessMiddleware rejects requests of the managed host, the link
+// carries the host's traffic, it doesn't manage the device.
+func usbNetworkAccessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isUsbNetworkAddr(c.Request.RemoteAddr) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// usbNetworkFilteredListener closes connections of the managed host.
+type usbNetworkFilteredListener struct {
+	net.Listener
+}
+
+func (l usbNetworkFilteredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if !isUsbNetworkAddr(conn.RemoteAddr().String()) {
+			return conn, nil
+		}
+		usbNetworkLogger.Info().Str("remote", conn.RemoteAddr().String()).Msg("refused connection from the USB network")
+		_ = conn.Close()
+	}
+}
+
+// refuseLocalDial keeps the proxy from reaching the device itself.
+func refuseLocalDial(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return fmt.Errorf("connections to the device are not allowed")
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return fmt.Errorf("connections to the device are not allowed")
+		}
+	}
+	return nil
+}
+
+var usbNetworkProxyDialer = &net.Dialer{Timeout: usbNetworkProxyDialTimeout, Control: refuseLocalDial}
+
+// startUsbNetworkProxy serves an HTTP proxy on the link, for hosts that
+// shouldn't route through the device. HTTPS goes through CONNECT.
+func startUsbNetworkProxy(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for proxy on %s: %w", addr, err)
+	}
+
+	transport := &http.Transport{
+		Proxy:       config.NetworkConfig.GetTransportProxyFunc(),
+		DialContext: usbNetworkProxyDialer.DialContext,
+	}
+	server := &http.Server{
+		Handler:           http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handleUsbNetworkProxy(transport, w, r) }),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	usbNetworkProxy = server
+	usbNetworkLogger.Info().Str("address", addr).Msg("USB network proxy started")
+	go func() {
+		if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+			usbNetworkLogger.Warn().Err(err).Msg("USB network proxy stopped")
+		}
+	}()
+	return nil
+}
+
+func handleUsbNetworkProxy(transport *http.Transport, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		upstream, err := usbNetworkProxyDialer.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			upstream.Close()
+			http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+			return
+		}
+		client, buf, err := hijacker.Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		if _, err := client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+			upstream.Close()
+			client.Close()
+			return
+		}
+		go func() {
+			defer upstream.Close()
+			defer client.Close()
+			// whatever the client sent after the request is already buffered
+			if n := buf.Reader.Buffered(); n > 0 {
+				data, _ := buf.Reader.Peek(n)
+				if _, err := upstream.Write(data); err != nil {
+					return
+				}
+			}
+			go func() {
+				_, _ = io.Copy(client, upstream)
+				client.Close()
+			}()
+			_, _ = io.Copy(upstream, client)
+		}()
+		return
+	}
+
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is a proxy, requests must use absolute URLs", http.StatusBadRequest)
+		return
+	}
+
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	for _, h := range []string{"Proxy-Connection", "Proxy-Authorization", "Connection", "Keep-Alive", "Te", "Trailer", "Upgrade"} {
+		req.Header.Del(h)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for k, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func rpcGetUsbNetworkSettings() (UsbNetworkConfig, error) {
+	return *config.UsbNetwork, nil
+}
+
+func rpcSetUsbNetworkSettings(settings UsbNetworkConfig) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	config.UsbNetwork = &settings
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	syncUsbNetwork()
+	return nil
+}
+
+func rpcGetUsbNetworkState() (UsbNetworkState, error) {
+	usbNetworkLock.Lock()
+	defer usbNetworkLock.Unlock()
+
+	state := usbNetworkState
+	if usbNetworkDHCP != nil {
+		state.Leases = usbNetworkDHCP.Leases()
+	}
+	return state, nil
+}
@@ -0,0 +1,133 @@
+package kvm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/usbgadget"
+	"go.bug.st/serial"
+)
+
+// usbSerialRetryInterval is how long to wait before reopening the gadget tty,
+// it disappears while the gadget is being reconfigured.
+const usbSerialRetryInterval = 2 * time.Second
+
+// The USB serial gadget (CDC-ACM) shows up as /dev/ttyACM0 on the host. Its
+// device side is read like the physical serial port and takes over the serial
+// console while it's enabled, so a getty on the host stays reachable even when
+// an extension uses the physical port.
+var (
+	usbSerialLock sync.Mutex
+	usbSerialPort serial.Port
+	// usbSerialStop stops the reader, nil if it isn't running
+	usbSerialStop chan struct{}
+)
+
+func isUsbSerialEnabled() bool {
+	return usbGadget() != nil && config.UsbDevices != nil && config.UsbDevices.Serial
+}
+
+// startUsbSerial reads the gadget tty and hands the data to onData until
+// stopUsbSerial is called.
+func startUsbSerial(onData func([]byte)) {
+	usbSerialLock.Lock()
+	defer usbSerialLock.Unlock()
+
+	if usbSerialStop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	usbSerialStop = stop
+	go runUsbSerial(stop, onData)
+}
+
+func stopUsbSerial() {
+	usbSerialLock.Lock()
+	defer usbSerialLock.Unlock()
+
+	if usbSerialStop == nil {
+		return
+	}
+	close(usbSerialStop)
+	usbSerialStop = nil
+	if usbSerialPort != nil {
+		usbSerialPort.Close()
+		usbSerialPort = nil
+	}
+}
+
+func runUsbSerial(stop chan struct{}, onData func([]byte)) {
+	for {
+		p, err := openUsbSerial(stop)
+		if err != nil {
+			serialLogger.Debug().Err(err).Str("path", usbgadget.SerialDevice).Msg("USB serial not available, retrying")
+		} else {
+			serialLogger.Info().Str("path", usbgadget.SerialDevice).Msg("USB serial opened")
+			readUsbSerial(p, onData)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(usbSerialRetryInterval):
+		}
+	}
+}
+
+// openUsbSerial opens the gadget tty and registers it for writing.
+func openUsbSerial(stop chan struct{}) (serial.Port, error) {
+	// the baud rate is meaningless for ACM, the mode only sets up raw mode
+	p, err := serial.Open(usbgadget.SerialDevice, &serial.Mode{BaudRate: 115200})
+	if err != nil {
+		return nil, err
+	}
+
+	usbSerialLock.Lock()
+	defer usbSerialLock.Unlock()
+
+	select {
+	case <-stop:
+		p.Close()
+		return nil, fmt.Errorf("USB serial stopped")
+	default:
+	}
+	usbSerialPort = p
+	return p, nil
+}
+
+func readUsbSerial(p serial.Port, onData func([]byte)) {
+	defer func() {
+		usbSerialLock.Lock()
+		defer usbSerialLock.Unlock()
+
+		if usbSerialPort == p {
+			usbSerialPort.Close()
+			usbSerialPort = nil
+		}
+	}()
+
+	buf := make([]byte, 1024)
+	for {
+		n, err := p.Read(buf)
+		if err != nil {
+			serialLogger.Debug().Err(err).Msg("USB serial read stopped")
+			return
+		}
+		onData(append([]byte{}, buf[:n]...))
+	}
+}
+
+func writeUsbSerial(data []byte) error {
+	usbSerialLock.Lock()
+	p := usbSerialPort
+	usbSerialLock.Unlock()
+
+	if p == nil {
+		return fmt.Errorf("USB serial not connected")
+	}
+	if _, err := p.Write(data); err != nil {
+		return fmt.Errorf("failed to write to USB serial: %w", err)
+	}
+	return nil
+}
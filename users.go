@@ -0,0 +1,213 @@
+package kvm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jetkvm/kvm/internal/users"
+)
+
+// defaultAdminUsername is the user created by the setup, and the account the
+// device password of older versions is migrated to. Logins without a user
+// name use it.
+const defaultAdminUsername = "admin"
+
+const authSessionKey = "authSession"
+
+var (
+	userStore     *users.Store
+	userStoreOnce sync.Once
+)
+
+// getUserStore returns the user store, loading it from the config on first
+// use.
+func getUserStore() *users.Store {
+	userStoreOnce.Do(func() {
+		userStore = users.NewStore(users.Options{
+			Save: func(u []users.User, sessions []users.Session) error {
+				config.Users = u
+				config.UserSessions = sessions
+				return SaveConfig()
+			},
+			Logger: authLogger,
+		}, config.Users, config.UserSessions)
+		migrateDevicePassword()
+	})
+	return userStore
+}
+
+// migrateDevicePassword turns the single device password into the admin user.
+func migrateDevicePassword() {
+	if config.HashedPassword == "" || userStore.Len() > 0 {
+		return
+	}
+	hash := config.HashedPassword
+	config.HashedPassword = ""
+	if err := userStore.AddUserWithHash(defaultAdminUsername, hash, users.RoleAdmin); err != nil {
+		config.HashedPassword = hash
+		authLogger.Error().Err(err).Msg("failed to migrate device password")
+		return
+	}
+	authLogger.Info().Str("username", defaultAdminUsername).Msg("migrated device password to user")
+}
+
+func loginUsername(username string) string {
+	if username == "" {
+		return defaultAdminUsername
+	}
+	return username
+}
+
+// startLocalSession starts a session and sets its cookie.
+func startLocalSession(c *gin.Context, username, role, source string) error {
+	token, session, err := getUserStore().CreateSession(username, role, source, c.ClientIP())
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	authLogger.Info().
+		Str("username", session.Username).
+		Str("source", session.Source).
+		Str("remote", session.RemoteAddr).
+		Msg("session started")
+
+	c.SetCookie("authToken", token, int(session.Expires.Sub(session.Created).Seconds()), "/", "", false, true)
+	return nil
+}
+
+// requestAuthSession returns the session of the request, nil if it's
+// authenticated otherwise, e.g. in noPassword mode.
+func requestAuthSession(c *gin.Context) *users.Session {
+	if s, ok := c.Get(authSessionKey); ok {
+		session := s.(users.Session)
+		return &session
+	}
+	return nil
+}
+
+// currentUser returns the user of the WebRTC session, nil for sessions that
+// aren't tied to a user, like cloud and noPassword sessions.
+func currentUser() *users.Session {
+	if currentSession == nil {
+		return nil
+	}
+	return currentSession.User
+}
+
+// requireAdmin refuses sessions of users without the admin role, sessions
+// that aren't tied to a user keep full access.
+func requireAdmin(session *Session) error {
+	if session == nil || session.User == nil {
+		return nil
+	}
+	if session.User.Role != users.RoleAdmin {
+		return errors.New("this action requires the admin role")
+	}
+	return nil
+}
+
+// requireLocalUser returns the local user of the session, for self service.
+func requireLocalUser() (string, error) {
+	u := currentUser()
+	if u == nil || u.Source != users.SourceLocal {
+		return "", errors.New("the session doesn't belong to a local user")
+	}
+	return u.Username, nil
+}
+
+func rpcGetCurrentUser() (*users.Session, error) {
+	return currentUser(), nil
+}
+
+func rpcGetUsers() ([]users.UserInfo, error) {
+	return getUserStore().Users(), nil
+}
+
+func rpcCreateUser(username, password, role string) error {
+	if config.LocalAuthMode != "password" {
+		return fmt.Errorf("users require password mode")
+	}
+	return getUserStore().AddUser(username, password, role)
+}
+
+func rpcDeleteUser(username string) error {
+	return getUserStore().DeleteUser(username)
+}
+
+func rpcSetUserPassword(username, password string) error {
+	return getUserStore().SetPassword(username, password)
+}
+
+func rpcSetUserRole(username, role string) error {
+	return getUserStore().SetRole(username, role)
+}
+
+func rpcSetUserDisabled(username string, disabled bool) error {
+	return getUserStore().SetDisabled(username, disabled)
+}
+
+func rpcUnlockUser(username string) error {
+	return getUserStore().Unlock(username)
+}
+
+func rpcResetUserTOTP(username string) error {
+	return getUserStore().DisableTOTP(username)
+}
+
+func rpcGetUserSessions(username string) ([]users.Session, error) {
+	if u := currentUser(); u != nil && u.Role != users.RoleAdmin {
+		// users can see their own sessions
+		username = u.Username
+	}
+	return getUserStore().Sessions(username), nil
+}
+
+func rpcRevokeUserSession(id string) error {
+	if u := currentUser(); u != nil && u.Role != users.RoleAdmin {
+		owned := false
+		for _, s := range getUserStore().Sessions(u.Username) {
+			owned = owned || s.ID == id
+		}
+		if !owned {
+			return fmt.Errorf("session not found")
+		}
+	}
+	return getUserStore().RevokeSession(id)
+}
+
+type TOTPSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+func rpcBeginTOTPSetup() (*TOTPSetup, error) {
+	username, err := requireLocalUser()
+	if err != nil {
+		return nil, err
+	}
+	secret, uri, err := getUserStore().BeginTOTP(username)
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPSetup{Secret: secret, URI: uri}, nil
+}
+
+func rpcEnableTOTP(code string) error {
+	username, err := requireLocalUser()
+	if err != nil {
+		return err
+	}
+	return getUserStore().EnableTOTP(username, code)
+}
+
+func rpcDisableTOTP(password string) error {
+	username, err := requireLocalUser()
+	if err != nil {
+		return err
+	}
+	if err := getUserStore().VerifyPassword(username, password); err != nil {
+		return err
+	}
+	return getUserStore().DisableTOTP(username)
+}
@@ -0,0 +1,42 @@
+package kvm
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jetkvm/kvm/internal/users"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordUsername(t *testing.T) {
+	request := func(session *users.Session) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if session != nil {
+			c.Set(authSessionKey, *session)
+		}
+		return c
+	}
+	operator := &users.Session{Username: "operator", Role: users.RoleUser, Source: users.SourceLocal}
+	admin := &users.Session{Username: "root", Role: users.RoleAdmin, Source: users.SourceLocal}
+	directory := &users.Session{Username: "jdoe", Role: users.RoleUser, Source: "ldap"}
+
+	username, err := passwordUsername(request(operator), "")
+	require.NoError(t, err)
+	assert.Equal(t, "operator", username)
+
+	username, err = passwordUsername(request(operator), "operator")
+	require.NoError(t, err)
+	assert.Equal(t, "operator", username)
+
+	_, err = passwordUsername(request(operator), "admin")
+	assert.Error(t, err, "users can't act on other accounts")
+
+	_, err = passwordUsername(request(directory), "")
+	assert.Error(t, err, "directory users have no local account")
+
+	username, err = passwordUsername(request(admin), "operator")
+	require.NoError(t, err)
+	assert.Equal(t, "operator", username)
+}
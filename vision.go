@@ -0,0 +1,163 @@
+package kvm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"time"
+
+	"github.com/jetkvm/kvm/internal/screenshot"
+	"github.com/jetkvm/kvm/internal/vision"
+)
+
+const (
+	// screenPollInterval is how often the wait functions check the screen
+	screenPollInterval = time.Second
+	// screenWaitDefaultTimeout is used when no timeout is given
+	screenWaitDefaultTimeout = 30 * time.Second
+	// screenWaitMaxTimeout keeps a forgotten wait from holding the video forever
+	screenWaitMaxTimeout = 30 * time.Minute
+
+	// minImageMatchScore is the correlation above which an image is considered found
+	minImageMatchScore = 0.9
+)
+
+// waitForScreen captures the screen until found reports a match or the
+// timeout expires. The video stream is kept running while waiting.
+func waitForScreen(ctx context.Context, timeout time.Duration, found func(image.Image) (bool, error)) (bool, error) {
+	release, err := holdScreenCapture()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		start := time.Now()
+
+		img, err := captureScreen(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return false, nil
+		case errors.Is(err, screenshot.ErrNoFrame):
+			// no video signal yet, e.g. while the host is booting
+		case err != nil:
+			return false, err
+		default:
+			ok, err := found(img)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+
+		if err := sleepContext(ctx, screenPollInterval-time.Since(start)); err != nil {
+			return false, nil
+		}
+	}
+}
+
+// waitForText waits until text is shown on the screen, it returns nil if it
+// isn't found before the timeout.
+func waitForText(ctx context.Context, text string, timeout time.Duration) (*vision.TextMatch, error) {
+	var match vision.TextMatch
+	found, err := waitForScreen(ctx, timeout, func(img image.Image) (bool, error) {
+		var ok bool
+		match, ok = vision.FindText(vision.Recognize(img), text, vision.MaxDistance(text))
+		return ok, nil
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// waitForImage waits until tpl is shown on the screen, it returns nil if it
+// isn't found before the timeout.
+func waitForImage(ctx context.Context, tpl image.Image, timeout time.Duration) (*vision.ImageMatch, error) {
+	var match vision.ImageMatch
+	found, err := waitForScreen(ctx, timeout, func(img image.Image) (bool, error) {
+		var err error
+		match, err = vision.FindImage(img, tpl)
+		if err != nil {
+			return false, err
+		}
+		return match.Score >= minImageMatchScore, nil
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &match, nil
+}
+
+func screenWaitTimeout(timeout int) (time.Duration, error) {
+	if timeout <= 0 {
+		return screenWaitDefaultTimeout, nil
+	}
+	d := time.Duration(timeout) * time.Millisecond
+	if d > screenWaitMaxTimeout {
+		return 0, fmt.Errorf("timeout must be at most %d", screenWaitMaxTimeout.Milliseconds())
+	}
+	return d, nil
+}
+
+// rpcGetScreenText returns the text recognized on the screen.
+func rpcGetScreenText() (string, error) {
+	img, err := captureScreen(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return vision.Text(vision.Recognize(img)), nil
+}
+
+// rpcWaitForText waits up to timeout milliseconds for text to be shown on the screen.
+func rpcWaitForText(text string, timeout int) (*vision.TextMatch, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text is required")
+	}
+	d, err := screenWaitTimeout(timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := waitForText(context.Background(), text, d)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil {
+		return nil, fmt.Errorf("timed out waiting for text %q", text)
+	}
+	return match, nil
+}
+
+// rpcWaitForImage waits up to timeout milliseconds for a base64 encoded PNG or
+// JPEG image to be shown on the screen.
+func rpcWaitForImage(imageData string, timeout int) (*vision.ImageMatch, error) {
+	data, err := base64.StdEncoding.DecodeString(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image data: %w", err)
+	}
+	tpl, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	d, err := screenWaitTimeout(timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	match, err := waitForImage(context.Background(), tpl, d)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil {
+		return nil, fmt.Errorf("timed out waiting for image")
+	}
+	return match, nil
+}
@@ -0,0 +1,89 @@
+package kvm
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"reflect"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// vlanWebServerPorts are the ports of the web server, plain and TLS.
+const vlanWebServerPorts = "80,443"
+
+var (
+	vlanFirewallLock sync.Mutex
+	// vlanFirewallRules are the rules in place, for iptables and ip6tables
+	vlanFirewallRules []iptablesRule
+)
+
+// vlanWebServerRules drops web server connections that come in on the denied
+// interfaces. Linux answers for any local address on every interface, only
+// the ingress interface tells the untagged port from a VLAN.
+func vlanWebServerRules(denied []string) []iptablesRule {
+	rules := make([]iptablesRule, 0, len(denied))
+	for _, iface := range denied {
+		rules = append(rules, iptablesRule{"filter", "INPUT", []string{
+			"-i", iface, "-p", "tcp", "-m", "multiport", "--dports", vlanWebServerPorts, "-j", "DROP",
+		}})
+	}
+	return rules
+}
+
+// updateVLANFirewall keeps the web server rules in line with the VLAN config,
+// it's called whenever the network state changes.
+func updateVLANFirewall() {
+	if networkState == nil {
+		return
+	}
+	rules := vlanWebServerRules(networkState.WebServerDeniedInterfaces())
+
+	vlanFirewallLock.Lock()
+	defer vlanFirewallLock.Unlock()
+
+	if reflect.DeepEqual(rules, vlanFirewallRules) {
+		return
+	}
+	for _, rule := range vlanFirewallRules {
+		for _, command := range []string{"iptables", "ip6tables"} {
+			if err := rule.runWith(command, "-D"); err != nil {
+				networkLogger.Warn().Err(err).Msg("failed to remove VLAN firewall rule")
+			}
+		}
+	}
+	vlanFirewallRules = nil
+	for _, rule := range rules {
+		for _, command := range []string{"iptables", "ip6tables"} {
+			if err := rule.runWith(command, "-I"); err != nil {
+				networkLogger.Warn().Err(err).Msg("failed to add VLAN firewall rule")
+			}
+		}
+	}
+	vlanFirewallRules = rules
+}
+
+// vlanAccessMiddleware rejects requests that came in on the untagged port
+// while the management interface is tagged, or on a VLAN the web server
+// isn't enabled for. It checks the local address, updateVLANFirewall filters
+// by the ingress interface.
+func vlanAccessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if networkState == nil {
+			c.Next()
+			return
+		}
+		localAddr, _ := c.Request.Context().Value(http.LocalAddrContextKey).(net.Addr)
+		if localAddr == nil {
+			c.Next()
+			return
+		}
+		ap, err := netip.ParseAddrPort(localAddr.String())
+		if err == nil && !networkState.WebServerAllowed(ap.Addr()) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
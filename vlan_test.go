@@ -0,0 +1,17 @@
+package kvm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVLANWebServerRules(t *testing.T) {
+	assert.Empty(t, vlanWebServerRules(nil))
+
+	rules := vlanWebServerRules([]string{"eth0", "eth0.30"})
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "INPUT", rules[0].chain)
+	assert.Equal(t, []string{"-i", "eth0", "-p", "tcp", "-m", "multiport", "--dports", "80,443", "-j", "DROP"}, rules[0].spec)
+	assert.Equal(t, "eth0.30", rules[1].spec[1])
+}
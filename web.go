@@ -21,12 +21,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jetkvm/kvm/internal/logging"
+	"github.com/jetkvm/kvm/internal/users"
+	"github.com/jetkvm/kvm/internal/websecure"
 	"github.com/pion/webrtc/v4"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
 	"github.com/vearutop/statigz"
-	"golang.org/x/crypto/bcrypt"
 )
 
 //nolint:typecheck
@@ -41,30 +42,39 @@ type WebRTCSessionRequest struct {
 }
 
 type SetPasswordRequest struct {
+	Username string `json:"username,omitempty"`
 	Password string `json:"password"`
 }
 
 type LoginRequest struct {
+	Username string `json:"username,omitempty"`
 	Password string `json:"password"`
+	TOTPCode string `json:"totpCode,omitempty"`
 }
 
 type ChangePasswordRequest struct {
+	Username    string `json:"username,omitempty"`
 	OldPassword string `json:"oldPassword"`
 	NewPassword string `json:"newPassword"`
+	TOTPCode    string `json:"totpCode,omitempty"`
 }
 
 type LocalDevice struct {
 	AuthMode     *string `json:"authMode"`
 	DeviceID     string  `json:"deviceId"`
 	LoopbackOnly bool    `json:"loopbackOnly"`
+	Username     string  `json:"username,omitempty"`
+	Role         string  `json:"role,omitempty"`
 }
 
 type DeviceStatus struct {
-	IsSetup bool `json:"isSetup"`
+	IsSetup          bool `json:"isSetup"`
+	OIDCLoginEnabled bool `json:"oidcLoginEnabled"`
 }
 
 type SetupRequest struct {
 	LocalAuthMode string `json:"localAuthMode"`
+	Username      string `json:"username,omitempty"`
 	Password      string `json:"password,omitempty"`
 }
 
@@ -81,6 +91,9 @@ func setupRouter() *gin.Engine {
 			return *ginLogger
 		}),
 	))
+	r.Use(wireGuardAccessMiddleware())
+	r.Use(vlanAccessMiddleware())
+	r.Use(usbNetworkAccessMiddleware())
 
 	staticFS, err := fs.Sub(staticFiles, "static")
 	if err != nil {
@@ -123,6 +136,11 @@ func setupRouter() *gin.Engine {
 
 	// Public routes (no authentication required)
 	r.POST("/auth/login-local", handleLogin)
+	r.GET("/auth/oidc/login", handleOIDCLogin)
+	r.GET(oidcCallbackPath, handleOIDCCallback)
+
+	// ACME HTTP-01 challenges
+	r.GET(websecure.ACMEChallengePath+":token", handleACMEChallenge)
 
 	// We use this to determine if the device is setup
 	r.GET("/device/status", handleDeviceStatus)
@@ -209,7 +227,7 @@ func handleWebRTCSession(c *gin.Context) {
 		return
 	}
 
-	session, err := newSession(SessionConfig{})
+	session, err := newSession(SessionConfig{User: requestAuthSession(c)})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
@@ -276,13 +294,17 @@ func handleLocalWebRTCSignal(c *gin.Context) {
 	// Now use conn for websocket operations
 	defer wsCon.Close(websocket.StatusNormalClosure, "")
 
-	err = wsjson.Write(context.Background(), wsCon, gin.H{"type": "device-metadata", "data": gin.H{"deviceVersion": builtAppVersion}})
+	iceUser := (&SessionConfig{User: requestAuthSession(c)}).iceUser()
+	err = wsjson.Write(context.Background(), wsCon, gin.H{"type": "device-metadata", "data": gin.H{
+		"deviceVersion": builtAppVersion,
+		"iceServers":    clientICEServers(iceUser),
+	}})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	err = handleWebRTCSignalWsMessages(wsCon, false, source, connectionID, &scopedLogger)
+	err = handleWebRTCSignalWsMessages(wsCon, false, source, connectionID, requestAuthSession(c), &scopedLogger)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -294,6 +316,7 @@ func handleWebRTCSignalWsMessages(
 	isCloudConnection bool,
 	source string,
 	connectionID string,
+	user *users.Session,
 	scopedLogger *zerolog.Logger,
 ) error {
 	runCtx, cancelRun := context.WithCancel(context.Background())
@@ -427,7 +450,7 @@ func handleWebRTCSignalWsMessages(
 
 			metricConnectionSessionRequestCount.WithLabelValues(sourceType, source).Inc()
 			metricConnectionLastSessionRequestTimestamp.WithLabelValues(sourceType, source).SetToCurrentTime()
-			err = handleSessionRequest(runCtx, wsCon, req, isCloudConnection, source, &l)
+			err = handleSessionRequest(runCtx, wsCon, req, isCloudConnection, source, user, &l)
 			if err != nil {
 				l.Warn().Str("error", err.Error()).Msg("error starting new session")
 				continue
@@ -475,25 +498,39 @@ func handleLogin(c *gin.Context) {
 		return
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(config.HashedPassword), []byte(req.Password))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+	username := loginUsername(req.Username)
+	identity, err := authenticateLogin(username, req.Password, req.TOTPCode)
+	switch {
+	case errors.Is(err, users.ErrTOTPRequired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor code required", "totpRequired": true})
+		return
+	case errors.Is(err, users.ErrInvalidTOTP):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code", "totpRequired": true})
+		return
+	case errors.Is(err, users.ErrLocked):
+		authLogger.Warn().Str("username", username).Str("remote", c.ClientIP()).Msg("login to locked account")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+		return
+	case err != nil:
+		authLogger.Warn().Err(err).Str("username", username).Str("remote", c.ClientIP()).Msg("login failed")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 		return
 	}
 
-	config.LocalAuthToken = uuid.New().String()
-
-	// Set the cookie
-	c.SetCookie("authToken", config.LocalAuthToken, 7*24*60*60, "/", "", false, true)
+	if err := startLocalSession(c, identity.Username, identity.Role, identity.Source); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Login successful"})
 }
 
 func handleLogout(c *gin.Context) {
-	config.LocalAuthToken = ""
-	if err := SaveConfig(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
-		return
+	if authToken, err := c.Cookie("authToken"); err == nil && authToken != "" {
+		if err := getUserStore().DeleteSession(authToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
+			return
+		}
 	}
 
 	// Clear the auth cookie
@@ -503,18 +540,34 @@ func handleLogout(c *gin.Context) {
 
 func protectedMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// required client certificates are only checked by the websecure
+		// server, refuse the plain HTTP listener
+		if a := getClientAuthenticator(); a != nil && a.Required() && c.Request.TLS == nil {
+			sendErrorJsonThenAbort(c, http.StatusForbidden, "A client certificate is required, use HTTPS")
+			return
+		}
+
 		if config.LocalAuthMode == "noPassword" {
 			c.Next()
 			return
 		}
 
-		authToken, err := c.Cookie("authToken")
-		if err != nil || authToken != config.LocalAuthToken || authToken == "" {
+		// a client certificate accepted by the websecure server replaces the cookie
+		if cert := requestClientCert(c.Request); cert != nil {
+			c.Set("clientCertSubject", cert.Subject.String())
+			c.Next()
+			return
+		}
+
+		authToken, _ := c.Cookie("authToken")
+		session, ok := getUserStore().Session(authToken)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			c.Abort()
 			return
 		}
 
+		c.Set(authSessionKey, session)
 		c.Next()
 	}
 }
@@ -545,18 +598,23 @@ func basicAuthProtectedMiddleware(requireDeveloperMode bool) gin.HandlerFunc {
 		}
 
 		// calculate basic auth credentials
-		_, password, ok := c.Request.BasicAuth()
+		username, password, ok := c.Request.BasicAuth()
 		if !ok {
 			c.Header("WWW-Authenticate", "Basic realm=\"JetKVM\"")
 			sendErrorJsonThenAbort(c, http.StatusUnauthorized, "Basic auth is required")
 			return
 		}
 
-		err := bcrypt.CompareHashAndPassword([]byte(config.HashedPassword), []byte(password))
+		// users with two-factor authentication append the code to the password
+		user, err := getUserStore().AuthenticateCombined(loginUsername(username), password)
 		if err != nil {
 			sendErrorJsonThenAbort(c, http.StatusUnauthorized, "Invalid password")
 			return
 		}
+		if user.Role != users.RoleAdmin {
+			sendErrorJsonThenAbort(c, http.StatusForbidden, "The resource is only available to admins")
+			return
+		}
 
 		c.Next()
 	}
@@ -601,12 +659,16 @@ func handleDevice(c *gin.Context) {
 		DeviceID:     GetDeviceID(),
 		LoopbackOnly: config.LocalLoopbackOnly,
 	}
+	if session := requestAuthSession(c); session != nil {
+		response.Username = session.Username
+		response.Role = session.Role
+	}
 
 	c.JSON(http.StatusOK, response)
 }
 
 func handleCreatePassword(c *gin.Context) {
-	if config.HashedPassword != "" {
+	if getUserStore().Len() > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Password already set"})
 		return
 	}
@@ -625,28 +687,70 @@ func handleCreatePassword(c *gin.Context) {
 		return
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+	username := loginUsername(req.Username)
+	if err := getUserStore().AddUser(username, req.Password, users.RoleAdmin); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	config.HashedPassword = string(hashedPassword)
-	config.LocalAuthToken = uuid.New().String()
 	config.LocalAuthMode = "password"
 	if err := SaveConfig(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
 		return
 	}
 
-	// Set the cookie
-	c.SetCookie("authToken", config.LocalAuthToken, 7*24*60*60, "/", "", false, true)
+	if err := startLocalSession(c, username, users.RoleAdmin, users.SourceLocal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Password set successfully"})
 }
 
+// passwordUsername returns the user whose password a request changes, the
+// user of the session, otherwise the user named in the request.
+// passwordUsername returns the account whose password the request changes,
+// the session's own account unless an admin names another one.
+func passwordUsername(c *gin.Context, username string) (string, error) {
+	session := requestAuthSession(c)
+	if session == nil {
+		return loginUsername(username), nil
+	}
+	if username == "" && session.Source == users.SourceLocal {
+		return session.Username, nil
+	}
+
+	target := loginUsername(username)
+	if session.Source == users.SourceLocal && target == session.Username {
+		return target, nil
+	}
+	if session.Role != users.RoleAdmin {
+		return "", errors.New("only admins can change the password of other users")
+	}
+	return target, nil
+}
+
+// verifyPasswordCredentials checks the password and TOTP code of the account
+// before its password is changed, it responds on failure.
+func verifyPasswordCredentials(c *gin.Context, username, password, code string) bool {
+	err := getUserStore().VerifyCredentials(username, password, code)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, users.ErrTOTPRequired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor code required", "totpRequired": true})
+	case errors.Is(err, users.ErrInvalidTOTP):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code", "totpRequired": true})
+	case errors.Is(err, users.ErrLocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+	default:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect password"})
+	}
+	return false
+}
+
 func handleUpdatePassword(c *gin.Context) {
-	if config.HashedPassword == "" {
+	if getUserStore().Len() == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is not set"})
 		return
 	}
@@ -664,32 +768,31 @@ func handleUpdatePassword(c *gin.Context) {
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(config.HashedPassword), []byte(req.OldPassword)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect old password"})
+	username, err := passwordUsername(c, req.Username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 		return
 	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash new password"})
+	if !verifyPasswordCredentials(c, username, req.OldPassword, req.TOTPCode) {
 		return
 	}
 
-	config.HashedPassword = string(hashedPassword)
-	config.LocalAuthToken = uuid.New().String()
-	if err := SaveConfig(); err != nil {
+	// ends all sessions of the user, including this one
+	if err := getUserStore().SetPassword(username, req.NewPassword); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
 		return
 	}
 
-	// Set the cookie
-	c.SetCookie("authToken", config.LocalAuthToken, 7*24*60*60, "/", "", false, true)
+	if err := startLocalSession(c, username, "", users.SourceLocal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 }
 
 func handleDeletePassword(c *gin.Context) {
-	if config.HashedPassword == "" {
+	if getUserStore().Len() == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is not set"})
 		return
 	}
@@ -705,16 +808,23 @@ func handleDeletePassword(c *gin.Context) {
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(config.HashedPassword), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Incorrect password"})
+	username, err := passwordUsername(c, req.Username)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := getUserStore().User(username)
+	if err != nil || user.Role != users.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can disable the password"})
+		return
+	}
+	if !verifyPasswordCredentials(c, username, req.Password, req.TOTPCode) {
 		return
 	}
 
-	// Disable password
-	config.HashedPassword = ""
-	config.LocalAuthToken = ""
+	// Disable password, this deletes all users
 	config.LocalAuthMode = "noPassword"
-	if err := SaveConfig(); err != nil {
+	if err := getUserStore().Reset(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save configuration"})
 		return
 	}
@@ -726,7 +836,8 @@ func handleDeletePassword(c *gin.Context) {
 
 func handleDeviceStatus(c *gin.Context) {
 	response := DeviceStatus{
-		IsSetup: config.LocalAuthMode != "",
+		IsSetup:          config.LocalAuthMode != "",
+		OIDCLoginEnabled: oidcLoginEnabled(),
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -744,7 +855,7 @@ func handleCloudState(c *gin.Context) {
 
 func handleSetup(c *gin.Context) {
 	// Check if the device is already set up
-	if config.LocalAuthMode != "" || config.HashedPassword != "" {
+	if config.LocalAuthMode != "" || getUserStore().Len() > 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Device is already set up"})
 		return
 	}
@@ -761,32 +872,25 @@ func handleSetup(c *gin.Context) {
 		return
 	}
 
-	config.LocalAuthMode = req.LocalAuthMode
-
 	if req.LocalAuthMode == "password" {
 		if req.Password == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Password is required for password mode"})
 			return
 		}
 
-		// Hash the password
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		username := loginUsername(req.Username)
+		if err := getUserStore().AddUser(username, req.Password, users.RoleAdmin); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		config.HashedPassword = string(hashedPassword)
-		config.LocalAuthToken = uuid.New().String()
-
-		// Set the cookie
-		c.SetCookie("authToken", config.LocalAuthToken, 7*24*60*60, "/", "", false, true)
-	} else {
-		// For noPassword mode, ensure the password field is empty
-		config.HashedPassword = ""
-		config.LocalAuthToken = ""
+		if err := startLocalSession(c, username, users.RoleAdmin, users.SourceLocal); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
+	config.LocalAuthMode = req.LocalAuthMode
 	err := SaveConfig()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
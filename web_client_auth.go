@@ -0,0 +1,99 @@
+package kvm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/jetkvm/kvm/internal/websecure"
+)
+
+// ClientCertAuthConfig lets the websecure server authenticate clients by
+// certificates signed by CABundle. Without Required clients may still log in
+// with the password.
+type ClientCertAuthConfig struct {
+	Enabled  bool                       `json:"enabled"`
+	Required bool                       `json:"required"`
+	CABundle string                     `json:"ca_bundle"`
+	Rules    []websecure.ClientCertRule `json:"rules"`
+}
+
+var (
+	clientAuthenticator     *websecure.ClientAuthenticator
+	clientAuthenticatorLock sync.Mutex
+)
+
+func (c *ClientCertAuthConfig) authenticator() (*websecure.ClientAuthenticator, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	return websecure.NewClientAuthenticator(c.CABundle, c.Rules, c.Required, websecureLogger)
+}
+
+// loadClientAuthenticator applies config.ClientCertAuth to new TLS connections.
+func loadClientAuthenticator() error {
+	a, err := config.ClientCertAuth.authenticator()
+	if err != nil {
+		return err
+	}
+
+	clientAuthenticatorLock.Lock()
+	clientAuthenticator = a
+	clientAuthenticatorLock.Unlock()
+	return nil
+}
+
+func getClientAuthenticator() *websecure.ClientAuthenticator {
+	clientAuthenticatorLock.Lock()
+	defer clientAuthenticatorLock.Unlock()
+	return clientAuthenticator
+}
+
+// getConfigForClient asks for client certificates if they're enabled.
+func getConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	a := getClientAuthenticator()
+	if a == nil {
+		return nil, nil
+	}
+	remote := ""
+	if hello.Conn != nil {
+		remote = hello.Conn.RemoteAddr().String()
+	}
+	return a.TLSConfig(webSecureTLSConfig(), remote), nil
+}
+
+// requestClientCert returns the allowed client certificate of the request,
+// nil if there's none.
+func requestClientCert(r *http.Request) *x509.Certificate {
+	a := getClientAuthenticator()
+	if a == nil {
+		return nil
+	}
+	return a.Verify(r.TLS)
+}
+
+func rpcGetClientCertAuth() (ClientCertAuthConfig, error) {
+	return *config.ClientCertAuth, nil
+}
+
+func rpcSetClientCertAuth(clientCertAuth ClientCertAuthConfig) error {
+	if clientCertAuth.Enabled && config.TLSMode == "" {
+		return fmt.Errorf("client certificates require TLS to be enabled")
+	}
+	if _, err := clientCertAuth.authenticator(); err != nil {
+		return fmt.Errorf("invalid client certificate settings: %w", err)
+	}
+
+	config.ClientCertAuth = &clientCertAuth
+	if err := SaveConfig(); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	websecureLogger.Info().
+		Bool("enabled", clientCertAuth.Enabled).
+		Bool("required", clientCertAuth.Required).
+		Int("rules", len(clientCertAuth.Rules)).
+		Msg("client certificate authentication updated")
+	return loadClientAuthenticator()
+}
@@ -3,12 +3,17 @@ package kvm
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/jetkvm/kvm/internal/websecure"
 )
 
@@ -20,17 +25,177 @@ const (
 	webSecureSelfSignedOrganization  = "JetKVM"
 	webSecureSelfSignedOU            = "JetKVM Self-Signed"
 	webSecureCustomCertificateName   = "user-defined"
+	webSecureACMECertificateName     = "acme"
 )
 
 var (
 	certStore  *websecure.CertStore
 	certSigner *websecure.SelfSigner
+
+	acmeIssuer     *websecure.ACMEIssuer
+	acmeCancel     context.CancelFunc
+	acmeIssuerLock sync.Mutex
 )
 
 type TLSState struct {
-	Mode        string `json:"mode"`
-	Certificate string `json:"certificate"`
-	PrivateKey  string `json:"privateKey"`
+	Mode        string      `json:"mode"`
+	Certificate string      `json:"certificate"`
+	PrivateKey  string      `json:"privateKey"`
+	ACME        *ACMEConfig `json:"acme,omitempty"`
+	// ACMEState is only set in acme mode
+	ACMEState *websecure.ACMEState `json:"acmeState,omitempty"`
+	// DNSWebhookTokenSet reports a stored webhook token, the token itself is write-only
+	DNSWebhookTokenSet bool `json:"dnsWebhookTokenSet,omitempty"`
+}
+
+// ACMEConfig configures the acme TLS mode. CABundle holds the roots of a
+// private CA serving the directory, DNS-01 records are published through a
+// webhook, see websecure.DNSWebhook.
+type ACMEConfig struct {
+	DirectoryURL          string   `json:"directory_url"`
+	Email                 string   `json:"email"`
+	Domains               []string `json:"domains"`
+	Challenge             string   `json:"challenge"` // http-01 or dns-01
+	CABundle              string   `json:"ca_bundle,omitempty"`
+	DNSProvider           string   `json:"dns_provider,omitempty"` // webhook
+	DNSWebhookURL         string   `json:"dns_webhook_url,omitempty"`
+	DNSWebhookToken       string   `json:"dns_webhook_token,omitempty"`
+	DNSPropagationSeconds int      `json:"dns_propagation_seconds,omitempty"`
+	RenewBeforeDays       int      `json:"renew_before_days,omitempty"`
+}
+
+func (c *ACMEConfig) Validate() error {
+	u, err := url.Parse(c.DirectoryURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid ACME directory url: %s", c.DirectoryURL)
+	}
+	if len(c.Domains) == 0 {
+		return fmt.Errorf("at least one domain is required")
+	}
+	for _, d := range c.Domains {
+		if d == "" || strings.ContainsAny(d, " /:") {
+			return fmt.Errorf("invalid domain: %q", d)
+		}
+		if strings.HasPrefix(d, "*.") && c.Challenge != websecure.ACMEChallengeDNS01 {
+			return fmt.Errorf("wildcard domains require the dns-01 challenge")
+		}
+	}
+	if c.CABundle != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(c.CABundle)) {
+		return fmt.Errorf("invalid CA bundle")
+	}
+
+	switch c.Challenge {
+	case "", websecure.ACMEChallengeHTTP01:
+	case websecure.ACMEChallengeDNS01:
+		if c.DNSProvider != "webhook" {
+			return fmt.Errorf("unsupported DNS provider: %s", c.DNSProvider)
+		}
+		u, err := url.Parse(c.DNSWebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid DNS webhook url: %s", c.DNSWebhookURL)
+		}
+	default:
+		return fmt.Errorf("unsupported ACME challenge: %s", c.Challenge)
+	}
+	if c.DNSPropagationSeconds < 0 || c.RenewBeforeDays < 0 {
+		return fmt.Errorf("durations can't be negative")
+	}
+	return nil
+}
+
+func (c *ACMEConfig) issuerOptions() (websecure.ACMEOptions, error) {
+	opts := websecure.ACMEOptions{
+		DirectoryURL:    c.DirectoryURL,
+		Email:           c.Email,
+		Domains:         c.Domains,
+		Challenge:       c.Challenge,
+		DNSPropagation:  time.Duration(c.DNSPropagationSeconds) * time.Second,
+		RenewBefore:     time.Duration(c.RenewBeforeDays) * 24 * time.Hour,
+		CertificateName: webSecureACMECertificateName,
+		Logger:          websecureLogger,
+	}
+	if c.CABundle != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(c.CABundle)) {
+			return opts, fmt.Errorf("invalid CA bundle")
+		}
+		opts.HTTPClient = &http.Client{
+			Timeout:   time.Minute,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		}
+	}
+	if c.Challenge == websecure.ACMEChallengeDNS01 {
+		opts.DNSProvider = websecure.NewDNSWebhook(c.DNSWebhookURL, c.DNSWebhookToken)
+	}
+	return opts, nil
+}
+
+// startACMEIssuer (re)starts obtaining and renewing the ACME certificate.
+func startACMEIssuer() error {
+	stopACMEIssuer()
+
+	if config.ACME == nil {
+		return fmt.Errorf("ACME is not configured")
+	}
+	opts, err := config.ACME.issuerOptions()
+	if err != nil {
+		return err
+	}
+	issuer, err := websecure.NewACMEIssuer(certStore, opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	acmeIssuerLock.Lock()
+	acmeIssuer = issuer
+	acmeCancel = cancel
+	acmeIssuerLock.Unlock()
+
+	go func() {
+		// certificate lifetimes can't be checked without a synced clock
+		for isTimeSyncNeeded() || !timeSync.IsSyncSuccess() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Second):
+			}
+		}
+		issuer.Run(ctx)
+	}()
+	return nil
+}
+
+func stopACMEIssuer() {
+	acmeIssuerLock.Lock()
+	defer acmeIssuerLock.Unlock()
+
+	if acmeCancel != nil {
+		acmeCancel()
+	}
+	acmeIssuer = nil
+	acmeCancel = nil
+}
+
+func getACMEIssuer() *websecure.ACMEIssuer {
+	acmeIssuerLock.Lock()
+	defer acmeIssuerLock.Unlock()
+	return acmeIssuer
+}
+
+// handleACMEChallenge answers HTTP-01 challenges, it has to be reachable on port 80.
+func handleACMEChallenge(c *gin.Context) {
+	issuer := getACMEIssuer()
+	if issuer == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	resp, ok := issuer.HTTPChallengeResponse(c.Param("token"))
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.String(http.StatusOK, resp)
 }
 
 func initCertStore() {
@@ -60,6 +225,15 @@ func getCertificate(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
 		return certSigner.GetCertificate(info)
 	case "custom":
 		return certStore.GetCertificate(webSecureCustomCertificateName), nil
+	case "acme":
+		if cert := certStore.GetCertificate(webSecureACMECertificateName); cert != nil {
+			return cert, nil
+		}
+		// serve a self-signed certificate until the first one is issued
+		if isTimeSyncNeeded() || !timeSync.IsSyncSuccess() {
+			return nil, fmt.Errorf("time is not synced")
+		}
+		return certSigner.GetCertificate(info)
 	}
 
 	websecureLogger.Info().Msg("TLS mode is disabled but WebSecure is running, returning nil")
@@ -89,6 +263,18 @@ func getTLSState() TLSState {
 		}
 	case "self-signed":
 		s.Mode = "self-signed"
+	case "acme":
+		s.Mode = "acme"
+		if config.ACME != nil {
+			acme := *config.ACME
+			s.DNSWebhookTokenSet = acme.DNSWebhookToken != ""
+			acme.DNSWebhookToken = ""
+			s.ACME = &acme
+		}
+		if issuer := getACMEIssuer(); issuer != nil {
+			state := issuer.State()
+			s.ACMEState = &state
+		}
 	}
 
 	return s
@@ -99,6 +285,9 @@ func setTLSState(s TLSState) error {
 
 	switch s.Mode {
 	case "disabled":
+		if config.ClientCertAuth.Enabled {
+			return fmt.Errorf("client certificates require TLS, disable them first")
+		}
 		if config.TLSMode != "" {
 			isChanged = true
 		}
@@ -122,9 +311,36 @@ func setTLSState(s TLSState) error {
 			isChanged = true
 		}
 		config.TLSMode = "self-signed"
+	case "acme":
+		if s.ACME == nil {
+			return fmt.Errorf("ACME configuration is required")
+		}
+		if err := s.ACME.Validate(); err != nil {
+			return err
+		}
+		// an empty token keeps the stored one, getTLSState never returns it
+		if s.ACME.DNSWebhookToken == "" && config.ACME != nil {
+			acme := *s.ACME
+			acme.DNSWebhookToken = config.ACME.DNSWebhookToken
+			s.ACME = &acme
+		}
+		if config.TLSMode == "" {
+			isChanged = true
+		}
+		if certStore == nil {
+			initCertStore()
+		}
+		config.ACME = s.ACME
+		config.TLSMode = "acme"
+		if err := startACMEIssuer(); err != nil {
+			return fmt.Errorf("failed to start ACME issuer: %w", err)
+		}
 	default:
 		return fmt.Errorf("invalid TLS mode: %s", s.Mode)
 	}
+	if config.TLSMode != "acme" {
+		stopACMEIssuer()
+	}
 
 	if !isChanged {
 		websecureLogger.Info().Msg("TLS enabled state is not changed, not starting/stopping websecure server")
@@ -149,6 +365,14 @@ var (
 	tlsStarted     = false
 )
 
+func webSecureTLSConfig() *tls.Config {
+	return &tls.Config{
+		MaxVersion:       tls.VersionTLS13,
+		CurvePreferences: []tls.CurveID{},
+		GetCertificate:   getCertificate,
+	}
+}
+
 // RunWebSecureServer runs a web server with TLS.
 func runWebSecureServer() {
 	tlsServiceLock.Lock()
@@ -161,14 +385,12 @@ func runWebSecureServer() {
 
 	r := setupRouter()
 
+	tlsConfig := webSecureTLSConfig()
+	tlsConfig.GetConfigForClient = getConfigForClient
 	server := &http.Server{
-		Addr:    webSecureListen,
-		Handler: r,
-		TLSConfig: &tls.Config{
-			MaxVersion:       tls.VersionTLS13,
-			CurvePreferences: []tls.CurveID{},
-			GetCertificate:   getCertificate,
-		},
+		Addr:      webSecureListen,
+		Handler:   r,
+		TLSConfig: tlsConfig,
 	}
 	websecureLogger.Info().Str("listen", webSecureListen).Msg("Starting websecure server")
 
@@ -210,6 +432,14 @@ func RunWebSecureServer() {
 		if certStore == nil {
 			initCertStore()
 		}
+		if err := loadClientAuthenticator(); err != nil {
+			websecureLogger.Error().Err(err).Msg("failed to load client certificate settings")
+		}
+		if config.TLSMode == "acme" && getACMEIssuer() == nil {
+			if err := startACMEIssuer(); err != nil {
+				websecureLogger.Error().Err(err).Msg("failed to start ACME issuer")
+			}
+		}
 		go runWebSecureServer()
 	}
 }
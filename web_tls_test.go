@@ -0,0 +1,33 @@
+package kvm
+
+import (
+	"testing"
+
+	"github.com/jetkvm/kvm/internal/websecure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSStateDNSWebhookToken(t *testing.T) {
+	savedConfig := config
+	t.Cleanup(func() {
+		config = savedConfig
+	})
+	config = &Config{
+		TLSMode: "acme",
+		ACME: &ACMEConfig{
+			DirectoryURL:    "https://acme.example.com/directory",
+			Domains:         []string{"kvm.example.com"},
+			Challenge:       websecure.ACMEChallengeDNS01,
+			DNSProvider:     "webhook",
+			DNSWebhookURL:   "https://dns.example.com/hook",
+			DNSWebhookToken: "secret",
+		},
+	}
+
+	s := getTLSState()
+	require.NotNil(t, s.ACME)
+	assert.Empty(t, s.ACME.DNSWebhookToken, "the token is never returned")
+	assert.True(t, s.DNSWebhookTokenSet)
+	assert.Equal(t, "secret", config.ACME.DNSWebhookToken, "the stored token is untouched")
+}
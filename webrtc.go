@@ -15,6 +15,7 @@ import (
 	"github.com/jetkvm/kvm/internal/hidrpc"
 	"github.com/jetkvm/kvm/internal/logging"
 	"github.com/jetkvm/kvm/internal/usbgadget"
+	"github.com/jetkvm/kvm/internal/users"
 	"github.com/pion/webrtc/v4"
 	"github.com/rs/zerolog"
 )
@@ -22,6 +23,8 @@ import (
 type Session struct {
 	peerConnection           *webrtc.PeerConnection
 	VideoTrack               *webrtc.TrackLocalStaticSample
+	AudioTrack               *webrtc.TrackLocalStaticSample
+	UsbAudioTrack            *webrtc.TrackLocalStaticSample
 	ControlChannel           *webrtc.DataChannel
 	RPCChannel               *webrtc.DataChannel
 	HidChannel               *webrtc.DataChannel
@@ -37,6 +40,10 @@ type Session struct {
 	hidQueue                 []chan hidQueueMessage
 
 	keysDownStateQueue chan usbgadget.KeysDownState
+
+	// User is the user that started the session, nil for cloud and
+	// noPassword sessions.
+	User *users.Session
 }
 
 func (s *Session) resetKeepAliveTime() {
@@ -57,6 +64,20 @@ type SessionConfig struct {
 	IsCloud    bool
 	ws         *websocket.Conn
 	Logger     *zerolog.Logger
+	// User is the logged in user, nil if the session isn't tied to one.
+	User *users.Session
+}
+
+// iceUser names the session in ephemeral TURN credentials.
+func (c *SessionConfig) iceUser() string {
+	switch {
+	case c.User != nil:
+		return c.User.Username
+	case c.IsCloud:
+		return "cloud"
+	default:
+		return "local"
+	}
 }
 
 func (s *Session) ExchangeOffer(offerStr string) (string, error) {
@@ -183,7 +204,7 @@ func newSession(config SessionConfig) (*Session, error) {
 	webrtcSettingEngine := webrtc.SettingEngine{
 		LoggerFactory: logging.GetPionDefaultLoggerFactory(),
 	}
-	iceServer := webrtc.ICEServer{}
+	var cloudICEServers []string
 
 	var scopedLogger *zerolog.Logger
 	if config.Logger != nil {
@@ -197,8 +218,8 @@ func newSession(config SessionConfig) (*Session, error) {
 		if config.ICEServers == nil {
 			scopedLogger.Info().Msg("ICE Servers not provided by cloud")
 		} else {
-			iceServer.URLs = config.ICEServers
-			scopedLogger.Info().Interface("iceServers", iceServer.URLs).Msg("Using ICE Servers provided by cloud")
+			cloudICEServers = config.ICEServers
+			scopedLogger.Info().Interface("iceServers", cloudICEServers).Msg("Using ICE Servers provided by cloud")
 		}
 
 		if config.LocalIP == "" || net.ParseIP(config.LocalIP) == nil {
@@ -209,16 +230,20 @@ func newSession(config SessionConfig) (*Session, error) {
 		}
 	}
 
+	if filter := wireGuardICEInterfaceFilter(); filter != nil {
+		webrtcSettingEngine.SetInterfaceFilter(filter)
+	}
+
 	api := webrtc.NewAPI(webrtc.WithSettingEngine(webrtcSettingEngine))
 	peerConnection, err := api.NewPeerConnection(webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{iceServer},
+		ICEServers: sessionICEServers(cloudICEServers, config.iceUser()),
 	})
 	if err != nil {
 		scopedLogger.Warn().Err(err).Msg("Failed to create PeerConnection")
 		return nil, err
 	}
 
-	session := &Session{peerConnection: peerConnection}
+	session := &Session{peerConnection: peerConnection, User: config.User}
 	session.rpcQueue = make(chan webrtc.DataChannelMessage, 256)
 	session.initQueues()
 	session.initKeysDownStateQueue()
@@ -287,14 +312,46 @@ func newSession(config SessionConfig) (*Session, error) {
 	// Read incoming RTCP packets
 	// Before these packets are returned they are processed by interceptors. For things
 	// like NACK this needs to be called.
-	go func() {
-		rtcpBuf := make([]byte, 1500)
-		for {
-			if _, _, rtcpErr := rtpSender.Read(rtcpBuf); rtcpErr != nil {
-				return
-			}
+	go drainRTCP(rtpSender)
+
+	// The audio track is always added so audio can be enabled without renegotiating,
+	// samples are only written while the capture pipeline is running.
+	session.AudioTrack, err = webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", "kvm")
+	if err != nil {
+		scopedLogger.Warn().Err(err).Msg("Failed to create AudioTrack")
+		return nil, err
+	}
+
+	audioRtpSender, err := peerConnection.AddTrack(session.AudioTrack)
+	if err != nil {
+		scopedLogger.Warn().Err(err).Msg("Failed to add AudioTrack to PeerConnection")
+		return nil, err
+	}
+	go drainRTCP(audioRtpSender)
+
+	// Host speaker output captured from the USB audio gadget.
+	session.UsbAudioTrack, err = webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "usb-audio", "kvm")
+	if err != nil {
+		scopedLogger.Warn().Err(err).Msg("Failed to create UsbAudioTrack")
+		return nil, err
+	}
+
+	usbAudioRtpSender, err := peerConnection.AddTrack(session.UsbAudioTrack)
+	if err != nil {
+		scopedLogger.Warn().Err(err).Msg("Failed to add UsbAudioTrack to PeerConnection")
+		return nil, err
+	}
+	go drainRTCP(usbAudioRtpSender)
+
+	// Browser microphone, played into the host through the USB audio gadget.
+	peerConnection.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		if track.Kind() != webrtc.RTPCodecTypeAudio {
+			return
 		}
-	}()
+		scopedLogger.Info().Str("codec", track.Codec().MimeType).Msg("received remote audio track")
+		go handleMicrophoneTrack(session, track)
+	})
+
 	var isConnected bool
 
 	peerConnection.OnICECandidate(func(candidate *webrtc.ICECandidate) {
@@ -364,6 +421,15 @@ func newSession(config SessionConfig) (*Session, error) {
 	return session, nil
 }
 
+func drainRTCP(rtpSender *webrtc.RTPSender) {
+	rtcpBuf := make([]byte, 1500)
+	for {
+		if _, _, rtcpErr := rtpSender.Read(rtcpBuf); rtcpErr != nil {
+			return
+		}
+	}
+}
+
 var actionSessions = 0
 
 func onActiveSessionsChanged() {
@@ -372,8 +438,16 @@ func onActiveSessionsChanged() {
 
 func onFirstSessionConnected() {
 	_ = nativeInstance.VideoStart()
+	startAudioCapture()
+	startUsbAudio()
 }
 
 func onLastSessionDisconnected() {
-	_ = nativeInstance.VideoStop()
+	// a screenshot in progress stops the video when it's done
+	if !isScreenCaptureActive() {
+		_ = nativeInstance.VideoStop()
+		screenGrabber.Reset()
+	}
+	stopAudioCapture()
+	stopUsbAudio()
 }
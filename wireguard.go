@@ -0,0 +1,102 @@
+package kvm
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	wireGuardWebServer     *http.Server
+	wireGuardWebServerAddr string
+	wireGuardWebServerLock sync.Mutex
+)
+
+// wireGuardWebServerWanted tells if the web server should serve the tunnel.
+func wireGuardWebServerWanted() bool {
+	wg := config.NetworkConfig.WireGuard
+	return wg.IsEnabled() && wg.WebServer
+}
+
+// isWireGuardAddr tells if addr is an address of the WireGuard interface.
+func isWireGuardAddr(addr net.Addr) bool {
+	if networkState == nil || addr == nil {
+		return false
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return false
+	}
+	return slices.Contains(networkState.WireGuardAddresses(), ap.Addr().Unmap())
+}
+
+// wireGuardAccessMiddleware rejects requests that came in over the tunnel
+// while the web server isn't enabled for it.
+func wireGuardAccessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		localAddr, _ := c.Request.Context().Value(http.LocalAddrContextKey).(net.Addr)
+		if !wireGuardWebServerWanted() && isWireGuardAddr(localAddr) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// updateWireGuardWebServer serves the web interface on the tunnel address
+// when the main web server is bound to loopback only.
+func updateWireGuardWebServer() {
+	wireGuardWebServerLock.Lock()
+	defer wireGuardWebServerLock.Unlock()
+
+	addr := ""
+	if config.LocalLoopbackOnly && wireGuardWebServerWanted() && networkState != nil {
+		if addrs := networkState.WireGuardAddresses(); len(addrs) > 0 {
+			addr = netip.AddrPortFrom(addrs[0], 80).String()
+		}
+	}
+	if addr == wireGuardWebServerAddr {
+		return
+	}
+
+	if wireGuardWebServer != nil {
+		_ = wireGuardWebServer.Close()
+		wireGuardWebServer = nil
+	}
+	wireGuardWebServerAddr = addr
+	if addr == "" {
+		return
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           setupRouter(),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	wireGuardWebServer = server
+	go func() {
+		logger.Info().Str("bindAddress", addr).Msg("Starting web server on WireGuard interface")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Warn().Err(err).Str("bindAddress", addr).Msg("WireGuard web server stopped")
+		}
+	}()
+}
+
+// wireGuardICEInterfaceFilter keeps the tunnel out of the WebRTC host
+// candidates unless ICE is enabled for it, nil if there's nothing to filter.
+func wireGuardICEInterfaceFilter() func(string) bool {
+	wg := config.NetworkConfig.WireGuard
+	if !wg.IsEnabled() || wg.ICE {
+		return nil
+	}
+	name := wg.Interface()
+	return func(iface string) bool {
+		return iface != name
+	}
+}